@@ -15,10 +15,12 @@
 package accesstoken
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/omec-project/nrf/logger"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/omec-project/nrf/producer"
 	"github.com/omec-project/openapi"
 	"github.com/omec-project/openapi/models"
@@ -26,40 +28,74 @@ import (
 )
 
 // AccessTokenRequest - Access Token Request
-func HTTPAccessTokenRequest(c *gin.Context) {
-	logger.AccessTokenLog.Infoln("In HTTPAccessTokenRequest")
-	var accessTokenReq models.AccessTokenReq
+func HTTPAccessTokenRequest(p *producer.Producer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p.Log.AccessTokenLog.Infoln("In HTTPAccessTokenRequest")
+		var accessTokenReq models.AccessTokenReq
 
-	// logger.AccessTokenLog.Infoln("Content Type: ", c.ContentType())
-	err := c.Bind(&accessTokenReq)
-	if err != nil {
-		problemDetail := "[Request Body] " + err.Error()
-		rsp := models.ProblemDetails{
-			Title:  "Malformed request syntax",
-			Status: http.StatusBadRequest,
-			Detail: problemDetail,
+		err := bindAccessTokenReq(c, &accessTokenReq)
+		if err != nil {
+			problemDetail := "[Request Body] " + err.Error()
+			rsp := models.ProblemDetails{
+				Title:  "Malformed request syntax",
+				Status: http.StatusBadRequest,
+				Detail: problemDetail,
+			}
+			p.Log.AccessTokenLog.Warnln(problemDetail)
+			c.JSON(http.StatusBadRequest, rsp)
+			return
 		}
-		logger.AccessTokenLog.Warnln(problemDetail)
-		c.JSON(http.StatusBadRequest, rsp)
-		return
-	}
 
-	req := httpwrapper.NewRequest(c.Request, accessTokenReq)
-	req.Params["paramName"] = c.Params.ByName("paramName")
+		req := httpwrapper.NewRequest(c.Request, accessTokenReq)
+		req.Params["paramName"] = c.Params.ByName("paramName")
+
+		httpResponse := p.HandleAccessTokenRequest(req)
 
-	httpResponse := producer.HandleAccessTokenRequest(req)
+		responseBody, err := openapi.Serialize(httpResponse.Body, "application/json")
 
-	responseBody, err := openapi.Serialize(httpResponse.Body, "application/json")
+		if err != nil {
+			p.Log.AccessTokenLog.Warnln(err)
+			problemDetails := models.ProblemDetails{
+				Status: http.StatusInternalServerError,
+				Cause:  "SYSTEM_FAILURE",
+				Detail: err.Error(),
+			}
+			c.JSON(http.StatusInternalServerError, problemDetails)
+		} else {
+			c.Data(httpResponse.Status, "application/json", responseBody)
+		}
+	}
+}
 
-	if err != nil {
-		logger.AccessTokenLog.Warnln(err)
-		problemDetails := models.ProblemDetails{
-			Status: http.StatusInternalServerError,
-			Cause:  "SYSTEM_FAILURE",
-			Detail: err.Error(),
+// bindAccessTokenReq decodes the access token request. The form fields of
+// TS 29.510 are named after the JSON attributes, not after the model fields.
+func bindAccessTokenReq(c *gin.Context, accessTokenReq *models.AccessTokenReq) error {
+	if c.ContentType() != binding.MIMEPOSTForm {
+		return c.Bind(accessTokenReq)
+	}
+	if err := c.Request.ParseForm(); err != nil {
+		return err
+	}
+	form := c.Request.PostForm
+	accessTokenReq.GrantType = form.Get("grant_type")
+	accessTokenReq.NfInstanceId = form.Get("nfInstanceId")
+	accessTokenReq.NfType = models.NfType(form.Get("nfType"))
+	accessTokenReq.TargetNfType = models.NfType(form.Get("targetNfType"))
+	accessTokenReq.Scope = form.Get("scope")
+	accessTokenReq.TargetNfInstanceId = form.Get("targetNfInstanceId")
+	for name, plmn := range map[string]**models.PlmnId{
+		"requesterPlmn": &accessTokenReq.RequesterPlmn,
+		"targetPlmn":    &accessTokenReq.TargetPlmn,
+	} {
+		if value := form.Get(name); value != "" {
+			*plmn = &models.PlmnId{}
+			if err := json.Unmarshal([]byte(value), *plmn); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
 		}
-		c.JSON(http.StatusInternalServerError, problemDetails)
-	} else {
-		c.JSON(httpResponse.Status, responseBody)
 	}
+	if accessTokenReq.GrantType == "" || accessTokenReq.NfInstanceId == "" || accessTokenReq.Scope == "" {
+		return fmt.Errorf("grant_type, nfInstanceId and scope are mandatory")
+	}
+	return nil
 }
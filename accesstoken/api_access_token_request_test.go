@@ -15,18 +15,28 @@ import (
 
 	"github.com/antihax/optional"
 	"github.com/omec-project/nrf/accesstoken"
+	nrfContext "github.com/omec-project/nrf/context"
 	"github.com/omec-project/nrf/dbadapter"
 	"github.com/omec-project/nrf/factory"
 	"github.com/omec-project/nrf/logger"
+	"github.com/omec-project/nrf/producer"
 	"github.com/omec-project/openapi/Nnrf_AccessToken"
 	"github.com/omec-project/openapi/models"
 )
 
 func TestAccessTokenRequest(t *testing.T) {
+	// connect to mongoDB
+	db, err := dbadapter.NewMongoDBClient("aether", "mongodb://140.113.214.205:30030", nil, logger.Default())
+	if err != nil {
+		t.Fatalf("failed to create MongoDB client: %v", err)
+	}
+	config := &factory.Config{Configuration: &factory.Configuration{}}
+	p := producer.New(nrfContext.New(config, db, logger.Default()))
+
 	// run accesstoken Server Routine
 	go func() {
 		kl, _ := os.OpenFile("/home/sslkey.log", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
-		router := accesstoken.NewRouter()
+		router := accesstoken.NewRouter(p)
 
 		server := http.Server{
 			Addr: factory.NRF_DEFAULT_IPV4 + ":" + factory.NRF_DEFAULT_PORT,
@@ -40,9 +50,6 @@ func TestAccessTokenRequest(t *testing.T) {
 	}()
 	time.Sleep(time.Duration(2) * time.Second)
 
-	// connect to mongoDB
-	dbadapter.ConnectToDBClient("aether", "mongodb://140.113.214.205:30030", false, false)
-
 	// Set client and set url
 	configuration := Nnrf_AccessToken.NewConfiguration()
 	configuration.SetBasePath("https://127.0.0.1:29510")
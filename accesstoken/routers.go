@@ -18,7 +18,7 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/omec-project/nrf/logger"
+	"github.com/omec-project/nrf/producer"
 	utilLogger "github.com/omec-project/util/logger"
 )
 
@@ -38,16 +38,16 @@ type Route struct {
 type Routes []Route
 
 // NewRouter returns a new router.
-func NewRouter() *gin.Engine {
-	router := utilLogger.NewGinWithZap(logger.GinLog)
-	AddService(router)
+func NewRouter(p *producer.Producer) *gin.Engine {
+	router := utilLogger.NewGinWithZap(p.Log.GinLog)
+	AddService(router, p)
 	return router
 }
 
-func AddService(engine *gin.Engine) *gin.RouterGroup {
+func AddService(engine *gin.Engine, p *producer.Producer) *gin.RouterGroup {
 	group := engine.Group("")
 
-	for _, route := range routes {
+	for _, route := range getRoutes(p) {
 		switch route.Method {
 		case "GET":
 			group.GET(route.Pattern, route.HandlerFunc)
@@ -70,18 +70,20 @@ func Index(c *gin.Context) {
 	c.String(http.StatusOK, "Hello World!")
 }
 
-var routes = Routes{
-	{
-		"Index",
-		"GET",
-		"/",
-		Index,
-	},
+func getRoutes(p *producer.Producer) Routes {
+	return Routes{
+		{
+			"Index",
+			"GET",
+			"/",
+			Index,
+		},
 
-	{
-		"AccessTokenRequest",
-		strings.ToUpper("Post"),
-		"/oauth2/token",
-		HTTPAccessTokenRequest,
-	},
+		{
+			"AccessTokenRequest",
+			strings.ToUpper("Post"),
+			"/oauth2/token",
+			HTTPAccessTokenRequest(p),
+		},
+	}
 }
@@ -0,0 +1,40 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package bootstrapping
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omec-project/nrf/producer"
+	"github.com/omec-project/openapi"
+	"github.com/omec-project/openapi/models"
+	"github.com/omec-project/util/httpwrapper"
+)
+
+// contentType is the media type of the bootstrapping information, a HAL
+// document (TS 29.510 6.4.7)
+const contentType = "application/3gppHal+json"
+
+// Bootstrapping - Retrieve the status of NRF and the links to its services
+func HTTPBootstrapping(p *producer.Producer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req := httpwrapper.NewRequest(c.Request, nil)
+		httpResponse := p.HandleBootstrappingRequest(req)
+
+		responseBody, err := openapi.Serialize(httpResponse.Body, "application/json")
+		if err != nil {
+			p.Log.HandlerLog.Warnln(err)
+			problemDetails := models.ProblemDetails{
+				Status: http.StatusInternalServerError,
+				Cause:  "SYSTEM_FAILURE",
+				Detail: err.Error(),
+			}
+			c.JSON(http.StatusInternalServerError, problemDetails)
+		} else {
+			c.Data(httpResponse.Status, contentType, responseBody)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+ * NRF Bootstrapping
+ *
+ * NRF Bootstrapping, TS 29.510 6.4
+ *
+ * API version: 1.0.0
+ */
+
+package bootstrapping
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omec-project/nrf/factory"
+	"github.com/omec-project/nrf/producer"
+	utilLogger "github.com/omec-project/util/logger"
+)
+
+// Route is the information for every URI.
+type Route struct {
+	// Name is the name of this Route.
+	Name string
+	// Method is the string for the HTTP method. ex) GET, POST etc..
+	Method string
+	// Pattern is the pattern of the URI.
+	Pattern string
+	// HandlerFunc is the handler function of this route.
+	HandlerFunc gin.HandlerFunc
+}
+
+// Routes is the list of the generated Route.
+type Routes []Route
+
+// NewRouter returns a new router.
+func NewRouter(p *producer.Producer) *gin.Engine {
+	router := utilLogger.NewGinWithZap(p.Log.GinLog)
+	AddService(router, p)
+	return router
+}
+
+func AddService(engine *gin.Engine, p *producer.Producer) *gin.RouterGroup {
+	group := engine.Group("")
+
+	for _, route := range getRoutes(p) {
+		switch route.Method {
+		case "GET":
+			group.GET(route.Pattern, route.HandlerFunc)
+		}
+	}
+
+	return group
+}
+
+func getRoutes(p *producer.Producer) Routes {
+	return Routes{
+		{
+			"Bootstrapping",
+			strings.ToUpper("Get"),
+			factory.NRF_BOOTSTRAPPING_URI,
+			HTTPBootstrapping(p),
+		},
+	}
+}
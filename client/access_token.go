@@ -0,0 +1,72 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/omec-project/openapi/models"
+)
+
+// tokenExpiryMargin renews a token this long before it expires, so that it
+// does not expire while the request it authorises is in flight
+const tokenExpiryMargin = 5 * time.Second
+
+// TokenClient requests OAuth2 access tokens and caches them until they expire
+type TokenClient struct {
+	client *Client
+
+	mu     sync.Mutex
+	tokens map[string]cachedToken
+}
+
+type cachedToken struct {
+	token     models.AccessTokenRsp
+	expiresAt time.Time
+}
+
+// NewTokenClient creates an access token client
+func NewTokenClient(c *Client) *TokenClient {
+	return &TokenClient{
+		client: c,
+		tokens: make(map[string]cachedToken),
+	}
+}
+
+// Token returns an access token for request, requesting a new one only when
+// the cached token of an identical request expired
+func (t *TokenClient) Token(ctx context.Context, request models.AccessTokenReq) (models.AccessTokenRsp, error) {
+	rawKey, err := json.Marshal(request)
+	if err != nil {
+		return models.AccessTokenRsp{}, err
+	}
+	key := string(rawKey)
+	now := time.Now()
+
+	t.mu.Lock()
+	cached, ok := t.tokens[key]
+	if ok && !now.Before(cached.expiresAt) {
+		delete(t.tokens, key)
+		ok = false
+	}
+	t.mu.Unlock()
+	if ok {
+		return cached.token, nil
+	}
+
+	token, err := t.client.RequestAccessToken(ctx, request)
+	if err != nil {
+		return token, err
+	}
+	if lifetime := time.Duration(token.ExpiresIn)*time.Second - tokenExpiryMargin; lifetime > 0 {
+		t.mu.Lock()
+		t.tokens[key] = cachedToken{token: token, expiresAt: now.Add(lifetime)}
+		t.mu.Unlock()
+	}
+	return token, nil
+}
@@ -0,0 +1,297 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+ * Client package is a typed Go SDK for the consumers of the NRF services:
+ * NF management, NF discovery and access token.
+ */
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/omec-project/nrf/factory"
+	"github.com/omec-project/openapi/models"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultRequestTimeout bounds the requests of the default HTTP client
+	defaultRequestTimeout = 10 * time.Second
+	accessTokenPath       = "/oauth2/token"
+)
+
+// Client sends typed requests to an NRF
+type Client struct {
+	nrfUri         string
+	httpClient     *http.Client
+	log            *zap.SugaredLogger
+	requestHeaders func(ctx context.Context) (http.Header, error)
+}
+
+// Option customises a Client
+type Option func(*Client)
+
+// WithHTTPClient makes the client send its requests with httpClient, e.g. to
+// use TLS client certificates or HTTP/2 over cleartext
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithLogger makes the client, and the managers built on it, log to log
+func WithLogger(log *zap.Logger) Option {
+	return func(c *Client) {
+		c.log = log.Sugar()
+	}
+}
+
+// WithRequestHeaders adds the headers returned by headers to each request,
+// e.g. an Authorization header holding an access token
+func WithRequestHeaders(headers func(ctx context.Context) (http.Header, error)) Option {
+	return func(c *Client) {
+		c.requestHeaders = headers
+	}
+}
+
+// New creates a client of the NRF at nrfUri, e.g. http://nrf:29510
+func New(nrfUri string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(nrfUri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NRF URI %q: %w", nrfUri, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid NRF URI %q: scheme must be http or https", nrfUri)
+	}
+	c := &Client{
+		nrfUri:     strings.TrimSuffix(nrfUri, "/"),
+		httpClient: &http.Client{Timeout: defaultRequestTimeout},
+		log:        zap.NewNop().Sugar(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// ProblemError is returned when the NRF answers with an error status
+type ProblemError struct {
+	Status  int
+	Problem *models.ProblemDetails
+}
+
+func (e *ProblemError) Error() string {
+	if e.Problem != nil && (e.Problem.Cause != "" || e.Problem.Detail != "") {
+		return fmt.Sprintf("NRF returned %d %s: %s", e.Status, e.Problem.Cause, e.Problem.Detail)
+	}
+	return fmt.Sprintf("NRF returned %d %s", e.Status, http.StatusText(e.Status))
+}
+
+// IsNotFound reports whether err is a 404 answer of the NRF
+func IsNotFound(err error) bool {
+	return statusOf(err) == http.StatusNotFound
+}
+
+func statusOf(err error) int {
+	problemErr, ok := err.(*ProblemError)
+	if !ok {
+		return 0
+	}
+	return problemErr.Status
+}
+
+// RegisterNFInstance registers, or replaces, profile and returns the profile
+// accepted by the NRF
+func (c *Client) RegisterNFInstance(ctx context.Context, profile models.NfProfile) (models.NfProfile, error) {
+	var registered models.NfProfile
+	body, err := json.Marshal(profile)
+	if err != nil {
+		return registered, err
+	}
+	err = c.do(ctx, http.MethodPut, c.nfInstanceUri(profile.NfInstanceId), nil, "application/json", body, &registered)
+	return registered, err
+}
+
+// UpdateNFInstance applies the JSON Patch patchItems to the profile of
+// nfInstanceId. The updated profile is returned when the NRF sends it back.
+func (c *Client) UpdateNFInstance(ctx context.Context, nfInstanceId string, patchItems []models.PatchItem) (*models.NfProfile, error) {
+	body, err := json.Marshal(patchItems)
+	if err != nil {
+		return nil, err
+	}
+	var updated models.NfProfile
+	if err = c.do(ctx, http.MethodPatch, c.nfInstanceUri(nfInstanceId), nil, "application/json-patch+json", body, &updated); err != nil {
+		return nil, err
+	}
+	if updated.NfInstanceId == "" {
+		return nil, nil
+	}
+	return &updated, nil
+}
+
+// GetNFInstance returns the profile of nfInstanceId
+func (c *Client) GetNFInstance(ctx context.Context, nfInstanceId string) (models.NfProfile, error) {
+	var profile models.NfProfile
+	err := c.do(ctx, http.MethodGet, c.nfInstanceUri(nfInstanceId), nil, "", nil, &profile)
+	return profile, err
+}
+
+// DeregisterNFInstance removes the profile of nfInstanceId
+func (c *Client) DeregisterNFInstance(ctx context.Context, nfInstanceId string) error {
+	return c.do(ctx, http.MethodDelete, c.nfInstanceUri(nfInstanceId), nil, "", nil, nil)
+}
+
+// SearchNFInstances discovers the instances of targetNfType available to
+// requesterNfType. query holds the optional discovery parameters, such as
+// service-names or snssais.
+func (c *Client) SearchNFInstances(ctx context.Context, targetNfType, requesterNfType models.NfType,
+	query url.Values,
+) (models.SearchResult, error) {
+	var result models.SearchResult
+	err := c.do(ctx, http.MethodGet, c.nrfUri+factory.NRF_DISC_RES_URI_PREFIX+"/nf-instances",
+		searchQuery(targetNfType, requesterNfType, query), "", nil, &result)
+	return result, err
+}
+
+// CreateSubscription subscribes to the NF status events of subscription and
+// returns the subscription created by the NRF, holding its id
+func (c *Client) CreateSubscription(ctx context.Context, subscription models.NrfSubscriptionData) (models.NrfSubscriptionData, error) {
+	var created models.NrfSubscriptionData
+	body, err := json.Marshal(subscription)
+	if err != nil {
+		return created, err
+	}
+	err = c.do(ctx, http.MethodPost, c.nrfUri+factory.NRF_NFM_RES_URI_PREFIX+"/subscriptions", nil,
+		"application/json", body, &created)
+	return created, err
+}
+
+// RemoveSubscription removes the subscription subscriptionId
+func (c *Client) RemoveSubscription(ctx context.Context, subscriptionId string) error {
+	return c.do(ctx, http.MethodDelete, c.nrfUri+factory.NRF_NFM_RES_URI_PREFIX+"/subscriptions/"+url.PathEscape(subscriptionId),
+		nil, "", nil, nil)
+}
+
+// RequestAccessToken requests an OAuth2 access token for request
+func (c *Client) RequestAccessToken(ctx context.Context, request models.AccessTokenReq) (models.AccessTokenRsp, error) {
+	var response models.AccessTokenRsp
+	form := url.Values{
+		"grant_type":   {request.GrantType},
+		"nfInstanceId": {request.NfInstanceId},
+		"scope":        {request.Scope},
+	}
+	if form.Get("grant_type") == "" {
+		form.Set("grant_type", "client_credentials")
+	}
+	if request.NfType != "" {
+		form.Set("nfType", string(request.NfType))
+	}
+	if request.TargetNfType != "" {
+		form.Set("targetNfType", string(request.TargetNfType))
+	}
+	if request.TargetNfInstanceId != "" {
+		form.Set("targetNfInstanceId", request.TargetNfInstanceId)
+	}
+	for name, plmn := range map[string]*models.PlmnId{"requesterPlmn": request.RequesterPlmn, "targetPlmn": request.TargetPlmn} {
+		if plmn == nil {
+			continue
+		}
+		value, err := json.Marshal(plmn)
+		if err != nil {
+			return response, err
+		}
+		form.Set(name, string(value))
+	}
+	err := c.do(ctx, http.MethodPost, c.nrfUri+accessTokenPath, nil, "application/x-www-form-urlencoded",
+		[]byte(form.Encode()), &response)
+	return response, err
+}
+
+func (c *Client) nfInstanceUri(nfInstanceId string) string {
+	return c.nrfUri + factory.NRF_NFM_RES_URI_PREFIX + "/nf-instances/" + url.PathEscape(nfInstanceId)
+}
+
+func searchQuery(targetNfType, requesterNfType models.NfType, query url.Values) url.Values {
+	values := url.Values{}
+	for name, value := range query {
+		values[name] = append([]string(nil), value...)
+	}
+	values.Set("target-nf-type", string(targetNfType))
+	values.Set("requester-nf-type", string(requesterNfType))
+	return values
+}
+
+// do sends a request to the NRF and decodes the JSON answer into out, if any.
+// Error statuses are returned as a *ProblemError.
+func (c *Client) do(ctx context.Context, method, uri string, query url.Values, contentType string, body []byte, out interface{}) error {
+	if len(query) != 0 {
+		uri += "?" + query.Encode()
+	}
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, uri, bodyReader)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json, application/problem+json")
+	if c.requestHeaders != nil {
+		headers, err := c.requestHeaders(ctx)
+		if err != nil {
+			return err
+		}
+		for name, values := range headers {
+			req.Header[name] = values
+		}
+	}
+
+	rsp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := rsp.Body.Close(); closeErr != nil {
+			c.log.Warnf("failed to close response body: %v", closeErr)
+		}
+	}()
+	rspBody, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return err
+	}
+
+	if rsp.StatusCode >= http.StatusBadRequest {
+		problemErr := &ProblemError{Status: rsp.StatusCode}
+		problem := &models.ProblemDetails{}
+		if json.Unmarshal(rspBody, problem) == nil {
+			problemErr.Problem = problem
+		}
+		return problemErr
+	}
+	if out == nil || rsp.StatusCode == http.StatusNoContent || len(bytes.TrimSpace(rspBody)) == 0 {
+		return nil
+	}
+	if mediaType, _, _ := mime.ParseMediaType(rsp.Header.Get("Content-Type")); mediaType != "" &&
+		!strings.HasSuffix(mediaType, "json") {
+		return fmt.Errorf("unexpected response Content-Type %q", mediaType)
+	}
+	if err = json.Unmarshal(rspBody, out); err != nil {
+		return fmt.Errorf("failed to decode %s %s response: %w", method, uri, err)
+	}
+	return nil
+}
@@ -0,0 +1,375 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package client_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/omec-project/nrf/client"
+	"github.com/omec-project/nrf/factory"
+	"github.com/omec-project/nrf/service"
+	"github.com/omec-project/openapi/models"
+	"go.uber.org/zap"
+)
+
+// testNRF serves the handlers of an NRF using an in-memory storage and
+// counts the requests it receives per path
+type testNRF struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests map[string]int
+}
+
+func newTestNRF(t *testing.T) *testNRF {
+	t.Helper()
+	config, err := factory.ReadConfig("../nrfTest/nrfcfg.yaml")
+	if err != nil {
+		t.Fatalf("failed to read test configuration: %v", err)
+	}
+	nrf, err := service.New(config, service.WithStorage(NewMemoryDB()), service.WithLogger(zap.NewNop()))
+	if err != nil {
+		t.Fatalf("failed to create NRF: %v", err)
+	}
+	n := &testNRF{requests: make(map[string]int)}
+	n.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n.mu.Lock()
+		n.requests[r.URL.Path]++
+		n.mu.Unlock()
+		nrf.Handler().ServeHTTP(w, r)
+	}))
+	t.Cleanup(n.Close)
+	return n
+}
+
+func (n *testNRF) requestCount(path string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.requests[path]
+}
+
+func newTestClient(t *testing.T, uri string) *client.Client {
+	t.Helper()
+	c, err := client.New(uri)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return c
+}
+
+func testProfile(nfType models.NfType) models.NfProfile {
+	return models.NfProfile{
+		NfInstanceId:  uuid.New().String(),
+		NfType:        nfType,
+		NfStatus:      models.NfStatus_REGISTERED,
+		PlmnList:      &[]models.PlmnId{{Mcc: "208", Mnc: "93"}},
+		Ipv4Addresses: []string{"127.0.0.1"},
+	}
+}
+
+func TestNewInvalidUri(t *testing.T) {
+	for _, uri := range []string{"nrf:29510", "ftp://nrf:29510", "http://nrf:port"} {
+		if _, err := client.New(uri); err == nil {
+			t.Errorf("expected an error for %s", uri)
+		}
+	}
+}
+
+func TestRegistrationManager(t *testing.T) {
+	nrf := newTestNRF(t)
+	c := newTestClient(t, nrf.URL)
+	profile := testProfile(models.NfType_AMF)
+	m := client.NewRegistrationManager(c, profile)
+	ctx := context.Background()
+
+	if m.Profile() != nil {
+		t.Fatal("expected no profile before the registration")
+	}
+	if err := m.Register(ctx); err != nil {
+		t.Fatalf("registration failed: %v", err)
+	}
+	if registered := m.Profile(); registered == nil || registered.HeartBeatTimer <= 0 {
+		t.Fatalf("expected the registered profile with a heartbeat timer, got %+v", registered)
+	}
+	if err := m.Heartbeat(ctx); err != nil {
+		t.Fatalf("heartbeat failed: %v", err)
+	}
+
+	// the NRF forgets the profile: the next heartbeat registers it again
+	if err := c.DeregisterNFInstance(ctx, profile.NfInstanceId); err != nil {
+		t.Fatalf("deregistration failed: %v", err)
+	}
+	if _, err := c.GetNFInstance(ctx, profile.NfInstanceId); !client.IsNotFound(err) {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+	registrations := nrf.requestCount(factory.NRF_NFM_RES_URI_PREFIX + "/nf-instances/" + profile.NfInstanceId)
+	if err := m.Heartbeat(ctx); err != nil {
+		t.Fatalf("heartbeat failed: %v", err)
+	}
+	got, err := c.GetNFInstance(ctx, profile.NfInstanceId)
+	if err != nil {
+		t.Fatalf("expected the profile to be registered again: %v", err)
+	}
+	if got.NfType != models.NfType_AMF {
+		t.Errorf("expected an AMF profile, got %+v", got)
+	}
+	// PATCH answered 404, then PUT, then GET
+	if count := nrf.requestCount(factory.NRF_NFM_RES_URI_PREFIX+"/nf-instances/"+profile.NfInstanceId) - registrations; count != 3 {
+		t.Errorf("expected 3 requests, got %d", count)
+	}
+}
+
+func TestRegistrationManagerRun(t *testing.T) {
+	nrf := newTestNRF(t)
+	c := newTestClient(t, nrf.URL)
+	profile := testProfile(models.NfType_SMF)
+	m := client.NewRegistrationManager(c, profile, client.WithRetryInterval(10*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run(ctx)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for m.Profile() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("profile not registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := c.GetNFInstance(context.Background(), profile.NfInstanceId); err != nil {
+		t.Fatalf("expected the profile to be registered: %v", err)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if _, err := c.GetNFInstance(context.Background(), profile.NfInstanceId); !client.IsNotFound(err) {
+		t.Errorf("expected the profile to be deregistered, got %v", err)
+	}
+}
+
+func TestDiscoveryClientCache(t *testing.T) {
+	nrf := newTestNRF(t)
+	c := newTestClient(t, nrf.URL)
+	ctx := context.Background()
+	profile := testProfile(models.NfType_AUSF)
+	if _, err := c.RegisterNFInstance(ctx, profile); err != nil {
+		t.Fatalf("registration failed: %v", err)
+	}
+
+	d := client.NewDiscoveryClient(c)
+	searchPath := factory.NRF_DISC_RES_URI_PREFIX + "/nf-instances"
+	for i := 0; i < 2; i++ {
+		result, err := d.Search(ctx, models.NfType_AUSF, models.NfType_AMF, nil)
+		if err != nil {
+			t.Fatalf("search failed: %v", err)
+		}
+		if len(result.NfInstances) != 1 || result.NfInstances[0].NfInstanceId != profile.NfInstanceId {
+			t.Fatalf("expected the registered AUSF, got %+v", result.NfInstances)
+		}
+	}
+	if count := nrf.requestCount(searchPath); count != 1 {
+		t.Errorf("expected the second search to be served from the cache, got %d requests", count)
+	}
+
+	if _, err := d.Search(ctx, models.NfType_AUSF, models.NfType_SMF, nil); err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if count := nrf.requestCount(searchPath); count != 2 {
+		t.Errorf("expected a different query not to be served from the cache, got %d requests", count)
+	}
+
+	d.Invalidate()
+	if _, err := d.Search(ctx, models.NfType_AUSF, models.NfType_AMF, nil); err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if count := nrf.requestCount(searchPath); count != 3 {
+		t.Errorf("expected the search to reach the NRF after Invalidate, got %d requests", count)
+	}
+}
+
+func TestDiscoveryClientValidityPeriod(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"validityPeriod": 1, "nfInstances": []}`))
+	}))
+	defer server.Close()
+	d := client.NewDiscoveryClient(newTestClient(t, server.URL))
+
+	search := func() {
+		if _, err := d.Search(context.Background(), models.NfType_UDM, models.NfType_AUSF, nil); err != nil {
+			t.Fatalf("search failed: %v", err)
+		}
+	}
+	search()
+	search()
+	time.Sleep(1100 * time.Millisecond)
+	search()
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != 2 {
+		t.Errorf("expected 2 requests, got %d", requests)
+	}
+}
+
+func TestSubscriptionManager(t *testing.T) {
+	nrf := newTestNRF(t)
+	c := newTestClient(t, nrf.URL)
+	notifications := make(chan models.NotificationData, 10)
+	m := client.NewSubscriptionManager(c, func(notification models.NotificationData) {
+		notifications <- notification
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- m.Serve(ctx, listener)
+	}()
+	defer func() {
+		cancel()
+		if err := <-served; err != nil {
+			t.Errorf("receiver failed: %v", err)
+		}
+	}()
+
+	subscription, err := m.Subscribe(ctx, models.NrfSubscriptionData{
+		NfStatusNotificationUri: "http://" + listener.Addr().String() + "/nf-status-notify",
+		SubscrCond:              models.NfTypeCond{NfType: models.NfType_PCF},
+		ReqNfType:               models.NfType_AMF,
+	})
+	if err != nil {
+		t.Fatalf("subscription failed: %v", err)
+	}
+	if subscription.SubscriptionId == "" || len(m.Subscriptions()) != 1 {
+		t.Fatalf("expected the subscription to be recorded, got %+v", m.Subscriptions())
+	}
+
+	profile := testProfile(models.NfType_PCF)
+	if _, err = c.RegisterNFInstance(ctx, profile); err != nil {
+		t.Fatalf("registration failed: %v", err)
+	}
+	select {
+	case notification := <-notifications:
+		if notification.Event != models.NotificationEventType_REGISTERED ||
+			!strings.HasSuffix(notification.NfInstanceUri, "/nf-instances/"+profile.NfInstanceId) {
+			t.Errorf("unexpected notification %+v", notification)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("notification not received")
+	}
+
+	if err = m.Close(ctx); err != nil {
+		t.Fatalf("failed to remove the subscriptions: %v", err)
+	}
+	if len(m.Subscriptions()) != 0 {
+		t.Errorf("expected no subscription, got %+v", m.Subscriptions())
+	}
+}
+
+func TestSubscriptionManagerReceiver(t *testing.T) {
+	m := client.NewSubscriptionManager(newTestClient(t, "http://127.0.0.1"), nil)
+	testCases := []struct {
+		name           string
+		method         string
+		body           string
+		expectedStatus int
+	}{
+		{
+			name:           "notification",
+			method:         http.MethodPost,
+			body:           `{"event": "NF_DEREGISTERED", "nfInstanceUri": "http://nrf/nnrf-nfm/v1/nf-instances/1"}`,
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "missing event",
+			method:         http.MethodPost,
+			body:           `{"nfInstanceUri": "http://nrf/nnrf-nfm/v1/nf-instances/1"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "malformed body",
+			method:         http.MethodPost,
+			body:           `{`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "wrong method",
+			method:         http.MethodGet,
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/nf-status-notify", strings.NewReader(tc.body))
+			rec := httptest.NewRecorder()
+			m.Handler().ServeHTTP(rec, req)
+			if rec.Code != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d", tc.expectedStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestTokenClient(t *testing.T) {
+	nrf := newTestNRF(t)
+	tokens := client.NewTokenClient(newTestClient(t, nrf.URL))
+	ctx := context.Background()
+	request := models.AccessTokenReq{
+		NfInstanceId: "amf-1",
+		NfType:       models.NfType_AMF,
+		TargetNfType: models.NfType_AUSF,
+		Scope:        "nausf-auth",
+	}
+
+	first, err := tokens.Token(ctx, request)
+	if err != nil {
+		t.Fatalf("token request failed: %v", err)
+	}
+	second, err := tokens.Token(ctx, request)
+	if err != nil {
+		t.Fatalf("token request failed: %v", err)
+	}
+	if first.AccessToken == "" || first.AccessToken != second.AccessToken || first.ExpiresIn <= 0 {
+		t.Errorf("expected the cached token, got %+v and %+v", first, second)
+	}
+	if count := nrf.requestCount("/oauth2/token"); count != 1 {
+		t.Errorf("expected 1 token request, got %d", count)
+	}
+
+	claims := jwt.MapClaims{}
+	if _, _, err = jwt.NewParser().ParseUnverified(first.AccessToken, claims); err != nil {
+		t.Fatalf("invalid access token: %v", err)
+	}
+	if claims["sub"] != "amf-1" || claims["scope"] != "nausf-auth" {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+
+	request.Scope = "nausf-sorprotection"
+	if _, err = tokens.Token(ctx, request); err != nil {
+		t.Fatalf("token request failed: %v", err)
+	}
+	if count := nrf.requestCount("/oauth2/token"); count != 2 {
+		t.Errorf("expected a token request for another scope, got %d", count)
+	}
+}
@@ -0,0 +1,80 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/omec-project/openapi/models"
+)
+
+// DiscoveryClient discovers NF instances and caches the search results for the
+// validity period set by the NRF
+type DiscoveryClient struct {
+	client *Client
+
+	mu    sync.Mutex
+	cache map[string]cachedSearchResult
+}
+
+type cachedSearchResult struct {
+	result    models.SearchResult
+	expiresAt time.Time
+}
+
+// NewDiscoveryClient creates a discovery client
+func NewDiscoveryClient(c *Client) *DiscoveryClient {
+	return &DiscoveryClient{
+		client: c,
+		cache:  make(map[string]cachedSearchResult),
+	}
+}
+
+// Search returns the instances of targetNfType available to requesterNfType
+// and matching query. A result is served from the cache until its validity
+// period expires; it is shared with the cache and must not be modified.
+func (d *DiscoveryClient) Search(ctx context.Context, targetNfType, requesterNfType models.NfType,
+	query url.Values,
+) (models.SearchResult, error) {
+	// Encode sorts the parameters, equivalent queries share the cache entry
+	key := searchQuery(targetNfType, requesterNfType, query).Encode()
+	now := time.Now()
+
+	d.mu.Lock()
+	cached, ok := d.cache[key]
+	if ok && now.After(cached.expiresAt) {
+		delete(d.cache, key)
+		ok = false
+	}
+	d.mu.Unlock()
+	if ok {
+		return cached.result, nil
+	}
+
+	result, err := d.client.SearchNFInstances(ctx, targetNfType, requesterNfType, query)
+	if err != nil {
+		return result, err
+	}
+	if result.ValidityPeriod > 0 {
+		d.mu.Lock()
+		d.cache[key] = cachedSearchResult{
+			result:    result,
+			expiresAt: now.Add(time.Duration(result.ValidityPeriod) * time.Second),
+		}
+		d.mu.Unlock()
+	}
+	return result, nil
+}
+
+// Invalidate drops the cached search results, e.g. when a subscription
+// notifies a change of the registered NF instances
+func (d *DiscoveryClient) Invalidate() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.cache = make(map[string]cachedSearchResult)
+}
@@ -0,0 +1,363 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package client_test
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// MemoryDB is an in-memory storage understanding the subset of the MongoDB
+// query language used by the NRF, so that the client can be tested against the
+// NRF handlers without a database
+type MemoryDB struct {
+	mu          sync.Mutex
+	collections map[string][]map[string]interface{}
+}
+
+func NewMemoryDB() *MemoryDB {
+	return &MemoryDB{collections: make(map[string][]map[string]interface{})}
+}
+
+// normalize converts v to its JSON representation, as documents and filters
+// mix bson, model and plain Go types
+func normalize(v interface{}) interface{} {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	var out interface{}
+	if err = json.Unmarshal(raw, &out); err != nil {
+		panic(err)
+	}
+	return out
+}
+
+func normalizeDocument(v interface{}) map[string]interface{} {
+	document, _ := normalize(v).(map[string]interface{})
+	return document
+}
+
+func lookup(document map[string]interface{}, path string) (interface{}, bool) {
+	var value interface{} = document
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func matches(document map[string]interface{}, filter map[string]interface{}) bool {
+	for key, condition := range filter {
+		switch key {
+		case "$and", "$or":
+			clauses, _ := condition.([]interface{})
+			matched := 0
+			for _, clause := range clauses {
+				clauseFilter, _ := clause.(map[string]interface{})
+				if matches(document, clauseFilter) {
+					matched++
+				}
+			}
+			if key == "$and" && matched != len(clauses) || key == "$or" && matched == 0 {
+				return false
+			}
+		default:
+			value, exists := lookup(document, key)
+			if !matchesCondition(value, exists, condition) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func matchesCondition(value interface{}, exists bool, condition interface{}) bool {
+	operators, ok := condition.(map[string]interface{})
+	if !ok || len(operators) == 0 {
+		return exists && equals(value, condition)
+	}
+	for operator := range operators {
+		if !strings.HasPrefix(operator, "$") {
+			return exists && equals(value, condition)
+		}
+	}
+	for operator, argument := range operators {
+		switch operator {
+		case "$exists":
+			if exists != argument.(bool) {
+				return false
+			}
+		case "$ne":
+			if exists && equals(value, argument) {
+				return false
+			}
+		case "$in":
+			in := false
+			for _, candidate := range argument.([]interface{}) {
+				if exists && equals(value, candidate) {
+					in = true
+				}
+			}
+			if !in {
+				return false
+			}
+		case "$lt", "$lte", "$gt", "$gte":
+			order, ok := compare(value, argument)
+			if !exists || !ok {
+				return false
+			}
+			switch {
+			case operator == "$lt" && order >= 0, operator == "$lte" && order > 0,
+				operator == "$gt" && order <= 0, operator == "$gte" && order < 0:
+				return false
+			}
+		case "$elemMatch":
+			elements, _ := value.([]interface{})
+			elemFilter, _ := argument.(map[string]interface{})
+			matched := false
+			for _, element := range elements {
+				if document, ok := element.(map[string]interface{}); ok && matches(document, elemFilter) {
+					matched = true
+				}
+			}
+			if !matched {
+				return false
+			}
+		default:
+			panic("MemoryDB does not support " + operator)
+		}
+	}
+	return true
+}
+
+// compare orders two numbers or two dates, which are normalized to their
+// RFC 3339 representation
+func compare(value, argument interface{}) (int, bool) {
+	if number, ok := value.(float64); ok {
+		bound, ok := argument.(float64)
+		return cmp.Compare(number, bound), ok
+	}
+	date, dateErr := time.Parse(time.RFC3339Nano, fmt.Sprint(value))
+	bound, boundErr := time.Parse(time.RFC3339Nano, fmt.Sprint(argument))
+	if dateErr != nil || boundErr != nil {
+		return 0, false
+	}
+	return date.Compare(bound), true
+}
+
+// equals compares like MongoDB: an array field equals any of its elements
+func equals(value, expected interface{}) bool {
+	if reflect.DeepEqual(value, expected) {
+		return true
+	}
+	if elements, ok := value.([]interface{}); ok {
+		for _, element := range elements {
+			if reflect.DeepEqual(element, expected) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (db *MemoryDB) find(collName string, filter bson.M) []int {
+	normalizedFilter := normalizeDocument(filter)
+	var indexes []int
+	for i, document := range db.collections[collName] {
+		if matches(document, normalizedFilter) {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+func (db *MemoryDB) RestfulAPIGetOne(ctx context.Context, collName string, filter bson.M) (map[string]interface{}, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if indexes := db.find(collName, filter); len(indexes) != 0 {
+		return normalizeDocument(db.collections[collName][indexes[0]]), nil
+	}
+	return nil, nil
+}
+
+func (db *MemoryDB) RestfulAPIGetMany(ctx context.Context, collName string, filter bson.M) ([]map[string]interface{}, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var documents []map[string]interface{}
+	for _, i := range db.find(collName, filter) {
+		documents = append(documents, normalizeDocument(db.collections[collName][i]))
+	}
+	return documents, nil
+}
+
+func (db *MemoryDB) RestfulAPIPutOne(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.putOne(collName, filter, putData), nil
+}
+
+func (db *MemoryDB) putOne(collName string, filter bson.M, putData map[string]interface{}) bool {
+	data := normalizeDocument(putData)
+	if indexes := db.find(collName, filter); len(indexes) != 0 {
+		for key, value := range data {
+			db.collections[collName][indexes[0]][key] = value
+		}
+		return true
+	}
+	db.collections[collName] = append(db.collections[collName], data)
+	return false
+}
+
+func (db *MemoryDB) RestfulAPIReplaceOne(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if indexes := db.find(collName, filter); len(indexes) != 0 {
+		db.collections[collName][indexes[0]] = normalizeDocument(putData)
+		return true, nil
+	}
+	db.collections[collName] = append(db.collections[collName], normalizeDocument(putData))
+	return false, nil
+}
+
+func (db *MemoryDB) RestfulAPIPutOneIf(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}, upsert bool) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if len(db.find(collName, filter)) == 0 && !upsert {
+		return false, nil
+	}
+	db.putOne(collName, filter, putData)
+	return true, nil
+}
+
+func (db *MemoryDB) RestfulAPIPutOneNotUpdate(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if len(db.find(collName, filter)) != 0 {
+		return true, nil
+	}
+	db.collections[collName] = append(db.collections[collName], normalizeDocument(putData))
+	return false, nil
+}
+
+func (db *MemoryDB) RestfulAPIPutMany(ctx context.Context, collName string, filterArray []bson.M, putDataArray []map[string]interface{}) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for i, putData := range putDataArray {
+		db.putOne(collName, filterArray[i], putData)
+	}
+	return nil
+}
+
+func (db *MemoryDB) deleteMatching(collName string, filter bson.M, limit int) int {
+	indexes := db.find(collName, filter)
+	if limit > 0 && len(indexes) > limit {
+		indexes = indexes[:limit]
+	}
+	for i := len(indexes) - 1; i >= 0; i-- {
+		documents := db.collections[collName]
+		db.collections[collName] = append(documents[:indexes[i]], documents[indexes[i]+1:]...)
+	}
+	return len(indexes)
+}
+
+func (db *MemoryDB) RestfulAPIDeleteOne(ctx context.Context, collName string, filter bson.M) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.deleteMatching(collName, filter, 1)
+	return nil
+}
+
+func (db *MemoryDB) RestfulAPIDeleteOneIf(ctx context.Context, collName string, filter bson.M) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.deleteMatching(collName, filter, 1) > 0, nil
+}
+
+func (db *MemoryDB) RestfulAPIDeleteMany(ctx context.Context, collName string, filter bson.M) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.deleteMatching(collName, filter, 0)
+	return nil
+}
+
+// patch replaces the first document matching filter by its patched version
+func (db *MemoryDB) patch(collName string, filter bson.M, apply func([]byte) ([]byte, error)) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	indexes := db.find(collName, filter)
+	if len(indexes) == 0 {
+		return nil
+	}
+	original, err := json.Marshal(db.collections[collName][indexes[0]])
+	if err != nil {
+		return err
+	}
+	modified, err := apply(original)
+	if err != nil {
+		return err
+	}
+	var document map[string]interface{}
+	if err = json.Unmarshal(modified, &document); err != nil {
+		return err
+	}
+	db.collections[collName][indexes[0]] = document
+	return nil
+}
+
+func (db *MemoryDB) RestfulAPIMergePatch(ctx context.Context, collName string, filter bson.M, patchData map[string]interface{}) error {
+	patchJSON, err := json.Marshal(patchData)
+	if err != nil {
+		return err
+	}
+	return db.patch(collName, filter, func(original []byte) ([]byte, error) {
+		return jsonpatch.MergePatch(original, patchJSON)
+	})
+}
+
+func (db *MemoryDB) RestfulAPIJSONPatch(ctx context.Context, collName string, filter bson.M, patchJSON []byte) error {
+	patch, err := jsonpatch.DecodePatch(patchJSON)
+	if err != nil {
+		return err
+	}
+	return db.patch(collName, filter, patch.Apply)
+}
+
+func (db *MemoryDB) RestfulAPIJSONPatchExtend(ctx context.Context, collName string, filter bson.M, patchJSON []byte, dataName string) error {
+	patch, err := jsonpatch.DecodePatch(patchJSON)
+	if err != nil {
+		return err
+	}
+	return db.patch(collName, filter, func(original []byte) ([]byte, error) {
+		var document map[string]json.RawMessage
+		if err := json.Unmarshal(original, &document); err != nil {
+			return nil, err
+		}
+		modified, err := patch.Apply(document[dataName])
+		if err != nil {
+			return nil, err
+		}
+		document[dataName] = modified
+		return json.Marshal(document)
+	})
+}
+
+func (db *MemoryDB) RestfulAPIPost(ctx context.Context, collName string, filter bson.M, postData map[string]interface{}) (bool, error) {
+	return db.RestfulAPIPutOne(ctx, collName, filter, postData)
+}
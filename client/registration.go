@@ -0,0 +1,154 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/omec-project/openapi/models"
+)
+
+const (
+	// defaultRetryInterval is the time between two registration attempts
+	defaultRetryInterval = 10 * time.Second
+	// deregistrationTimeout bounds the deregistration sent when Run returns
+	deregistrationTimeout = 5 * time.Second
+)
+
+// RegistrationManager keeps an NF profile registered: it registers the
+// profile, sends the heartbeats requested by the NRF and registers the profile
+// again when the NRF no longer knows it, e.g. after the profile expired
+type RegistrationManager struct {
+	client        *Client
+	profile       models.NfProfile
+	retryInterval time.Duration
+
+	mu         sync.RWMutex
+	registered *models.NfProfile
+}
+
+// RegistrationOption customises a RegistrationManager
+type RegistrationOption func(*RegistrationManager)
+
+// WithRetryInterval sets the time between two registration attempts, and
+// between heartbeats when the NRF does not request a heartbeat timer
+func WithRetryInterval(interval time.Duration) RegistrationOption {
+	return func(m *RegistrationManager) {
+		m.retryInterval = interval
+	}
+}
+
+// NewRegistrationManager creates the registration manager of profile
+func NewRegistrationManager(c *Client, profile models.NfProfile, opts ...RegistrationOption) *RegistrationManager {
+	m := &RegistrationManager{
+		client:        c,
+		profile:       profile,
+		retryInterval: defaultRetryInterval,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Profile returns the profile accepted by the NRF, or nil before the
+// registration succeeded
+func (m *RegistrationManager) Profile() *models.NfProfile {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.registered == nil {
+		return nil
+	}
+	profile := *m.registered
+	return &profile
+}
+
+// Register registers the profile with the NRF
+func (m *RegistrationManager) Register(ctx context.Context) error {
+	registered, err := m.client.RegisterNFInstance(ctx, m.profile)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.registered = &registered
+	m.mu.Unlock()
+	m.client.log.Infof("NF instance %s registered, heartbeat timer %ds", m.profile.NfInstanceId, registered.HeartBeatTimer)
+	return nil
+}
+
+// Heartbeat tells the NRF that the NF is still alive. The profile is registered
+// again when the NRF answers that it does not know it.
+func (m *RegistrationManager) Heartbeat(ctx context.Context) error {
+	patchItems := []models.PatchItem{{
+		Op:    models.PatchOperation_REPLACE,
+		Path:  "/nfStatus",
+		Value: models.NfStatus_REGISTERED,
+	}}
+	_, err := m.client.UpdateNFInstance(ctx, m.profile.NfInstanceId, patchItems)
+	if IsNotFound(err) {
+		m.client.log.Warnf("NF instance %s unknown to the NRF, registering again", m.profile.NfInstanceId)
+		return m.Register(ctx)
+	}
+	return err
+}
+
+// Deregister removes the profile from the NRF
+func (m *RegistrationManager) Deregister(ctx context.Context) error {
+	if err := m.client.DeregisterNFInstance(ctx, m.profile.NfInstanceId); err != nil && !IsNotFound(err) {
+		return err
+	}
+	m.mu.Lock()
+	m.registered = nil
+	m.mu.Unlock()
+	return nil
+}
+
+// Run registers the profile, retrying until it succeeds, then sends the
+// heartbeats until ctx is done. The profile is deregistered before Run
+// returns.
+func (m *RegistrationManager) Run(ctx context.Context) error {
+	for {
+		err := m.Register(ctx)
+		if err == nil {
+			break
+		}
+		m.client.log.Warnf("NF instance %s registration failed: %v", m.profile.NfInstanceId, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(m.retryInterval):
+		}
+	}
+
+	timer := time.NewTimer(m.heartbeatInterval())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			deregisterCtx, cancel := context.WithTimeout(context.Background(), deregistrationTimeout)
+			defer cancel()
+			return m.Deregister(deregisterCtx)
+		case <-timer.C:
+		}
+		interval := m.heartbeatInterval()
+		if err := m.Heartbeat(ctx); err != nil {
+			m.client.log.Warnf("NF instance %s heartbeat failed: %v", m.profile.NfInstanceId, err)
+			interval = min(interval, m.retryInterval)
+		}
+		timer.Reset(interval)
+	}
+}
+
+// heartbeatInterval returns the heartbeat timer requested by the NRF
+func (m *RegistrationManager) heartbeatInterval() time.Duration {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.registered == nil || m.registered.HeartBeatTimer <= 0 {
+		return m.retryInterval
+	}
+	return time.Duration(m.registered.HeartBeatTimer) * time.Second
+}
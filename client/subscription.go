@@ -0,0 +1,168 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/omec-project/openapi/models"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// receiverShutdownTimeout bounds the time spent completing the notifications
+// in flight when the receiver stops
+const receiverShutdownTimeout = 5 * time.Second
+
+// NotificationHandler processes an NF status notification sent by the NRF
+type NotificationHandler func(notification models.NotificationData)
+
+// SubscriptionManager manages the subscriptions of an NF to NF status events
+// and receives the notifications sent for them
+type SubscriptionManager struct {
+	client  *Client
+	handler NotificationHandler
+
+	mu            sync.Mutex
+	subscriptions map[string]models.NrfSubscriptionData
+}
+
+// NewSubscriptionManager creates a subscription manager passing the received
+// notifications to handler
+func NewSubscriptionManager(c *Client, handler NotificationHandler) *SubscriptionManager {
+	return &SubscriptionManager{
+		client:        c,
+		handler:       handler,
+		subscriptions: make(map[string]models.NrfSubscriptionData),
+	}
+}
+
+// Subscribe creates subscription. Its NfStatusNotificationUri must reach the
+// receiver of the manager.
+func (m *SubscriptionManager) Subscribe(ctx context.Context, subscription models.NrfSubscriptionData) (models.NrfSubscriptionData, error) {
+	created, err := m.client.CreateSubscription(ctx, subscription)
+	if err != nil {
+		return created, err
+	}
+	m.mu.Lock()
+	m.subscriptions[created.SubscriptionId] = created
+	m.mu.Unlock()
+	return created, nil
+}
+
+// Unsubscribe removes the subscription subscriptionId
+func (m *SubscriptionManager) Unsubscribe(ctx context.Context, subscriptionId string) error {
+	if err := m.client.RemoveSubscription(ctx, subscriptionId); err != nil && !IsNotFound(err) {
+		return err
+	}
+	m.mu.Lock()
+	delete(m.subscriptions, subscriptionId)
+	m.mu.Unlock()
+	return nil
+}
+
+// Subscriptions returns the subscriptions created by the manager
+func (m *SubscriptionManager) Subscriptions() []models.NrfSubscriptionData {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	subscriptions := make([]models.NrfSubscriptionData, 0, len(m.subscriptions))
+	for _, subscription := range m.subscriptions {
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions
+}
+
+// Close removes all the subscriptions created by the manager
+func (m *SubscriptionManager) Close(ctx context.Context) error {
+	var errs []error
+	for _, subscription := range m.Subscriptions() {
+		errs = append(errs, m.Unsubscribe(ctx, subscription.SubscriptionId))
+	}
+	return errors.Join(errs...)
+}
+
+// Handler returns the notification receiver, to be mounted on the SBI server
+// of the NF at the path of the NfStatusNotificationUri
+func (m *SubscriptionManager) Handler() http.Handler {
+	return http.HandlerFunc(m.receive)
+}
+
+// Serve runs the notification receiver on listener until ctx is done. The NRF
+// sends notifications over HTTP/2, cleartext HTTP/2 is accepted.
+func (m *SubscriptionManager) Serve(ctx context.Context, listener net.Listener) error {
+	server := &http.Server{
+		Handler:           h2c.NewHandler(m.Handler(), &http2.Server{}),
+		ReadHeaderTimeout: defaultRequestTimeout,
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), receiverShutdownTimeout)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
+
+func (m *SubscriptionManager) receive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeProblem(w, &models.ProblemDetails{
+			Status: http.StatusMethodNotAllowed,
+			Cause:  "METHOD_NOT_ALLOWED",
+		})
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeProblem(w, &models.ProblemDetails{
+			Title:  "System failure",
+			Status: http.StatusInternalServerError,
+			Cause:  "SYSTEM_FAILURE",
+			Detail: err.Error(),
+		})
+		return
+	}
+	var notification models.NotificationData
+	if err = json.Unmarshal(body, &notification); err != nil || notification.Event == "" {
+		detail := "missing event"
+		if err != nil {
+			detail = err.Error()
+		}
+		m.client.log.Warnf("invalid NF status notification: %s", detail)
+		writeProblem(w, &models.ProblemDetails{
+			Title:  "Malformed request syntax",
+			Status: http.StatusBadRequest,
+			Cause:  "INVALID_MSG_FORMAT",
+			Detail: detail,
+		})
+		return
+	}
+	m.client.log.Debugf("NF status notification %s for %s", notification.Event, notification.NfInstanceUri)
+	if m.handler != nil {
+		m.handler(notification)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeProblem(w http.ResponseWriter, problem *models.ProblemDetails) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(int(problem.Status))
+	if err := json.NewEncoder(w).Encode(problem); err != nil {
+		return
+	}
+}
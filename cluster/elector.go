@@ -0,0 +1,255 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package cluster coordinates the NRF replicas sharing one storage: each
+// replica has an identity, and the replicas elect a leader, holding a lease
+// in the storage, to run the jobs which must run once in the cluster
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/omec-project/nrf/dbadapter"
+	"github.com/omec-project/nrf/logger"
+	"github.com/omec-project/nrf/metrics"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// LeaseCollName is the collection of the leases held by the replicas
+const LeaseCollName = "Leases"
+
+// NewReplicaId returns an identity for a replica: its host name, the pod name
+// on Kubernetes, followed by a random suffix telling apart the instances of a
+// host and the restarts of a pod
+func NewReplicaId() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "nrf"
+	}
+	return hostname + "-" + uuid.New().String()[:8]
+}
+
+// Elector elects a leader among the replicas sharing a storage. The leader
+// holds the lease of the election in the storage and renews it; when it
+// stops renewing it, another replica acquires the lease once it expired.
+type Elector struct {
+	db        dbadapter.DBInterface
+	name      string
+	replicaId string
+	duration  time.Duration
+	stats     *metrics.NrfStats
+	log       *logger.Logger
+
+	mu     sync.Mutex
+	leader bool
+	// reported is the leadership last signalled
+	reported bool
+	// validUntil is the time until which this replica considers itself the
+	// leader without renewing its lease. It is earlier than the expiry of the
+	// lease, to bear with the clock drift between the replicas.
+	validUntil time.Time
+	holder     string
+	// changed is closed, and replaced, when the leadership changes
+	changed chan struct{}
+}
+
+// NewElector creates the elector of replicaId in the election name, whose
+// lease lasts duration. It does not take part in the election until Run is
+// called.
+func NewElector(db dbadapter.DBInterface, name, replicaId string, duration time.Duration, stats *metrics.NrfStats,
+	log *logger.Logger,
+) *Elector {
+	return &Elector{
+		db:        db,
+		name:      name,
+		replicaId: replicaId,
+		duration:  duration,
+		stats:     stats,
+		log:       log,
+		changed:   make(chan struct{}),
+	}
+}
+
+// ReplicaId returns the identity of the replica
+func (e *Elector) ReplicaId() string {
+	return e.replicaId
+}
+
+// Run acquires or renews the lease three times per lease duration, until
+// stop is closed. The lease is then released, so that another replica takes
+// over at once.
+func (e *Elector) Run(stop <-chan struct{}) {
+	interval := e.duration / 3
+	for {
+		e.renew()
+		select {
+		case <-stop:
+			e.release()
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// renew acquires the lease when it is free or expired, or renews it when
+// this replica holds it. The lease is taken with one conditional update, so
+// that two replicas finding it expired cannot both take it, even without
+// transactions: the unique index on the name of the lease fails the insert
+// of the second one.
+func (e *Elector) renew() {
+	ctx, cancel := context.WithTimeout(context.Background(), e.duration/3)
+	defer cancel()
+	now := time.Now()
+	filter := bson.M{
+		"name": e.name,
+		"$or": []bson.M{
+			{"holder": e.replicaId},
+			{"expireAt": bson.M{"$lt": now}},
+		},
+	}
+	acquired, err := e.db.RestfulAPIPutOneIf(ctx, LeaseCollName, filter, bson.M{
+		"name":      e.name,
+		"holder":    e.replicaId,
+		"renewedAt": now,
+		"expireAt":  now.Add(e.duration),
+	}, true)
+	if err != nil {
+		// the leadership lapses on its own when the lease cannot be renewed
+		e.log.AppLog.Warnf("failed to renew the %s lease: %v", e.name, err)
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.setLeadership(e.leader, e.validUntil)
+		return
+	}
+	holder := e.replicaId
+	if !acquired {
+		// another replica holds the lease
+		lease, err := e.db.RestfulAPIGetOne(ctx, LeaseCollName, bson.M{"name": e.name})
+		if err != nil {
+			e.log.AppLog.Warnf("failed to read the %s lease: %v", e.name, err)
+		}
+		holder = ""
+		if lease != nil {
+			holder = fmt.Sprint(lease["holder"])
+		}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.holder = holder
+	e.setLeadership(acquired, now.Add(e.duration*2/3))
+}
+
+// release gives the lease up when this replica holds it
+func (e *Elector) release() {
+	if !e.IsLeader() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), e.duration/3)
+	defer cancel()
+	err := e.db.RestfulAPIDeleteOne(ctx, LeaseCollName, bson.M{"name": e.name, "holder": e.replicaId})
+	if err != nil {
+		e.log.AppLog.Warnf("failed to release the %s lease: %v", e.name, err)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.holder = ""
+	e.setLeadership(false, time.Time{})
+}
+
+// setLeadership records the leadership of this replica, valid until
+// validUntil, and signals its changes. e.mu must be held.
+func (e *Elector) setLeadership(leader bool, validUntil time.Time) {
+	e.leader = leader && time.Now().Before(validUntil)
+	e.validUntil = validUntil
+	if e.leader == e.reported {
+		return
+	}
+	e.reported = e.leader
+	if e.leader {
+		e.log.AppLog.Infof("replica %s is the %s leader", e.replicaId, e.name)
+	} else {
+		e.log.AppLog.Infof("replica %s is no longer the %s leader", e.replicaId, e.name)
+	}
+	e.stats.SetClusterLeader(e.leader)
+	close(e.changed)
+	e.changed = make(chan struct{})
+}
+
+// IsLeader reports whether this replica is the leader. It stops being so when
+// its lease could not be renewed in time, even before another replica
+// acquires the lease.
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader && time.Now().Before(e.validUntil)
+}
+
+// Leader returns the replica last seen holding the lease, empty when none
+// was seen
+func (e *Elector) Leader() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.holder
+}
+
+// leadership returns the leadership of this replica and a channel closed when
+// it changes
+func (e *Elector) leadership() (bool, <-chan struct{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader && time.Now().Before(e.validUntil), e.changed
+}
+
+// RunWhileLeader runs job whenever this replica becomes the leader, until stop
+// is closed. The stop channel given to job is closed when the replica is no
+// longer the leader, and RunWhileLeader waits for job to return before
+// running it again.
+func (e *Elector) RunWhileLeader(stop <-chan struct{}, job func(stop <-chan struct{})) {
+	for {
+		if !e.waitLeadership(stop, true) {
+			return
+		}
+		jobStop := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			job(jobStop)
+		}()
+		stopped := !e.waitLeadership(stop, false)
+		close(jobStop)
+		<-done
+		if stopped {
+			return
+		}
+	}
+}
+
+// waitLeadership waits for the leadership of this replica to be leader. It
+// returns false when stop was closed meanwhile.
+func (e *Elector) waitLeadership(stop <-chan struct{}, leader bool) bool {
+	for {
+		current, changed := e.leadership()
+		if current == leader {
+			return true
+		}
+		// the leadership also lapses when the lease is not renewed in time
+		var lapse <-chan time.Time
+		if current {
+			e.mu.Lock()
+			lapse = time.After(time.Until(e.validUntil))
+			e.mu.Unlock()
+		}
+		select {
+		case <-stop:
+			return false
+		case <-changed:
+		case <-lapse:
+		}
+	}
+}
@@ -0,0 +1,246 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cluster_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/omec-project/nrf/cluster"
+	"github.com/omec-project/nrf/dbadapter"
+	"github.com/omec-project/nrf/logger"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const leaseDuration = 300 * time.Millisecond
+
+// SharedMockMongoDBClient is the storage shared by the replicas of a test,
+// without transactions. The leases are unique by name.
+type SharedMockMongoDBClient struct {
+	dbadapter.DBInterface
+	mu     sync.Mutex
+	leases map[string]map[string]interface{}
+}
+
+func (db *SharedMockMongoDBClient) RestfulAPIGetOne(ctx context.Context, collName string, filter bson.M) (map[string]interface{}, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	lease, ok := db.leases[fmt.Sprint(filter["name"])]
+	if !ok {
+		return nil, nil
+	}
+	return maps.Clone(lease), nil
+}
+
+func (db *SharedMockMongoDBClient) RestfulAPIPutOneIf(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}, upsert bool) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	name := fmt.Sprint(filter["name"])
+	lease, ok := db.leases[name]
+	if ok && !leaseMatches(lease, filter) || !ok && !upsert {
+		return false, nil
+	}
+	db.leases[name] = maps.Clone(putData)
+	return true, nil
+}
+
+// leaseMatches tells whether lease matches one of the $or clauses of filter,
+// on its holder or on its expiry time
+func leaseMatches(lease map[string]interface{}, filter bson.M) bool {
+	for _, clause := range filter["$or"].([]bson.M) {
+		if holder, ok := clause["holder"]; ok && lease["holder"] == holder {
+			return true
+		}
+		if expireAt, ok := clause["expireAt"].(bson.M); ok && lease["expireAt"].(time.Time).Before(expireAt["$lt"].(time.Time)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (db *SharedMockMongoDBClient) RestfulAPIDeleteOne(ctx context.Context, collName string, filter bson.M) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	name := fmt.Sprint(filter["name"])
+	if lease, ok := db.leases[name]; ok && lease["holder"] == filter["holder"] {
+		delete(db.leases, name)
+	}
+	return nil
+}
+
+// PartitionedMockMongoDBClient is the view of a replica on the shared
+// storage, which it can be cut off from
+type PartitionedMockMongoDBClient struct {
+	*SharedMockMongoDBClient
+	partitioned atomic.Bool
+}
+
+func (db *PartitionedMockMongoDBClient) RestfulAPIGetOne(ctx context.Context, collName string, filter bson.M) (map[string]interface{}, error) {
+	if db.partitioned.Load() {
+		return nil, errors.New("server selection timeout")
+	}
+	return db.SharedMockMongoDBClient.RestfulAPIGetOne(ctx, collName, filter)
+}
+
+func (db *PartitionedMockMongoDBClient) RestfulAPIPutOneIf(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}, upsert bool) (bool, error) {
+	if db.partitioned.Load() {
+		return false, errors.New("server selection timeout")
+	}
+	return db.SharedMockMongoDBClient.RestfulAPIPutOneIf(ctx, collName, filter, putData, upsert)
+}
+
+// replica is an elector taking part in the election until stopped
+type replica struct {
+	db      *PartitionedMockMongoDBClient
+	elector *cluster.Elector
+	stop    chan struct{}
+	// done is closed once the replica released its lease and stopped its job
+	done chan struct{}
+	// jobs counts the leader jobs running
+	jobs atomic.Int32
+}
+
+func startReplicas(t *testing.T, count int) []*replica {
+	t.Helper()
+	return startReplicasOn(t, &SharedMockMongoDBClient{leases: map[string]map[string]interface{}{}}, count)
+}
+
+// startReplicasOn starts count replicas at once on the shared storage
+func startReplicasOn(t *testing.T, shared *SharedMockMongoDBClient, count int) []*replica {
+	t.Helper()
+	replicas := make([]*replica, count)
+	for i := range replicas {
+		r := &replica{
+			db:   &PartitionedMockMongoDBClient{SharedMockMongoDBClient: shared},
+			stop: make(chan struct{}),
+			done: make(chan struct{}),
+		}
+		r.elector = cluster.NewElector(r.db, "nrf-leader", fmt.Sprintf("nrf-%d", i), leaseDuration, nil, logger.Default())
+		var running sync.WaitGroup
+		running.Add(2)
+		go func() {
+			defer running.Done()
+			r.elector.Run(r.stop)
+		}()
+		go func() {
+			defer running.Done()
+			r.elector.RunWhileLeader(r.stop, func(stop <-chan struct{}) {
+				r.jobs.Add(1)
+				<-stop
+				r.jobs.Add(-1)
+			})
+		}()
+		go func() {
+			running.Wait()
+			close(r.done)
+		}()
+		replicas[i] = r
+	}
+	t.Cleanup(func() {
+		for _, r := range replicas {
+			r.shutdown()
+		}
+	})
+	return replicas
+}
+
+func (r *replica) shutdown() {
+	select {
+	case <-r.stop:
+	default:
+		close(r.stop)
+		<-r.done
+	}
+}
+
+// waitLeader waits for exactly one of replicas, other than previous, to be
+// the leader, running the leader job, and returns it
+func waitLeader(t *testing.T, replicas []*replica, previous *replica) *replica {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		var leaders []*replica
+		jobs := int32(0)
+		for _, r := range replicas {
+			if r.elector.IsLeader() {
+				leaders = append(leaders, r)
+			}
+			jobs += r.jobs.Load()
+		}
+		if len(leaders) == 1 && leaders[0] != previous && jobs == 1 && leaders[0].jobs.Load() == 1 {
+			return leaders[0]
+		}
+		if len(leaders) > 1 {
+			t.Fatalf("%d replicas are leaders at once", len(leaders))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for a leader")
+	return nil
+}
+
+func TestLeaderElection(t *testing.T) {
+	replicas := startReplicas(t, 3)
+	leader := waitLeader(t, replicas, nil)
+	for _, r := range replicas {
+		if r != leader && r.elector.Leader() != "" && r.elector.Leader() != leader.elector.ReplicaId() {
+			t.Errorf("replica %s sees %s as leader instead of %s", r.elector.ReplicaId(), r.elector.Leader(),
+				leader.elector.ReplicaId())
+		}
+	}
+
+	// a stopped leader releases its lease, another replica takes over without
+	// waiting for the lease to expire
+	start := time.Now()
+	leader.shutdown()
+	if leader.jobs.Load() != 0 {
+		t.Error("expected the leader job to be stopped with the leader")
+	}
+	waitLeader(t, replicas, leader)
+	if elapsed := time.Since(start); elapsed >= leaseDuration {
+		t.Errorf("expected the lease to be taken over before it expires, took %v", elapsed)
+	}
+}
+
+func TestLeadershipLapses(t *testing.T) {
+	replicas := startReplicas(t, 2)
+	leader := waitLeader(t, replicas, nil)
+
+	// a leader cut off from the storage stops its job before its lease
+	// expires, then another replica acquires the lease
+	leader.db.partitioned.Store(true)
+	next := waitLeader(t, replicas, leader)
+	if leader.elector.IsLeader() || leader.jobs.Load() != 0 {
+		t.Error("expected the partitioned replica to no longer lead")
+	}
+
+	// back in the partition, the former leader follows the new one
+	leader.db.partitioned.Store(false)
+	time.Sleep(leaseDuration)
+	if waitLeader(t, replicas, nil) != next {
+		t.Error("expected the leadership to stay with the new leader")
+	}
+}
+
+func TestExpiredLeaseTakenOnce(t *testing.T) {
+	// the replicas all find the lease of a former leader expired
+	shared := &SharedMockMongoDBClient{leases: map[string]map[string]interface{}{
+		"nrf-leader": {"name": "nrf-leader", "holder": "nrf-gone", "expireAt": time.Now().Add(-time.Second)},
+	}}
+	replicas := startReplicasOn(t, shared, 5)
+	leader := waitLeader(t, replicas, nil)
+	for _, r := range replicas {
+		if r != leader && r.elector.Leader() != leader.elector.ReplicaId() {
+			t.Errorf("replica %s sees %q as leader instead of %s", r.elector.ReplicaId(), r.elector.Leader(),
+				leader.elector.ReplicaId())
+		}
+	}
+}
@@ -6,32 +6,111 @@
 package context
 
 import (
+	"context"
+	"net/http"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/google/uuid"
+	"github.com/omec-project/nrf/dbadapter"
 	"github.com/omec-project/nrf/factory"
 	"github.com/omec-project/nrf/logger"
+	"github.com/omec-project/nrf/metrics"
+	"github.com/omec-project/nrf/polling"
+	"github.com/omec-project/nrf/tracing"
+	"github.com/omec-project/nrf/webhook"
 	"github.com/omec-project/openapi/models"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"golang.org/x/sync/singleflight"
 )
 
-var NrfNfProfile models.NfProfile
+// NRFContext holds the state of one NRF instance
+type NRFContext struct {
+	Config   *factory.Config
+	DB       dbadapter.DBInterface
+	Log      *logger.Logger
+	Metrics  *metrics.NrfStats
+	Webhooks *webhook.Dispatcher
+	// TracerProvider traces the SBI procedures and the outbound calls
+	TracerProvider trace.TracerProvider
+	// FetchPlmnConfig returns the supported PLMNs configured in webconsole
+	FetchPlmnConfig func(ctx context.Context) ([]models.PlmnId, error)
+	// RegistryRefreshInterval is the period at which the NrfInfo of the NRF
+	// profile and the registry metrics are rebuilt even when no registry
+	// change was signalled
+	RegistryRefreshInterval time.Duration
+	// ExpirySweepInterval is the period at which the leader removes the NF
+	// profiles which missed their heartbeats
+	ExpirySweepInterval time.Duration
+	// IsLeader reports whether this NRF is the leader of the replicas sharing
+	// its storage, which stores the NRF profile and removes the expired NF
+	// profiles. An NRF without IsLeader is alone.
+	IsLeader func() bool
+	// IsOperative reports whether the NRF can serve the NFs, which it cannot
+	// while its storage is unavailable. An NRF without IsOperative always can.
+	IsOperative func() bool
 
-func InitNrfContext() {
-	config := factory.NrfConfig
-	logger.InitLog.Infof("nrfconfig Info: Version[%s] Description[%s]", config.Info.Version, config.Info.Description)
-	configuration := config.Configuration
+	nrfNfProfile      models.NfProfile
+	nrfProfileMutex   sync.RWMutex
+	registryChangedCh chan struct{}
+	// nrfProfilePublished is set once the NRF profile is stored with its
+	// persistent instance id
+	nrfProfilePublished atomic.Bool
+	servingPlmnsMutex   sync.Mutex
+	servingPlmns        servingPlmns
+	// servingPlmnsFetch fetches the serving PLMNs once for the concurrent
+	// callers
+	servingPlmnsFetch singleflight.Group
+}
+
+// New creates the context of an NRF instance using the given configuration,
+// storage and loggers
+func New(config *factory.Config, db dbadapter.DBInterface, log *logger.Logger) *NRFContext {
+	log.InitLog.Infof("nrfconfig Info: Version[%s] Description[%s]", config.GetVersion(), config.GetDescription())
+	c := &NRFContext{
+		Config:                  config,
+		DB:                      db,
+		Log:                     log,
+		TracerProvider:          noop.NewTracerProvider(),
+		RegistryRefreshInterval: 60 * time.Second,
+		ExpirySweepInterval:     5 * time.Second,
+		registryChangedCh:       make(chan struct{}, 1),
+	}
+	c.FetchPlmnConfig = func(ctx context.Context) ([]models.PlmnId, error) {
+		return polling.FetchPlmnConfig(ctx, c.HTTPClient(), config.Configuration.WebuiUri)
+	}
+
+	// the persistent instance id is assigned by PublishNrfProfile once the
+	// database is connected
+	c.nrfNfProfile.NfType = models.NfType_NRF
+	c.nrfNfProfile.NfStatus = models.NfStatus_REGISTERED
 
-	NrfNfProfile.NfInstanceId = uuid.New().String()
-	NrfNfProfile.NfType = models.NfType_NRF
-	NrfNfProfile.NfStatus = models.NfStatus_REGISTERED
+	serviceNameList := config.Configuration.ServiceNameList
+	NFServices := c.InitNFService(serviceNameList, config.GetVersion())
+	c.nrfNfProfile.NfServices = &NFServices
+	return c
+}
+
+// leader reports whether this NRF runs the jobs done once for all the
+// replicas
+func (c *NRFContext) leader() bool {
+	return c.IsLeader == nil || c.IsLeader()
+}
+
+// Tracer returns the tracer of the NRF spans
+func (c *NRFContext) Tracer() trace.Tracer {
+	return c.TracerProvider.Tracer(tracing.InstrumentationName)
+}
 
-	serviceNameList := configuration.ServiceNameList
-	NFServices := InitNFService(serviceNameList, config.Info.Version)
-	NrfNfProfile.NfServices = &NFServices
+// HTTPClient returns a client tracing the outbound HTTP requests
+func (c *NRFContext) HTTPClient() *http.Client {
+	return &http.Client{Transport: tracing.Transport(nil, c.TracerProvider)}
 }
 
-func InitNFService(srvNameList []string, version string) []models.NfService {
+func (c *NRFContext) InitNFService(srvNameList []string, version string) []models.NfService {
 	tmpVersion := strings.Split(version, ".")
 	versionUri := "v" + tmpVersion[0]
 	NFServices := make([]models.NfService, len(srvNameList))
@@ -46,14 +125,14 @@ func InitNFService(srvNameList []string, version string) []models.NfService {
 					ApiVersionInUri: versionUri,
 				},
 			},
-			Scheme:          models.UriScheme(factory.NrfConfig.GetSbiScheme()),
+			Scheme:          models.UriScheme(c.Config.GetSbiScheme()),
 			NfServiceStatus: models.NfServiceStatus_REGISTERED,
-			ApiPrefix:       factory.NrfConfig.GetSbiUri(),
+			ApiPrefix:       c.Config.GetSbiUri(),
 			IpEndPoints: &[]models.IpEndPoint{
 				{
-					Ipv4Address: factory.NrfConfig.GetSbiRegisterIP(),
+					Ipv4Address: c.Config.GetSbiRegisterIP(),
 					Transport:   models.TransportProtocol_TCP,
-					Port:        int32(factory.NrfConfig.GetSbiPort()),
+					Port:        int32(c.Config.GetSbiPort()),
 				},
 			},
 		}
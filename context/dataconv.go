@@ -6,6 +6,7 @@ package context
 
 import (
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"math/big"
 	"net"
@@ -14,6 +15,7 @@ import (
 	"unicode"
 
 	"github.com/omec-project/nrf/logger"
+	"github.com/omec-project/openapi/models"
 )
 
 // Ipv6ToInt - Convert Ipv6 string to *bigInt
@@ -104,3 +106,17 @@ func EncodeGroupId(groupId string) string {
 
 	return encodedGroupId
 }
+
+// ParsePlmnList - Parse a PLMN list query parameter, such as target-plmn-list,
+// given as a JSON array or as JSON objects separated by commas
+func ParsePlmnList(value string) ([]models.PlmnId, error) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "[") {
+		value = "[" + value + "]"
+	}
+	var plmnList []models.PlmnId
+	if err := json.Unmarshal([]byte(value), &plmnList); err != nil {
+		return nil, fmt.Errorf("invalid PLMN list: %w", err)
+	}
+	return plmnList, nil
+}
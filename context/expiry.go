@@ -0,0 +1,72 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package context
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/omec-project/nrf/webhook"
+	"github.com/omec-project/openapi/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// SweepExpiredNfInstances removes the NF profiles which missed their
+// heartbeats, counting each removal and calling the NF-down hooks of its
+// EXPIRED event. A profile is removed only while it is still expired, so a
+// heartbeat received meanwhile keeps it, and a profile removed by a
+// deregistration meanwhile is not counted.
+func (c *NRFContext) SweepExpiredNfInstances(ctx context.Context) error {
+	now := time.Now()
+	nfProfiles, err := c.DB.RestfulAPIGetMany(ctx, "NfProfile", bson.M{"expireAt": bson.M{"$lt": now}})
+	if err != nil {
+		return err
+	}
+	removed := false
+	for _, nfProfile := range nfProfiles {
+		nfInstanceId, ok := nfProfile["nfInstanceId"].(string)
+		if !ok {
+			continue
+		}
+		filter := bson.M{"nfInstanceId": nfInstanceId, "expireAt": bson.M{"$lt": now}}
+		deleted, err := c.DB.RestfulAPIDeleteOneIf(ctx, "NfProfile", filter)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			continue
+		}
+		removed = true
+		nfType := fmt.Sprint(nfProfile["nfType"])
+		c.Log.ManagementLog.Infof("NF instance %s [%s] expired", nfInstanceId, nfType)
+		c.Metrics.IncrementNfExpiriesStats(nfType)
+		c.Webhooks.NotifyNfDown(ctx, webhook.EventExpired, nfInstanceId, models.NfType(nfType))
+	}
+	if removed {
+		c.RegistryChanged()
+	}
+	return nil
+}
+
+// RunExpirySweeper removes the expired NF profiles every ExpirySweepInterval
+// until stop is closed. It is run by the leader only, so that each expired
+// profile is removed and notified once.
+func (c *NRFContext) RunExpirySweeper(stop <-chan struct{}) {
+	ticker := time.NewTicker(c.ExpirySweepInterval)
+	defer ticker.Stop()
+	// the sweeps are bounded by the storage timeouts
+	ctx := context.Background()
+	for {
+		if err := c.SweepExpiredNfInstances(ctx); err != nil {
+			c.Log.ManagementLog.Warnf("failed to remove the expired NF instances: %v", err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
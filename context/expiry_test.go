@@ -0,0 +1,144 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package context_test
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	nrfContext "github.com/omec-project/nrf/context"
+	"github.com/omec-project/nrf/dbadapter"
+	"github.com/omec-project/nrf/factory"
+	"github.com/omec-project/nrf/logger"
+	"github.com/omec-project/nrf/metrics"
+	"github.com/omec-project/nrf/webhook"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+)
+
+// ExpiryMockMongoDBClient stores the NF profiles by NF instance id, and
+// applies the conditions on expireAt of the sweeps. afterList is called once
+// the expired profiles are listed, to change the registry meanwhile.
+type ExpiryMockMongoDBClient struct {
+	dbadapter.DBInterface
+
+	mu        sync.Mutex
+	profiles  map[string]map[string]interface{}
+	afterList func()
+}
+
+func expiredBefore(profile map[string]interface{}, filter bson.M) bool {
+	condition, ok := filter["expireAt"].(bson.M)
+	if !ok {
+		return true
+	}
+	expireAt, ok := profile["expireAt"].(time.Time)
+	return ok && expireAt.Before(condition["$lt"].(time.Time))
+}
+
+func (db *ExpiryMockMongoDBClient) RestfulAPIGetMany(ctx context.Context, collName string, filter bson.M) ([]map[string]interface{}, error) {
+	db.mu.Lock()
+	var profiles []map[string]interface{}
+	for _, profile := range db.profiles {
+		if expiredBefore(profile, filter) {
+			profiles = append(profiles, maps.Clone(profile))
+		}
+	}
+	afterList := db.afterList
+	db.afterList = nil
+	db.mu.Unlock()
+	if afterList != nil {
+		afterList()
+	}
+	return profiles, nil
+}
+
+func (db *ExpiryMockMongoDBClient) RestfulAPIDeleteOneIf(ctx context.Context, collName string, filter bson.M) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	nfInstanceId := fmt.Sprint(filter["nfInstanceId"])
+	profile, ok := db.profiles[nfInstanceId]
+	if !ok || !expiredBefore(profile, filter) {
+		return false, nil
+	}
+	delete(db.profiles, nfInstanceId)
+	return true, nil
+}
+
+func TestSweepExpiredNfInstances(t *testing.T) {
+	config, err := factory.ReadConfig("../nrfTest/nrfcfg.yaml")
+	if err != nil {
+		t.Fatalf("failed to read test configuration: %v", err)
+	}
+	registry := prometheus.NewRegistry()
+	stats, err := metrics.NewNrfStats(registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	db := &ExpiryMockMongoDBClient{profiles: map[string]map[string]interface{}{
+		"amf-1": {"nfInstanceId": "amf-1", "nfType": "AMF", "expireAt": now.Add(-time.Second)},
+		"amf-2": {"nfInstanceId": "amf-2", "nfType": "AMF", "expireAt": now.Add(-time.Second)},
+		"amf-3": {"nfInstanceId": "amf-3", "nfType": "AMF", "expireAt": now.Add(-time.Second)},
+		"smf-1": {"nfInstanceId": "smf-1", "nfType": "SMF", "expireAt": now.Add(time.Hour)},
+		"nrf-1": {"nfInstanceId": "nrf-1", "nfType": "NRF"},
+	}}
+	// amf-2 is deregistered and amf-3 sends a heartbeat once listed
+	db.afterList = func() {
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		delete(db.profiles, "amf-2")
+		db.profiles["amf-3"]["expireAt"] = time.Now().Add(time.Hour)
+	}
+	var mu sync.Mutex
+	var hookPaths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		hookPaths = append(hookPaths, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	hooks, err := webhook.NewDispatcher([]factory.NfDownHook{{Url: server.URL + "/{event}/{nfType}/{nfInstanceId}"}},
+		logger.New(zap.NewNop()), noop.NewTracerProvider())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := nrfContext.New(config, db, logger.New(zap.NewNop()))
+	c.Metrics = stats
+	c.Webhooks = hooks
+
+	// the second sweep finds nothing more to remove
+	for range 2 {
+		if err = c.SweepExpiredNfInstances(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := db.profiles["amf-1"]; ok {
+		t.Error("expected the expired amf-1 to be removed")
+	}
+	for _, nfInstanceId := range []string{"amf-3", "smf-1", "nrf-1"} {
+		if _, ok := db.profiles[nfInstanceId]; !ok {
+			t.Errorf("expected %s to be kept", nfInstanceId)
+		}
+	}
+	if got := metricValue(t, registry, "nrf_nf_expiries", map[string]string{"nf_type": "AMF"}); got != 1 {
+		t.Errorf("expected 1 expired AMF instance, got %v", got)
+	}
+	hooks.Wait()
+	mu.Lock()
+	defer mu.Unlock()
+	if len(hookPaths) != 1 || hookPaths[0] != "/EXPIRED/AMF/amf-1" {
+		t.Errorf("expected the hooks to be called for the expired AMF instance only, got %v", hookPaths)
+	}
+}
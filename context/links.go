@@ -11,5 +11,8 @@ package context
 import "github.com/omec-project/openapi/models"
 
 type Links struct {
-	Item []models.Link `json:"item" bson:"item"`
+	Self *models.Link  `json:"self,omitempty" bson:"self,omitempty" mapstructure:"self"`
+	Item []models.Link `json:"item" bson:"item" mapstructure:"item"`
+	Prev *models.Link  `json:"prev,omitempty" bson:"prev,omitempty" mapstructure:"prev"`
+	Next *models.Link  `json:"next,omitempty" bson:"next,omitempty" mapstructure:"next"`
 }
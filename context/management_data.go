@@ -8,17 +8,15 @@
 package context
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"math/big"
 	"strconv"
 
-	"github.com/mitchellh/mapstructure"
-	"github.com/omec-project/nrf/dbadapter"
 	"github.com/omec-project/nrf/factory"
-	"github.com/omec-project/nrf/logger"
-	"github.com/omec-project/nrf/polling"
 	"github.com/omec-project/openapi"
 	"github.com/omec-project/openapi/models"
 	"go.mongodb.org/mongo-driver/bson"
@@ -36,7 +34,7 @@ func GenerateRandomNumber() (int, error) {
 	return int(randomNumber.Int64()), nil
 }
 
-func NnrfNFManagementDataModel(nf *models.NfProfile, nfprofile models.NfProfile) error {
+func (c *NRFContext) NnrfNFManagementDataModel(ctx context.Context, nf *models.NfProfile, nfprofile models.NfProfile) error {
 	if nfprofile.NfInstanceId == "" {
 		return fmt.Errorf("NfInstanceId field is required")
 	}
@@ -52,55 +50,48 @@ func NnrfNFManagementDataModel(nf *models.NfProfile, nfprofile models.NfProfile)
 	}
 	nf.NfStatus = nfprofile.NfStatus
 
-	nfPlmnList, err := buildNfProfilePlmnList(nfprofile.PlmnList)
+	nfPlmnList, err := c.buildNfProfilePlmnList(ctx, nfprofile.PlmnList)
 	if err != nil {
 		return err
 	}
 
-	nnrfNFManagementCondition(nf, nfprofile)
+	c.nnrfNFManagementCondition(nf, nfprofile)
 	nf.PlmnList = &nfPlmnList
-	nnrfNFManagementOption(nf, nfprofile)
+	c.nnrfNFManagementOption(nf, nfprofile)
 
 	return nil
 }
 
-func buildNfProfilePlmnList(nfProvidedPlmnList *[]models.PlmnId) ([]models.PlmnId, error) {
+func (c *NRFContext) buildNfProfilePlmnList(ctx context.Context, nfProvidedPlmnList *[]models.PlmnId) ([]models.PlmnId, error) {
 	// NF provided a list of supported PLMNs
 	if nfProvidedPlmnList != nil && len(*nfProvidedPlmnList) != 0 {
 		return *nfProvidedPlmnList, nil
 	}
 	// NF did not provide supported PLMNs: fetch from webconsole
-	logger.ManagementLog.Warnln("PLMN config not provided by NF, using supported PLMNs from webconsole")
-	supportedPlmnList, err := polling.FetchPlmnConfig()
+	c.Log.ManagementLog.Warnln("PLMN config not provided by NF, using supported PLMNs from webconsole")
+	supportedPlmnList, err := c.FetchPlmnConfig(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch PLMN config from webconsole: %v", err)
 	}
-	logger.ManagementLog.Debugf("Fetched PLMN list from webconsole: %+v", supportedPlmnList)
+	c.Log.ManagementLog.Debugf("Fetched PLMN list from webconsole: %+v", supportedPlmnList)
 	if len(supportedPlmnList) == 0 {
 		return nil, fmt.Errorf("PLMN config not provided by NF and no local PLMN config available")
 	}
 	return supportedPlmnList, nil
 }
 
-func SetsubscriptionId() string {
+func (c *NRFContext) SetsubscriptionId() string {
 	x, err := GenerateRandomNumber()
 	if err != nil {
-		logger.ManagementLog.Error(err)
+		c.Log.ManagementLog.Error(err)
 	}
 	return strconv.Itoa(x)
 }
 
-func nnrfNFManagementCondition(nf *models.NfProfile, nfprofile models.NfProfile) {
+func (c *NRFContext) nnrfNFManagementCondition(nf *models.NfProfile, nfprofile models.NfProfile) {
 	// HeartBeatTimer
-	if !factory.NrfConfig.Configuration.NfProfileExpiryEnable {
-		// setting 1day keepAliveTimer value
-		factory.NrfConfig.Configuration.NfKeepAliveTime = 24 * 60 * 60
-	} else if factory.NrfConfig.Configuration.NfKeepAliveTime == 0 {
-		logger.ManagementLog.Infoln("NfProfileExpiryEnable: true but keepAliveTime: 0, setting default keepAliveTimer: 60 sec")
-		factory.NrfConfig.Configuration.NfKeepAliveTime = 60
-	}
-	nf.HeartBeatTimer = factory.NrfConfig.Configuration.NfKeepAliveTime
-	logger.ManagementLog.Infof("HeartBeat Timer value: %v sec", nf.HeartBeatTimer)
+	nf.HeartBeatTimer = c.Config.GetNfKeepAliveTime()
+	c.Log.ManagementLog.Infof("HeartBeat Timer value: %v sec", nf.HeartBeatTimer)
 
 	// fqdn
 	if nfprofile.Fqdn != "" {
@@ -126,7 +117,7 @@ func nnrfNFManagementCondition(nf *models.NfProfile, nfprofile models.NfProfile)
 	}
 }
 
-func nnrfNFManagementOption(nf *models.NfProfile, nfprofile models.NfProfile) {
+func (c *NRFContext) nnrfNFManagementOption(nf *models.NfProfile, nfprofile models.NfProfile) {
 	// sNssais
 	if nfprofile.SNssais != nil {
 		// fmt.Println("SNssais")
@@ -296,23 +287,23 @@ func nnrfNFManagementOption(nf *models.NfProfile, nfprofile models.NfProfile) {
 		var a models.SmfInfo
 
 		if nfprofile.SmfInfo.SNssaiSmfInfoList != nil {
-			logger.ManagementLog.Debugln("Setting SNssaiSmfInfoList in SmfInfo")
+			c.Log.ManagementLog.Debugln("Setting SNssaiSmfInfoList in SmfInfo")
 			a.SNssaiSmfInfoList = nfprofile.SmfInfo.SNssaiSmfInfoList
 		}
 		if nfprofile.SmfInfo.TaiList != nil {
-			logger.ManagementLog.Debugln("Setting TaiList in SmfInfo")
+			c.Log.ManagementLog.Debugln("Setting TaiList in SmfInfo")
 			a.TaiList = nfprofile.SmfInfo.TaiList
 		}
 		if nfprofile.SmfInfo.TaiRangeList != nil {
-			logger.ManagementLog.Debugln("Setting TaiRangeList in SmfInfo")
+			c.Log.ManagementLog.Debugln("Setting TaiRangeList in SmfInfo")
 			a.TaiRangeList = nfprofile.SmfInfo.TaiRangeList
 		}
 		if nfprofile.SmfInfo.PgwFqdn != "" {
-			logger.ManagementLog.Debugf("Setting PgwFqdn in SmfInfo: %s", nfprofile.SmfInfo.PgwFqdn)
+			c.Log.ManagementLog.Debugf("Setting PgwFqdn in SmfInfo: %s", nfprofile.SmfInfo.PgwFqdn)
 			a.PgwFqdn = nfprofile.SmfInfo.PgwFqdn
 		}
 		if nfprofile.SmfInfo.AccessType != nil {
-			logger.ManagementLog.Debugln("Setting AccessType in SmfInfo")
+			c.Log.ManagementLog.Debugln("Setting AccessType in SmfInfo")
 			a.AccessType = nfprofile.SmfInfo.AccessType
 		}
 		nf.SmfInfo = &a
@@ -332,6 +323,9 @@ func nnrfNFManagementOption(nf *models.NfProfile, nfprofile models.NfProfile) {
 		}
 
 		a.IwkEpsInd = nfprofile.UpfInfo.IwkEpsInd
+		a.PduSessionTypes = nfprofile.UpfInfo.PduSessionTypes
+		a.AtsssCapability = nfprofile.UpfInfo.AtsssCapability
+		a.Priority = nfprofile.UpfInfo.Priority
 
 		nf.UpfInfo = &a
 	}
@@ -394,6 +388,9 @@ func nnrfNFManagementOption(nf *models.NfProfile, nfprofile models.NfProfile) {
 		if nfprofile.ChfInfo.PlmnRangeList != nil {
 			a.PlmnRangeList = nfprofile.ChfInfo.PlmnRangeList
 		}
+		a.GroupId = nfprofile.ChfInfo.GroupId
+		a.PrimaryChfInstance = nfprofile.ChfInfo.PrimaryChfInstance
+		a.SecondaryChfInstance = nfprofile.ChfInfo.SecondaryChfInstance
 		nf.ChfInfo = &a
 	}
 	// nrfInfo
@@ -406,6 +403,23 @@ func nnrfNFManagementOption(nf *models.NfProfile, nfprofile models.NfProfile) {
 		nf.RecoveryTime = nfprofile.RecoveryTime
 	}
 
+	// nfSetIdList
+	if nfprofile.NfSetIdList != nil {
+		a := make([]string, len(nfprofile.NfSetIdList))
+		copy(a, nfprofile.NfSetIdList)
+		nf.NfSetIdList = a
+	}
+	// nfSetRecoveryTimeList
+	if nfprofile.NfSetRecoveryTimeList != nil {
+		a := maps.Clone(*nfprofile.NfSetRecoveryTimeList)
+		nf.NfSetRecoveryTimeList = &a
+	}
+	// serviceSetRecoveryTimeList
+	if nfprofile.ServiceSetRecoveryTimeList != nil {
+		a := maps.Clone(*nfprofile.ServiceSetRecoveryTimeList)
+		nf.ServiceSetRecoveryTimeList = &a
+	}
+
 	// nfServicePersistence
 	if nfprofile.NfServicePersistence {
 		nf.NfServicePersistence = true
@@ -421,7 +435,7 @@ func nnrfNFManagementOption(nf *models.NfProfile, nfprofile models.NfProfile) {
 	}
 	// fill the NfServiceList if NfServices is set
 	if nfprofile.NfServices != nil && nfprofile.NfServiceList == nil {
-		logger.ManagementLog.Debugln("NfServiceList is nil, setting NfServiceList from NfServices")
+		c.Log.ManagementLog.Debugln("NfServiceList is nil, setting NfServiceList from NfServices")
 
 		map_service := make(map[string]models.NfService, len(*nfprofile.NfServices))
 		for _, nfService := range *nfprofile.NfServices {
@@ -432,7 +446,7 @@ func nnrfNFManagementOption(nf *models.NfProfile, nfprofile models.NfProfile) {
 
 	// fill the NfServices if NfServiceList is set
 	if nfprofile.NfServiceList != nil && nfprofile.NfServices == nil {
-		logger.ManagementLog.Debugln("NfServices is nil, setting NfServices from NfServiceList")
+		c.Log.ManagementLog.Debugln("NfServices is nil, setting NfServices from NfServiceList")
 
 		var nfServices []models.NfService
 		for _, nfService := range *nfprofile.NfServiceList {
@@ -440,61 +454,42 @@ func nnrfNFManagementOption(nf *models.NfProfile, nfprofile models.NfProfile) {
 		}
 		nf.NfServices = &nfServices
 	}
-	logger.ManagementLog.Debugln("finish the function nnrfNFManagementOption")
+	c.Log.ManagementLog.Debugln("finish the function nnrfNFManagementOption")
 }
 
-func GetNfInstanceURI(nfInstID string) string {
-	return factory.NrfConfig.GetSbiUri() + NRF_NFINST_RES_URI_PREFIX + nfInstID
+func (c *NRFContext) GetNfInstanceURI(nfInstID string) string {
+	return c.Config.GetSbiUri() + NRF_NFINST_RES_URI_PREFIX + nfInstID
 }
 
-func SetLocationHeader(nfprofile models.NfProfile) string {
-	var modifyUL UriList
-	var locationHeader []string
-
-	// set nfprofile location
-	locationHeader = append(locationHeader, GetNfInstanceURI(nfprofile.NfInstanceId))
-
-	collName := "urilist"
-	nfType := nfprofile.NfType
-	filter := bson.M{"nfType": nfType}
-
-	ul, _ := dbadapter.DBClient.RestfulAPIGetOne(collName, filter)
-
-	var originalUL UriList
-	err := mapstructure.Decode(ul, &originalUL)
-	if err != nil {
-		panic(err)
-	}
-
-	// obtain location header = NF URI
-	nnrfUriList(&originalUL, &modifyUL, locationHeader)
-	modifyUL.NfType = nfprofile.NfType
+// legacyUriListCollection is the collection where older NRF releases kept a
+// per-nfType list of NF instance URIs. The NF instance listing is now derived
+// from the NfProfile collection, so the documents found here are never read
+// and are only stale copies of the registry.
+const legacyUriListCollection = "urilist"
 
-	tmp, err := json.Marshal(modifyUL)
+// RemoveLegacyUriList removes the per-nfType urilist documents written by
+// older NRF releases so that they cannot drift from the NfProfile collection
+func (c *NRFContext) RemoveLegacyUriList(ctx context.Context) error {
+	legacy, err := c.DB.RestfulAPIGetMany(ctx, legacyUriListCollection, bson.M{})
 	if err != nil {
-		logger.ManagementLog.Error(err)
+		return fmt.Errorf("failed to read legacy %s collection: %v", legacyUriListCollection, err)
 	}
-	putData := bson.M{}
-	err = json.Unmarshal(tmp, &putData)
-	if err != nil {
-		logger.ManagementLog.Error(err)
+	if len(legacy) == 0 {
+		return nil
 	}
-
-	if ok, _ := dbadapter.DBClient.RestfulAPIPutOne(collName, filter, putData); ok {
-		logger.ManagementLog.Info("urilist update")
-	} else {
-		logger.ManagementLog.Info("urilist create")
+	if err := c.DB.RestfulAPIDeleteMany(ctx, legacyUriListCollection, bson.M{}); err != nil {
+		return fmt.Errorf("failed to remove legacy %s collection: %v", legacyUriListCollection, err)
 	}
-
-	return locationHeader[0]
+	c.Log.ManagementLog.Infof("removed %d legacy %s documents", len(legacy), legacyUriListCollection)
+	return nil
 }
 
-func setUriListByFilter(filter bson.M, uriList *[]string) {
-	filterNfTypeResultsRaw, _ := dbadapter.DBClient.RestfulAPIGetMany("Subscriptions", filter)
+func (c *NRFContext) setUriListByFilter(ctx context.Context, filter bson.M, uriList *[]string) {
+	filterNfTypeResultsRaw, _ := c.DB.RestfulAPIGetMany(ctx, "Subscriptions", filter)
 	var filterNfTypeResults []models.NrfSubscriptionData
 	err := openapi.Convert(filterNfTypeResultsRaw, &filterNfTypeResults)
 	if err != nil {
-		logger.ManagementLog.Error(err)
+		c.Log.ManagementLog.Error(err)
 	}
 
 	for _, subscr := range filterNfTypeResults {
@@ -502,38 +497,7 @@ func setUriListByFilter(filter bson.M, uriList *[]string) {
 	}
 }
 
-func nnrfUriList(originalUL *UriList, UL *UriList, location []string) {
-	var b *Links
-	var flag bool
-	var c []models.Link
-	flag = true
-	b = new(Links)
-	size := len(location) + len(originalUL.Link.Item)
-
-	// check duplicate
-	for _, item := range originalUL.Link.Item {
-		if item.Href == location[0] {
-			flag = false
-			break
-		}
-	}
-
-	if flag {
-		c = make([]models.Link, size)
-		copy(c, originalUL.Link.Item)
-		for i, loc := range location {
-			c[len(originalUL.Link.Item)+i].Href = loc
-		}
-	} else {
-		c = make([]models.Link, size-1)
-		copy(c, originalUL.Link.Item)
-	}
-
-	b.Item = c
-	UL.Link = *b
-}
-
-func GetNotificationUri(nfProfile models.NfProfile) []string {
+func (c *NRFContext) GetNotificationUri(ctx context.Context, nfProfile models.NfProfile) []string {
 	var uriList []string
 
 	// nfTypeCond
@@ -542,7 +506,7 @@ func GetNotificationUri(nfProfile models.NfProfile) []string {
 			"nfType": nfProfile.NfType,
 		},
 	}
-	setUriListByFilter(nfTypeCond, &uriList)
+	c.setUriListByFilter(ctx, nfTypeCond, &uriList)
 
 	// NfInstanceIdCond
 	nfInstanceIDCond := bson.M{
@@ -550,7 +514,16 @@ func GetNotificationUri(nfProfile models.NfProfile) []string {
 			"nfInstanceId": nfProfile.NfInstanceId,
 		},
 	}
-	setUriListByFilter(nfInstanceIDCond, &uriList)
+	c.setUriListByFilter(ctx, nfInstanceIDCond, &uriList)
+
+	// NfSetCond
+	if len(nfProfile.NfSetIdList) != 0 {
+		var nfSetConds bson.A
+		for _, nfSetId := range nfProfile.NfSetIdList {
+			nfSetConds = append(nfSetConds, bson.M{"subscrCond": bson.M{"nfSetId": nfSetId}})
+		}
+		c.setUriListByFilter(ctx, bson.M{"$or": nfSetConds}, &uriList)
+	}
 
 	// ServiceNameCond
 	if nfProfile.NfServices != nil {
@@ -564,7 +537,7 @@ func GetNotificationUri(nfProfile models.NfProfile) []string {
 				"$in": serviceNames,
 			},
 		}
-		setUriListByFilter(ServiceNameCond, &uriList)
+		c.setUriListByFilter(ctx, ServiceNameCond, &uriList)
 	}
 
 	// AmfCond
@@ -575,7 +548,7 @@ func GetNotificationUri(nfProfile models.NfProfile) []string {
 				"amfRegionId": (*nfProfile.AmfInfo).AmfRegionId,
 			},
 		}
-		setUriListByFilter(amfCond, &uriList)
+		c.setUriListByFilter(ctx, amfCond, &uriList)
 	}
 
 	// GuamiListCond
@@ -586,12 +559,12 @@ func GetNotificationUri(nfProfile models.NfProfile) []string {
 			for _, guami := range *(*nfProfile.AmfInfo).GuamiList {
 				tmp, err := json.Marshal(guami)
 				if err != nil {
-					logger.ManagementLog.Error(err)
+					c.Log.ManagementLog.Error(err)
 				}
 				guamiMarshal := bson.M{}
 				err = json.Unmarshal(tmp, &guamiMarshal)
 				if err != nil {
-					logger.ManagementLog.Error(err)
+					c.Log.ManagementLog.Error(err)
 				}
 
 				guamiListBsonArray = append(guamiListBsonArray, bson.M{"subscrCond": bson.M{"$elemMatch": guamiMarshal}})
@@ -600,7 +573,7 @@ func GetNotificationUri(nfProfile models.NfProfile) []string {
 				"$or": guamiListBsonArray,
 			}
 		}
-		setUriListByFilter(guamiListFilter, &uriList)
+		c.setUriListByFilter(ctx, guamiListFilter, &uriList)
 	}
 
 	// NetworkSliceCond
@@ -610,12 +583,12 @@ func GetNotificationUri(nfProfile models.NfProfile) []string {
 		for _, snssai := range *nfProfile.SNssais {
 			tmp, err := json.Marshal(snssai)
 			if err != nil {
-				logger.ManagementLog.Error(err)
+				c.Log.ManagementLog.Error(err)
 			}
 			snssaiMarshal := bson.M{}
 			err = json.Unmarshal(tmp, &snssaiMarshal)
 			if err != nil {
-				logger.ManagementLog.Error(err)
+				c.Log.ManagementLog.Error(err)
 			}
 
 			snssaisBsonArray = append(snssaisBsonArray, bson.M{"subscrCond": bson.M{"$elemMatch": snssaiMarshal}})
@@ -650,7 +623,7 @@ func GetNotificationUri(nfProfile models.NfProfile) []string {
 				},
 			}
 		}
-		setUriListByFilter(networkSliceFilter, &uriList)
+		c.setUriListByFilter(ctx, networkSliceFilter, &uriList)
 	}
 
 	// NfGroupCond
@@ -661,7 +634,7 @@ func GetNotificationUri(nfProfile models.NfProfile) []string {
 				"nfGroupId": (*nfProfile.UdrInfo).GroupId,
 			},
 		}
-		setUriListByFilter(nfGroupCond, &uriList)
+		c.setUriListByFilter(ctx, nfGroupCond, &uriList)
 	} else if nfProfile.UdmInfo != nil {
 		nfGroupCond := bson.M{
 			"subscrCond": bson.M{
@@ -669,7 +642,7 @@ func GetNotificationUri(nfProfile models.NfProfile) []string {
 				"nfGroupId": (*nfProfile.UdmInfo).GroupId,
 			},
 		}
-		setUriListByFilter(nfGroupCond, &uriList)
+		c.setUriListByFilter(ctx, nfGroupCond, &uriList)
 	} else if nfProfile.AusfInfo != nil {
 		nfGroupCond := bson.M{
 			"subscrCond": bson.M{
@@ -677,20 +650,27 @@ func GetNotificationUri(nfProfile models.NfProfile) []string {
 				"nfGroupId": (*nfProfile.AusfInfo).GroupId,
 			},
 		}
-		setUriListByFilter(nfGroupCond, &uriList)
+		c.setUriListByFilter(ctx, nfGroupCond, &uriList)
 	}
 
 	return uriList
 }
 
-func NnrfUriListLimit(originalUL *UriList, limit int) {
-	// response limit
-
-	if limit < len(originalUL.Link.Item) {
-		b := new(Links)
-		c := make([]models.Link, limit)
-		copy(c, originalUL.Link.Item[:limit])
-		b.Item = c
-		originalUL.Link = *b
+// GetServiceSetNotificationUri returns the notification URIs of the
+// subscriptions to any of the NF service sets nfServiceSetIds. The NF service
+// sets of a profile are not part of models.NfProfile, so that they are looked
+// up apart from GetNotificationUri.
+func (c *NRFContext) GetServiceSetNotificationUri(ctx context.Context, nfServiceSetIds []string) []string {
+	var uriList []string
+	if len(nfServiceSetIds) == 0 {
+		return uriList
+	}
+	// NfServiceSetCond
+	nfServiceSetCond := bson.M{
+		"subscrCond.nfServiceSetId": bson.M{
+			"$in": nfServiceSetIds,
+		},
 	}
+	c.setUriListByFilter(ctx, nfServiceSetCond, &uriList)
+	return uriList
 }
@@ -0,0 +1,272 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package context
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/omec-project/nrf/dbadapter"
+	"github.com/omec-project/nrf/util"
+	"github.com/omec-project/openapi/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// nrfInstanceCollName holds the single document recording the NF instance id
+// of this NRF, so that the id survives restarts
+const nrfInstanceCollName = "NrfInstance"
+
+// GetNrfNfProfile returns a copy of the NF profile of this NRF
+func (c *NRFContext) GetNrfNfProfile() models.NfProfile {
+	c.nrfProfileMutex.RLock()
+	defer c.nrfProfileMutex.RUnlock()
+	return c.nrfNfProfile
+}
+
+// loadNrfInstanceId returns the configured NRF instance id, or the one
+// persisted in the database, generating and persisting it on first start
+func (c *NRFContext) loadNrfInstanceId(ctx context.Context) (string, error) {
+	if id := c.Config.Configuration.NrfInstanceId; id != "" {
+		return id, nil
+	}
+	stored, err := c.DB.RestfulAPIGetOne(ctx, nrfInstanceCollName, bson.M{})
+	if err != nil {
+		return "", err
+	}
+	if id, ok := stored["nrfInstanceId"].(string); ok && id != "" {
+		return id, nil
+	}
+	// another replica may be creating the document concurrently: only insert
+	// when none exists and read back whichever document won
+	putData := bson.M{"nrfInstanceId": uuid.New().String()}
+	if _, err = c.DB.RestfulAPIPutOneNotUpdate(ctx, nrfInstanceCollName, bson.M{}, putData); err != nil {
+		return "", err
+	}
+	stored, err = c.DB.RestfulAPIGetOne(ctx, nrfInstanceCollName, bson.M{})
+	if err != nil {
+		return "", err
+	}
+	if id, ok := stored["nrfInstanceId"].(string); ok && id != "" {
+		return id, nil
+	}
+	return "", fmt.Errorf("failed to persist the NRF instance id")
+}
+
+// PublishNrfProfile assigns the persistent instance id to the NRF profile and
+// stores it in the NfProfile collection, so that the NRF can be retrieved and
+// discovered like any other NF
+func (c *NRFContext) PublishNrfProfile(ctx context.Context) error {
+	nrfInstanceId, err := c.loadNrfInstanceId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load NRF instance id: %v", err)
+	}
+
+	c.nrfProfileMutex.Lock()
+	c.nrfNfProfile.NfInstanceId = nrfInstanceId
+	if c.nrfNfProfile.PlmnList == nil {
+		if plmnList, fetchErr := c.FetchPlmnConfig(ctx); fetchErr != nil {
+			c.Log.InitLog.Warnf("NRF profile published without PLMN list: %v", fetchErr)
+		} else if len(plmnList) != 0 {
+			c.nrfNfProfile.PlmnList = &plmnList
+		}
+	}
+	c.nrfProfileMutex.Unlock()
+	c.Log.InitLog.Infof("NRF instance id: %s", nrfInstanceId)
+
+	if err = c.refreshNrfInfo(ctx, true); err != nil {
+		return err
+	}
+	c.nrfProfilePublished.Store(true)
+	return nil
+}
+
+// NrfProfilePublished reports whether the NRF profile was stored with its
+// persistent instance id
+func (c *NRFContext) NrfProfilePublished() bool {
+	return c.nrfProfilePublished.Load()
+}
+
+// RefreshNrfInfo rebuilds the NrfInfo of the NRF profile from the registered
+// NF profiles and, on the leader, stores the updated NRF profile when its
+// NrfInfo changed. The replicas share the NRF profile, the other ones only
+// rebuild their copy of it.
+func (c *NRFContext) RefreshNrfInfo(ctx context.Context) error {
+	return c.refreshNrfInfo(ctx, false)
+}
+
+// refreshNrfInfo rebuilds the NrfInfo of the NRF profile and, on the leader,
+// stores the NRF profile when always is set or when its NrfInfo changed
+func (c *NRFContext) refreshNrfInfo(ctx context.Context, always bool) error {
+	nrfInfo, err := c.BuildNrfInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.nrfProfileMutex.Lock()
+	c.nrfNfProfile.NrfInfo = nrfInfo
+	profile := c.nrfNfProfile
+	c.nrfProfileMutex.Unlock()
+	if !c.leader() {
+		return nil
+	}
+
+	filter := bson.M{"nfInstanceId": profile.NfInstanceId}
+	if !always {
+		// the stored NrfInfo is compared once decoded, as the encoding of its
+		// maps is not stable: storing an unchanged profile would still be a
+		// change of the registry
+		stored, err := c.DB.RestfulAPIGetOne(ctx, "NfProfile", filter)
+		if err != nil {
+			return err
+		}
+		if len(stored) != 0 {
+			storedProfiles, err := util.Decode([]map[string]interface{}{stored}, time.RFC3339)
+			if err == nil && len(storedProfiles) == 1 && sameNrfInfo(storedProfiles[0].NrfInfo, nrfInfo) {
+				return nil
+			}
+		}
+	}
+
+	tmp, err := json.Marshal(profile)
+	if err != nil {
+		return err
+	}
+	putData := bson.M{}
+	if err = json.Unmarshal(tmp, &putData); err != nil {
+		return err
+	}
+	if _, err = c.DB.RestfulAPIPutOne(ctx, "NfProfile", filter, putData); err != nil {
+		return err
+	}
+	c.Log.ManagementLog.Debugln("NRF profile updated")
+	return nil
+}
+
+// sameNrfInfo tells whether two NrfInfo are equal, comparing their JSON
+// encodings, which sort the keys of the maps
+func sameNrfInfo(a, b *models.NrfInfo) bool {
+	encodedA, errA := json.Marshal(a)
+	encodedB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(encodedA, encodedB)
+}
+
+// BuildNrfInfo collects the NF type specific info of the registered NF
+// profiles, keyed by NF instance id
+func (c *NRFContext) BuildNrfInfo(ctx context.Context) (*models.NrfInfo, error) {
+	filter := bson.M{"nfType": bson.M{"$ne": string(models.NfType_NRF)}}
+	nfProfilesRaw, err := c.DB.RestfulAPIGetMany(ctx, "NfProfile", filter)
+	if err != nil {
+		return nil, err
+	}
+	nfProfiles, err := util.Decode(nfProfilesRaw, time.RFC3339)
+	if err != nil {
+		return nil, err
+	}
+
+	nrfInfo := &models.NrfInfo{}
+	for _, nfProfile := range nfProfiles {
+		id := nfProfile.NfInstanceId
+		switch {
+		case nfProfile.UdrInfo != nil:
+			if nrfInfo.ServedUdrInfo == nil {
+				nrfInfo.ServedUdrInfo = make(map[string]models.UdrInfo)
+			}
+			nrfInfo.ServedUdrInfo[id] = *nfProfile.UdrInfo
+		case nfProfile.UdmInfo != nil:
+			if nrfInfo.ServedUdmInfo == nil {
+				nrfInfo.ServedUdmInfo = make(map[string]models.UdmInfo)
+			}
+			nrfInfo.ServedUdmInfo[id] = *nfProfile.UdmInfo
+		case nfProfile.AusfInfo != nil:
+			if nrfInfo.ServedAusfInfo == nil {
+				nrfInfo.ServedAusfInfo = make(map[string]models.AusfInfo)
+			}
+			nrfInfo.ServedAusfInfo[id] = *nfProfile.AusfInfo
+		case nfProfile.AmfInfo != nil:
+			if nrfInfo.ServedAmfInfo == nil {
+				nrfInfo.ServedAmfInfo = make(map[string]models.AmfInfo)
+			}
+			nrfInfo.ServedAmfInfo[id] = *nfProfile.AmfInfo
+		case nfProfile.SmfInfo != nil:
+			if nrfInfo.ServedSmfInfo == nil {
+				nrfInfo.ServedSmfInfo = make(map[string]models.SmfInfo)
+			}
+			nrfInfo.ServedSmfInfo[id] = *nfProfile.SmfInfo
+		case nfProfile.UpfInfo != nil:
+			if nrfInfo.ServedUpfInfo == nil {
+				nrfInfo.ServedUpfInfo = make(map[string]models.UpfInfo)
+			}
+			nrfInfo.ServedUpfInfo[id] = *nfProfile.UpfInfo
+		case nfProfile.PcfInfo != nil:
+			if nrfInfo.ServedPcfInfo == nil {
+				nrfInfo.ServedPcfInfo = make(map[string]models.PcfInfo)
+			}
+			nrfInfo.ServedPcfInfo[id] = *nfProfile.PcfInfo
+		case nfProfile.BsfInfo != nil:
+			if nrfInfo.ServedBsfInfo == nil {
+				nrfInfo.ServedBsfInfo = make(map[string]models.BsfInfo)
+			}
+			nrfInfo.ServedBsfInfo[id] = *nfProfile.BsfInfo
+		case nfProfile.ChfInfo != nil:
+			if nrfInfo.ServedChfInfo == nil {
+				nrfInfo.ServedChfInfo = make(map[string]models.ChfInfo)
+			}
+			nrfInfo.ServedChfInfo[id] = *nfProfile.ChfInfo
+		}
+	}
+	return nrfInfo, nil
+}
+
+// WatchRegistry has the registry refreshed on the changes reported by
+// notifier, but for those of the NRF profile, which the refreshes store
+// themselves
+func (c *NRFContext) WatchRegistry(notifier dbadapter.ChangeNotifier) {
+	notifier.OnChange(func(collName, nfInstanceId string) {
+		if collName == "NfProfile" && nfInstanceId != "" && nfInstanceId == c.GetNrfNfProfile().NfInstanceId {
+			return
+		}
+		c.RegistryChanged()
+	})
+}
+
+// RegistryChanged signals that the registry changed and that the NrfInfo of
+// the NRF profile and the registry metrics must be rebuilt. It never blocks:
+// changes signalled while a refresh is pending are folded into it.
+func (c *NRFContext) RegistryChanged() {
+	select {
+	case c.registryChangedCh <- struct{}{}:
+	default:
+	}
+}
+
+// RunRegistryRefresher rebuilds the NrfInfo and the registry metrics on
+// start, when the registry changes and periodically, until stop is closed
+func (c *NRFContext) RunRegistryRefresher(stop <-chan struct{}) {
+	ticker := time.NewTicker(c.RegistryRefreshInterval)
+	defer ticker.Stop()
+	// the refreshes are bounded by the storage timeouts
+	ctx := context.Background()
+	for {
+		// the NRF profile is stored once it has its persistent instance id
+		if c.NrfProfilePublished() {
+			if err := c.RefreshNrfInfo(ctx); err != nil {
+				c.Log.ManagementLog.Warnf("failed to refresh NRF info: %v", err)
+			}
+		}
+		if err := c.RefreshRegistryMetrics(ctx); err != nil {
+			c.Log.ManagementLog.Warnf("failed to refresh registry metrics: %v", err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		case <-c.registryChangedCh:
+		}
+	}
+}
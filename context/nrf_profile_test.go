@@ -0,0 +1,142 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package context_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"sync"
+	"testing"
+	"time"
+
+	nrfContext "github.com/omec-project/nrf/context"
+	"github.com/omec-project/nrf/dbadapter"
+	"github.com/omec-project/nrf/factory"
+	"github.com/omec-project/nrf/logger"
+	"github.com/omec-project/nrf/metrics"
+	"github.com/omec-project/openapi/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.uber.org/zap"
+)
+
+// EchoMockMongoDBClient stores the NF profiles by NF instance id, and reports
+// each write back to the OnChange handlers, as the change stream does
+type EchoMockMongoDBClient struct {
+	dbadapter.DBInterface
+
+	mu       sync.Mutex
+	profiles map[string]map[string]interface{}
+	writes   int
+	handlers []func(collName, nfInstanceId string)
+}
+
+func (db *EchoMockMongoDBClient) OnChange(fn func(collName, nfInstanceId string)) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.handlers = append(db.handlers, fn)
+}
+
+func (db *EchoMockMongoDBClient) emit(collName, nfInstanceId string) {
+	db.mu.Lock()
+	handlers := db.handlers
+	db.mu.Unlock()
+	for _, handler := range handlers {
+		handler(collName, nfInstanceId)
+	}
+}
+
+func (db *EchoMockMongoDBClient) RestfulAPIGetOne(ctx context.Context, collName string, filter bson.M) (map[string]interface{}, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if collName != "NfProfile" {
+		return nil, nil
+	}
+	return maps.Clone(db.profiles[fmt.Sprint(filter["nfInstanceId"])]), nil
+}
+
+func (db *EchoMockMongoDBClient) RestfulAPIGetMany(ctx context.Context, collName string, filter bson.M) ([]map[string]interface{}, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if collName != "NfProfile" {
+		return nil, nil
+	}
+	var profiles []map[string]interface{}
+	for _, profile := range db.profiles {
+		if _, ok := filter["nfType"]; ok && profile["nfType"] == string(models.NfType_NRF) {
+			continue
+		}
+		profiles = append(profiles, maps.Clone(profile))
+	}
+	return profiles, nil
+}
+
+func (db *EchoMockMongoDBClient) RestfulAPIPutOne(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}) (bool, error) {
+	nfInstanceId := fmt.Sprint(filter["nfInstanceId"])
+	db.mu.Lock()
+	_, existed := db.profiles[nfInstanceId]
+	db.profiles[nfInstanceId] = maps.Clone(putData)
+	db.writes++
+	db.mu.Unlock()
+	db.emit(collName, nfInstanceId)
+	return existed, nil
+}
+
+func (db *EchoMockMongoDBClient) writeCount() int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.writes
+}
+
+func TestRegistryRefresherOwnWrites(t *testing.T) {
+	config, err := factory.ReadConfig("../nrfTest/nrfcfg.yaml")
+	if err != nil {
+		t.Fatalf("failed to read test configuration: %v", err)
+	}
+	config.Configuration.NrfInstanceId = "nrf-1"
+	amf := map[string]interface{}{}
+	raw, _ := json.Marshal(models.NfProfile{
+		NfInstanceId: "amf-1",
+		NfType:       models.NfType_AMF,
+		NfStatus:     models.NfStatus_REGISTERED,
+		AmfInfo:      &models.AmfInfo{AmfSetId: "1", AmfRegionId: "ca"},
+	})
+	_ = json.Unmarshal(raw, &amf)
+	db := &EchoMockMongoDBClient{profiles: map[string]map[string]interface{}{"amf-1": amf}}
+	c := nrfContext.New(config, db, logger.New(zap.NewNop()))
+	if c.Metrics, err = metrics.NewNrfStats(prometheus.NewRegistry()); err != nil {
+		t.Fatal(err)
+	}
+	c.FetchPlmnConfig = func(context.Context) ([]models.PlmnId, error) {
+		return []models.PlmnId{{Mcc: "208", Mnc: "93"}}, nil
+	}
+	c.RegistryRefreshInterval = time.Hour
+	c.WatchRegistry(db)
+
+	if err = c.PublishNrfProfile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.RunRegistryRefresher(stop)
+	}()
+	// the change of another NF profile refreshes the unchanged NrfInfo
+	time.Sleep(50 * time.Millisecond)
+	db.emit("NfProfile", "amf-1")
+	time.Sleep(50 * time.Millisecond)
+	close(stop)
+	<-done
+
+	if writes := db.writeCount(); writes != 1 {
+		t.Errorf("expected the NRF profile to be stored once, got %d writes", writes)
+	}
+	if c.GetNrfNfProfile().NrfInfo.ServedAmfInfo["amf-1"].AmfSetId != "1" {
+		t.Errorf("expected the NrfInfo to serve amf-1, got %+v", c.GetNrfNfProfile().NrfInfo)
+	}
+}
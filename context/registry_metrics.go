@@ -0,0 +1,45 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package context
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/omec-project/nrf/metrics"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// RefreshRegistryMetrics recomputes the registry gauges from the storage, so
+// that they are accurate across restarts and replicas
+func (c *NRFContext) RefreshRegistryMetrics(ctx context.Context) error {
+	nfProfiles, err := c.DB.RestfulAPIGetMany(ctx, "NfProfile", bson.M{})
+	if err != nil {
+		return err
+	}
+	subscriptions, err := c.DB.RestfulAPIGetMany(ctx, "Subscriptions", bson.M{})
+	if err != nil {
+		return err
+	}
+
+	nfInstanceCounts := make(map[metrics.NfInstanceKey]int)
+	for _, nfProfile := range nfProfiles {
+		nfInstanceCounts[metrics.NfInstanceKey{
+			NfType:   fmt.Sprint(nfProfile["nfType"]),
+			NfStatus: fmt.Sprint(nfProfile["nfStatus"]),
+		}]++
+	}
+	subscriptionCounts := make(map[string]int)
+	for _, subscription := range subscriptions {
+		reqNfType, ok := subscription["reqNfType"].(string)
+		if !ok || reqNfType == "" {
+			reqNfType = "UNKNOWN_NF"
+		}
+		subscriptionCounts[reqNfType]++
+	}
+	c.Metrics.SetRegisteredNfInstances(nfInstanceCounts)
+	c.Metrics.SetActiveSubscriptions(subscriptionCounts)
+	return nil
+}
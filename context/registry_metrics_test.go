@@ -0,0 +1,160 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package context_test
+
+import (
+	"context"
+	"testing"
+
+	nrfContext "github.com/omec-project/nrf/context"
+	"github.com/omec-project/nrf/dbadapter"
+	"github.com/omec-project/nrf/factory"
+	"github.com/omec-project/nrf/logger"
+	"github.com/omec-project/nrf/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.uber.org/zap"
+)
+
+type RegistryMockMongoDBClient struct {
+	dbadapter.DBInterface
+	collections map[string][]map[string]interface{}
+}
+
+func (db *RegistryMockMongoDBClient) RestfulAPIGetMany(ctx context.Context, collName string, filter bson.M) ([]map[string]interface{}, error) {
+	return db.collections[collName], nil
+}
+
+func metricValue(t *testing.T, registry *prometheus.Registry, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metric:
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if value, ok := labels[label.GetName()]; ok && value != label.GetValue() {
+					continue metric
+				}
+			}
+			if metric.GetGauge() != nil {
+				return metric.GetGauge().GetValue()
+			}
+			return metric.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+func TestRefreshRegistryMetrics(t *testing.T) {
+	config, err := factory.ReadConfig("../nrfTest/nrfcfg.yaml")
+	if err != nil {
+		t.Fatalf("failed to read test configuration: %v", err)
+	}
+	registry := prometheus.NewRegistry()
+	stats, err := metrics.NewNrfStats(registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &RegistryMockMongoDBClient{collections: map[string][]map[string]interface{}{
+		"NfProfile": {
+			{"nfInstanceId": "amf-1", "nfType": "AMF", "nfStatus": "REGISTERED"},
+			{"nfInstanceId": "amf-2", "nfType": "AMF", "nfStatus": "REGISTERED"},
+			{"nfInstanceId": "smf-1", "nfType": "SMF", "nfStatus": "SUSPENDED"},
+		},
+		"Subscriptions": {
+			{"subscriptionId": "1", "reqNfType": "SMF"},
+			{"subscriptionId": "2"},
+		},
+	}}
+	c := nrfContext.New(config, db, logger.New(zap.NewNop()))
+	c.Metrics = stats
+
+	if err = c.RefreshRegistryMetrics(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name     string
+		labels   map[string]string
+		expected float64
+	}{
+		{"nrf_registered_nf_instances", map[string]string{"nf_type": "AMF", "nf_status": "REGISTERED"}, 2},
+		{"nrf_registered_nf_instances", map[string]string{"nf_type": "SMF", "nf_status": "SUSPENDED"}, 1},
+		{"nrf_active_subscriptions", map[string]string{"request_nf_type": "SMF"}, 1},
+		{"nrf_active_subscriptions", map[string]string{"request_nf_type": "UNKNOWN_NF"}, 1},
+	} {
+		if got := metricValue(t, registry, tc.name, tc.labels); got != tc.expected {
+			t.Errorf("%s%v: expected %v, got %v", tc.name, tc.labels, tc.expected, got)
+		}
+	}
+}
+
+func TestRefreshRegistryMetricsReplacesSeries(t *testing.T) {
+	config, err := factory.ReadConfig("../nrfTest/nrfcfg.yaml")
+	if err != nil {
+		t.Fatalf("failed to read test configuration: %v", err)
+	}
+	registry := prometheus.NewRegistry()
+	stats, err := metrics.NewNrfStats(registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &RegistryMockMongoDBClient{collections: map[string][]map[string]interface{}{
+		"NfProfile": {{"nfInstanceId": "amf-1", "nfType": "AMF", "nfStatus": "REGISTERED"}},
+	}}
+	c := nrfContext.New(config, db, logger.New(zap.NewNop()))
+	c.Metrics = stats
+	if err = c.RefreshRegistryMetrics(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the scrapes during the refreshes see the series of amf-1
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 20000 {
+			if err := c.RefreshRegistryMetrics(context.Background()); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	amf := map[string]string{"nf_type": "AMF", "nf_status": "REGISTERED"}
+	for scraping := true; scraping; {
+		select {
+		case <-done:
+			scraping = false
+		default:
+		}
+		if got := metricValue(t, registry, "nrf_registered_nf_instances", amf); got != 1 {
+			t.Fatalf("expected 1 registered AMF instance, got %v", got)
+		}
+	}
+
+	db.collections["NfProfile"] = []map[string]interface{}{
+		{"nfInstanceId": "amf-1", "nfType": "AMF", "nfStatus": "SUSPENDED"},
+	}
+	if err = c.RefreshRegistryMetrics(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() == "nrf_registered_nf_instances" && len(family.GetMetric()) != 1 {
+			t.Errorf("expected the REGISTERED series to be deleted, got %v", family.GetMetric())
+		}
+	}
+	suspended := map[string]string{"nf_type": "AMF", "nf_status": "SUSPENDED"}
+	if got := metricValue(t, registry, "nrf_registered_nf_instances", suspended); got != 1 {
+		t.Errorf("expected 1 suspended AMF instance, got %v", got)
+	}
+}
@@ -0,0 +1,75 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package context
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/omec-project/openapi/models"
+)
+
+// servingPlmnsTtl is the time the serving PLMNs fetched from webconsole are
+// used before being fetched again
+const servingPlmnsTtl = 60 * time.Second
+
+// servingPlmns caches the PLMNs served by the NRF
+type servingPlmns struct {
+	plmns []models.PlmnId
+	// err is the failure of the last fetch
+	err       error
+	fetchedAt time.Time
+}
+
+// ServingPlmns returns the PLMNs served by this NRF, configured in webconsole.
+// They are fetched at most once per minute; when webconsole cannot be
+// reached, the PLMNs last fetched, or else the PLMNs of the NRF profile, are
+// returned. The callers do not wait for a fetch while the PLMNs are fresh.
+func (c *NRFContext) ServingPlmns(ctx context.Context) ([]models.PlmnId, error) {
+	c.servingPlmnsMutex.Lock()
+	cached := c.servingPlmns
+	c.servingPlmnsMutex.Unlock()
+	if time.Since(cached.fetchedAt) >= servingPlmnsTtl {
+		// the fetch is shared by the concurrent callers, so it is not
+		// cancelled with the request of the first of them
+		fetched := c.servingPlmnsFetch.DoChan("", func() (interface{}, error) {
+			return c.fetchServingPlmns(context.WithoutCancel(ctx)), nil
+		})
+		select {
+		case result := <-fetched:
+			cached = result.Val.(servingPlmns)
+		case <-ctx.Done():
+		}
+	}
+	if cached.plmns != nil {
+		return cached.plmns, nil
+	}
+	if profile := c.GetNrfNfProfile(); profile.PlmnList != nil && len(*profile.PlmnList) != 0 {
+		return *profile.PlmnList, nil
+	}
+	return nil, fmt.Errorf("serving PLMNs unknown: %w", cached.err)
+}
+
+// fetchServingPlmns fetches the serving PLMNs from webconsole, without
+// holding the lock of the cache, and returns the cache once updated
+func (c *NRFContext) fetchServingPlmns(ctx context.Context) servingPlmns {
+	plmns, err := c.FetchPlmnConfig(ctx)
+	if err == nil && len(plmns) == 0 {
+		err = fmt.Errorf("no PLMN configured")
+	}
+	if err != nil {
+		c.Log.DiscoveryLog.Warnf("failed to fetch the serving PLMNs: %v", err)
+	}
+
+	c.servingPlmnsMutex.Lock()
+	defer c.servingPlmnsMutex.Unlock()
+	if err == nil {
+		c.servingPlmns.plmns = plmns
+	}
+	c.servingPlmns.err = err
+	c.servingPlmns.fetchedAt = time.Now()
+	return c.servingPlmns
+}
@@ -0,0 +1,80 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package context_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	nrfContext "github.com/omec-project/nrf/context"
+	"github.com/omec-project/nrf/factory"
+	"github.com/omec-project/nrf/logger"
+	"github.com/omec-project/openapi/models"
+	"go.uber.org/zap"
+)
+
+func TestServingPlmnsFetch(t *testing.T) {
+	config, err := factory.ReadConfig("../nrfTest/nrfcfg.yaml")
+	if err != nil {
+		t.Fatalf("failed to read test configuration: %v", err)
+	}
+	c := nrfContext.New(config, &RegistryMockMongoDBClient{}, logger.New(zap.NewNop()))
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	c.FetchPlmnConfig = func(context.Context) ([]models.PlmnId, error) {
+		fetches.Add(1)
+		<-release
+		return []models.PlmnId{{Mcc: "208", Mnc: "93"}}, nil
+	}
+
+	// a caller giving up does not wait for the fetch
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = c.ServingPlmns(ctx)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the cancelled caller not to wait for the fetch")
+	}
+
+	// the concurrent callers share the pending fetch
+	var wg sync.WaitGroup
+	results := make(chan []models.PlmnId, 3)
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			plmns, err := c.ServingPlmns(context.Background())
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			results <- plmns
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+	for plmns := range results {
+		if len(plmns) != 1 || plmns[0].Mnc != "93" {
+			t.Errorf("expected the fetched PLMNs, got %v", plmns)
+		}
+	}
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("expected the PLMNs to be fetched once, got %d fetches", got)
+	}
+
+	// the fresh PLMNs are answered without fetching them again
+	if _, err = c.ServingPlmns(context.Background()); err != nil || fetches.Load() != 1 {
+		t.Errorf("expected the cached PLMNs, got %v after %d fetches", err, fetches.Load())
+	}
+}
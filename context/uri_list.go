@@ -14,6 +14,8 @@ import (
 )
 
 type UriList struct {
-	NfType models.NfType `json:"nfType" bson:"nfType"`
-	Link   Links         `json:"_link" bson:"_link" mapstructure:"_link"`
+	NfType         models.NfType      `json:"nfType,omitempty" bson:"nfType" mapstructure:"nfType"`
+	Link           Links              `json:"_links" bson:"_links" mapstructure:"_links"`
+	TotalItemCount int32              `json:"totalItemCount" bson:"totalItemCount" mapstructure:"totalItemCount"`
+	NfInstances    []models.NfProfile `json:"nfInstances,omitempty" bson:"nfInstances,omitempty" mapstructure:"nfInstances"`
 }
@@ -5,130 +5,316 @@ package dbadapter
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/omec-project/nrf/logger"
+	"github.com/omec-project/nrf/metrics"
 	"github.com/omec-project/util/mongoapi"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
+// DBInterface is the storage of the NRF. Each operation is bounded by the
+// deadline of ctx and gives up when ctx is done.
 type DBInterface interface {
-	RestfulAPIGetOne(collName string, filter bson.M) (map[string]interface{}, error)
-	RestfulAPIGetMany(collName string, filter bson.M) ([]map[string]interface{}, error)
-	RestfulAPIPutOne(collName string, filter bson.M, putData map[string]interface{}) (bool, error)
-	RestfulAPIPutOneNotUpdate(collName string, filter bson.M, putData map[string]interface{}) (bool, error)
-	RestfulAPIDeleteOne(collName string, filter bson.M) error
-	RestfulAPIDeleteMany(collName string, filter bson.M) error
-	RestfulAPIMergePatch(collName string, filter bson.M, patchData map[string]interface{}) error
-	RestfulAPIJSONPatch(collName string, filter bson.M, patchJSON []byte) error
-	RestfulAPIJSONPatchExtend(collName string, filter bson.M, patchJSON []byte, dataName string) error
-	RestfulAPIPost(collName string, filter bson.M, postData map[string]interface{}) (bool, error)
-	RestfulAPIPutMany(collName string, filterArray []primitive.M, putDataArray []map[string]interface{}) error
+	RestfulAPIGetOne(ctx context.Context, collName string, filter bson.M) (map[string]interface{}, error)
+	RestfulAPIGetMany(ctx context.Context, collName string, filter bson.M) ([]map[string]interface{}, error)
+	RestfulAPIPutOne(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}) (bool, error)
+	RestfulAPIPutOneNotUpdate(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}) (bool, error)
+	RestfulAPIReplaceOne(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}) (bool, error)
+	RestfulAPIPutOneIf(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}, upsert bool) (bool, error)
+	RestfulAPIDeleteOne(ctx context.Context, collName string, filter bson.M) error
+	RestfulAPIDeleteOneIf(ctx context.Context, collName string, filter bson.M) (bool, error)
+	RestfulAPIDeleteMany(ctx context.Context, collName string, filter bson.M) error
+	RestfulAPIMergePatch(ctx context.Context, collName string, filter bson.M, patchData map[string]interface{}) error
+	RestfulAPIJSONPatch(ctx context.Context, collName string, filter bson.M, patchJSON []byte) error
+	RestfulAPIJSONPatchExtend(ctx context.Context, collName string, filter bson.M, patchJSON []byte, dataName string) error
+	RestfulAPIPost(ctx context.Context, collName string, filter bson.M, postData map[string]interface{}) (bool, error)
+	RestfulAPIPutMany(ctx context.Context, collName string, filterArray []primitive.M, putDataArray []map[string]interface{}) error
 }
 
-var DBClient DBInterface = nil
+// Pinger is implemented by the storages able to check that they are reachable
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// ChangeNotifier is implemented by the storages reporting the changes of the
+// registry, whichever NRF replica made them
+type ChangeNotifier interface {
+	// OnChange has fn called with the collection of each change of the
+	// NfProfile and Subscriptions collections, and the nfInstanceId of the
+	// document changed when it is known
+	OnChange(fn func(collName, nfInstanceId string))
+}
+
+// changeStreamHistoryLost is the code of the error answered by MongoDB when
+// a change stream cannot be resumed after the given token anymore
+const changeStreamHistoryLost = 286
 
+// MongoDBClient is the MongoDB implementation of DBInterface
 type MongoDBClient struct {
-	mongoapi.MongoClient
+	*mongoapi.MongoClient
+	dbName  string
+	log     *logger.Logger
+	stats   *metrics.NrfStats
+	backoff Backoff
+
+	changeStreamCancel  context.CancelFunc
+	changeStreamDone    chan struct{}
+	changeStreamRunning atomic.Bool
+	transactions        atomic.Int32
+
+	changeHandlersMu sync.RWMutex
+	changeHandlers   []func(collName, nfInstanceId string)
 }
 
-func iterateChangeStream(routineCtx context.Context, stream *mongo.ChangeStream) {
-	logger.AppLog.Infoln("iterate change stream for timeout")
-	defer stream.Close(routineCtx)
-	for stream.Next(routineCtx) {
-		var data bson.M
-		if err := stream.Decode(&data); err != nil {
-			panic(err)
-		}
-		logger.AppLog.Infoln("iterate stream:", data)
+var (
+	_ DBInterface    = (*MongoDBClient)(nil)
+	_ Pinger         = (*MongoDBClient)(nil)
+	_ ChangeNotifier = (*MongoDBClient)(nil)
+)
+
+// NewMongoDBClient creates a client of the dbName database at url. The
+// connection itself is established on first use.
+func NewMongoDBClient(dbName string, url string, stats *metrics.NrfStats, log *logger.Logger) (*MongoDBClient, error) {
+	mongoClient, err := mongoapi.NewMongoClient(url, dbName)
+	if err != nil {
+		log.AppLog.Infoln("MongoDB Connection Failed")
+		return nil, err
 	}
+	log.AppLog.Infoln("MongoDB Connection Successful")
+	return &MongoDBClient{MongoClient: mongoClient, dbName: dbName, log: log, stats: stats, backoff: DefaultBackoff}, nil
 }
 
-func ConnectToDBClient(dbName string, url string, enableStream bool, nfProfileExpiryEnable bool) DBInterface {
-	for {
-		MongoClient, _ := mongoapi.NewMongoClient(url, dbName)
-		if MongoClient != nil {
-			logger.AppLog.Infoln("MongoDB Connection Successful")
-			DBClient = MongoClient
-			break
-		} else {
-			logger.AppLog.Infoln("MongoDB Connection Failed")
-		}
+// Setup waits for MongoDB to be reachable, retrying with backoff until ctx is
+// done. It then creates the NfProfile expiry index and the Leases index, and
+// starts the registry change stream, as enabled in the configuration. The
+// change stream reports the changes of the registry to the OnChange handlers.
+func (db *MongoDBClient) Setup(ctx context.Context, enableStream bool, nfProfileExpiryEnable bool) error {
+	if err := db.waitConnected(ctx); err != nil {
+		return err
 	}
 
-	db := DBClient.(*mongoapi.MongoClient)
-	if enableStream {
-		logger.AppLog.Infoln("MongoDB Change stream Enabled")
-		database := db.Client.Database(dbName)
-		NfProfileColl := database.Collection("NfProfile")
-		// create stream to monitor actions on the collection
-		NfProfStream, err := NfProfileColl.Watch(context.TODO(), mongo.Pipeline{})
-		if err != nil {
-			panic(err)
+	if nfProfileExpiryEnable {
+		db.log.AppLog.Infoln("NfProfile document expiry enabled")
+		if err := db.ensureExpiryIndex(ctx); err != nil {
+			return err
 		}
-		routineCtx, cancel := context.WithCancel(context.Background())
-		defer cancel()
-		// run routine to get messages from stream
-		go iterateChangeStream(routineCtx, NfProfStream)
+		db.log.AppLog.Infoln("index ensured for field 'expireAt' in collection 'NfProfile'")
 	}
 
-	if nfProfileExpiryEnable {
-		logger.AppLog.Infoln("NfProfile document expiry enabled")
-		ttlIndexCreated := db.RestfulAPICreateTTLIndex("NfProfile", 0, "expireAt")
-		ttlIndexStatus := "exists"
-		if ttlIndexCreated {
-			ttlIndexStatus = "created"
-		}
-		logger.AppLog.Infof("ttl Index %s for field 'expireAt' in collection 'NfProfile'", ttlIndexStatus)
+	// the lease of an election is one document, even when replicas acquire it
+	// at once without transaction
+	leaseIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("name"),
+	}
+	if _, err := db.GetCollection("Leases").Indexes().CreateOne(ctx, leaseIndex); err != nil {
+		return fmt.Errorf("failed to create the Leases index: %w", err)
 	}
-	return DBClient
-}
 
-func (db *MongoDBClient) RestfulAPIGetOne(collName string, filter bson.M) (map[string]interface{}, error) {
-	return db.MongoClient.RestfulAPIGetOne(collName, filter)
-}
+	// a shared data is one document, and the NF profiles referring to it are
+	// found when it changes
+	sharedDataIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "sharedDataId", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("sharedDataId"),
+	}
+	if _, err := db.GetCollection("SharedData").Indexes().CreateOne(ctx, sharedDataIndex); err != nil {
+		return fmt.Errorf("failed to create the SharedData index: %w", err)
+	}
+	sharedDataIdListIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "sharedDataIdList", Value: 1}},
+		Options: options.Index().SetName("sharedDataIdList"),
+	}
+	if _, err := db.GetCollection("NfProfile").Indexes().CreateOne(ctx, sharedDataIdListIndex); err != nil {
+		return fmt.Errorf("failed to create the NfProfile sharedDataIdList index: %w", err)
+	}
 
-func (db *MongoDBClient) RestfulAPIGetMany(collName string, filter bson.M) ([]map[string]interface{}, error) {
-	return db.MongoClient.RestfulAPIGetMany(collName, filter)
-}
+	// the profiles mirrored from the peer NRFs are removed once their peer
+	// stopped refreshing them
+	federatedIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expireAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0).SetName("expireAt"),
+	}
+	if _, err := db.GetCollection("FederatedNfProfile").Indexes().CreateOne(ctx, federatedIndex); err != nil {
+		return fmt.Errorf("failed to create the FederatedNfProfile ttl index: %w", err)
+	}
 
-func (db *MongoDBClient) RestfulAPIPutOne(collName string, filter bson.M, putData map[string]interface{}) (bool, error) {
-	return db.MongoClient.RestfulAPIPutOne(collName, filter, putData)
+	if enableStream {
+		db.log.AppLog.Infoln("MongoDB Change stream Enabled")
+		routineCtx, cancel := context.WithCancel(context.Background())
+		db.changeStreamCancel = cancel
+		db.changeStreamDone = make(chan struct{})
+		// run routine to get messages from stream, until Disconnect is called
+		go db.watchChangeStream(routineCtx)
+	}
+	return nil
 }
 
-func (db *MongoDBClient) RestfulAPIPutOneNotUpdate(collName string, filter bson.M, putData map[string]interface{}) (bool, error) {
-	return db.MongoClient.RestfulAPIPutOneNotUpdate(collName, filter, putData)
+// ensureExpiryIndex indexes the expiry time of the NF profiles, which the
+// leader removes once expired. The TTL index of the previous versions is
+// dropped, as MongoDB would remove the profiles without their EXPIRED event.
+func (db *MongoDBClient) ensureExpiryIndex(ctx context.Context) error {
+	indexes := db.GetCollection("NfProfile").Indexes()
+	specifications, err := indexes.ListSpecifications(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list the NfProfile indexes: %w", err)
+	}
+	for _, specification := range specifications {
+		if specification.Name == "expireAt" && specification.ExpireAfterSeconds != nil {
+			if _, err = indexes.DropOne(ctx, specification.Name); err != nil {
+				return fmt.Errorf("failed to drop the NfProfile ttl index: %w", err)
+			}
+		}
+	}
+	index := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expireAt", Value: 1}},
+		Options: options.Index().SetName("expireAt"),
+	}
+	if _, err = indexes.CreateOne(ctx, index); err != nil {
+		return fmt.Errorf("failed to create the NfProfile expiry index: %w", err)
+	}
+	return nil
 }
 
-func (db *MongoDBClient) RestfulAPIPutMany(collName string, filterArray []primitive.M, putDataArray []map[string]interface{}) error {
-	return db.MongoClient.RestfulAPIPutMany(collName, filterArray, putDataArray)
+// waitConnected pings MongoDB until it answers or ctx is done
+func (db *MongoDBClient) waitConnected(ctx context.Context) error {
+	for failures := 1; ; failures++ {
+		pingCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+		err := db.Ping(pingCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		delay := db.backoff.Delay(failures)
+		db.log.AppLog.Warnf("MongoDB not reachable, next attempt in %v: %v", delay, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
 }
 
-func (db *MongoDBClient) RestfulAPIDeleteOne(collName string, filter bson.M) {
-	db.MongoClient.RestfulAPIDeleteOne(collName, filter)
-}
+// watchedCollections are the collections of the registry the change stream
+// reports the changes of
+var watchedCollections = bson.A{"NfProfile", "Subscriptions"}
 
-func (db *MongoDBClient) RestfulAPIDeleteMany(collName string, filter bson.M) {
-	db.MongoClient.RestfulAPIDeleteMany(collName, filter)
+// watchChangeStream iterates the registry change stream until ctx is done.
+// When the stream fails it is opened again with backoff, resuming after the
+// last event seen.
+func (db *MongoDBClient) watchChangeStream(ctx context.Context) {
+	defer close(db.changeStreamDone)
+	var resumeToken bson.Raw
+	for failures := 1; ; failures++ {
+		// the changed documents are looked up for their nfInstanceId only
+		opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+		if resumeToken != nil {
+			opts.SetResumeAfter(resumeToken)
+		}
+		pipeline := mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"ns.coll": bson.M{"$in": watchedCollections}}}},
+			{{Key: "$project", Value: bson.M{"operationType": 1, "ns": 1, "fullDocument.nfInstanceId": 1}}},
+		}
+		stream, err := db.Client.Database(db.dbName).Watch(ctx, pipeline, opts)
+		if err == nil {
+			var seen bool
+			resumeToken, seen, err = db.iterateChangeStream(ctx, stream, resumeToken)
+			if seen {
+				failures = 1
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		var serverErr mongo.ServerError
+		if errors.As(err, &serverErr) && serverErr.HasErrorCode(changeStreamHistoryLost) {
+			db.log.AppLog.Warnln("change stream history lost, events were missed")
+			resumeToken = nil
+		}
+		delay := db.backoff.Delay(failures)
+		db.log.AppLog.Warnf("change stream failed, reopening in %v: %v", delay, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		db.stats.IncrementChangeStreamRestartsStats()
+	}
 }
 
-func (db *MongoDBClient) RestfulAPIMergePatch(collName string, filter bson.M, patchData map[string]interface{}) error {
-	return db.MongoClient.RestfulAPIMergePatch(collName, filter, patchData)
+// iterateChangeStream reports the events of stream to the OnChange handlers
+// until it fails or ctx is done. It returns the resume token of the last event, whether any event was
+// seen and the error of the stream.
+func (db *MongoDBClient) iterateChangeStream(ctx context.Context, stream *mongo.ChangeStream, resumeToken bson.Raw) (bson.Raw, bool, error) {
+	db.log.AppLog.Infoln("iterate change stream for timeout")
+	db.changeStreamRunning.Store(true)
+	defer db.changeStreamRunning.Store(false)
+	// ctx is cancelled on shutdown, close with a fresh one
+	defer stream.Close(context.WithoutCancel(ctx))
+	seen := false
+	for stream.Next(ctx) {
+		seen = true
+		resumeToken = stream.ResumeToken()
+		var event struct {
+			OperationType string `bson:"operationType"`
+			Ns            struct {
+				Coll string `bson:"coll"`
+			} `bson:"ns"`
+			FullDocument struct {
+				NfInstanceId string `bson:"nfInstanceId"`
+			} `bson:"fullDocument"`
+		}
+		if err := stream.Decode(&event); err != nil {
+			db.log.AppLog.Warnf("failed to decode change stream event: %v", err)
+			continue
+		}
+		db.log.AppLog.Debugf("change stream event: %s %s", event.OperationType, event.Ns.Coll)
+		db.changeHandlersMu.RLock()
+		for _, handler := range db.changeHandlers {
+			handler(event.Ns.Coll, event.FullDocument.NfInstanceId)
+		}
+		db.changeHandlersMu.RUnlock()
+	}
+	return resumeToken, seen, stream.Err()
 }
 
-func (db *MongoDBClient) RestfulAPIJSONPatch(collName string, filter bson.M, patchJSON []byte) error {
-	return db.MongoClient.RestfulAPIJSONPatch(collName, filter, patchJSON)
+// OnChange has fn called with the collection of each change of the registry
+// seen by the change stream, and the nfInstanceId of the document changed
+// unless it was deleted. fn must not block.
+func (db *MongoDBClient) OnChange(fn func(collName, nfInstanceId string)) {
+	db.changeHandlersMu.Lock()
+	defer db.changeHandlersMu.Unlock()
+	db.changeHandlers = append(db.changeHandlers, fn)
 }
 
-func (db *MongoDBClient) RestfulAPIJSONPatchExtend(collName string, filter bson.M, patchJSON []byte, dataName string) error {
-	return db.MongoClient.RestfulAPIJSONPatchExtend(collName, filter, patchJSON, dataName)
+// Ping checks that the MongoDB primary is reachable
+func (db *MongoDBClient) Ping(ctx context.Context) error {
+	return db.Client.Ping(ctx, readpref.Primary())
 }
 
-func (db *MongoDBClient) RestfulAPIPost(collName string, filter bson.M, postData map[string]interface{}) (bool, error) {
-	return db.MongoClient.RestfulAPIPost(collName, filter, postData)
+// ChangeStreamAlive reports whether the change stream is currently being
+// iterated
+func (db *MongoDBClient) ChangeStreamAlive() bool {
+	return db.changeStreamRunning.Load()
 }
 
-func (db *MongoDBClient) RestfulAPIPostMany(collName string, filter bson.M, postDataArray []interface{}) error {
-	return db.MongoClient.RestfulAPIPostMany(collName, filter, postDataArray)
+// Disconnect stops the change stream routine and closes the MongoDB connection
+func (db *MongoDBClient) Disconnect(ctx context.Context) error {
+	if db.changeStreamCancel != nil {
+		db.changeStreamCancel()
+		select {
+		case <-db.changeStreamDone:
+		case <-ctx.Done():
+			db.log.AppLog.Warnln("change stream routine did not stop before the deadline")
+		}
+	}
+	return db.Client.Disconnect(ctx)
 }
@@ -0,0 +1,125 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package dbadapter
+
+import (
+	"context"
+	"time"
+
+	"github.com/omec-project/nrf/factory"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// deadlineDB bounds the operations of a storage with the default timeout of
+// their class. The deadline of their ctx still applies when it is earlier.
+type deadlineDB struct {
+	db       DBInterface
+	timeouts factory.StorageTimeouts
+}
+
+// NewDeadlineDB returns db bounding each of its operations with the timeout
+// of its class in timeouts. A class without timeout is not bounded.
+func NewDeadlineDB(db DBInterface, timeouts factory.StorageTimeouts) DBInterface {
+	return &deadlineDB{db: db, timeouts: timeouts}
+}
+
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func (d *deadlineDB) RestfulAPIGetOne(ctx context.Context, collName string, filter bson.M) (map[string]interface{}, error) {
+	ctx, cancel := withTimeout(ctx, d.timeouts.Read)
+	defer cancel()
+	return d.db.RestfulAPIGetOne(ctx, collName, filter)
+}
+
+func (d *deadlineDB) RestfulAPIGetMany(ctx context.Context, collName string, filter bson.M) ([]map[string]interface{}, error) {
+	ctx, cancel := withTimeout(ctx, d.timeouts.Read)
+	defer cancel()
+	return d.db.RestfulAPIGetMany(ctx, collName, filter)
+}
+
+func (d *deadlineDB) RestfulAPIPutOne(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}) (bool, error) {
+	ctx, cancel := withTimeout(ctx, d.timeouts.Write)
+	defer cancel()
+	return d.db.RestfulAPIPutOne(ctx, collName, filter, putData)
+}
+
+func (d *deadlineDB) RestfulAPIPutOneNotUpdate(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}) (bool, error) {
+	ctx, cancel := withTimeout(ctx, d.timeouts.Write)
+	defer cancel()
+	return d.db.RestfulAPIPutOneNotUpdate(ctx, collName, filter, putData)
+}
+
+func (d *deadlineDB) RestfulAPIReplaceOne(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}) (bool, error) {
+	ctx, cancel := withTimeout(ctx, d.timeouts.Write)
+	defer cancel()
+	return d.db.RestfulAPIReplaceOne(ctx, collName, filter, putData)
+}
+
+func (d *deadlineDB) RestfulAPIPutOneIf(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}, upsert bool) (bool, error) {
+	ctx, cancel := withTimeout(ctx, d.timeouts.Write)
+	defer cancel()
+	return d.db.RestfulAPIPutOneIf(ctx, collName, filter, putData, upsert)
+}
+
+func (d *deadlineDB) RestfulAPIDeleteOne(ctx context.Context, collName string, filter bson.M) error {
+	ctx, cancel := withTimeout(ctx, d.timeouts.Write)
+	defer cancel()
+	return d.db.RestfulAPIDeleteOne(ctx, collName, filter)
+}
+
+func (d *deadlineDB) RestfulAPIDeleteOneIf(ctx context.Context, collName string, filter bson.M) (bool, error) {
+	ctx, cancel := withTimeout(ctx, d.timeouts.Write)
+	defer cancel()
+	return d.db.RestfulAPIDeleteOneIf(ctx, collName, filter)
+}
+
+func (d *deadlineDB) RestfulAPIDeleteMany(ctx context.Context, collName string, filter bson.M) error {
+	ctx, cancel := withTimeout(ctx, d.timeouts.Bulk)
+	defer cancel()
+	return d.db.RestfulAPIDeleteMany(ctx, collName, filter)
+}
+
+func (d *deadlineDB) RestfulAPIMergePatch(ctx context.Context, collName string, filter bson.M, patchData map[string]interface{}) error {
+	ctx, cancel := withTimeout(ctx, d.timeouts.Write)
+	defer cancel()
+	return d.db.RestfulAPIMergePatch(ctx, collName, filter, patchData)
+}
+
+func (d *deadlineDB) RestfulAPIJSONPatch(ctx context.Context, collName string, filter bson.M, patchJSON []byte) error {
+	ctx, cancel := withTimeout(ctx, d.timeouts.Write)
+	defer cancel()
+	return d.db.RestfulAPIJSONPatch(ctx, collName, filter, patchJSON)
+}
+
+func (d *deadlineDB) RestfulAPIJSONPatchExtend(ctx context.Context, collName string, filter bson.M, patchJSON []byte, dataName string) error {
+	ctx, cancel := withTimeout(ctx, d.timeouts.Write)
+	defer cancel()
+	return d.db.RestfulAPIJSONPatchExtend(ctx, collName, filter, patchJSON, dataName)
+}
+
+func (d *deadlineDB) RestfulAPIPost(ctx context.Context, collName string, filter bson.M, postData map[string]interface{}) (bool, error) {
+	ctx, cancel := withTimeout(ctx, d.timeouts.Write)
+	defer cancel()
+	return d.db.RestfulAPIPost(ctx, collName, filter, postData)
+}
+
+func (d *deadlineDB) RestfulAPIPutMany(ctx context.Context, collName string, filterArray []primitive.M, putDataArray []map[string]interface{}) error {
+	ctx, cancel := withTimeout(ctx, d.timeouts.Bulk)
+	defer cancel()
+	return d.db.RestfulAPIPutMany(ctx, collName, filterArray, putDataArray)
+}
+
+// RunTransaction bounds the whole transaction with the bulk timeout
+func (d *deadlineDB) RunTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	ctx, cancel := withTimeout(ctx, d.timeouts.Bulk)
+	defer cancel()
+	return RunTransaction(ctx, d.db, fn)
+}
@@ -0,0 +1,168 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package dbadapter
+
+import (
+	"context"
+	"time"
+
+	"github.com/omec-project/nrf/metrics"
+	"github.com/omec-project/nrf/tracing"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentedDB records the duration of the operations of a storage, and
+// traces them as children of the span of their ctx
+type instrumentedDB struct {
+	db     DBInterface
+	stats  *metrics.NrfStats
+	tracer trace.Tracer
+}
+
+// NewInstrumentedDB returns db recording the duration of each of its
+// operations in stats and tracing them with tracerProvider
+func NewInstrumentedDB(db DBInterface, stats *metrics.NrfStats, tracerProvider trace.TracerProvider) DBInterface {
+	return &instrumentedDB{
+		db:     db,
+		stats:  stats,
+		tracer: tracerProvider.Tracer(tracing.InstrumentationName),
+	}
+}
+
+func (i *instrumentedDB) start(ctx context.Context, operation, collName string) (context.Context, trace.Span, time.Time) {
+	name := operation
+	if collName != "" {
+		name += " " + collName
+	}
+	ctx, span := i.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system.name", "mongodb"),
+			attribute.String("db.operation.name", operation),
+			attribute.String("db.collection.name", collName),
+		))
+	return ctx, span, time.Now()
+}
+
+func (i *instrumentedDB) observe(span trace.Span, operation, collName string, start time.Time, err error) {
+	result := "SUCCESS"
+	if err != nil {
+		result = "FAILURE"
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+	i.stats.ObserveStorageOperation(operation, collName, result, time.Since(start))
+}
+
+func (i *instrumentedDB) RestfulAPIGetOne(ctx context.Context, collName string, filter bson.M) (map[string]interface{}, error) {
+	ctx, span, start := i.start(ctx, "GetOne", collName)
+	result, err := i.db.RestfulAPIGetOne(ctx, collName, filter)
+	i.observe(span, "GetOne", collName, start, err)
+	return result, err
+}
+
+func (i *instrumentedDB) RestfulAPIGetMany(ctx context.Context, collName string, filter bson.M) ([]map[string]interface{}, error) {
+	ctx, span, start := i.start(ctx, "GetMany", collName)
+	result, err := i.db.RestfulAPIGetMany(ctx, collName, filter)
+	i.observe(span, "GetMany", collName, start, err)
+	return result, err
+}
+
+func (i *instrumentedDB) RestfulAPIPutOne(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}) (bool, error) {
+	ctx, span, start := i.start(ctx, "PutOne", collName)
+	existed, err := i.db.RestfulAPIPutOne(ctx, collName, filter, putData)
+	i.observe(span, "PutOne", collName, start, err)
+	return existed, err
+}
+
+func (i *instrumentedDB) RestfulAPIPutOneNotUpdate(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}) (bool, error) {
+	ctx, span, start := i.start(ctx, "PutOneNotUpdate", collName)
+	existed, err := i.db.RestfulAPIPutOneNotUpdate(ctx, collName, filter, putData)
+	i.observe(span, "PutOneNotUpdate", collName, start, err)
+	return existed, err
+}
+
+func (i *instrumentedDB) RestfulAPIReplaceOne(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}) (bool, error) {
+	ctx, span, start := i.start(ctx, "ReplaceOne", collName)
+	existed, err := i.db.RestfulAPIReplaceOne(ctx, collName, filter, putData)
+	i.observe(span, "ReplaceOne", collName, start, err)
+	return existed, err
+}
+
+func (i *instrumentedDB) RestfulAPIPutOneIf(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}, upsert bool) (bool, error) {
+	ctx, span, start := i.start(ctx, "PutOneIf", collName)
+	updated, err := i.db.RestfulAPIPutOneIf(ctx, collName, filter, putData, upsert)
+	i.observe(span, "PutOneIf", collName, start, err)
+	return updated, err
+}
+
+func (i *instrumentedDB) RestfulAPIDeleteOne(ctx context.Context, collName string, filter bson.M) error {
+	ctx, span, start := i.start(ctx, "DeleteOne", collName)
+	err := i.db.RestfulAPIDeleteOne(ctx, collName, filter)
+	i.observe(span, "DeleteOne", collName, start, err)
+	return err
+}
+
+func (i *instrumentedDB) RestfulAPIDeleteOneIf(ctx context.Context, collName string, filter bson.M) (bool, error) {
+	ctx, span, start := i.start(ctx, "DeleteOneIf", collName)
+	deleted, err := i.db.RestfulAPIDeleteOneIf(ctx, collName, filter)
+	i.observe(span, "DeleteOneIf", collName, start, err)
+	return deleted, err
+}
+
+func (i *instrumentedDB) RestfulAPIDeleteMany(ctx context.Context, collName string, filter bson.M) error {
+	ctx, span, start := i.start(ctx, "DeleteMany", collName)
+	err := i.db.RestfulAPIDeleteMany(ctx, collName, filter)
+	i.observe(span, "DeleteMany", collName, start, err)
+	return err
+}
+
+func (i *instrumentedDB) RestfulAPIMergePatch(ctx context.Context, collName string, filter bson.M, patchData map[string]interface{}) error {
+	ctx, span, start := i.start(ctx, "MergePatch", collName)
+	err := i.db.RestfulAPIMergePatch(ctx, collName, filter, patchData)
+	i.observe(span, "MergePatch", collName, start, err)
+	return err
+}
+
+func (i *instrumentedDB) RestfulAPIJSONPatch(ctx context.Context, collName string, filter bson.M, patchJSON []byte) error {
+	ctx, span, start := i.start(ctx, "JSONPatch", collName)
+	err := i.db.RestfulAPIJSONPatch(ctx, collName, filter, patchJSON)
+	i.observe(span, "JSONPatch", collName, start, err)
+	return err
+}
+
+func (i *instrumentedDB) RestfulAPIJSONPatchExtend(ctx context.Context, collName string, filter bson.M, patchJSON []byte, dataName string) error {
+	ctx, span, start := i.start(ctx, "JSONPatchExtend", collName)
+	err := i.db.RestfulAPIJSONPatchExtend(ctx, collName, filter, patchJSON, dataName)
+	i.observe(span, "JSONPatchExtend", collName, start, err)
+	return err
+}
+
+func (i *instrumentedDB) RestfulAPIPost(ctx context.Context, collName string, filter bson.M, postData map[string]interface{}) (bool, error) {
+	ctx, span, start := i.start(ctx, "Post", collName)
+	existed, err := i.db.RestfulAPIPost(ctx, collName, filter, postData)
+	i.observe(span, "Post", collName, start, err)
+	return existed, err
+}
+
+func (i *instrumentedDB) RestfulAPIPutMany(ctx context.Context, collName string, filterArray []primitive.M, putDataArray []map[string]interface{}) error {
+	ctx, span, start := i.start(ctx, "PutMany", collName)
+	err := i.db.RestfulAPIPutMany(ctx, collName, filterArray, putDataArray)
+	i.observe(span, "PutMany", collName, start, err)
+	return err
+}
+
+// RunTransaction traces the transaction as the parent of its operations
+func (i *instrumentedDB) RunTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	ctx, span, start := i.start(ctx, "Transaction", "")
+	err := RunTransaction(ctx, i.db, fn)
+	i.observe(span, "Transaction", "", start, err)
+	return err
+}
@@ -0,0 +1,245 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package dbadapter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The operations of MongoDBClient wrap the errors of the driver, so that
+// callers can tell an expired or cancelled ctx with errors.Is
+
+// findOne returns the document matching filter without its "_id", nil when
+// there is none
+func (db *MongoDBClient) findOne(ctx context.Context, collName string, filter bson.M) (map[string]interface{}, error) {
+	var result map[string]interface{}
+	if err := db.GetCollection(collName).FindOne(ctx, filter).Decode(&result); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	// Delete "_id" entry which is auto-inserted by MongoDB
+	delete(result, "_id")
+	return result, nil
+}
+
+func (db *MongoDBClient) RestfulAPIGetOne(ctx context.Context, collName string, filter bson.M) (map[string]interface{}, error) {
+	result, err := db.findOne(ctx, collName, filter)
+	if err != nil {
+		return nil, fmt.Errorf("RestfulAPIGetOne err: %w", err)
+	}
+	return result, nil
+}
+
+func (db *MongoDBClient) RestfulAPIGetMany(ctx context.Context, collName string, filter bson.M) ([]map[string]interface{}, error) {
+	cur, err := db.GetCollection(collName).Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("RestfulAPIGetMany err: %w", err)
+	}
+	// close even when ctx is done, not to leak the server cursor
+	defer cur.Close(context.WithoutCancel(ctx))
+
+	var resultArray []map[string]interface{}
+	for cur.Next(ctx) {
+		var result map[string]interface{}
+		if err := cur.Decode(&result); err != nil {
+			return nil, fmt.Errorf("RestfulAPIGetMany err: %w", err)
+		}
+		// Delete "_id" entry which is auto-inserted by MongoDB
+		delete(result, "_id")
+		resultArray = append(resultArray, result)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, fmt.Errorf("RestfulAPIGetMany err: %w", err)
+	}
+	return resultArray, nil
+}
+
+// RestfulAPIPutOne sets putData in the document matching filter, inserting
+// it when there is none. It returns whether the document existed.
+func (db *MongoDBClient) RestfulAPIPutOne(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}) (bool, error) {
+	result, err := db.GetCollection(collName).UpdateOne(ctx, filter, bson.M{"$set": putData},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return false, fmt.Errorf("RestfulAPIPutOne err: %w", err)
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// RestfulAPIPutOneNotUpdate inserts putData when no document matches filter.
+// It returns whether the document existed, in which case it is left as is.
+func (db *MongoDBClient) RestfulAPIPutOneNotUpdate(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}) (bool, error) {
+	result, err := db.GetCollection(collName).UpdateOne(ctx, filter, bson.M{"$setOnInsert": putData},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return false, fmt.Errorf("RestfulAPIPutOneNotUpdate err: %w", err)
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// RestfulAPIReplaceOne replaces the document matching filter with putData,
+// inserting it when there is none, so that the fields putData does not have
+// are removed. It returns whether the document existed.
+func (db *MongoDBClient) RestfulAPIReplaceOne(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}) (bool, error) {
+	result, err := db.GetCollection(collName).ReplaceOne(ctx, filter, putData, options.Replace().SetUpsert(true))
+	if err != nil {
+		return false, fmt.Errorf("RestfulAPIReplaceOne err: %w", err)
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// RestfulAPIPutOneIf sets the fields of putData on the document matching
+// filter, inserting it when upsert is set and none matches. The condition and
+// the update are atomic. It returns whether a document was updated or
+// inserted, which it was not when the insert conflicts with a unique index.
+func (db *MongoDBClient) RestfulAPIPutOneIf(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}, upsert bool) (bool, error) {
+	result, err := db.GetCollection(collName).UpdateOne(ctx, filter, bson.M{"$set": putData},
+		options.Update().SetUpsert(upsert))
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("RestfulAPIPutOneIf err: %w", err)
+	}
+	return result.MatchedCount > 0 || result.UpsertedCount > 0, nil
+}
+
+func (db *MongoDBClient) RestfulAPIDeleteOne(ctx context.Context, collName string, filter bson.M) error {
+	if _, err := db.GetCollection(collName).DeleteOne(ctx, filter); err != nil {
+		return fmt.Errorf("RestfulAPIDeleteOne err: %w", err)
+	}
+	return nil
+}
+
+// RestfulAPIDeleteOneIf removes the document matching filter. The condition
+// and the removal are atomic. It returns whether a document was removed.
+func (db *MongoDBClient) RestfulAPIDeleteOneIf(ctx context.Context, collName string, filter bson.M) (bool, error) {
+	result, err := db.GetCollection(collName).DeleteOne(ctx, filter)
+	if err != nil {
+		return false, fmt.Errorf("RestfulAPIDeleteOneIf err: %w", err)
+	}
+	return result.DeletedCount > 0, nil
+}
+
+func (db *MongoDBClient) RestfulAPIDeleteMany(ctx context.Context, collName string, filter bson.M) error {
+	if _, err := db.GetCollection(collName).DeleteMany(ctx, filter); err != nil {
+		return fmt.Errorf("RestfulAPIDeleteMany err: %w", err)
+	}
+	return nil
+}
+
+// patchOne applies patch to the field dataName of the document matching
+// filter, or to the whole document when dataName is empty
+func (db *MongoDBClient) patchOne(ctx context.Context, collName string, filter bson.M, dataName string,
+	patch func(original []byte) ([]byte, error),
+) error {
+	originalData, err := db.findOne(ctx, collName, filter)
+	if err != nil {
+		return err
+	}
+	var original []byte
+	if dataName == "" {
+		original, err = json.Marshal(originalData)
+	} else {
+		original, err = json.Marshal(originalData[dataName])
+	}
+	if err != nil {
+		return err
+	}
+	modified, err := patch(original)
+	if err != nil {
+		return err
+	}
+	var modifiedData map[string]interface{}
+	if err = json.Unmarshal(modified, &modifiedData); err != nil {
+		return err
+	}
+	update := bson.M{"$set": modifiedData}
+	if dataName != "" {
+		update = bson.M{"$set": bson.M{dataName: modifiedData}}
+	}
+	_, err = db.GetCollection(collName).UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (db *MongoDBClient) RestfulAPIMergePatch(ctx context.Context, collName string, filter bson.M, patchData map[string]interface{}) error {
+	patchJSON, err := json.Marshal(patchData)
+	if err != nil {
+		return fmt.Errorf("RestfulAPIMergePatch err: %w", err)
+	}
+	err = db.patchOne(ctx, collName, filter, "", func(original []byte) ([]byte, error) {
+		return jsonpatch.MergePatch(original, patchJSON)
+	})
+	if err != nil {
+		return fmt.Errorf("RestfulAPIMergePatch err: %w", err)
+	}
+	return nil
+}
+
+func (db *MongoDBClient) RestfulAPIJSONPatch(ctx context.Context, collName string, filter bson.M, patchJSON []byte) error {
+	if err := db.patchOne(ctx, collName, filter, "", jsonPatch(patchJSON)); err != nil {
+		return fmt.Errorf("RestfulAPIJSONPatch err: %w", err)
+	}
+	return nil
+}
+
+func (db *MongoDBClient) RestfulAPIJSONPatchExtend(ctx context.Context, collName string, filter bson.M, patchJSON []byte, dataName string) error {
+	if err := db.patchOne(ctx, collName, filter, dataName, jsonPatch(patchJSON)); err != nil {
+		return fmt.Errorf("RestfulAPIJSONPatchExtend err: %w", err)
+	}
+	return nil
+}
+
+func jsonPatch(patchJSON []byte) func(original []byte) ([]byte, error) {
+	return func(original []byte) ([]byte, error) {
+		patch, err := jsonpatch.DecodePatch(patchJSON)
+		if err != nil {
+			return nil, err
+		}
+		return patch.Apply(original)
+	}
+}
+
+func (db *MongoDBClient) RestfulAPIPost(ctx context.Context, collName string, filter bson.M, postData map[string]interface{}) (bool, error) {
+	return db.RestfulAPIPutOne(ctx, collName, filter, postData)
+}
+
+func (db *MongoDBClient) RestfulAPIPutMany(ctx context.Context, collName string, filterArray []primitive.M, putDataArray []map[string]interface{}) error {
+	for i, putData := range putDataArray {
+		if _, err := db.RestfulAPIPutOne(ctx, collName, filterArray[i], putData); err != nil {
+			return fmt.Errorf("RestfulAPIPutMany err: %w", err)
+		}
+	}
+	return nil
+}
+
+// ToTime converts a date read from the storage, which is a BSON date, a
+// time.Time or an RFC 3339 string depending on the storage and its test
+// doubles. It returns the zero time for any other value.
+func ToTime(value interface{}) time.Time {
+	switch t := value.(type) {
+	case primitive.DateTime:
+		return t.Time()
+	case time.Time:
+		return t
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, t)
+		if err == nil {
+			return parsed
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,36 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package dbadapter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/omec-project/nrf/dbadapter"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestToTime(t *testing.T) {
+	date := time.Date(2025, 6, 1, 12, 30, 15, 250_000_000, time.UTC)
+	testCases := []struct {
+		name     string
+		value    interface{}
+		expected time.Time
+	}{
+		{name: "BSON date", value: primitive.NewDateTimeFromTime(date), expected: date},
+		{name: "time", value: date, expected: date},
+		{name: "RFC 3339 with fractional seconds", value: "2025-06-01T12:30:15.25Z", expected: date},
+		{name: "RFC 3339", value: "2025-06-01T12:30:15Z", expected: date.Truncate(time.Second)},
+		{name: "malformed string", value: "tomorrow", expected: time.Time{}},
+		{name: "missing", value: nil, expected: time.Time{}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := dbadapter.ToTime(tc.value); !got.Equal(tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
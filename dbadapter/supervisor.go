@@ -0,0 +1,345 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package dbadapter
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/omec-project/nrf/logger"
+	"github.com/omec-project/nrf/metrics"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// probeInterval is the time between two connection probes while the
+	// storage is connected
+	probeInterval = 5 * time.Second
+	// probeTimeout bounds the time the storage has to answer a probe
+	probeTimeout = 2 * time.Second
+)
+
+// ErrStorageUnavailable is returned, without reaching the storage, while the
+// storage is known to be down
+var ErrStorageUnavailable = errors.New("storage unavailable")
+
+// ConnectionState is the state of the connection to the storage
+type ConnectionState string
+
+const (
+	// StateConnecting is the state until the storage answers, or fails, a
+	// first probe
+	StateConnecting   ConnectionState = "CONNECTING"
+	StateConnected    ConnectionState = "CONNECTED"
+	StateDisconnected ConnectionState = "DISCONNECTED"
+)
+
+// Backoff is an exponential backoff between attempts to reach the storage
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+// DefaultBackoff retries after 0.5s, then doubles the delay up to 30s
+var DefaultBackoff = Backoff{Initial: 500 * time.Millisecond, Max: 30 * time.Second}
+
+// Delay returns the delay before the next attempt, after failures
+// consecutive failed attempts. It is jittered between half and all of the
+// exponential delay, so that replicas do not retry in lockstep.
+func (b Backoff) Delay(failures int) time.Duration {
+	delay := b.Initial
+	for i := 1; i < failures && delay < b.Max; i++ {
+		delay *= 2
+	}
+	if delay > b.Max {
+		delay = b.Max
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// Supervisor probes the connection to the storage: periodically while it is
+// connected, with backoff while it is down. It is also a circuit breaker:
+// while the storage is down the breaker is open, and the supervised storage
+// fails its operations without reaching the storage until a probe succeeds.
+type Supervisor struct {
+	pinger  Pinger
+	backoff Backoff
+	stats   *metrics.NrfStats
+	log     *logger.Logger
+	wake    chan struct{}
+
+	mu        sync.RWMutex
+	state     ConnectionState
+	since     time.Time
+	lastErr   error
+	failures  int
+	nextProbe time.Time
+}
+
+// NewSupervisor creates the supervisor of the storage reached by pinger. It
+// does not probe the storage until Run is called.
+func NewSupervisor(pinger Pinger, backoff Backoff, stats *metrics.NrfStats, log *logger.Logger) *Supervisor {
+	return &Supervisor{
+		pinger:  pinger,
+		backoff: backoff,
+		stats:   stats,
+		log:     log,
+		wake:    make(chan struct{}, 1),
+		state:   StateConnecting,
+		since:   time.Now(),
+	}
+}
+
+// Run probes the storage until stop is closed
+func (s *Supervisor) Run(stop <-chan struct{}) {
+	for {
+		timer := time.NewTimer(s.probe())
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		case <-s.wake:
+			timer.Stop()
+		}
+	}
+}
+
+// probe pings the storage, updates the connection state and returns the
+// delay before the next probe
+func (s *Supervisor) probe() time.Duration {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	err := s.pinger.Ping(ctx)
+	cancel()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.stats.SetStorageConnected(err == nil)
+	if err == nil {
+		if s.state != StateConnected {
+			s.log.AppLog.Infoln("storage connected")
+			if s.state == StateDisconnected {
+				s.stats.IncrementStorageReconnectionsStats()
+			}
+			s.state = StateConnected
+			s.since = now
+		}
+		s.failures = 0
+		s.lastErr = nil
+		s.nextProbe = now.Add(probeInterval)
+		return probeInterval
+	}
+
+	if s.state != StateDisconnected {
+		s.state = StateDisconnected
+		s.since = now
+	}
+	s.failures++
+	s.lastErr = err
+	delay := s.backoff.Delay(s.failures)
+	s.nextProbe = now.Add(delay)
+	s.log.AppLog.Warnf("storage not reachable, next attempt in %v: %v", delay, err)
+	return delay
+}
+
+// Wake asks for an immediate probe, unless the storage is already known to be
+// down, in which case the backoff applies
+func (s *Supervisor) Wake() {
+	if state, _, _ := s.Status(); state == StateDisconnected {
+		return
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Status returns the state of the connection, since when it is in that
+// state and, when disconnected, the error of the last probe
+func (s *Supervisor) Status() (ConnectionState, time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state, s.since, s.lastErr
+}
+
+// Open reports whether the circuit breaker is open, and when it is the time
+// until the storage is probed again
+func (s *Supervisor) Open() (bool, time.Duration) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.state != StateDisconnected {
+		return false, 0
+	}
+	return true, max(time.Until(s.nextProbe), 0)
+}
+
+// allow fails fast while the circuit breaker is open
+func (s *Supervisor) allow() error {
+	if open, _ := s.Open(); open {
+		return ErrStorageUnavailable
+	}
+	return nil
+}
+
+// report has the storage probed when an operation failed for another reason
+// than its request being cancelled
+func (s *Supervisor) report(err error) {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, ErrStorageUnavailable) {
+		return
+	}
+	s.Wake()
+}
+
+// supervisedDB fails the operations of a storage fast while its supervisor
+// reports it down, and reports their failures to the supervisor
+type supervisedDB struct {
+	db         DBInterface
+	supervisor *Supervisor
+}
+
+// NewSupervisedDB returns db guarded by the circuit breaker of supervisor
+func NewSupervisedDB(db DBInterface, supervisor *Supervisor) DBInterface {
+	return &supervisedDB{db: db, supervisor: supervisor}
+}
+
+func (d *supervisedDB) RestfulAPIGetOne(ctx context.Context, collName string, filter bson.M) (map[string]interface{}, error) {
+	if err := d.supervisor.allow(); err != nil {
+		return nil, err
+	}
+	result, err := d.db.RestfulAPIGetOne(ctx, collName, filter)
+	d.supervisor.report(err)
+	return result, err
+}
+
+func (d *supervisedDB) RestfulAPIGetMany(ctx context.Context, collName string, filter bson.M) ([]map[string]interface{}, error) {
+	if err := d.supervisor.allow(); err != nil {
+		return nil, err
+	}
+	result, err := d.db.RestfulAPIGetMany(ctx, collName, filter)
+	d.supervisor.report(err)
+	return result, err
+}
+
+func (d *supervisedDB) RestfulAPIPutOne(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}) (bool, error) {
+	if err := d.supervisor.allow(); err != nil {
+		return false, err
+	}
+	existed, err := d.db.RestfulAPIPutOne(ctx, collName, filter, putData)
+	d.supervisor.report(err)
+	return existed, err
+}
+
+func (d *supervisedDB) RestfulAPIPutOneNotUpdate(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}) (bool, error) {
+	if err := d.supervisor.allow(); err != nil {
+		return false, err
+	}
+	existed, err := d.db.RestfulAPIPutOneNotUpdate(ctx, collName, filter, putData)
+	d.supervisor.report(err)
+	return existed, err
+}
+
+func (d *supervisedDB) RestfulAPIReplaceOne(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}) (bool, error) {
+	if err := d.supervisor.allow(); err != nil {
+		return false, err
+	}
+	existed, err := d.db.RestfulAPIReplaceOne(ctx, collName, filter, putData)
+	d.supervisor.report(err)
+	return existed, err
+}
+
+func (d *supervisedDB) RestfulAPIPutOneIf(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}, upsert bool) (bool, error) {
+	if err := d.supervisor.allow(); err != nil {
+		return false, err
+	}
+	updated, err := d.db.RestfulAPIPutOneIf(ctx, collName, filter, putData, upsert)
+	d.supervisor.report(err)
+	return updated, err
+}
+
+func (d *supervisedDB) RestfulAPIDeleteOne(ctx context.Context, collName string, filter bson.M) error {
+	if err := d.supervisor.allow(); err != nil {
+		return err
+	}
+	err := d.db.RestfulAPIDeleteOne(ctx, collName, filter)
+	d.supervisor.report(err)
+	return err
+}
+
+func (d *supervisedDB) RestfulAPIDeleteOneIf(ctx context.Context, collName string, filter bson.M) (bool, error) {
+	if err := d.supervisor.allow(); err != nil {
+		return false, err
+	}
+	deleted, err := d.db.RestfulAPIDeleteOneIf(ctx, collName, filter)
+	d.supervisor.report(err)
+	return deleted, err
+}
+
+func (d *supervisedDB) RestfulAPIDeleteMany(ctx context.Context, collName string, filter bson.M) error {
+	if err := d.supervisor.allow(); err != nil {
+		return err
+	}
+	err := d.db.RestfulAPIDeleteMany(ctx, collName, filter)
+	d.supervisor.report(err)
+	return err
+}
+
+func (d *supervisedDB) RestfulAPIMergePatch(ctx context.Context, collName string, filter bson.M, patchData map[string]interface{}) error {
+	if err := d.supervisor.allow(); err != nil {
+		return err
+	}
+	err := d.db.RestfulAPIMergePatch(ctx, collName, filter, patchData)
+	d.supervisor.report(err)
+	return err
+}
+
+func (d *supervisedDB) RestfulAPIJSONPatch(ctx context.Context, collName string, filter bson.M, patchJSON []byte) error {
+	if err := d.supervisor.allow(); err != nil {
+		return err
+	}
+	err := d.db.RestfulAPIJSONPatch(ctx, collName, filter, patchJSON)
+	d.supervisor.report(err)
+	return err
+}
+
+func (d *supervisedDB) RestfulAPIJSONPatchExtend(ctx context.Context, collName string, filter bson.M, patchJSON []byte, dataName string) error {
+	if err := d.supervisor.allow(); err != nil {
+		return err
+	}
+	err := d.db.RestfulAPIJSONPatchExtend(ctx, collName, filter, patchJSON, dataName)
+	d.supervisor.report(err)
+	return err
+}
+
+func (d *supervisedDB) RestfulAPIPost(ctx context.Context, collName string, filter bson.M, postData map[string]interface{}) (bool, error) {
+	if err := d.supervisor.allow(); err != nil {
+		return false, err
+	}
+	existed, err := d.db.RestfulAPIPost(ctx, collName, filter, postData)
+	d.supervisor.report(err)
+	return existed, err
+}
+
+func (d *supervisedDB) RestfulAPIPutMany(ctx context.Context, collName string, filterArray []primitive.M, putDataArray []map[string]interface{}) error {
+	if err := d.supervisor.allow(); err != nil {
+		return err
+	}
+	err := d.db.RestfulAPIPutMany(ctx, collName, filterArray, putDataArray)
+	d.supervisor.report(err)
+	return err
+}
+
+func (d *supervisedDB) RunTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := d.supervisor.allow(); err != nil {
+		return err
+	}
+	err := RunTransaction(ctx, d.db, fn)
+	d.supervisor.report(err)
+	return err
+}
@@ -0,0 +1,142 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package dbadapter_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/omec-project/nrf/dbadapter"
+	"github.com/omec-project/nrf/logger"
+	"github.com/omec-project/nrf/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// FlakyMockMongoDBClient answers the pings and the operations only while up
+type FlakyMockMongoDBClient struct {
+	dbadapter.DBInterface
+	up    atomic.Bool
+	calls atomic.Int32
+}
+
+func (db *FlakyMockMongoDBClient) Ping(ctx context.Context) error {
+	if !db.up.Load() {
+		return errors.New("server selection timeout")
+	}
+	return nil
+}
+
+func (db *FlakyMockMongoDBClient) RestfulAPIGetOne(ctx context.Context, collName string, filter bson.M) (map[string]interface{}, error) {
+	db.calls.Add(1)
+	return nil, db.Ping(ctx)
+}
+
+func TestBackoffDelay(t *testing.T) {
+	backoff := dbadapter.Backoff{Initial: 100 * time.Millisecond, Max: time.Second}
+	for failures, expected := range map[int]time.Duration{
+		1:  100 * time.Millisecond,
+		2:  200 * time.Millisecond,
+		4:  800 * time.Millisecond,
+		5:  time.Second,
+		50: time.Second,
+	} {
+		for range 10 {
+			if delay := backoff.Delay(failures); delay < expected/2 || delay > expected {
+				t.Errorf("after %d failures: expected a delay between %v and %v, got %v", failures, expected/2, expected, delay)
+			}
+		}
+	}
+}
+
+func metricValue(t *testing.T, registry *prometheus.Registry, name string) float64 {
+	t.Helper()
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != name || len(family.GetMetric()) == 0 {
+			continue
+		}
+		metric := family.GetMetric()[0]
+		if metric.GetGauge() != nil {
+			return metric.GetGauge().GetValue()
+		}
+		return metric.GetCounter().GetValue()
+	}
+	return 0
+}
+
+func waitFor(t *testing.T, description string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", description)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSupervisorCircuitBreaker(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	stats, err := metrics.NewNrfStats(registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock := &FlakyMockMongoDBClient{}
+	mock.up.Store(true)
+	supervisor := dbadapter.NewSupervisor(mock, dbadapter.Backoff{Initial: 20 * time.Millisecond, Max: 50 * time.Millisecond},
+		stats, logger.Default())
+	db := dbadapter.NewSupervisedDB(mock, supervisor)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go supervisor.Run(stop)
+	waitFor(t, "the storage to be connected", func() bool {
+		state, _, _ := supervisor.Status()
+		return state == dbadapter.StateConnected
+	})
+
+	// a failed operation has the storage probed at once, and opens the breaker
+	mock.up.Store(false)
+	if _, err = db.RestfulAPIGetOne(context.Background(), "NfProfile", bson.M{}); err == nil {
+		t.Fatal("expected the operation to fail")
+	}
+	waitFor(t, "the breaker to open", func() bool {
+		open, _ := supervisor.Open()
+		return open
+	})
+	if value := metricValue(t, registry, "nrf_storage_connected"); value != 0 {
+		t.Errorf("expected the storage to be reported disconnected, got %v", value)
+	}
+	calls := mock.calls.Load()
+	if _, err = db.RestfulAPIGetOne(context.Background(), "NfProfile", bson.M{}); !errors.Is(err, dbadapter.ErrStorageUnavailable) {
+		t.Errorf("expected %v while the breaker is open, got %v", dbadapter.ErrStorageUnavailable, err)
+	}
+	if mock.calls.Load() != calls {
+		t.Error("expected the storage not to be reached while the breaker is open")
+	}
+
+	// the breaker closes once a probe succeeds again
+	mock.up.Store(true)
+	waitFor(t, "the breaker to close", func() bool {
+		open, _ := supervisor.Open()
+		return !open
+	})
+	if _, err = db.RestfulAPIGetOne(context.Background(), "NfProfile", bson.M{}); err != nil {
+		t.Errorf("unexpected error once the storage is back: %v", err)
+	}
+	if value := metricValue(t, registry, "nrf_storage_connected"); value != 1 {
+		t.Errorf("expected the storage to be reported connected, got %v", value)
+	}
+	if value := metricValue(t, registry, "nrf_storage_reconnections"); value != 1 {
+		t.Errorf("expected 1 reconnection, got %v", value)
+	}
+}
@@ -0,0 +1,88 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package dbadapter
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Transactor is implemented by the storages able to apply several operations
+// atomically
+type Transactor interface {
+	// RunTransaction runs fn in a transaction, committed when fn returns nil
+	// and aborted otherwise. The operations of the transaction are the ones
+	// done with the ctx given to fn. fn may be run again when the transaction
+	// is retried, so it must not have effects beyond the storage.
+	RunTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// RunTransaction runs fn in a transaction of db when db is a Transactor, and
+// runs it as is otherwise
+func RunTransaction(ctx context.Context, db DBInterface, fn func(ctx context.Context) error) error {
+	if transactor, ok := db.(Transactor); ok {
+		return transactor.RunTransaction(ctx, fn)
+	}
+	return fn(ctx)
+}
+
+// The support of transactions by MongoDB, detected on the first transaction
+const (
+	transactionsUnknown int32 = iota
+	transactionsSupported
+	transactionsUnsupported
+)
+
+var _ Transactor = (*MongoDBClient)(nil)
+
+// RunTransaction runs fn in a MongoDB transaction. Transactions need a
+// replica set or a sharded cluster: with a standalone server, fn is run
+// without transaction.
+func (db *MongoDBClient) RunTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !db.supportsTransactions(ctx) {
+		return fn(ctx)
+	}
+	session, err := db.Client.StartSession()
+	if err != nil {
+		return fmt.Errorf("RunTransaction err: %w", err)
+	}
+	defer session.EndSession(context.WithoutCancel(ctx))
+
+	_, err = session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessionCtx)
+	})
+	if err != nil {
+		return fmt.Errorf("RunTransaction err: %w", err)
+	}
+	return nil
+}
+
+// supportsTransactions reports whether MongoDB is a replica set or a sharded
+// cluster. When it cannot be told, the transaction is attempted and reports
+// the failure.
+func (db *MongoDBClient) supportsTransactions(ctx context.Context) bool {
+	switch db.transactions.Load() {
+	case transactionsSupported:
+		return true
+	case transactionsUnsupported:
+		return false
+	}
+	var hello bson.M
+	err := db.Client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	if err != nil {
+		return true
+	}
+	_, replicaSet := hello["setName"]
+	if replicaSet || hello["msg"] == "isdbgrid" {
+		db.transactions.Store(transactionsSupported)
+		return true
+	}
+	db.log.AppLog.Warnln("MongoDB is a standalone server, the registry changes are not applied atomically")
+	db.transactions.Store(transactionsUnsupported)
+	return false
+}
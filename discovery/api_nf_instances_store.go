@@ -18,7 +18,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/omec-project/nrf/logger"
+	"github.com/omec-project/nrf/forwarding"
 	"github.com/omec-project/nrf/producer"
 	"github.com/omec-project/openapi"
 	"github.com/omec-project/openapi/models"
@@ -26,28 +26,32 @@ import (
 )
 
 // SearchNFInstances - Search a collection of NF Instances
-func HTTPSearchNFInstances(c *gin.Context) {
-	// var searchNFInstance context.SearchNFInstances
-	// c.BindQuery(&searchNFInstance)
-	// logger.DiscoveryLog.Infoln("searchNFInstance: ", searchNFInstance)
-	// logger.DiscoveryLog.Infoln("targetNFType: ", searchNFInstance.TargetNFType)
-	// logger.DiscoveryLog.Infoln("requesterNFType: ", searchNFInstance.RequesterNFType)
-	// logger.DiscoveryLog.Infoln("ChfSupportedPlmn: ", searchNFInstance.ChfSupportedPlmn)
+func HTTPSearchNFInstances(p *producer.Producer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// var searchNFInstance context.SearchNFInstances
+		// c.BindQuery(&searchNFInstance)
+		// p.Log.DiscoveryLog.Infoln("searchNFInstance: ", searchNFInstance)
+		// p.Log.DiscoveryLog.Infoln("targetNFType: ", searchNFInstance.TargetNFType)
+		// p.Log.DiscoveryLog.Infoln("requesterNFType: ", searchNFInstance.RequesterNFType)
+		// p.Log.DiscoveryLog.Infoln("ChfSupportedPlmn: ", searchNFInstance.ChfSupportedPlmn)
 
-	req := httpwrapper.NewRequest(c.Request, nil)
-	req.Query = c.Request.URL.Query()
-	httpResponse := producer.HandleNFDiscoveryRequest(req)
+		req := httpwrapper.NewRequest(c.Request, nil)
+		req.Query = c.Request.URL.Query()
+		// the NRFs the query was relayed through, if any
+		ctx := forwarding.WithVia(c.Request.Context(), c.Request.Header.Values("Via"))
+		httpResponse := p.HandleNFDiscoveryRequest(ctx, req)
 
-	responseBody, err := openapi.Serialize(httpResponse.Body, "application/json")
-	if err != nil {
-		logger.DiscoveryLog.Warnln(err)
-		problemDetails := models.ProblemDetails{
-			Status: http.StatusInternalServerError,
-			Cause:  "SYSTEM_FAILURE",
-			Detail: err.Error(),
+		responseBody, err := openapi.Serialize(httpResponse.Body, "application/json")
+		if err != nil {
+			p.Log.DiscoveryLog.Warnln(err)
+			problemDetails := models.ProblemDetails{
+				Status: http.StatusInternalServerError,
+				Cause:  "SYSTEM_FAILURE",
+				Detail: err.Error(),
+			}
+			c.JSON(http.StatusInternalServerError, problemDetails)
+		} else {
+			c.Data(httpResponse.Status, "application/json", responseBody)
 		}
-		c.JSON(http.StatusInternalServerError, problemDetails)
-	} else {
-		c.Data(httpResponse.Status, "application/json", responseBody)
 	}
 }
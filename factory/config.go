@@ -13,6 +13,7 @@ package factory
 import (
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/omec-project/nrf/logger"
 	"github.com/omec-project/openapi/models"
@@ -20,13 +21,31 @@ import (
 )
 
 const (
-	NRF_EXPECTED_CONFIG_VERSION = "1.0.0"
-	NRF_DEFAULT_IPV4            = "127.0.0.10"
-	NRF_DEFAULT_PORT            = "8000"
-	NRF_DEFAULT_PORT_INT        = 8000
-	NRF_DEFAULT_SCHEME          = "https"
-	NRF_NFM_RES_URI_PREFIX      = "/nnrf-nfm/v1"
-	NRF_DISC_RES_URI_PREFIX     = "/nnrf-disc/v1"
+	NRF_EXPECTED_CONFIG_VERSION   = "1.0.0"
+	NRF_DEFAULT_IPV4              = "127.0.0.10"
+	NRF_DEFAULT_PORT              = "8000"
+	NRF_DEFAULT_PORT_INT          = 8000
+	NRF_DEFAULT_SCHEME            = "https"
+	NRF_NFM_RES_URI_PREFIX        = "/nnrf-nfm/v1"
+	NRF_DISC_RES_URI_PREFIX       = "/nnrf-disc/v1"
+	NRF_FEDERATION_URI_PREFIX     = "/nnrf-federation/v1"
+	NRF_ACCESS_TOKEN_URI          = "/oauth2/token"
+	NRF_BOOTSTRAPPING_URI         = "/bootstrapping"
+	NRF_DEFAULT_SHUTDOWN_TIMEOUT  = 30 * time.Second
+	NRF_DEFAULT_NF_KEEPALIVE_TIME = 60
+	NRF_DEFAULT_ADMIN_ADDR        = ":8080"
+	NRF_DEFAULT_STORAGE_READ      = 3 * time.Second
+	NRF_DEFAULT_STORAGE_WRITE     = 5 * time.Second
+	NRF_DEFAULT_STORAGE_BULK      = 10 * time.Second
+	NRF_DEFAULT_LEASE_DURATION    = 15 * time.Second
+	NRF_DEFAULT_FEDERATION_RESYNC = 60 * time.Second
+	NRF_FEDERATION_LOCAL_FIRST    = "localFirst"
+	NRF_FEDERATION_MERGED         = "merged"
+	NRF_FORWARDING_MERGE          = "merge"
+	NRF_FORWARDING_REPLACE        = "replace"
+	NRF_TRACING_EXPORTER_OTLP     = "otlp"
+	NRF_TRACING_EXPORTER_STDOUT   = "stdout"
+	NRF_TRACING_EXPORTER_FILE     = "file"
 )
 
 type Config struct {
@@ -50,6 +69,194 @@ type Configuration struct {
 	NfKeepAliveTime       int32    `yaml:"nfKeepAliveTime,omitempty"`
 	MongoDBStreamEnable   bool     `yaml:"mongoDBStreamEnable"`
 	NfProfileExpiryEnable bool     `yaml:"nfProfileExpiryEnable"`
+	// NrfInstanceId is the NF instance id of this NRF. When not set, an id is
+	// generated once and persisted in the database.
+	NrfInstanceId string `yaml:"nrfInstanceId,omitempty"`
+	// NfDownHooks are called when an NF instance goes away. When the key is
+	// absent the legacy AMF OAM hook is installed, an empty list disables hooks.
+	NfDownHooks []NfDownHook `yaml:"nfDownHooks"`
+	// ShutdownTimeout bounds the time given to in-flight SBI requests and
+	// pending notifications to complete on shutdown
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout,omitempty"`
+	// Admin is the listener serving the metrics, health, profiling and
+	// administration endpoints, apart from the SBI
+	Admin *Admin `yaml:"admin,omitempty"`
+	// Tracing exports OpenTelemetry spans of the SBI requests, storage
+	// operations and outbound HTTP calls
+	Tracing *Tracing `yaml:"tracing,omitempty"`
+	// StorageTimeouts bound the storage operations that are not bounded
+	// earlier by the deadline of the request they are done for
+	StorageTimeouts *StorageTimeouts `yaml:"storageTimeouts,omitempty"`
+	// Cluster coordinates the NRF replicas sharing one storage
+	Cluster *Cluster `yaml:"cluster,omitempty"`
+	// Federation mirrors the registries of the NRFs of other sites
+	Federation *Federation `yaml:"federation,omitempty"`
+	// DiscoveryForwarding relays the discovery queries to other NRFs
+	DiscoveryForwarding *DiscoveryForwarding `yaml:"discoveryForwarding,omitempty"`
+	// Roaming resolves the discovery queries targeting other PLMNs
+	Roaming *Roaming `yaml:"roaming,omitempty"`
+}
+
+// Roaming configures the resolution of the discovery queries targeting PLMNs
+// which are not served by NRF, the serving PLMNs being those of webconsole
+type Roaming struct {
+	// SeppUri is the API root of the SEPP the queries are sent through, with
+	// the API root of the home NRF in 3gpp-Sbi-Target-apiRoot. Without SEPP,
+	// the queries are sent to the home NRF.
+	SeppUri string `yaml:"seppUri,omitempty"`
+	// HomeNrfs are the NRFs of other PLMNs. The NRF of a PLMN is
+	// https://nrf.5gc.mnc<MNC>.mcc<MCC>.3gppnetwork.org by default.
+	HomeNrfs []HomeNrf `yaml:"homeNrfs,omitempty"`
+	// AccessToken authorises the queries with an access token of the home NRF
+	AccessToken bool `yaml:"accessToken,omitempty"`
+}
+
+// HomeNrf is the NRF of a PLMN
+type HomeNrf struct {
+	PlmnId models.PlmnId `yaml:"plmnId"`
+	// Uri is the API root of the NRF
+	Uri string `yaml:"uri"`
+}
+
+// DiscoveryForwarding configures the relaying of the discovery queries to
+// other NRFs, such as the NRF of the home PLMN or a parent NRF
+type DiscoveryForwarding struct {
+	// Rules are tried in order, a query is relayed by the first one matching
+	Rules []ForwardingRule `yaml:"rules"`
+}
+
+// ForwardingRule relays the discovery queries it matches to an NRF. A query
+// matches when it meets all the conditions set.
+type ForwardingRule struct {
+	Name string `yaml:"name"`
+	// NrfUri is the API root of the NRF the queries are relayed to
+	NrfUri string `yaml:"nrfUri,omitempty"`
+	// HnrfUri relays the queries carrying an hnrf-uri to that NRF, rather
+	// than to NrfUri. It matches the queries without hnrf-uri only with an
+	// NrfUri.
+	HnrfUri bool `yaml:"hnrfUri,omitempty"`
+	// HnrfHosts are the hosts, as host or host:port, an hnrf-uri may target
+	// besides the home NRFs of the roaming configuration. The queries with
+	// any other hnrf-uri are rejected.
+	HnrfHosts []string `yaml:"hnrfHosts,omitempty"`
+	// TargetPlmns matches the queries with one of them in target-plmn-list
+	TargetPlmns []models.PlmnId `yaml:"targetPlmns,omitempty"`
+	// NfTypes matches the queries with one of them as target-nf-type
+	NfTypes []string `yaml:"nfTypes,omitempty"`
+	// OnEmpty matches the queries no local profile answers
+	OnEmpty bool `yaml:"onEmpty,omitempty"`
+	// Results is merge (default), to add the relayed profiles to the local
+	// ones, or replace, to answer the relayed profiles only
+	Results string `yaml:"results,omitempty"`
+	// Annotate records the NRF answering a relayed profile in its customInfo
+	Annotate bool `yaml:"annotate,omitempty"`
+	// AccessToken authorises the relayed queries with an access token of the
+	// NRF they are relayed to
+	AccessToken bool `yaml:"accessToken,omitempty"`
+}
+
+// Federation configures the mirroring of the NF profiles registered with
+// peer NRFs, and their discovery
+type Federation struct {
+	Peers []FederationPeer `yaml:"peers"`
+	// NotificationUri is the API root the peers send their notifications to,
+	// the SBI URI of NRF by default
+	NotificationUri string `yaml:"notificationUri,omitempty"`
+	// ResyncInterval is the time between two full copies of the profiles of
+	// a peer, 60s by default
+	ResyncInterval time.Duration `yaml:"resyncInterval,omitempty"`
+	// Ttl is the time a mirrored profile is kept without being refreshed by
+	// its peer, three resync intervals by default
+	Ttl time.Duration `yaml:"ttl,omitempty"`
+	// Discovery is localFirst (default), to answer the mirrored profiles only
+	// when no local profile matches, or merged, to always add them
+	Discovery string `yaml:"discovery,omitempty"`
+}
+
+// FederationPeer is an NRF the profiles of which are mirrored
+type FederationPeer struct {
+	// Name identifies the peer, it is the origin of its mirrored profiles
+	Name string `yaml:"name"`
+	// Uri is the API root of the peer, e.g. http://nrf.site-b:29510
+	Uri string `yaml:"uri"`
+	// NfTypes are the NF types mirrored, the 5GC NF types by default
+	NfTypes []string `yaml:"nfTypes,omitempty"`
+}
+
+// Cluster configures the coordination of the NRF replicas sharing one
+// storage
+type Cluster struct {
+	// ReplicaId identifies the replica among the others, its host name
+	// followed by a random suffix by default
+	ReplicaId string `yaml:"replicaId,omitempty"`
+	// LeaseDuration is the time the leader of the replicas remains so without
+	// renewing its lease, 15s by default
+	LeaseDuration time.Duration `yaml:"leaseDuration,omitempty"`
+}
+
+// StorageTimeouts are the default timeouts of the storage operations, by
+// class of operation
+type StorageTimeouts struct {
+	Read  time.Duration `yaml:"read,omitempty"`  // lookups of documents, 3s by default
+	Write time.Duration `yaml:"write,omitempty"` // updates of one document, 5s by default
+	Bulk  time.Duration `yaml:"bulk,omitempty"`  // updates of several documents, 10s by default
+}
+
+// Admin configures the listener of the metrics, health, profiling and
+// administration endpoints
+type Admin struct {
+	BindingAddr string     `yaml:"bindingAddr,omitempty"` // address to listen on, :8080 by default
+	TLS         *TLS       `yaml:"tls,omitempty"`         // served over HTTPS when set
+	Auth        *AdminAuth `yaml:"auth,omitempty"`        // no authentication when not set
+	Pprof       bool       `yaml:"pprof,omitempty"`       // serve the Go profiles at /debug/pprof/
+	// LogLevelUpdate accepts the changes of the log level at
+	// /admin/log-level, which is otherwise read-only
+	LogLevelUpdate bool `yaml:"logLevelUpdate,omitempty"`
+}
+
+// Tracing configures the OpenTelemetry trace exporter
+type Tracing struct {
+	Enabled bool `yaml:"enabled"`
+	// Exporter is otlp (default), stdout or file
+	Exporter string `yaml:"exporter,omitempty"`
+	// Endpoint is the OTLP/HTTP endpoint, e.g. http://otel-collector:4318.
+	// The OTEL_EXPORTER_OTLP_* environment variables apply when not set.
+	Endpoint string `yaml:"endpoint,omitempty"`
+	// File is the file the spans are appended to with the file exporter
+	File string `yaml:"file,omitempty"`
+	// SampleRatio is the ratio of the traces started by NRF that are
+	// sampled, 1 by default. Incoming sampled traces are always sampled.
+	SampleRatio float64 `yaml:"sampleRatio,omitempty"`
+}
+
+// AdminAuth holds the credentials accepted by the admin listener: HTTP basic
+// credentials, a bearer token, or both
+type AdminAuth struct {
+	Username    string `yaml:"username,omitempty"`
+	Password    string `yaml:"password,omitempty"`
+	BearerToken string `yaml:"bearerToken,omitempty"`
+}
+
+// NfDownHook is a webhook called when a registered NF instance goes away
+type NfDownHook struct {
+	Name string `yaml:"name,omitempty"`
+	// Url is a template in which {nfInstanceId}, {nfType} and {event} are
+	// replaced by the values of the NF instance that went away
+	Url           string         `yaml:"url"`
+	Method        string         `yaml:"method,omitempty"`  // HTTP method, POST by default
+	NfTypes       []string       `yaml:"nfTypes,omitempty"` // NF types the hook applies to, all when empty
+	Events        []string       `yaml:"events,omitempty"`  // events the hook applies to, all when empty
+	Timeout       time.Duration  `yaml:"timeout,omitempty"`
+	Retries       int            `yaml:"retries,omitempty"`
+	RetryInterval time.Duration  `yaml:"retryInterval,omitempty"`
+	TLS           *NfDownHookTLS `yaml:"tls,omitempty"`
+}
+
+type NfDownHookTLS struct {
+	CaCert             string `yaml:"caCert,omitempty"` // CA bundle used to verify the hook server
+	Cert               string `yaml:"cert,omitempty"`   // client certificate for mutual TLS
+	Key                string `yaml:"key,omitempty"`    // client private key for mutual TLS
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify,omitempty"`
 }
 
 type PlmnSupportItem struct {
@@ -77,6 +284,13 @@ func (c *Config) GetVersion() string {
 	return ""
 }
 
+func (c *Config) GetDescription() string {
+	if c.Info != nil {
+		return c.Info.Description
+	}
+	return ""
+}
+
 func (c *Config) GetSbiScheme() string {
 	if c.Configuration != nil && c.Configuration.Sbi != nil && c.Configuration.Sbi.Scheme != "" {
 		return c.Configuration.Sbi.Scheme
@@ -131,6 +345,118 @@ func (c *Config) GetSbiRegisterAddr() string {
 	return regAddr
 }
 
+// GetNfKeepAliveTime returns the heartbeat timer, in seconds, assigned to the
+// registered NF instances
+func (c *Config) GetNfKeepAliveTime() int32 {
+	if !c.Configuration.NfProfileExpiryEnable {
+		// NF profiles do not expire, heartbeats are only required daily
+		return 24 * 60 * 60
+	}
+	if c.Configuration.NfKeepAliveTime == 0 {
+		return NRF_DEFAULT_NF_KEEPALIVE_TIME
+	}
+	return c.Configuration.NfKeepAliveTime
+}
+
+func (c *Config) GetShutdownTimeout() time.Duration {
+	if c.Configuration != nil && c.Configuration.ShutdownTimeout > 0 {
+		return c.Configuration.ShutdownTimeout
+	}
+	return NRF_DEFAULT_SHUTDOWN_TIMEOUT
+}
+
+// GetStorageTimeouts returns the storage timeouts of the configuration, with
+// the defaults of the classes it does not set
+func (c *Config) GetStorageTimeouts() StorageTimeouts {
+	timeouts := StorageTimeouts{
+		Read:  NRF_DEFAULT_STORAGE_READ,
+		Write: NRF_DEFAULT_STORAGE_WRITE,
+		Bulk:  NRF_DEFAULT_STORAGE_BULK,
+	}
+	if c.Configuration == nil || c.Configuration.StorageTimeouts == nil {
+		return timeouts
+	}
+	configured := c.Configuration.StorageTimeouts
+	if configured.Read > 0 {
+		timeouts.Read = configured.Read
+	}
+	if configured.Write > 0 {
+		timeouts.Write = configured.Write
+	}
+	if configured.Bulk > 0 {
+		timeouts.Bulk = configured.Bulk
+	}
+	return timeouts
+}
+
+// GetCluster returns the cluster configuration, with the default lease
+// duration when it is not set
+func (c *Config) GetCluster() Cluster {
+	cluster := Cluster{LeaseDuration: NRF_DEFAULT_LEASE_DURATION}
+	if c.Configuration == nil || c.Configuration.Cluster == nil {
+		return cluster
+	}
+	cluster.ReplicaId = c.Configuration.Cluster.ReplicaId
+	if c.Configuration.Cluster.LeaseDuration > 0 {
+		cluster.LeaseDuration = c.Configuration.Cluster.LeaseDuration
+	}
+	return cluster
+}
+
+// GetFederation returns the federation configuration with its defaults,
+// nil when no peer is configured
+func (c *Config) GetFederation() *Federation {
+	if c.Configuration == nil || c.Configuration.Federation == nil || len(c.Configuration.Federation.Peers) == 0 {
+		return nil
+	}
+	federation := *c.Configuration.Federation
+	if federation.NotificationUri == "" {
+		federation.NotificationUri = c.GetSbiUri()
+	}
+	if federation.ResyncInterval <= 0 {
+		federation.ResyncInterval = NRF_DEFAULT_FEDERATION_RESYNC
+	}
+	if federation.Ttl <= 0 {
+		federation.Ttl = 3 * federation.ResyncInterval
+	}
+	if federation.Discovery == "" {
+		federation.Discovery = NRF_FEDERATION_LOCAL_FIRST
+	}
+	return &federation
+}
+
+// GetDiscoveryForwarding returns the discovery forwarding rules with their
+// defaults
+func (c *Config) GetDiscoveryForwarding() []ForwardingRule {
+	if c.Configuration == nil || c.Configuration.DiscoveryForwarding == nil {
+		return nil
+	}
+	rules := make([]ForwardingRule, 0, len(c.Configuration.DiscoveryForwarding.Rules))
+	for _, rule := range c.Configuration.DiscoveryForwarding.Rules {
+		if rule.Results == "" {
+			rule.Results = NRF_FORWARDING_MERGE
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// GetRoaming returns the roaming configuration, nil when the queries
+// targeting other PLMNs are not resolved
+func (c *Config) GetRoaming() *Roaming {
+	if c.Configuration == nil {
+		return nil
+	}
+	return c.Configuration.Roaming
+}
+
+func (c *Config) GetAdminBindingAddr() string {
+	if c.Configuration != nil && c.Configuration.Admin != nil && c.Configuration.Admin.BindingAddr != "" {
+		return c.Configuration.Admin.BindingAddr
+	}
+	return NRF_DEFAULT_ADMIN_ADDR
+}
+
 func (c *Config) GetSbiUri() string {
 	return c.GetSbiScheme() + "://" + c.GetSbiRegisterAddr()
 }
@@ -14,36 +14,60 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"strings"
 
 	"github.com/omec-project/nrf/logger"
 	"gopkg.in/yaml.v2"
 )
 
-var NrfConfig Config
-
-// InitConfigFactory gets the NrfConfig and sets the REST API endpoint used to
-// fetch the configuration from.
-func InitConfigFactory(f string) error {
+// ReadConfig reads and validates the NRF configuration file f
+func ReadConfig(f string) (*Config, error) {
 	content, err := os.ReadFile(f)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	NrfConfig = Config{}
+	config := &Config{}
 
-	if err = yaml.Unmarshal(content, &NrfConfig); err != nil {
-		return err
+	if err = yaml.Unmarshal(content, config); err != nil {
+		return nil, err
 	}
-	if NrfConfig.Configuration.WebuiUri == "" {
-		NrfConfig.Configuration.WebuiUri = "http://webui:5001"
-		logger.CfgLog.Infof("webuiUri not set in configuration file. Using %v", NrfConfig.Configuration.WebuiUri)
-		return nil
+	if config.Configuration == nil {
+		return nil, fmt.Errorf("configuration section missing in %s", f)
+	}
+	if err = validateAdmin(config.Configuration.Admin); err != nil {
+		return nil, err
+	}
+	if err = validateTracing(config.Configuration.Tracing); err != nil {
+		return nil, err
+	}
+	if err = validateStorageTimeouts(config.Configuration.StorageTimeouts); err != nil {
+		return nil, err
+	}
+	if err = validateCluster(config.Configuration.Cluster); err != nil {
+		return nil, err
 	}
-	err = validateWebuiUri(NrfConfig.Configuration.WebuiUri)
-	return err
+	if err = validateFederation(config.Configuration.Federation); err != nil {
+		return nil, err
+	}
+	if err = validateDiscoveryForwarding(config.Configuration.DiscoveryForwarding); err != nil {
+		return nil, err
+	}
+	if err = validateRoaming(config.Configuration.Roaming); err != nil {
+		return nil, err
+	}
+	if config.Configuration.WebuiUri == "" {
+		config.Configuration.WebuiUri = "http://webui:5001"
+		logger.CfgLog.Infof("webuiUri not set in configuration file. Using %v", config.Configuration.WebuiUri)
+		return config, nil
+	}
+	if err = validateWebuiUri(config.Configuration.WebuiUri); err != nil {
+		return nil, err
+	}
+	return config, nil
 }
 
-func CheckConfigVersion() error {
-	currentVersion := NrfConfig.GetVersion()
+func (c *Config) CheckConfigVersion() error {
+	currentVersion := c.GetVersion()
 
 	if currentVersion != NRF_EXPECTED_CONFIG_VERSION {
 		return fmt.Errorf("config version is [%s], but expected is [%s]",
@@ -68,3 +92,151 @@ func validateWebuiUri(uri string) error {
 	}
 	return nil
 }
+
+func validateAdmin(admin *Admin) error {
+	if admin == nil {
+		return nil
+	}
+	if admin.TLS != nil && (admin.TLS.PEM == "" || admin.TLS.Key == "") {
+		return fmt.Errorf("admin tls requires both pem and key")
+	}
+	if auth := admin.Auth; auth != nil {
+		if auth.Username == "" && auth.BearerToken == "" {
+			return fmt.Errorf("admin auth requires a username or a bearerToken")
+		}
+		if auth.Username != "" && auth.Password == "" {
+			return fmt.Errorf("admin auth requires a password for username %s", auth.Username)
+		}
+	}
+	return nil
+}
+
+func validateTracing(tracing *Tracing) error {
+	if tracing == nil || !tracing.Enabled {
+		return nil
+	}
+	switch tracing.Exporter {
+	case "", NRF_TRACING_EXPORTER_OTLP, NRF_TRACING_EXPORTER_STDOUT:
+	case NRF_TRACING_EXPORTER_FILE:
+		if tracing.File == "" {
+			return fmt.Errorf("tracing file exporter requires a file")
+		}
+	default:
+		return fmt.Errorf("unsupported tracing exporter: %s", tracing.Exporter)
+	}
+	if tracing.SampleRatio < 0 || tracing.SampleRatio > 1 {
+		return fmt.Errorf("tracing sampleRatio must be between 0 and 1")
+	}
+	return nil
+}
+
+func validateStorageTimeouts(timeouts *StorageTimeouts) error {
+	if timeouts == nil {
+		return nil
+	}
+	if timeouts.Read < 0 || timeouts.Write < 0 || timeouts.Bulk < 0 {
+		return fmt.Errorf("storageTimeouts must not be negative")
+	}
+	return nil
+}
+
+func validateCluster(cluster *Cluster) error {
+	if cluster == nil {
+		return nil
+	}
+	if cluster.LeaseDuration < 0 {
+		return fmt.Errorf("cluster leaseDuration must not be negative")
+	}
+	return nil
+}
+
+func validateFederation(federation *Federation) error {
+	if federation == nil {
+		return nil
+	}
+	names := make(map[string]bool, len(federation.Peers))
+	for _, peer := range federation.Peers {
+		if peer.Name == "" {
+			return fmt.Errorf("federation peer %s requires a name", peer.Uri)
+		}
+		if names[peer.Name] {
+			return fmt.Errorf("federation peer %s is configured twice", peer.Name)
+		}
+		names[peer.Name] = true
+		peerUrl, err := url.ParseRequestURI(peer.Uri)
+		if err != nil || (peerUrl.Scheme != "http" && peerUrl.Scheme != "https") || peerUrl.Hostname() == "" {
+			return fmt.Errorf("federation peer %s requires an http or https uri", peer.Name)
+		}
+	}
+	switch federation.Discovery {
+	case "", NRF_FEDERATION_LOCAL_FIRST, NRF_FEDERATION_MERGED:
+	default:
+		return fmt.Errorf("unsupported federation discovery policy: %s", federation.Discovery)
+	}
+	if federation.ResyncInterval < 0 || federation.Ttl < 0 {
+		return fmt.Errorf("federation resyncInterval and ttl must not be negative")
+	}
+	return nil
+}
+
+func validateDiscoveryForwarding(forwarding *DiscoveryForwarding) error {
+	if forwarding == nil {
+		return nil
+	}
+	for i, rule := range forwarding.Rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i)
+		}
+		if rule.NrfUri == "" && !rule.HnrfUri {
+			return fmt.Errorf("discovery forwarding rule %s requires an nrfUri or hnrfUri", name)
+		}
+		if rule.NrfUri != "" {
+			nrfUrl, err := url.ParseRequestURI(rule.NrfUri)
+			if err != nil || (nrfUrl.Scheme != "http" && nrfUrl.Scheme != "https") || nrfUrl.Hostname() == "" {
+				return fmt.Errorf("discovery forwarding rule %s requires an http or https nrfUri", name)
+			}
+		}
+		if len(rule.HnrfHosts) != 0 && !rule.HnrfUri {
+			return fmt.Errorf("discovery forwarding rule %s sets hnrfHosts without hnrfUri", name)
+		}
+		for _, host := range rule.HnrfHosts {
+			if host == "" || strings.ContainsAny(host, "/?#@") {
+				return fmt.Errorf("discovery forwarding rule %s has an invalid hnrfHost %q", name, host)
+			}
+		}
+		// a rule without condition would relay every query
+		if len(rule.TargetPlmns) == 0 && len(rule.NfTypes) == 0 && !rule.OnEmpty && rule.NrfUri != "" {
+			return fmt.Errorf("discovery forwarding rule %s requires targetPlmns, nfTypes or onEmpty", name)
+		}
+		switch rule.Results {
+		case "", NRF_FORWARDING_MERGE, NRF_FORWARDING_REPLACE:
+		default:
+			return fmt.Errorf("unsupported discovery forwarding results: %s", rule.Results)
+		}
+	}
+	return nil
+}
+
+func validateRoaming(roaming *Roaming) error {
+	if roaming == nil {
+		return nil
+	}
+	isApiRoot := func(uri string) bool {
+		apiRoot, err := url.ParseRequestURI(uri)
+		return err == nil && (apiRoot.Scheme == "http" || apiRoot.Scheme == "https") && apiRoot.Hostname() != ""
+	}
+	if roaming.SeppUri != "" && !isApiRoot(roaming.SeppUri) {
+		return fmt.Errorf("roaming seppUri must be an http or https uri")
+	}
+	for _, homeNrf := range roaming.HomeNrfs {
+		if homeNrf.PlmnId.Mcc == "" || homeNrf.PlmnId.Mnc == "" {
+			return fmt.Errorf("roaming home NRF %s requires a plmnId", homeNrf.Uri)
+		}
+		if !isApiRoot(homeNrf.Uri) {
+			return fmt.Errorf("roaming home NRF of PLMN %s%s requires an http or https uri", homeNrf.PlmnId.Mcc,
+				homeNrf.PlmnId.Mnc)
+		}
+	}
+	return nil
+}
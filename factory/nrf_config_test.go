@@ -8,26 +8,30 @@ package factory
 
 import (
 	"testing"
+	"time"
 
+	"github.com/omec-project/openapi/models"
 	"github.com/stretchr/testify/assert"
 )
 
 // Webui URL is not set then default Webui URL value is returned
 func TestGetDefaultWebuiUrl(t *testing.T) {
-	if err := InitConfigFactory("../nrfTest/nrfcfg.yaml"); err != nil {
-		t.Errorf("error in InitConfigFactory: %v", err)
+	config, err := ReadConfig("../nrfTest/nrfcfg.yaml")
+	if err != nil {
+		t.Fatalf("error in ReadConfig: %v", err)
 	}
-	got := NrfConfig.Configuration.WebuiUri
+	got := config.Configuration.WebuiUri
 	want := "http://webui:5001"
 	assert.Equal(t, got, want, "The webui URL is not correct.")
 }
 
 // Webui URL is set to a custom value then custom Webui URL is returned
 func TestGetCustomWebuiUrl(t *testing.T) {
-	if err := InitConfigFactory("../nrfTest/nrfcfg_with_custom_webui_url.yaml"); err != nil {
-		t.Errorf("error in InitConfigFactory: %v", err)
+	config, err := ReadConfig("../nrfTest/nrfcfg_with_custom_webui_url.yaml")
+	if err != nil {
+		t.Fatalf("error in ReadConfig: %v", err)
 	}
-	got := NrfConfig.Configuration.WebuiUri
+	got := config.Configuration.WebuiUri
 	want := "https://myspecialwebui:5002"
 	assert.Equal(t, got, want, "The webui URL is not correct.")
 }
@@ -82,3 +86,227 @@ func TestValidateWebuiUri(t *testing.T) {
 		})
 	}
 }
+
+func TestGetShutdownTimeout(t *testing.T) {
+	config := &Config{Configuration: &Configuration{}}
+	assert.Equal(t, NRF_DEFAULT_SHUTDOWN_TIMEOUT, config.GetShutdownTimeout())
+
+	config.Configuration.ShutdownTimeout = 5 * time.Second
+	assert.Equal(t, 5*time.Second, config.GetShutdownTimeout())
+}
+
+func TestValidateAdmin(t *testing.T) {
+	tests := []struct {
+		name    string
+		admin   *Admin
+		isValid bool
+	}{
+		{
+			name:    "No admin section",
+			isValid: true,
+		},
+		{
+			name:    "TLS and basic auth",
+			admin:   &Admin{TLS: &TLS{PEM: "admin.pem", Key: "admin.key"}, Auth: &AdminAuth{Username: "oam", Password: "secret"}},
+			isValid: true,
+		},
+		{
+			name:    "Bearer token",
+			admin:   &Admin{Auth: &AdminAuth{BearerToken: "token"}},
+			isValid: true,
+		},
+		{
+			name:  "TLS without key",
+			admin: &Admin{TLS: &TLS{PEM: "admin.pem"}},
+		},
+		{
+			name:  "Username without password",
+			admin: &Admin{Auth: &AdminAuth{Username: "oam"}},
+		},
+		{
+			name:  "Empty auth",
+			admin: &Admin{Auth: &AdminAuth{}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateAdmin(tc.admin)
+			if tc.isValid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestGetAdminBindingAddr(t *testing.T) {
+	config := &Config{Configuration: &Configuration{}}
+	assert.Equal(t, NRF_DEFAULT_ADMIN_ADDR, config.GetAdminBindingAddr())
+
+	config.Configuration.Admin = &Admin{BindingAddr: "127.0.0.1:9090"}
+	assert.Equal(t, "127.0.0.1:9090", config.GetAdminBindingAddr())
+}
+
+func TestValidateTracing(t *testing.T) {
+	tests := []struct {
+		name    string
+		tracing *Tracing
+		isValid bool
+	}{
+		{
+			name:    "No tracing section",
+			isValid: true,
+		},
+		{
+			name:    "Disabled with unknown exporter",
+			tracing: &Tracing{Exporter: "zipkin"},
+			isValid: true,
+		},
+		{
+			name:    "Default OTLP exporter",
+			tracing: &Tracing{Enabled: true, Endpoint: "http://otel-collector:4318"},
+			isValid: true,
+		},
+		{
+			name:    "File exporter",
+			tracing: &Tracing{Enabled: true, Exporter: NRF_TRACING_EXPORTER_FILE, File: "/tmp/nrf-traces.json", SampleRatio: 0.5},
+			isValid: true,
+		},
+		{
+			name:    "File exporter without file",
+			tracing: &Tracing{Enabled: true, Exporter: NRF_TRACING_EXPORTER_FILE},
+		},
+		{
+			name:    "Unknown exporter",
+			tracing: &Tracing{Enabled: true, Exporter: "zipkin"},
+		},
+		{
+			name:    "Sample ratio above 1",
+			tracing: &Tracing{Enabled: true, SampleRatio: 2},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateTracing(tc.tracing)
+			if tc.isValid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestGetStorageTimeouts(t *testing.T) {
+	config := &Config{Configuration: &Configuration{}}
+	assert.Equal(t, StorageTimeouts{
+		Read:  NRF_DEFAULT_STORAGE_READ,
+		Write: NRF_DEFAULT_STORAGE_WRITE,
+		Bulk:  NRF_DEFAULT_STORAGE_BULK,
+	}, config.GetStorageTimeouts())
+
+	config.Configuration.StorageTimeouts = &StorageTimeouts{Read: time.Second}
+	assert.Equal(t, StorageTimeouts{
+		Read:  time.Second,
+		Write: NRF_DEFAULT_STORAGE_WRITE,
+		Bulk:  NRF_DEFAULT_STORAGE_BULK,
+	}, config.GetStorageTimeouts())
+
+	config.Configuration.StorageTimeouts = &StorageTimeouts{Write: -time.Second}
+	assert.Error(t, validateStorageTimeouts(config.Configuration.StorageTimeouts))
+}
+
+func TestGetCluster(t *testing.T) {
+	config := &Config{Configuration: &Configuration{}}
+	assert.Equal(t, Cluster{LeaseDuration: NRF_DEFAULT_LEASE_DURATION}, config.GetCluster())
+
+	config.Configuration.Cluster = &Cluster{ReplicaId: "nrf-0"}
+	assert.Equal(t, Cluster{ReplicaId: "nrf-0", LeaseDuration: NRF_DEFAULT_LEASE_DURATION}, config.GetCluster())
+
+	config.Configuration.Cluster = &Cluster{LeaseDuration: -time.Second}
+	assert.Error(t, validateCluster(config.Configuration.Cluster))
+}
+
+func TestGetFederation(t *testing.T) {
+	config := &Config{Configuration: &Configuration{Sbi: &Sbi{}, Federation: &Federation{}}}
+	assert.Nil(t, config.GetFederation())
+
+	config.Configuration.Federation.Peers = []FederationPeer{{Name: "site-b", Uri: "http://nrf.site-b:29510"}}
+	federation := config.GetFederation()
+	assert.Equal(t, NRF_DEFAULT_FEDERATION_RESYNC, federation.ResyncInterval)
+	assert.Equal(t, 3*NRF_DEFAULT_FEDERATION_RESYNC, federation.Ttl)
+	assert.Equal(t, NRF_FEDERATION_LOCAL_FIRST, federation.Discovery)
+	assert.Equal(t, config.GetSbiUri(), federation.NotificationUri)
+	assert.NoError(t, validateFederation(config.Configuration.Federation))
+
+	config.Configuration.Federation.Peers = append(config.Configuration.Federation.Peers,
+		FederationPeer{Name: "site-b", Uri: "http://nrf.site-c:29510"})
+	assert.Error(t, validateFederation(config.Configuration.Federation))
+
+	config.Configuration.Federation.Peers = []FederationPeer{{Name: "site-c", Uri: "nrf.site-c"}}
+	assert.Error(t, validateFederation(config.Configuration.Federation))
+}
+
+func TestGetDiscoveryForwarding(t *testing.T) {
+	config := &Config{Configuration: &Configuration{}}
+	assert.Nil(t, config.GetDiscoveryForwarding())
+
+	config.Configuration.DiscoveryForwarding = &DiscoveryForwarding{Rules: []ForwardingRule{
+		{Name: "home", NrfUri: "http://hnrf:29510", OnEmpty: true},
+		{Name: "visited", HnrfUri: true, HnrfHosts: []string{"nrf.home", "10.0.0.1:29510"}, Results: NRF_FORWARDING_REPLACE},
+	}}
+	rules := config.GetDiscoveryForwarding()
+	assert.Equal(t, NRF_FORWARDING_MERGE, rules[0].Results)
+	assert.Equal(t, NRF_FORWARDING_REPLACE, rules[1].Results)
+	assert.NoError(t, validateDiscoveryForwarding(config.Configuration.DiscoveryForwarding))
+
+	for _, rule := range []ForwardingRule{
+		{Name: "no uri", OnEmpty: true},
+		{Name: "invalid uri", NrfUri: "hnrf:29510", OnEmpty: true},
+		{Name: "no condition", NrfUri: "http://hnrf:29510"},
+		{Name: "invalid results", NrfUri: "http://hnrf:29510", OnEmpty: true, Results: "annotate"},
+		{Name: "hosts without hnrfUri", NrfUri: "http://hnrf:29510", OnEmpty: true, HnrfHosts: []string{"nrf.home"}},
+		{Name: "invalid host", HnrfUri: true, HnrfHosts: []string{"http://nrf.home"}},
+	} {
+		assert.Error(t, validateDiscoveryForwarding(&DiscoveryForwarding{Rules: []ForwardingRule{rule}}), rule.Name)
+	}
+}
+
+func TestValidateRoaming(t *testing.T) {
+	tests := []struct {
+		name    string
+		roaming *Roaming
+		isValid bool
+	}{
+		{
+			name:    "No roaming section",
+			isValid: true,
+		},
+		{
+			name: "SEPP and home NRF",
+			roaming: &Roaming{SeppUri: "http://sepp:8080", HomeNrfs: []HomeNrf{
+				{PlmnId: models.PlmnId{Mcc: "001", Mnc: "01"}, Uri: "https://nrf.home:29510"},
+			}},
+			isValid: true,
+		},
+		{
+			name:    "Invalid SEPP uri",
+			roaming: &Roaming{SeppUri: "sepp:8080"},
+		},
+		{
+			name:    "Home NRF without PLMN",
+			roaming: &Roaming{HomeNrfs: []HomeNrf{{Uri: "https://nrf.home:29510"}}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateRoaming(tc.roaming)
+			if tc.isValid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,374 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package federation mirrors the NF profiles registered with the NRFs of
+// other sites, the peers, into a read-only partition of the registry, so that
+// the NFs of this site can discover them. The leader subscribes to the NF
+// status events of each peer through its NF management API, and copies all
+// the profiles of the peer at every resync interval. The mirrored profiles
+// expire when their peer stops refreshing them.
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omec-project/nrf/client"
+	nrfContext "github.com/omec-project/nrf/context"
+	"github.com/omec-project/nrf/dbadapter"
+	"github.com/omec-project/nrf/factory"
+	"github.com/omec-project/openapi/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// CollName is the collection of the profiles mirrored from the peers.
+	// The NF management API does not read nor write it.
+	CollName = "FederatedNfProfile"
+	// OriginField holds the name of the peer a mirrored profile comes from
+	OriginField = "federationOrigin"
+	// subscriptionCollName is the collection of the subscriptions to the NF
+	// status events of the peers, kept across the changes of leader
+	subscriptionCollName = "FederationSubscriptions"
+	// notificationTimeout bounds the handling of a notification of a peer
+	notificationTimeout = 10 * time.Second
+)
+
+// defaultNfTypes are the NF types mirrored from a peer without NfTypes. The
+// NRFs are never mirrored, each site discovers its own.
+var defaultNfTypes = []string{
+	"AMF", "SMF", "UPF", "AUSF", "UDM", "UDR", "PCF", "NSSF", "NEF", "CHF", "SMSF", "BSF",
+}
+
+// Federator mirrors the profiles of the peers of the configuration
+type Federator struct {
+	nrfCtx *nrfContext.NRFContext
+	config *factory.Federation
+	peers  map[string]*peer
+}
+
+// peer is an NRF the profiles of which are mirrored
+type peer struct {
+	name    string
+	nfTypes []models.NfType
+	client  *client.Client
+	// receiver receives the notifications of the subscriptions to the peer
+	receiver *client.SubscriptionManager
+}
+
+// New creates the federator of the NRF instance described by nrfCtx. It
+// returns nil when no peer is configured.
+func New(nrfCtx *nrfContext.NRFContext) (*Federator, error) {
+	config := nrfCtx.Config.GetFederation()
+	if config == nil {
+		return nil, nil
+	}
+	f := &Federator{
+		nrfCtx: nrfCtx,
+		config: config,
+		peers:  make(map[string]*peer, len(config.Peers)),
+	}
+	for _, peerConfig := range config.Peers {
+		peerClient, err := client.New(peerConfig.Uri, client.WithHTTPClient(nrfCtx.HTTPClient()))
+		if err != nil {
+			return nil, fmt.Errorf("federation peer %s: %w", peerConfig.Name, err)
+		}
+		nfTypes := peerConfig.NfTypes
+		if len(nfTypes) == 0 {
+			nfTypes = defaultNfTypes
+		}
+		p := &peer{
+			name:   peerConfig.Name,
+			client: peerClient,
+		}
+		for _, nfType := range nfTypes {
+			p.nfTypes = append(p.nfTypes, models.NfType(nfType))
+		}
+		p.receiver = client.NewSubscriptionManager(peerClient, func(notification models.NotificationData) {
+			f.handleNotification(p, notification)
+		})
+		f.peers[p.name] = p
+	}
+	return f, nil
+}
+
+// AddService serves the notifications of the peers on engine. Every replica
+// serves them, as the peers notify the NRF whichever replica is the leader.
+func (f *Federator) AddService(engine *gin.Engine) *gin.RouterGroup {
+	group := engine.Group(factory.NRF_FEDERATION_URI_PREFIX)
+	group.POST("/notify/:peer", func(c *gin.Context) {
+		p, ok := f.peers[c.Param("peer")]
+		if !ok {
+			c.JSON(http.StatusNotFound, models.ProblemDetails{
+				Title:  "Unknown federation peer",
+				Status: http.StatusNotFound,
+				Cause:  "RESOURCE_NOT_FOUND",
+				Detail: c.Param("peer"),
+			})
+			return
+		}
+		p.receiver.Handler().ServeHTTP(c.Writer, c.Request)
+	})
+	return group
+}
+
+// notificationUri is the URI the peer p sends its notifications to
+func (f *Federator) notificationUri(p *peer) string {
+	return f.config.NotificationUri + factory.NRF_FEDERATION_URI_PREFIX + "/notify/" + url.PathEscape(p.name)
+}
+
+// Run mirrors the profiles of the peers, every resync interval, until stop is
+// closed. It is run by the leader only.
+func (f *Federator) Run(stop <-chan struct{}) {
+	var wg sync.WaitGroup
+	for _, p := range f.peers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.runPeer(stop, p)
+		}()
+	}
+	wg.Wait()
+}
+
+func (f *Federator) runPeer(stop <-chan struct{}, p *peer) {
+	ticker := time.NewTicker(f.config.ResyncInterval)
+	defer ticker.Stop()
+	for {
+		f.Sync(context.Background(), p.name)
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync subscribes to the NF status events of the peer name, when not yet
+// done, and copies all its profiles
+func (f *Federator) Sync(ctx context.Context, name string) {
+	p, ok := f.peers[name]
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, f.config.ResyncInterval)
+	defer cancel()
+	log := f.nrfCtx.Log.FederationLog
+	if err := f.subscribe(ctx, p); err != nil {
+		// the copies keep the mirror up to date meanwhile
+		log.Warnf("failed to subscribe to peer %s: %v", p.name, err)
+	}
+	if err := f.copyProfiles(ctx, p); err != nil {
+		// the profiles of the peer expire unless it answers again in time
+		log.Warnf("failed to copy the profiles of peer %s: %v", p.name, err)
+		f.nrfCtx.Metrics.IncrementFederationSyncsStats(p.name, "FAILURE")
+		return
+	}
+	f.nrfCtx.Metrics.IncrementFederationSyncsStats(p.name, "SUCCESS")
+}
+
+// subscribe creates the subscriptions to the NF types of the peer which are
+// missing, expiring before the next resync, or notifying another URI
+func (f *Federator) subscribe(ctx context.Context, p *peer) error {
+	notificationUri := f.notificationUri(p)
+	renewBefore := time.Now().Add(f.config.ResyncInterval)
+	for _, nfType := range p.nfTypes {
+		filter := bson.M{"peer": p.name, "nfType": string(nfType)}
+		existing, err := f.nrfCtx.DB.RestfulAPIGetOne(ctx, subscriptionCollName, filter)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			validityTime, limited := existing["validityTime"]
+			if existing["notificationUri"] == notificationUri && (!limited || dbadapter.ToTime(validityTime).After(renewBefore)) {
+				continue
+			}
+			err = p.client.RemoveSubscription(ctx, fmt.Sprint(existing["subscriptionId"]))
+			if err != nil && !client.IsNotFound(err) {
+				f.nrfCtx.Log.FederationLog.Infof("failed to remove subscription %v of peer %s: %v",
+					existing["subscriptionId"], p.name, err)
+			}
+		}
+		created, err := p.client.CreateSubscription(ctx, models.NrfSubscriptionData{
+			NfStatusNotificationUri: notificationUri,
+			SubscrCond:              models.NfTypeCond{NfType: nfType},
+			ReqNfType:               models.NfType_NRF,
+		})
+		if err != nil {
+			return fmt.Errorf("%s subscription: %w", nfType, err)
+		}
+		subscription := bson.M{
+			"peer":            p.name,
+			"nfType":          string(nfType),
+			"subscriptionId":  created.SubscriptionId,
+			"notificationUri": notificationUri,
+		}
+		if created.ValidityTime != nil {
+			subscription["validityTime"] = *created.ValidityTime
+		}
+		if _, err = f.nrfCtx.DB.RestfulAPIPutOne(ctx, subscriptionCollName, filter, subscription); err != nil {
+			return err
+		}
+		f.nrfCtx.Log.FederationLog.Infof("subscribed to the %s instances of peer %s", nfType, p.name)
+	}
+	return nil
+}
+
+// copyProfiles mirrors the profiles the peer answers for each of its NF
+// types, and removes the mirrored profiles the peer no longer answers
+func (f *Federator) copyProfiles(ctx context.Context, p *peer) error {
+	expireAt := time.Now().Add(f.config.Ttl)
+	for _, nfType := range p.nfTypes {
+		result, err := p.client.SearchNFInstances(ctx, nfType, models.NfType_NRF, nil)
+		if err != nil && !client.IsNotFound(err) {
+			return fmt.Errorf("%s discovery: %w", nfType, err)
+		}
+		mirrored := make(map[string]bool, len(result.NfInstances))
+		for _, profile := range result.NfInstances {
+			ok, err := f.mirror(ctx, p, profile, expireAt)
+			if err != nil {
+				return err
+			}
+			mirrored[profile.NfInstanceId] = ok
+		}
+		stale, err := f.nrfCtx.DB.RestfulAPIGetMany(ctx, CollName, bson.M{OriginField: p.name, "nfType": string(nfType)})
+		if err != nil {
+			return err
+		}
+		for _, profile := range stale {
+			nfInstanceId := fmt.Sprint(profile["nfInstanceId"])
+			if mirrored[nfInstanceId] {
+				continue
+			}
+			if err = f.remove(ctx, p, nfInstanceId); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// mirror stores the profile of the peer p until expireAt. It reports false
+// when the profile is not mirrored: the NF instance is registered with this
+// NRF, or mirrored from another peer which still refreshes it.
+func (f *Federator) mirror(ctx context.Context, p *peer, profile models.NfProfile, expireAt time.Time) (bool, error) {
+	if profile.NfType == models.NfType_NRF {
+		return false, nil
+	}
+	log := f.nrfCtx.Log.FederationLog
+	filter := bson.M{"nfInstanceId": profile.NfInstanceId}
+	local, err := f.nrfCtx.DB.RestfulAPIGetOne(ctx, "NfProfile", filter)
+	if err != nil {
+		return false, err
+	}
+	if local != nil {
+		log.Debugf("NF instance %s of peer %s is registered locally", profile.NfInstanceId, p.name)
+		return false, nil
+	}
+	existing, err := f.nrfCtx.DB.RestfulAPIGetOne(ctx, CollName, filter)
+	if err != nil {
+		return false, err
+	}
+	if existing != nil && existing[OriginField] != p.name && !Expired(existing, time.Now()) {
+		log.Debugf("NF instance %s of peer %s is mirrored from peer %v", profile.NfInstanceId, p.name, existing[OriginField])
+		return false, nil
+	}
+
+	var nf models.NfProfile
+	if err = f.nrfCtx.NnrfNFManagementDataModel(ctx, &nf, profile); err != nil {
+		log.Warnf("invalid profile of NF instance %s of peer %s: %v", profile.NfInstanceId, p.name, err)
+		return false, nil
+	}
+	tmp, err := json.Marshal(nf)
+	if err != nil {
+		return false, err
+	}
+	putData := bson.M{}
+	if err = json.Unmarshal(tmp, &putData); err != nil {
+		return false, err
+	}
+	putData[OriginField] = p.name
+	putData["expireAt"] = expireAt
+	if _, err = f.nrfCtx.DB.RestfulAPIPutOne(ctx, CollName, filter, putData); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// remove removes the profile of nfInstanceId mirrored from the peer p
+func (f *Federator) remove(ctx context.Context, p *peer, nfInstanceId string) error {
+	f.nrfCtx.Log.FederationLog.Debugf("NF instance %s of peer %s removed", nfInstanceId, p.name)
+	return f.nrfCtx.DB.RestfulAPIDeleteOne(ctx, CollName, bson.M{"nfInstanceId": nfInstanceId, OriginField: p.name})
+}
+
+// handleNotification applies an NF status event of the peer p to its mirrored
+// profiles. The notification does not hold the profile, which is read back
+// from the peer as the copies read it.
+func (f *Federator) handleNotification(p *peer, notification models.NotificationData) {
+	ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+	defer cancel()
+	log := f.nrfCtx.Log.FederationLog
+	nfInstanceId := path.Base(notification.NfInstanceUri)
+	var err error
+	switch notification.Event {
+	case models.NotificationEventType_DEREGISTERED:
+		err = f.remove(ctx, p, nfInstanceId)
+	case models.NotificationEventType_REGISTERED, models.NotificationEventType_PROFILE_CHANGED:
+		err = f.refresh(ctx, p, nfInstanceId)
+	}
+	if err != nil {
+		// the next copy of the profiles of the peer catches up
+		log.Warnf("failed to apply %s of NF instance %s of peer %s: %v", notification.Event, nfInstanceId, p.name, err)
+	}
+}
+
+// refresh mirrors the current profile of nfInstanceId of the peer p, or
+// removes it when the peer no longer answers it
+func (f *Federator) refresh(ctx context.Context, p *peer, nfInstanceId string) error {
+	profile, err := p.client.GetNFInstance(ctx, nfInstanceId)
+	if client.IsNotFound(err) {
+		return f.remove(ctx, p, nfInstanceId)
+	}
+	if err != nil {
+		return err
+	}
+	if !p.mirrors(profile.NfType) {
+		return nil
+	}
+	result, err := p.client.SearchNFInstances(ctx, profile.NfType, models.NfType_NRF,
+		url.Values{"target-nf-instance-id": {nfInstanceId}})
+	if err != nil && !client.IsNotFound(err) {
+		return err
+	}
+	for _, found := range result.NfInstances {
+		if found.NfInstanceId == nfInstanceId {
+			_, err = f.mirror(ctx, p, found, time.Now().Add(f.config.Ttl))
+			return err
+		}
+	}
+	return f.remove(ctx, p, nfInstanceId)
+}
+
+// mirrors reports whether the profiles of nfType are mirrored from the peer
+func (p *peer) mirrors(nfType models.NfType) bool {
+	for _, mirrored := range p.nfTypes {
+		if mirrored == nfType {
+			return true
+		}
+	}
+	return false
+}
+
+// Expired reports whether the mirrored profile expired at now. The storage
+// removes the expired profiles a while after they expired.
+func Expired(profile map[string]interface{}, now time.Time) bool {
+	return !dbadapter.ToTime(profile["expireAt"]).After(now)
+}
@@ -0,0 +1,389 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package federation_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	nrfContext "github.com/omec-project/nrf/context"
+	"github.com/omec-project/nrf/dbadapter"
+	"github.com/omec-project/nrf/factory"
+	"github.com/omec-project/nrf/federation"
+	"github.com/omec-project/nrf/logger"
+	"github.com/omec-project/nrf/producer"
+	"github.com/omec-project/openapi/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// RegistryMockMongoDBClient stores the documents of each collection. Its
+// filters only match the fields of the documents, the operators are ignored.
+type RegistryMockMongoDBClient struct {
+	dbadapter.DBInterface
+	mu          sync.Mutex
+	collections map[string][]map[string]interface{}
+}
+
+func newRegistryMockMongoDBClient() *RegistryMockMongoDBClient {
+	return &RegistryMockMongoDBClient{collections: map[string][]map[string]interface{}{}}
+}
+
+func matches(document map[string]interface{}, filter bson.M) bool {
+	for key, value := range filter {
+		if strings.HasPrefix(key, "$") {
+			continue
+		}
+		if fmt.Sprint(document[key]) != fmt.Sprint(value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (db *RegistryMockMongoDBClient) RestfulAPIGetOne(ctx context.Context, collName string, filter bson.M) (map[string]interface{}, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, document := range db.collections[collName] {
+		if matches(document, filter) {
+			return maps.Clone(document), nil
+		}
+	}
+	return nil, nil
+}
+
+func (db *RegistryMockMongoDBClient) RestfulAPIGetMany(ctx context.Context, collName string, filter bson.M) ([]map[string]interface{}, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var documents []map[string]interface{}
+	for _, document := range db.collections[collName] {
+		if matches(document, filter) {
+			documents = append(documents, maps.Clone(document))
+		}
+	}
+	return documents, nil
+}
+
+func (db *RegistryMockMongoDBClient) RestfulAPIPutOne(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for i, document := range db.collections[collName] {
+		if matches(document, filter) {
+			db.collections[collName][i] = maps.Clone(putData)
+			return true, nil
+		}
+	}
+	db.collections[collName] = append(db.collections[collName], maps.Clone(putData))
+	return false, nil
+}
+
+func (db *RegistryMockMongoDBClient) RestfulAPIDeleteOne(ctx context.Context, collName string, filter bson.M) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for i, document := range db.collections[collName] {
+		if matches(document, filter) {
+			db.collections[collName] = append(db.collections[collName][:i], db.collections[collName][i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (db *RegistryMockMongoDBClient) put(collName string, document map[string]interface{}) {
+	db.collections[collName] = append(db.collections[collName], document)
+}
+
+// origins returns the origin of each mirrored profile, by NF instance
+func (db *RegistryMockMongoDBClient) origins() map[string]interface{} {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	origins := map[string]interface{}{}
+	for _, document := range db.collections[federation.CollName] {
+		origins[fmt.Sprint(document["nfInstanceId"])] = document[federation.OriginField]
+	}
+	return origins
+}
+
+// peerNRF is the NRF of another site, answering its registered profiles
+type peerNRF struct {
+	*httptest.Server
+	mu            sync.Mutex
+	profiles      map[string]models.NfProfile
+	subscriptions []models.NrfSubscriptionData
+}
+
+func newPeerNRF(t *testing.T, profiles ...models.NfProfile) *peerNRF {
+	t.Helper()
+	peer := &peerNRF{profiles: map[string]models.NfProfile{}}
+	for _, profile := range profiles {
+		peer.profiles[profile.NfInstanceId] = profile
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /nnrf-disc/v1/nf-instances", func(w http.ResponseWriter, r *http.Request) {
+		peer.mu.Lock()
+		defer peer.mu.Unlock()
+		result := models.SearchResult{NfInstances: []models.NfProfile{}}
+		query := r.URL.Query()
+		for _, profile := range peer.profiles {
+			if string(profile.NfType) != query.Get("target-nf-type") {
+				continue
+			}
+			if id := query.Get("target-nf-instance-id"); id != "" && id != profile.NfInstanceId {
+				continue
+			}
+			result.NfInstances = append(result.NfInstances, profile)
+		}
+		writeJSON(w, http.StatusOK, result)
+	})
+	mux.HandleFunc("GET /nnrf-nfm/v1/nf-instances/{id}", func(w http.ResponseWriter, r *http.Request) {
+		peer.mu.Lock()
+		defer peer.mu.Unlock()
+		profile, ok := peer.profiles[r.PathValue("id")]
+		if !ok {
+			writeJSON(w, http.StatusNotFound, models.ProblemDetails{Status: http.StatusNotFound})
+			return
+		}
+		writeJSON(w, http.StatusOK, profile)
+	})
+	mux.HandleFunc("POST /nnrf-nfm/v1/subscriptions", func(w http.ResponseWriter, r *http.Request) {
+		var subscription models.NrfSubscriptionData
+		if err := json.NewDecoder(r.Body).Decode(&subscription); err != nil {
+			writeJSON(w, http.StatusBadRequest, models.ProblemDetails{Status: http.StatusBadRequest})
+			return
+		}
+		peer.mu.Lock()
+		defer peer.mu.Unlock()
+		subscription.SubscriptionId = fmt.Sprint(len(peer.subscriptions) + 1)
+		peer.subscriptions = append(peer.subscriptions, subscription)
+		writeJSON(w, http.StatusCreated, subscription)
+	})
+	peer.Server = httptest.NewServer(mux)
+	t.Cleanup(peer.Close)
+	return peer
+}
+
+func (peer *peerNRF) register(profile models.NfProfile) {
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+	peer.profiles[profile.NfInstanceId] = profile
+}
+
+func (peer *peerNRF) deregister(nfInstanceId string) {
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+	delete(peer.profiles, nfInstanceId)
+}
+
+func (peer *peerNRF) subscriptionCount() int {
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+	return len(peer.subscriptions)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func amfProfile(nfInstanceId string) models.NfProfile {
+	return models.NfProfile{
+		NfInstanceId: nfInstanceId,
+		NfType:       models.NfType_AMF,
+		NfStatus:     models.NfStatus_REGISTERED,
+		PlmnList:     &[]models.PlmnId{{Mcc: "208", Mnc: "93"}},
+	}
+}
+
+func newTestContext(t *testing.T, db dbadapter.DBInterface, federationConfig *factory.Federation) *nrfContext.NRFContext {
+	t.Helper()
+	config, err := factory.ReadConfig("../nrfTest/nrfcfg.yaml")
+	if err != nil {
+		t.Fatalf("failed to read test configuration: %v", err)
+	}
+	config.Configuration.Federation = federationConfig
+	return nrfContext.New(config, db, logger.Default())
+}
+
+func newTestFederator(t *testing.T, db dbadapter.DBInterface, peer *peerNRF) *federation.Federator {
+	t.Helper()
+	f, err := federation.New(newTestContext(t, db, &factory.Federation{
+		Peers: []factory.FederationPeer{{Name: "site-b", Uri: peer.URL, NfTypes: []string{"AMF"}}},
+	}))
+	if err != nil {
+		t.Fatalf("failed to create the federator: %v", err)
+	}
+	return f
+}
+
+func TestSync(t *testing.T) {
+	peer := newPeerNRF(t, amfProfile("amf-b"), amfProfile("amf-a"))
+	db := newRegistryMockMongoDBClient()
+	// amf-a is registered with both NRFs, the local profile wins
+	db.put("NfProfile", map[string]interface{}{"nfInstanceId": "amf-a", "nfType": "AMF"})
+	f := newTestFederator(t, db, peer)
+
+	f.Sync(context.Background(), "site-b")
+	origins := db.origins()
+	if len(origins) != 1 || origins["amf-b"] != "site-b" {
+		t.Errorf("expected amf-b only to be mirrored from site-b, got %v", origins)
+	}
+	if peer.subscriptionCount() != 1 {
+		t.Fatalf("expected 1 subscription to the peer, got %d", peer.subscriptionCount())
+	}
+	subscription := peer.subscriptions[0]
+	if subscription.NfStatusNotificationUri != "http://127.0.0.10:8000/nnrf-federation/v1/notify/site-b" ||
+		subscription.ReqNfType != models.NfType_NRF {
+		t.Errorf("unexpected subscription: %+v", subscription)
+	}
+
+	// the subscription is kept, the profiles the peer no longer answers are
+	// removed
+	peer.deregister("amf-b")
+	peer.register(amfProfile("amf-c"))
+	f.Sync(context.Background(), "site-b")
+	origins = db.origins()
+	if len(origins) != 1 || origins["amf-c"] != "site-b" {
+		t.Errorf("expected amf-c only to be mirrored from site-b, got %v", origins)
+	}
+	if peer.subscriptionCount() != 1 {
+		t.Errorf("expected the subscription to be kept, got %d subscriptions", peer.subscriptionCount())
+	}
+}
+
+func TestSyncConflict(t *testing.T) {
+	peer := newPeerNRF(t, amfProfile("amf-b"))
+	db := newRegistryMockMongoDBClient()
+	f := newTestFederator(t, db, peer)
+
+	// a profile mirrored from another peer is kept while refreshed, and
+	// replaced once expired
+	db.put(federation.CollName, map[string]interface{}{
+		"nfInstanceId": "amf-b", "nfType": "AMF", federation.OriginField: "site-c", "expireAt": time.Now().Add(time.Minute),
+	})
+	f.Sync(context.Background(), "site-b")
+	if origins := db.origins(); origins["amf-b"] != "site-c" {
+		t.Errorf("expected amf-b to stay mirrored from site-c, got %v", origins)
+	}
+
+	db.collections[federation.CollName][0]["expireAt"] = time.Now().Add(-time.Second)
+	f.Sync(context.Background(), "site-b")
+	if origins := db.origins(); origins["amf-b"] != "site-b" {
+		t.Errorf("expected amf-b to be mirrored from site-b, got %v", origins)
+	}
+}
+
+func TestNotifications(t *testing.T) {
+	peer := newPeerNRF(t)
+	db := newRegistryMockMongoDBClient()
+	f := newTestFederator(t, db, peer)
+	router := gin.New()
+	f.AddService(router)
+
+	notify := func(peerName string, event models.NotificationEventType, nfInstanceId string) int {
+		body, err := json.Marshal(models.NotificationData{
+			Event:         event,
+			NfInstanceUri: peer.URL + "/nnrf-nfm/v1/nf-instances/" + nfInstanceId,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/nnrf-federation/v1/notify/"+url.PathEscape(peerName), bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	peer.register(amfProfile("amf-b"))
+	if status := notify("site-b", models.NotificationEventType_REGISTERED, "amf-b"); status != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", status)
+	}
+	if origins := db.origins(); origins["amf-b"] != "site-b" {
+		t.Errorf("expected amf-b to be mirrored from site-b, got %v", origins)
+	}
+
+	peer.deregister("amf-b")
+	if status := notify("site-b", models.NotificationEventType_DEREGISTERED, "amf-b"); status != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", status)
+	}
+	if origins := db.origins(); len(origins) != 0 {
+		t.Errorf("expected amf-b to be removed, got %v", origins)
+	}
+
+	if status := notify("site-x", models.NotificationEventType_REGISTERED, "amf-x"); status != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown peer, got %d", status)
+	}
+}
+
+func TestDiscoveryPolicy(t *testing.T) {
+	newProducer := func(policy string, local bool) *producer.Producer {
+		db := newRegistryMockMongoDBClient()
+		if local {
+			db.put("NfProfile", map[string]interface{}{"nfInstanceId": "amf-a", "nfType": "AMF", "nfStatus": "REGISTERED"})
+		}
+		for id, expireAt := range map[string]time.Time{
+			"amf-a":       time.Now().Add(time.Minute),
+			"amf-b":       time.Now().Add(time.Minute),
+			"amf-expired": time.Now().Add(-time.Second),
+		} {
+			db.put(federation.CollName, map[string]interface{}{
+				"nfInstanceId": id, "nfType": "AMF", "nfStatus": "REGISTERED",
+				federation.OriginField: "site-b", "expireAt": expireAt,
+			})
+		}
+		return producer.New(newTestContext(t, db, &factory.Federation{
+			Peers:     []factory.FederationPeer{{Name: "site-b", Uri: "http://nrf.site-b:29510"}},
+			Discovery: policy,
+		}))
+	}
+	discover := func(p *producer.Producer, requesterNfType string) []string {
+		result, problemDetails := p.NFDiscoveryProcedure(context.Background(), url.Values{
+			"target-nf-type":    {"AMF"},
+			"requester-nf-type": {requesterNfType},
+		})
+		if problemDetails != nil {
+			t.Fatalf("unexpected discovery failure: %+v", problemDetails)
+		}
+		var ids []string
+		for _, profile := range result.NfInstances {
+			ids = append(ids, profile.NfInstanceId)
+		}
+		return ids
+	}
+
+	testCases := []struct {
+		name            string
+		policy          string
+		local           bool
+		requesterNfType string
+		expected        string
+	}{
+		{"local first with local profiles", factory.NRF_FEDERATION_LOCAL_FIRST, true, "SMF", "[amf-a]"},
+		{"local first without local profiles", factory.NRF_FEDERATION_LOCAL_FIRST, false, "SMF", "[amf-a amf-b]"},
+		{"merged", factory.NRF_FEDERATION_MERGED, true, "SMF", "[amf-a amf-b]"},
+		{"NRF requester", factory.NRF_FEDERATION_MERGED, true, "NRF", "[amf-a]"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ids := discover(newProducer(tc.policy, tc.local), tc.requesterNfType)
+			slices.Sort(ids)
+			if fmt.Sprint(ids) != tc.expected {
+				t.Errorf("expected %s, got %v", tc.expected, ids)
+			}
+		})
+	}
+}
@@ -0,0 +1,459 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package forwarding relays the discovery queries to other NRFs, such as the
+// NRF of the home PLMN or a parent NRF, following the forwarding rules of the
+// configuration. The queries targeting PLMNs which are not served are relayed
+// to the NRFs of those PLMNs, through the SEPP when one is configured. The
+// results of the other NRFs are cached for their validity period.
+package forwarding
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/omec-project/nrf/client"
+	nrfContext "github.com/omec-project/nrf/context"
+	"github.com/omec-project/nrf/factory"
+	"github.com/omec-project/openapi/models"
+)
+
+const (
+	// maxHops bounds the NRFs a query is relayed through, so that NRFs
+	// relaying to each other do not loop
+	maxHops = 3
+	// viaReceivedBy is the pseudonym of NRF in the Via header of the relayed
+	// queries, which counts their hops
+	viaReceivedBy = "nrf"
+	// maxHnrfRelays bounds the home NRFs, given by the queries, the relays of
+	// which are kept
+	maxHnrfRelays = 32
+	// tokenScope is the scope of the access tokens of the relayed queries
+	tokenScope = "nnrf-disc"
+	// annotationKey is the customInfo key recording the NRF which answered a
+	// relayed profile
+	annotationKey = "nrfUri"
+	// roamingRule names the relays to the NRFs of other PLMNs in the metrics
+	roamingRule = "roaming"
+	// targetApiRootHeader tells the SEPP the API root of the NRF a query is
+	// relayed to
+	targetApiRootHeader = "3gpp-Sbi-Target-apiRoot"
+)
+
+type viaKey struct{}
+
+// WithVia returns ctx carrying the Via header of the discovery request, which
+// tells the NRFs the query was relayed through
+func WithVia(ctx context.Context, via []string) context.Context {
+	if len(via) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, viaKey{}, via)
+}
+
+func viaOf(ctx context.Context) []string {
+	via, _ := ctx.Value(viaKey{}).([]string)
+	return via
+}
+
+// hops counts the NRFs the query of ctx was relayed through
+func hops(ctx context.Context) int {
+	count := 0
+	for _, value := range viaOf(ctx) {
+		for _, entry := range strings.Split(value, ",") {
+			fields := strings.Fields(entry)
+			if len(fields) >= 2 && fields[1] == viaReceivedBy {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// Forwarder relays the discovery queries matching its rules
+type Forwarder struct {
+	nrfCtx *nrfContext.NRFContext
+	rules  []rule
+
+	// roaming resolves the queries targeting other PLMNs, nil when they are
+	// not resolved
+	roaming *factory.Roaming
+
+	mu sync.Mutex
+	// hnrfRelays are the relays to the home NRFs given by the queries
+	hnrfRelays map[string]*relay
+	// roamingRelays are the relays to the NRFs of other PLMNs
+	roamingRelays map[models.PlmnId]*relay
+}
+
+type rule struct {
+	factory.ForwardingRule
+	// relay is the relay to the NrfUri of the rule, nil without NrfUri
+	relay *relay
+}
+
+// relay sends the queries to one NRF and caches its results
+type relay struct {
+	uri       string
+	discovery *client.DiscoveryClient
+}
+
+// New creates the forwarder of the NRF instance described by nrfCtx. It
+// returns nil when neither forwarding rule nor roaming is configured.
+func New(nrfCtx *nrfContext.NRFContext) (*Forwarder, error) {
+	rules := nrfCtx.Config.GetDiscoveryForwarding()
+	roaming := nrfCtx.Config.GetRoaming()
+	if len(rules) == 0 && roaming == nil {
+		return nil, nil
+	}
+	f := &Forwarder{
+		nrfCtx:        nrfCtx,
+		roaming:       roaming,
+		hnrfRelays:    make(map[string]*relay),
+		roamingRelays: make(map[models.PlmnId]*relay),
+	}
+	for _, forwardingRule := range rules {
+		r := rule{ForwardingRule: forwardingRule}
+		if r.Name == "" {
+			r.Name = r.NrfUri
+		}
+		if r.NrfUri != "" {
+			var err error
+			if r.relay, err = f.newRelay(r.NrfUri, "", r.AccessToken); err != nil {
+				return nil, fmt.Errorf("discovery forwarding rule %s: %w", r.Name, err)
+			}
+		}
+		f.rules = append(f.rules, r)
+	}
+	return f, nil
+}
+
+// newRelay creates the relay to the NRF at uri, which authorises the queries
+// with its access tokens when accessToken is set. When targetApiRoot is set,
+// uri is the SEPP the queries are sent through to the NRF at targetApiRoot.
+func (f *Forwarder) newRelay(uri, targetApiRoot string, accessToken bool) (*relay, error) {
+	target := func(header http.Header) http.Header {
+		if targetApiRoot != "" {
+			header.Set(targetApiRootHeader, targetApiRoot)
+		}
+		return header
+	}
+	var tokens *client.TokenClient
+	if accessToken {
+		tokenClient, err := client.New(uri, client.WithHTTPClient(f.nrfCtx.HTTPClient()),
+			client.WithRequestHeaders(func(context.Context) (http.Header, error) {
+				return target(http.Header{}), nil
+			}))
+		if err != nil {
+			return nil, err
+		}
+		tokens = client.NewTokenClient(tokenClient)
+	}
+	headers := func(ctx context.Context) (http.Header, error) {
+		header := target(http.Header{})
+		for _, via := range viaOf(ctx) {
+			header.Add("Via", via)
+		}
+		header.Add("Via", "2.0 "+viaReceivedBy)
+		if tokens == nil {
+			return header, nil
+		}
+		token, err := tokens.Token(ctx, models.AccessTokenReq{
+			GrantType:    "client_credentials",
+			NfInstanceId: f.nrfCtx.GetNrfNfProfile().NfInstanceId,
+			NfType:       models.NfType_NRF,
+			TargetNfType: models.NfType_NRF,
+			Scope:        tokenScope,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("access token request failed: %w", err)
+		}
+		header.Set("Authorization", "Bearer "+token.AccessToken)
+		return header, nil
+	}
+	c, err := client.New(uri, client.WithHTTPClient(f.nrfCtx.HTTPClient()), client.WithRequestHeaders(headers))
+	if err != nil {
+		return nil, err
+	}
+	if targetApiRoot != "" {
+		uri = targetApiRoot
+	}
+	return &relay{uri: uri, discovery: client.NewDiscoveryClient(c)}, nil
+}
+
+// hnrfRelay returns the relay to the home NRF at uri
+func (f *Forwarder) hnrfRelay(uri string, accessToken bool) (*relay, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r, ok := f.hnrfRelays[uri]; ok {
+		return r, nil
+	}
+	r, err := f.newRelay(uri, "", accessToken)
+	if err != nil {
+		return nil, err
+	}
+	if len(f.hnrfRelays) == maxHnrfRelays {
+		f.hnrfRelays = make(map[string]*relay)
+	}
+	f.hnrfRelays[uri] = r
+	return r, nil
+}
+
+// Forward relays query to the NRF of the first rule matching it, and returns
+// local merged with, or replaced by, the result of that NRF. When no rule
+// matches, a query targeting PLMNs which are not served is relayed to the
+// NRFs of those PLMNs and their results are merged with local. local is
+// returned as is when the query is not relayed, when the query was relayed
+// through too many NRFs already, or when the NRF fails.
+func (f *Forwarder) Forward(ctx context.Context, query url.Values, local *models.SearchResult) *models.SearchResult {
+	log := f.nrfCtx.Log.DiscoveryLog
+	if hops := hops(ctx); hops >= maxHops {
+		log.Warnf("discovery query relayed through %d NRFs, not relayed further", hops)
+		return local
+	}
+	for _, r := range f.rules {
+		target, err := f.match(r, query, local)
+		if err != nil {
+			log.Warnf("discovery forwarding rule %s: %v", r.Name, err)
+			continue
+		}
+		if target == nil {
+			continue
+		}
+		// the NRF the query is relayed to answers it from its own registry
+		relayed := maps.Clone(query)
+		delete(relayed, "hnrf-uri")
+		remote, err := target.discovery.Search(ctx, models.NfType(query.Get("target-nf-type")),
+			models.NfType(query.Get("requester-nf-type")), relayed)
+		if err != nil {
+			log.Warnf("discovery query relayed to %s by rule %s failed: %v", target.uri, r.Name, err)
+			f.nrfCtx.Metrics.IncrementDiscoveryForwardsStats(r.Name, "FAILURE")
+			return local
+		}
+		f.nrfCtx.Metrics.IncrementDiscoveryForwardsStats(r.Name, "SUCCESS")
+		log.Debugf("discovery query relayed to %s by rule %s: %d NF instances", target.uri, r.Name, len(remote.NfInstances))
+		return r.combine(target, local, remote)
+	}
+	return f.roam(ctx, query, local)
+}
+
+// match returns the relay of r for query, nil when r does not match query
+func (f *Forwarder) match(r rule, query url.Values, local *models.SearchResult) (*relay, error) {
+	if r.OnEmpty && local != nil && len(local.NfInstances) != 0 {
+		return nil, nil
+	}
+	if len(r.NfTypes) != 0 && !slices.Contains(r.NfTypes, query.Get("target-nf-type")) {
+		return nil, nil
+	}
+	if len(r.TargetPlmns) != 0 {
+		if query.Get("target-plmn-list") == "" {
+			return nil, nil
+		}
+		targetPlmns, err := nrfContext.ParsePlmnList(query.Get("target-plmn-list"))
+		if err != nil {
+			return nil, err
+		}
+		if !slices.ContainsFunc(targetPlmns, func(plmn models.PlmnId) bool {
+			return slices.Contains(r.TargetPlmns, plmn)
+		}) {
+			return nil, nil
+		}
+	}
+	if hnrfUri := query.Get("hnrf-uri"); r.HnrfUri && hnrfUri != "" {
+		if err := f.checkHnrfUri(r, hnrfUri); err != nil {
+			return nil, fmt.Errorf("hnrf-uri %q %w", hnrfUri, err)
+		}
+		return f.hnrfRelay(hnrfUri, r.AccessToken)
+	}
+	return r.relay, nil
+}
+
+// CheckHnrfUri returns why the hnrf-uri of query is rejected, nil when query
+// carries none or no rule relays to it. The queries cannot make NRF send
+// requests to any host: an hnrf-uri must target a home NRF of the roaming
+// configuration or one of the hnrfHosts of a rule relaying to it.
+func (f *Forwarder) CheckHnrfUri(query url.Values) error {
+	hnrfUri := query.Get("hnrf-uri")
+	if hnrfUri == "" {
+		return nil
+	}
+	var err error
+	for _, r := range f.rules {
+		if !r.HnrfUri {
+			continue
+		}
+		if err = f.checkHnrfUri(r, hnrfUri); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// checkHnrfUri returns why r does not relay to hnrfUri, nil when it does
+func (f *Forwarder) checkHnrfUri(r rule, hnrfUri string) error {
+	hnrfUrl, err := url.ParseRequestURI(hnrfUri)
+	if err != nil || (hnrfUrl.Scheme != "http" && hnrfUrl.Scheme != "https") || hnrfUrl.Hostname() == "" {
+		return fmt.Errorf("must be an http or https URI")
+	}
+	// a host without port allows any port
+	if slices.ContainsFunc(r.HnrfHosts, func(host string) bool {
+		return strings.EqualFold(host, hnrfUrl.Host) || strings.EqualFold(host, hnrfUrl.Hostname())
+	}) {
+		return nil
+	}
+	if f.roaming != nil {
+		for _, homeNrf := range f.roaming.HomeNrfs {
+			homeNrfUrl, err := url.Parse(homeNrf.Uri)
+			if err == nil && strings.EqualFold(homeNrfUrl.Host, hnrfUrl.Host) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("targets %s, which is not an allowed home NRF", hnrfUrl.Host)
+}
+
+// combine returns the result of the query relayed by r to target: remote,
+// added to local unless the rule replaces it. The local profile wins over a
+// relayed one of the same NF instance. The result is valid as long as both
+// results are.
+func (r rule) combine(target *relay, local *models.SearchResult, remote models.SearchResult) *models.SearchResult {
+	result := &models.SearchResult{NfInstances: []models.NfProfile{}}
+	known := map[string]bool{}
+	if local != nil {
+		result.ValidityPeriod = local.ValidityPeriod
+		result.NrfSupportedFeatures = local.NrfSupportedFeatures
+		if r.Results != factory.NRF_FORWARDING_REPLACE {
+			result.NfInstances = append(result.NfInstances, local.NfInstances...)
+			for _, profile := range local.NfInstances {
+				known[profile.NfInstanceId] = true
+			}
+		}
+	}
+	if remote.ValidityPeriod > 0 && (result.ValidityPeriod == 0 || remote.ValidityPeriod < result.ValidityPeriod) {
+		result.ValidityPeriod = remote.ValidityPeriod
+	}
+	for _, profile := range remote.NfInstances {
+		if known[profile.NfInstanceId] {
+			continue
+		}
+		known[profile.NfInstanceId] = true
+		if r.Annotate {
+			// the relayed profiles are shared with the cache
+			customInfo := maps.Clone(profile.CustomInfo)
+			if customInfo == nil {
+				customInfo = map[string]interface{}{}
+			}
+			customInfo[annotationKey] = target.uri
+			profile.CustomInfo = customInfo
+		}
+		result.NfInstances = append(result.NfInstances, profile)
+	}
+	return result
+}
+
+// roam relays query to the NRFs of the PLMNs of its target-plmn-list which are
+// not served, and merges their results with local. The relayed query carries
+// the serving PLMNs as requester-plmn-list unless it gives one.
+func (f *Forwarder) roam(ctx context.Context, query url.Values, local *models.SearchResult) *models.SearchResult {
+	if f.roaming == nil || query.Get("target-plmn-list") == "" {
+		return local
+	}
+	log := f.nrfCtx.Log.DiscoveryLog
+	targetPlmns, err := nrfContext.ParsePlmnList(query.Get("target-plmn-list"))
+	if err != nil {
+		log.Warnf("discovery query not relayed to other PLMNs: %v", err)
+		return local
+	}
+	servingPlmns, err := f.nrfCtx.ServingPlmns(ctx)
+	if err != nil {
+		log.Warnf("discovery query not relayed to other PLMNs: %v", err)
+		return local
+	}
+	relayed := maps.Clone(query)
+	delete(relayed, "hnrf-uri")
+	if relayed.Get("requester-plmn-list") == "" {
+		requesterPlmns, err := json.Marshal(servingPlmns)
+		if err != nil {
+			log.Warnf("discovery query not relayed to other PLMNs: %v", err)
+			return local
+		}
+		relayed.Set("requester-plmn-list", string(requesterPlmns))
+	}
+	r := rule{ForwardingRule: factory.ForwardingRule{Name: roamingRule}}
+	result := local
+	// each PLMN is relayed to once, however often target-plmn-list lists it
+	relayedPlmns := make(map[models.PlmnId]bool, len(targetPlmns))
+	for _, plmn := range targetPlmns {
+		if relayedPlmns[plmn] || slices.Contains(servingPlmns, plmn) {
+			continue
+		}
+		relayedPlmns[plmn] = true
+		target, err := f.roamingRelay(plmn)
+		if err != nil {
+			log.Warnf("discovery query not relayed to PLMN %s%s: %v", plmn.Mcc, plmn.Mnc, err)
+			continue
+		}
+		remote, err := target.discovery.Search(ctx, models.NfType(query.Get("target-nf-type")),
+			models.NfType(query.Get("requester-nf-type")), relayed)
+		if err != nil {
+			log.Warnf("discovery query relayed to %s of PLMN %s%s failed: %v", target.uri, plmn.Mcc, plmn.Mnc, err)
+			f.nrfCtx.Metrics.IncrementDiscoveryForwardsStats(roamingRule, "FAILURE")
+			continue
+		}
+		f.nrfCtx.Metrics.IncrementDiscoveryForwardsStats(roamingRule, "SUCCESS")
+		log.Debugf("discovery query relayed to %s of PLMN %s%s: %d NF instances", target.uri, plmn.Mcc, plmn.Mnc,
+			len(remote.NfInstances))
+		result = r.combine(target, result, remote)
+	}
+	return result
+}
+
+// roamingRelay returns the relay to the NRF of plmn, through the SEPP when one
+// is configured
+func (f *Forwarder) roamingRelay(plmn models.PlmnId) (*relay, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r, ok := f.roamingRelays[plmn]; ok {
+		return r, nil
+	}
+	apiRoot := homeNrfApiRoot(plmn)
+	for _, homeNrf := range f.roaming.HomeNrfs {
+		if homeNrf.PlmnId == plmn {
+			apiRoot = homeNrf.Uri
+		}
+	}
+	var r *relay
+	var err error
+	if f.roaming.SeppUri != "" {
+		r, err = f.newRelay(f.roaming.SeppUri, apiRoot, f.roaming.AccessToken)
+	} else {
+		r, err = f.newRelay(apiRoot, "", f.roaming.AccessToken)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// the PLMNs are given by the queries, like the home NRFs
+	if len(f.roamingRelays) == maxHnrfRelays {
+		f.roamingRelays = make(map[models.PlmnId]*relay)
+	}
+	f.roamingRelays[plmn] = r
+	return r, nil
+}
+
+// homeNrfApiRoot returns the API root of the NRF of plmn in the 3GPP network
+// domain (TS 23.003 28.3.2.3)
+func homeNrfApiRoot(plmn models.PlmnId) string {
+	mnc := plmn.Mnc
+	if len(mnc) == 2 {
+		mnc = "0" + mnc
+	}
+	return fmt.Sprintf("https://nrf.5gc.mnc%s.mcc%s.3gppnetwork.org", mnc, plmn.Mcc)
+}
@@ -0,0 +1,374 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package forwarding_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	nrfContext "github.com/omec-project/nrf/context"
+	"github.com/omec-project/nrf/factory"
+	"github.com/omec-project/nrf/forwarding"
+	"github.com/omec-project/nrf/logger"
+	"github.com/omec-project/nrf/metrics"
+	"github.com/omec-project/openapi/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// standInNRF answers the relayed discovery queries with its profiles
+type standInNRF struct {
+	*httptest.Server
+	profiles []models.NfProfile
+	fail     bool
+
+	mu      sync.Mutex
+	queries []*http.Request
+	tokens  int
+}
+
+func newStandInNRF(t *testing.T, profiles ...models.NfProfile) *standInNRF {
+	t.Helper()
+	nrf := &standInNRF{profiles: profiles}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /nnrf-disc/v1/nf-instances", func(w http.ResponseWriter, r *http.Request) {
+		nrf.mu.Lock()
+		nrf.queries = append(nrf.queries, r)
+		nrf.mu.Unlock()
+		if nrf.fail {
+			writeJSON(w, http.StatusInternalServerError, models.ProblemDetails{Status: http.StatusInternalServerError})
+			return
+		}
+		writeJSON(w, http.StatusOK, models.SearchResult{ValidityPeriod: 30, NfInstances: nrf.profiles})
+	})
+	mux.HandleFunc("POST /oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		nrf.mu.Lock()
+		nrf.tokens++
+		nrf.mu.Unlock()
+		writeJSON(w, http.StatusOK, models.AccessTokenRsp{AccessToken: "hnrf-token", TokenType: "Bearer", ExpiresIn: 3600})
+	})
+	nrf.Server = httptest.NewServer(mux)
+	t.Cleanup(nrf.Close)
+	return nrf
+}
+
+func (nrf *standInNRF) queryCount() int {
+	nrf.mu.Lock()
+	defer nrf.mu.Unlock()
+	return len(nrf.queries)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func newTestForwarder(t *testing.T, rules ...factory.ForwardingRule) *forwarding.Forwarder {
+	t.Helper()
+	config, err := factory.ReadConfig("../nrfTest/nrfcfg.yaml")
+	if err != nil {
+		t.Fatalf("failed to read test configuration: %v", err)
+	}
+	config.Configuration.DiscoveryForwarding = &factory.DiscoveryForwarding{Rules: rules}
+	f, err := forwarding.New(nrfContext.New(config, nil, logger.Default()))
+	if err != nil {
+		t.Fatalf("failed to create the forwarder: %v", err)
+	}
+	return f
+}
+
+func udmQuery(extra url.Values) url.Values {
+	query := url.Values{"target-nf-type": {"UDM"}, "requester-nf-type": {"AMF"}}
+	for name, values := range extra {
+		query[name] = values
+	}
+	return query
+}
+
+func ids(result *models.SearchResult) []string {
+	var ids []string
+	for _, profile := range result.NfInstances {
+		ids = append(ids, profile.NfInstanceId)
+	}
+	return ids
+}
+
+func TestForwardOnEmpty(t *testing.T) {
+	hnrf := newStandInNRF(t, models.NfProfile{NfInstanceId: "udm-home", NfType: models.NfType_UDM})
+	f := newTestForwarder(t, factory.ForwardingRule{
+		Name: "home", NrfUri: hnrf.URL, OnEmpty: true, AccessToken: true, Annotate: true,
+	})
+
+	// a query answered locally is not relayed
+	local := &models.SearchResult{ValidityPeriod: 100, NfInstances: []models.NfProfile{{NfInstanceId: "udm-local"}}}
+	if result := f.Forward(context.Background(), udmQuery(nil), local); result != local {
+		t.Errorf("expected the local result, got %v", ids(result))
+	}
+
+	empty := &models.SearchResult{ValidityPeriod: 100, NfInstances: []models.NfProfile{}}
+	for range 2 {
+		result := f.Forward(context.Background(), udmQuery(nil), empty)
+		if len(result.NfInstances) != 1 || result.NfInstances[0].NfInstanceId != "udm-home" {
+			t.Fatalf("expected the profile of the home NRF, got %v", ids(result))
+		}
+		if result.ValidityPeriod != 30 {
+			t.Errorf("expected the validity period of the home NRF, got %d", result.ValidityPeriod)
+		}
+		if result.NfInstances[0].CustomInfo["nrfUri"] != hnrf.URL {
+			t.Errorf("expected the profile to be annotated with %s, got %v", hnrf.URL, result.NfInstances[0].CustomInfo)
+		}
+	}
+	// the result is cached for its validity period
+	if count := hnrf.queryCount(); count != 1 {
+		t.Fatalf("expected 1 relayed query, got %d", count)
+	}
+	query := hnrf.queries[0]
+	if query.Header.Get("Authorization") != "Bearer hnrf-token" || hnrf.tokens != 1 {
+		t.Errorf("expected the query to carry the access token of the home NRF, got %q", query.Header.Get("Authorization"))
+	}
+	if query.Header.Get("Via") != "2.0 nrf" {
+		t.Errorf("expected the query to carry the Via of NRF, got %q", query.Header.Get("Via"))
+	}
+	if query.URL.Query().Get("requester-nf-type") != "AMF" {
+		t.Errorf("expected the query to be relayed as is, got %s", query.URL.RawQuery)
+	}
+}
+
+func TestForwardRules(t *testing.T) {
+	home := newStandInNRF(t, models.NfProfile{NfInstanceId: "udm-home"}, models.NfProfile{NfInstanceId: "udm-local"})
+	parent := newStandInNRF(t, models.NfProfile{NfInstanceId: "udm-parent"})
+	f := newTestForwarder(t,
+		factory.ForwardingRule{
+			Name: "home", NrfUri: home.URL, TargetPlmns: []models.PlmnId{{Mcc: "001", Mnc: "01"}},
+		},
+		factory.ForwardingRule{
+			Name: "parent", NrfUri: parent.URL, NfTypes: []string{"UDM"}, Results: factory.NRF_FORWARDING_REPLACE,
+		},
+	)
+	local := &models.SearchResult{ValidityPeriod: 100, NfInstances: []models.NfProfile{{NfInstanceId: "udm-local"}}}
+
+	testCases := []struct {
+		name     string
+		query    url.Values
+		expected []string
+	}{
+		{
+			name:     "target PLMN merged",
+			query:    udmQuery(url.Values{"target-plmn-list": {`{"mcc":"001","mnc":"01"}`}}),
+			expected: []string{"udm-local", "udm-home"},
+		},
+		{
+			name:     "NF type replaced",
+			query:    udmQuery(url.Values{"target-plmn-list": {`[{"mcc":"002","mnc":"02"}]`}}),
+			expected: []string{"udm-parent"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := f.Forward(context.Background(), tc.query, local)
+			if got := ids(result); fmt.Sprint(got) != fmt.Sprint(tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+
+	// a query of another NF type matches no rule
+	query := url.Values{"target-nf-type": {"AMF"}, "requester-nf-type": {"SMF"}}
+	if result := f.Forward(context.Background(), query, local); result != local {
+		t.Errorf("expected the local result, got %v", ids(result))
+	}
+}
+
+func TestForwardHnrfUri(t *testing.T) {
+	hnrf := newStandInNRF(t, models.NfProfile{NfInstanceId: "ausf-home"})
+	other := newStandInNRF(t, models.NfProfile{NfInstanceId: "ausf-other"})
+	hnrfUrl, err := url.Parse(hnrf.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := newTestForwarder(t, factory.ForwardingRule{Name: "visited", HnrfUri: true, HnrfHosts: []string{hnrfUrl.Host}})
+	empty := &models.SearchResult{NfInstances: []models.NfProfile{}}
+
+	// a query without hnrf-uri matches no rule
+	if result := f.Forward(context.Background(), udmQuery(nil), empty); result != empty {
+		t.Errorf("expected the local result, got %v", ids(result))
+	}
+	result := f.Forward(context.Background(), udmQuery(url.Values{"hnrf-uri": {hnrf.URL}}), empty)
+	if got := ids(result); len(got) != 1 || got[0] != "ausf-home" {
+		t.Errorf("expected the profile of the hnrf-uri, got %v", got)
+	}
+	if hnrf.queryCount() == 1 && hnrf.queries[0].URL.Query().Has("hnrf-uri") {
+		t.Error("expected the hnrf-uri not to be relayed to the home NRF")
+	}
+
+	// the other NRF runs on another port of the same host
+	for _, hnrfUri := range []string{other.URL, "ftp://" + hnrfUrl.Host, "http://" + hnrfUrl.Host + "@example.com"} {
+		if err := f.CheckHnrfUri(udmQuery(url.Values{"hnrf-uri": {hnrfUri}})); err == nil {
+			t.Errorf("expected hnrf-uri %s to be rejected", hnrfUri)
+		}
+		if result := f.Forward(context.Background(), udmQuery(url.Values{"hnrf-uri": {hnrfUri}}), empty); result != empty {
+			t.Errorf("expected the local result for hnrf-uri %s, got %v", hnrfUri, ids(result))
+		}
+	}
+	if other.queryCount() != 0 {
+		t.Errorf("expected no query relayed to the other NRF, got %d", other.queryCount())
+	}
+	if err := f.CheckHnrfUri(udmQuery(url.Values{"hnrf-uri": {hnrf.URL}})); err != nil {
+		t.Errorf("expected the hnrf-uri of the allowed host to be accepted, got %v", err)
+	}
+}
+
+func TestCheckHnrfUriHomeNrfs(t *testing.T) {
+	config, err := factory.ReadConfig("../nrfTest/nrfcfg.yaml")
+	if err != nil {
+		t.Fatalf("failed to read test configuration: %v", err)
+	}
+	config.Configuration.DiscoveryForwarding = &factory.DiscoveryForwarding{Rules: []factory.ForwardingRule{
+		{Name: "visited", HnrfUri: true},
+	}}
+	config.Configuration.Roaming = &factory.Roaming{HomeNrfs: []factory.HomeNrf{
+		{PlmnId: models.PlmnId{Mcc: "001", Mnc: "01"}, Uri: "https://nrf.home:29510"},
+	}}
+	f, err := forwarding.New(nrfContext.New(config, nil, logger.Default()))
+	if err != nil {
+		t.Fatalf("failed to create the forwarder: %v", err)
+	}
+	testCases := []struct {
+		hnrfUri string
+		valid   bool
+	}{
+		{hnrfUri: "https://nrf.home:29510", valid: true},
+		{hnrfUri: "https://NRF.home:29510/nnrf-disc/v1", valid: true},
+		{hnrfUri: "https://nrf.home:8080"},
+		{hnrfUri: "http://169.254.169.254/latest"},
+		{hnrfUri: "nrf.home:29510"},
+	}
+	for _, tc := range testCases {
+		err := f.CheckHnrfUri(udmQuery(url.Values{"hnrf-uri": {tc.hnrfUri}}))
+		if tc.valid && err != nil {
+			t.Errorf("expected hnrf-uri %s to be accepted, got %v", tc.hnrfUri, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("expected hnrf-uri %s to be rejected", tc.hnrfUri)
+		}
+	}
+}
+
+func TestForwardLimits(t *testing.T) {
+	hnrf := newStandInNRF(t, models.NfProfile{NfInstanceId: "udm-home"})
+	f := newTestForwarder(t, factory.ForwardingRule{Name: "home", NrfUri: hnrf.URL, OnEmpty: true})
+	empty := &models.SearchResult{NfInstances: []models.NfProfile{}}
+
+	// a query relayed through too many NRFs is not relayed further
+	ctx := forwarding.WithVia(context.Background(), []string{"2.0 nrf, 2.0 scp", "2.0 nrf", "2.0 nrf"})
+	if result := f.Forward(ctx, udmQuery(nil), empty); result != empty {
+		t.Errorf("expected the local result, got %v", ids(result))
+	}
+	if hnrf.queryCount() != 0 {
+		t.Errorf("expected no relayed query, got %d", hnrf.queryCount())
+	}
+
+	// a query relayed once keeps its hops
+	ctx = forwarding.WithVia(context.Background(), []string{"2.0 nrf"})
+	f.Forward(ctx, udmQuery(nil), empty)
+	if hnrf.queryCount() != 1 || len(hnrf.queries[0].Header.Values("Via")) != 2 {
+		t.Errorf("expected the relayed query to carry 2 Via entries")
+	}
+
+	// the local result is answered when the NRF fails
+	hnrf.fail = true
+	if result := f.Forward(context.Background(), udmQuery(url.Values{"dnn": {"internet"}}), empty); result != empty {
+		t.Errorf("expected the local result, got %v", ids(result))
+	}
+}
+
+func TestForwardRoaming(t *testing.T) {
+	sepp := newStandInNRF(t, models.NfProfile{NfInstanceId: "udm-home"})
+	config, err := factory.ReadConfig("../nrfTest/nrfcfg.yaml")
+	if err != nil {
+		t.Fatalf("failed to read test configuration: %v", err)
+	}
+	config.Configuration.Roaming = &factory.Roaming{
+		SeppUri:     sepp.URL,
+		AccessToken: true,
+		HomeNrfs:    []factory.HomeNrf{{PlmnId: models.PlmnId{Mcc: "002", Mnc: "02"}, Uri: "https://nrf.home:29510"}},
+	}
+	nrfCtx := nrfContext.New(config, nil, logger.Default())
+	nrfCtx.FetchPlmnConfig = func(context.Context) ([]models.PlmnId, error) {
+		return []models.PlmnId{{Mcc: "208", Mnc: "93"}}, nil
+	}
+	registry := prometheus.NewRegistry()
+	if nrfCtx.Metrics, err = metrics.NewNrfStats(registry); err != nil {
+		t.Fatal(err)
+	}
+	f, err := forwarding.New(nrfCtx)
+	if err != nil {
+		t.Fatalf("failed to create the forwarder: %v", err)
+	}
+	local := &models.SearchResult{ValidityPeriod: 100, NfInstances: []models.NfProfile{}}
+
+	// a query targeting the serving PLMN is answered locally
+	query := udmQuery(url.Values{"target-plmn-list": {`{"mcc":"208","mnc":"93"}`}})
+	if result := f.Forward(context.Background(), query, local); result != local {
+		t.Errorf("expected the local result, got %v", ids(result))
+	}
+
+	testCases := []struct {
+		name    string
+		plmn    string
+		apiRoot string
+	}{
+		{name: "default NRF", plmn: `{"mcc":"001","mnc":"01"}`, apiRoot: "https://nrf.5gc.mnc001.mcc001.3gppnetwork.org"},
+		{name: "configured NRF", plmn: `{"mcc":"002","mnc":"02"}`, apiRoot: "https://nrf.home:29510"},
+	}
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query := udmQuery(url.Values{"target-plmn-list": {tc.plmn}})
+			result := f.Forward(context.Background(), query, local)
+			if got := ids(result); len(got) != 1 || got[0] != "udm-home" {
+				t.Fatalf("expected the profile of the home NRF, got %v", got)
+			}
+			if count := sepp.queryCount(); count != i+1 {
+				t.Fatalf("expected %d relayed queries, got %d", i+1, count)
+			}
+			relayed := sepp.queries[i]
+			if apiRoot := relayed.Header.Get("3gpp-Sbi-Target-apiRoot"); apiRoot != tc.apiRoot {
+				t.Errorf("expected the query to target %s, got %q", tc.apiRoot, apiRoot)
+			}
+			if plmns := relayed.URL.Query().Get("requester-plmn-list"); plmns != `[{"mcc":"208","mnc":"93"}]` {
+				t.Errorf("expected the serving PLMNs as requester-plmn-list, got %q", plmns)
+			}
+			if relayed.Header.Get("Authorization") != "Bearer hnrf-token" {
+				t.Errorf("expected the access token of the home NRF, got %q", relayed.Header.Get("Authorization"))
+			}
+		})
+	}
+	// a PLMN listed twice is relayed to once
+	query = udmQuery(url.Values{"target-plmn-list": {
+		`[{"mcc":"003","mnc":"03"},{"mcc":"004","mnc":"04"},{"mcc":"003","mnc":"03"}]`,
+	}})
+	f.Forward(context.Background(), query, local)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var forwards float64
+	for _, family := range families {
+		if family.GetName() == "nrf_discovery_forwards" {
+			for _, metric := range family.GetMetric() {
+				forwards += metric.GetCounter().GetValue()
+			}
+		}
+	}
+	if forwards != float64(len(testCases)+2) {
+		t.Errorf("expected %d discovery forwards, got %v", len(testCases)+2, forwards)
+	}
+}
@@ -0,0 +1,194 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+ * Health package reports the liveness and the readiness of the NRF along with
+ * the status of each of its dependencies.
+ */
+
+package health
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultProbeTimeout bounds the time a dependency has to answer a probe
+const DefaultProbeTimeout = 3 * time.Second
+
+// Status of a dependency, or of the NRF as a whole
+type Status string
+
+const (
+	StatusUp Status = "UP"
+	// StatusDegraded reports that only non critical dependencies are down
+	StatusDegraded Status = "DEGRADED"
+	StatusDown     Status = "DOWN"
+)
+
+// ProbeFunc probes a dependency. It returns a human readable detail on
+// success, and an error when the dependency is not usable.
+type ProbeFunc func(ctx context.Context) (string, error)
+
+// Check is the probe of one dependency
+type Check struct {
+	Name string
+	// Critical checks must pass for the NRF to be ready
+	Critical bool
+	Probe    ProbeFunc
+}
+
+// CheckResult is the status of one dependency
+type CheckResult struct {
+	Status   Status `json:"status"`
+	Critical bool   `json:"critical"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+// Report is the status of the NRF and of its dependencies
+type Report struct {
+	Status Status                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Checker runs the checks of the dependencies of the NRF
+type Checker struct {
+	checks       []Check
+	probeTimeout time.Duration
+}
+
+// NewChecker creates a checker running checks
+func NewChecker(checks ...Check) *Checker {
+	return &Checker{
+		checks:       checks,
+		probeTimeout: DefaultProbeTimeout,
+	}
+}
+
+// Report runs all the checks concurrently
+func (c *Checker) Report(ctx context.Context) Report {
+	results := make([]CheckResult, len(c.checks))
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{
+		Status: StatusUp,
+		Checks: make(map[string]CheckResult, len(c.checks)),
+	}
+	for i, check := range c.checks {
+		result := results[i]
+		report.Checks[check.Name] = result
+		if result.Status == StatusUp {
+			continue
+		}
+		if result.Critical {
+			report.Status = StatusDown
+		} else if report.Status == StatusUp {
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+// run probes a dependency, which is down if it does not answer in time
+func (c *Checker) run(ctx context.Context, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.probeTimeout)
+	defer cancel()
+
+	type probeResult struct {
+		detail string
+		err    error
+	}
+	done := make(chan probeResult, 1)
+	go func() {
+		detail, err := check.Probe(ctx)
+		done <- probeResult{detail: detail, err: err}
+	}()
+
+	result := CheckResult{Status: StatusUp, Critical: check.Critical}
+	select {
+	case probe := <-done:
+		result.Detail = probe.detail
+		if probe.err != nil {
+			result.Status = StatusDown
+			result.Detail = probe.err.Error()
+		}
+	case <-ctx.Done():
+		result.Status = StatusDown
+		result.Detail = fmt.Sprintf("no answer within %v", c.probeTimeout)
+	}
+	return result
+}
+
+// LivenessHandler serves the report of the dependencies. It answers 200 as
+// long as the NRF is able to serve it: a dependency being down must not get
+// the NRF restarted.
+func (c *Checker) LivenessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, http.StatusOK, c.Report(r.Context()))
+	}
+}
+
+// ReadinessHandler serves the report of the dependencies. It answers 503
+// until all the critical dependencies are up.
+func (c *Checker) ReadinessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := c.Report(r.Context())
+		status := http.StatusOK
+		if report.Status == StatusDown {
+			status = http.StatusServiceUnavailable
+		}
+		writeReport(w, status, report)
+	}
+}
+
+func writeReport(w http.ResponseWriter, status int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		return
+	}
+}
+
+// CertificateProbe checks that the first certificate of the PEM file
+// certFile is currently valid
+func CertificateProbe(certFile string) ProbeFunc {
+	return func(ctx context.Context) (string, error) {
+		certPEM, err := os.ReadFile(certFile)
+		if err != nil {
+			return "", err
+		}
+		block, _ := pem.Decode(certPEM)
+		if block == nil || block.Type != "CERTIFICATE" {
+			return "", fmt.Errorf("no certificate found in %s", certFile)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return "", err
+		}
+		now := time.Now()
+		if now.Before(cert.NotBefore) {
+			return "", fmt.Errorf("certificate not valid before %s", cert.NotBefore.UTC().Format(time.RFC3339))
+		}
+		if now.After(cert.NotAfter) {
+			return "", fmt.Errorf("certificate expired at %s", cert.NotAfter.UTC().Format(time.RFC3339))
+		}
+		return fmt.Sprintf("valid until %s", cert.NotAfter.UTC().Format(time.RFC3339)), nil
+	}
+}
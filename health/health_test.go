@@ -0,0 +1,185 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package health_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/omec-project/nrf/health"
+)
+
+func probe(detail string, err error) health.ProbeFunc {
+	return func(ctx context.Context) (string, error) {
+		return detail, err
+	}
+}
+
+func TestReport(t *testing.T) {
+	testCases := []struct {
+		name           string
+		checks         []health.Check
+		expectedStatus health.Status
+		expectedReady  int
+	}{
+		{
+			name: "all up",
+			checks: []health.Check{
+				{Name: "storage", Critical: true, Probe: probe("", nil)},
+				{Name: "webui", Probe: probe("1 PLMN(s) configured", nil)},
+			},
+			expectedStatus: health.StatusUp,
+			expectedReady:  http.StatusOK,
+		},
+		{
+			name: "non critical down",
+			checks: []health.Check{
+				{Name: "storage", Critical: true, Probe: probe("", nil)},
+				{Name: "webui", Probe: probe("", errors.New("connection refused"))},
+			},
+			expectedStatus: health.StatusDegraded,
+			expectedReady:  http.StatusOK,
+		},
+		{
+			name: "critical down",
+			checks: []health.Check{
+				{Name: "storage", Critical: true, Probe: probe("", errors.New("server selection timeout"))},
+				{Name: "webui", Probe: probe("", errors.New("connection refused"))},
+			},
+			expectedStatus: health.StatusDown,
+			expectedReady:  http.StatusServiceUnavailable,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			checker := health.NewChecker(tc.checks...)
+			for path, handler := range map[string]http.HandlerFunc{
+				"/healthz": checker.LivenessHandler(),
+				"/readyz":  checker.ReadinessHandler(),
+			} {
+				rec := httptest.NewRecorder()
+				handler(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+				expectedCode := http.StatusOK
+				if path == "/readyz" {
+					expectedCode = tc.expectedReady
+				}
+				if rec.Code != expectedCode {
+					t.Errorf("%s: expected status %d, got %d", path, expectedCode, rec.Code)
+				}
+				var report health.Report
+				if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+					t.Fatalf("%s: invalid report: %v", path, err)
+				}
+				if report.Status != tc.expectedStatus {
+					t.Errorf("%s: expected status %s, got %s", path, tc.expectedStatus, report.Status)
+				}
+				if len(report.Checks) != len(tc.checks) {
+					t.Errorf("%s: expected %d checks, got %+v", path, len(tc.checks), report.Checks)
+				}
+			}
+		})
+	}
+}
+
+func TestReportProbeTimeout(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for the probe timeout")
+	}
+	checker := health.NewChecker(health.Check{
+		Name:     "storage",
+		Critical: true,
+		Probe: func(ctx context.Context) (string, error) {
+			time.Sleep(2 * health.DefaultProbeTimeout)
+			return "", nil
+		},
+	})
+	start := time.Now()
+	report := checker.Report(context.Background())
+	if elapsed := time.Since(start); elapsed > health.DefaultProbeTimeout+time.Second {
+		t.Errorf("expected the report within the probe timeout, took %v", elapsed)
+	}
+	if result := report.Checks["storage"]; result.Status != health.StatusDown || !strings.Contains(result.Detail, "no answer") {
+		t.Errorf("expected the storage to be down, got %+v", result)
+	}
+}
+
+func writeCertificate(t *testing.T, notBefore, notAfter time.Time) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "nrf"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(t.TempDir(), "nrf.pem")
+	if err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile
+}
+
+func TestCertificateProbe(t *testing.T) {
+	now := time.Now()
+	testCases := []struct {
+		name        string
+		certFile    string
+		expectError bool
+	}{
+		{
+			name:     "valid",
+			certFile: writeCertificate(t, now.Add(-time.Hour), now.Add(time.Hour)),
+		},
+		{
+			name:        "expired",
+			certFile:    writeCertificate(t, now.Add(-2*time.Hour), now.Add(-time.Hour)),
+			expectError: true,
+		},
+		{
+			name:        "not yet valid",
+			certFile:    writeCertificate(t, now.Add(time.Hour), now.Add(2*time.Hour)),
+			expectError: true,
+		},
+		{
+			name:        "missing",
+			certFile:    filepath.Join(t.TempDir(), "missing.pem"),
+			expectError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			detail, err := health.CertificateProbe(tc.certFile)(context.Background())
+			if tc.expectError && err == nil {
+				t.Errorf("expected an error, got %s", detail)
+			}
+			if !tc.expectError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -7,6 +7,8 @@
 package logger
 
 import (
+	"net/http"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -20,9 +22,11 @@ var (
 	ManagementLog  *zap.SugaredLogger
 	AccessTokenLog *zap.SugaredLogger
 	DiscoveryLog   *zap.SugaredLogger
+	FederationLog  *zap.SugaredLogger
 	GinLog         *zap.SugaredLogger
 	UtilLog        *zap.SugaredLogger
 	atomicLevel    zap.AtomicLevel
+	defaultLogger  *Logger
 )
 
 func init() {
@@ -51,15 +55,53 @@ func init() {
 		panic(err)
 	}
 
-	AppLog = log.Sugar().With("component", "NRF", "category", "App")
-	InitLog = log.Sugar().With("component", "NRF", "category", "Init")
-	CfgLog = log.Sugar().With("component", "NRF", "category", "CFG")
-	HandlerLog = log.Sugar().With("component", "NRF", "category", "HDLR")
-	ManagementLog = log.Sugar().With("component", "NRF", "category", "MGMT")
-	AccessTokenLog = log.Sugar().With("component", "NRF", "category", "Token")
-	DiscoveryLog = log.Sugar().With("component", "NRF", "category", "DSCV")
-	GinLog = log.Sugar().With("component", "NRF", "category", "GIN")
-	UtilLog = log.Sugar().With("component", "NRF", "category", "Util")
+	defaultLogger = New(log)
+	AppLog = defaultLogger.AppLog
+	InitLog = defaultLogger.InitLog
+	CfgLog = defaultLogger.CfgLog
+	HandlerLog = defaultLogger.HandlerLog
+	ManagementLog = defaultLogger.ManagementLog
+	AccessTokenLog = defaultLogger.AccessTokenLog
+	DiscoveryLog = defaultLogger.DiscoveryLog
+	FederationLog = defaultLogger.FederationLog
+	GinLog = defaultLogger.GinLog
+	UtilLog = defaultLogger.UtilLog
+}
+
+// Logger holds the category loggers of an NRF instance
+type Logger struct {
+	AppLog         *zap.SugaredLogger
+	InitLog        *zap.SugaredLogger
+	CfgLog         *zap.SugaredLogger
+	HandlerLog     *zap.SugaredLogger
+	ManagementLog  *zap.SugaredLogger
+	AccessTokenLog *zap.SugaredLogger
+	DiscoveryLog   *zap.SugaredLogger
+	FederationLog  *zap.SugaredLogger
+	GinLog         *zap.SugaredLogger
+	UtilLog        *zap.SugaredLogger
+}
+
+// New derives the category loggers of an NRF instance from base
+func New(base *zap.Logger) *Logger {
+	sugar := base.Sugar().With("component", "NRF")
+	return &Logger{
+		AppLog:         sugar.With("category", "App"),
+		InitLog:        sugar.With("category", "Init"),
+		CfgLog:         sugar.With("category", "CFG"),
+		HandlerLog:     sugar.With("category", "HDLR"),
+		ManagementLog:  sugar.With("category", "MGMT"),
+		AccessTokenLog: sugar.With("category", "Token"),
+		DiscoveryLog:   sugar.With("category", "DSCV"),
+		FederationLog:  sugar.With("category", "FDRT"),
+		GinLog:         sugar.With("category", "GIN"),
+		UtilLog:        sugar.With("category", "Util"),
+	}
+}
+
+// Default returns the category loggers writing to the process-wide logger
+func Default() *Logger {
+	return defaultLogger
 }
 
 func GetLogger() *zap.Logger {
@@ -71,3 +113,9 @@ func SetLogLevel(level zapcore.Level) {
 	InitLog.Infoln("set log level:", level)
 	atomicLevel.SetLevel(level)
 }
+
+// LevelHandler serves the level of the process-wide logger: GET reports it and
+// PUT changes it, e.g. with the JSON body {"level":"debug"}
+func LevelHandler() http.Handler {
+	return atomicLevel
+}
@@ -17,7 +17,6 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/omec-project/nrf/logger"
 	"github.com/omec-project/nrf/producer"
 	"github.com/omec-project/openapi"
 	"github.com/omec-project/openapi/models"
@@ -25,138 +24,147 @@ import (
 )
 
 // HTTPDeregisterNFInstance - Deregisters a given NF Instance
-func HTTPDeregisterNFInstance(c *gin.Context) {
-	// parse nfInstanceId
-
-	req := httpwrapper.NewRequest(c.Request, nil)
-	req.Params["nfInstanceID"] = c.Params.ByName("nfInstanceID")
-
-	httpResponse := producer.HandleNFDeregisterRequest(req)
-
-	responseBody, err := openapi.Serialize(httpResponse.Body, "application/json")
-	if err != nil {
-		logger.ManagementLog.Warnln(err)
-		problemDetails := models.ProblemDetails{
-			Status: http.StatusInternalServerError,
-			Cause:  "SYSTEM_FAILURE",
-			Detail: err.Error(),
+func HTTPDeregisterNFInstance(p *producer.Producer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// parse nfInstanceId
+
+		req := httpwrapper.NewRequest(c.Request, nil)
+		req.Params["nfInstanceID"] = c.Params.ByName("nfInstanceID")
+
+		httpResponse := p.HandleNFDeregisterRequest(c.Request.Context(), req)
+
+		responseBody, err := openapi.Serialize(httpResponse.Body, "application/json")
+		if err != nil {
+			p.Log.ManagementLog.Warnln(err)
+			problemDetails := models.ProblemDetails{
+				Status: http.StatusInternalServerError,
+				Cause:  "SYSTEM_FAILURE",
+				Detail: err.Error(),
+			}
+			c.JSON(http.StatusInternalServerError, problemDetails)
+		} else {
+			c.Data(httpResponse.Status, "application/json", responseBody)
 		}
-		c.JSON(http.StatusInternalServerError, problemDetails)
-	} else {
-		c.Data(httpResponse.Status, "application/json", responseBody)
 	}
 }
 
 // HTTPGetNFInstance - Read the profile of a given NF Instance
-func HTTPGetNFInstance(c *gin.Context) {
-	req := httpwrapper.NewRequest(c.Request, nil)
-	req.Params["nfInstanceID"] = c.Params.ByName("nfInstanceID")
-
-	httpResponse := producer.HandleGetNFInstanceRequest(req)
-
-	responseBody, err := openapi.Serialize(httpResponse.Body, "application/json")
-	if err != nil {
-		logger.ManagementLog.Warnln(err)
-		problemDetails := models.ProblemDetails{
-			Status: http.StatusInternalServerError,
-			Cause:  "SYSTEM_FAILURE",
-			Detail: err.Error(),
+func HTTPGetNFInstance(p *producer.Producer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req := httpwrapper.NewRequest(c.Request, nil)
+		req.Params["nfInstanceID"] = c.Params.ByName("nfInstanceID")
+
+		httpResponse := p.HandleGetNFInstanceRequest(c.Request.Context(), req)
+
+		responseBody, err := openapi.Serialize(httpResponse.Body, "application/json")
+		if err != nil {
+			p.Log.ManagementLog.Warnln(err)
+			problemDetails := models.ProblemDetails{
+				Status: http.StatusInternalServerError,
+				Cause:  "SYSTEM_FAILURE",
+				Detail: err.Error(),
+			}
+			c.JSON(http.StatusInternalServerError, problemDetails)
+		} else {
+			c.Data(httpResponse.Status, "application/json", responseBody)
 		}
-		c.JSON(http.StatusInternalServerError, problemDetails)
-	} else {
-		c.Data(httpResponse.Status, "application/json", responseBody)
 	}
 }
 
 // HTTPRegisterNFInstance - Register a new NF Instance
-func HTTPRegisterNFInstance(c *gin.Context) {
-	var nfprofile models.NfProfile
-
-	// step 1: retrieve http request body
-	requestBody, err := c.GetRawData()
-	if err != nil {
-		problemDetail := models.ProblemDetails{
-			Title:  "System failure",
-			Status: http.StatusInternalServerError,
-			Detail: err.Error(),
-			Cause:  "SYSTEM_FAILURE",
+func HTTPRegisterNFInstance(p *producer.Producer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// the NfProfile and the shared data it refers to
+		var nfprofile producer.NfProfileRegistration
+
+		// step 1: retrieve http request body
+		requestBody, err := c.GetRawData()
+		if err != nil {
+			problemDetail := models.ProblemDetails{
+				Title:  "System failure",
+				Status: http.StatusInternalServerError,
+				Detail: err.Error(),
+				Cause:  "SYSTEM_FAILURE",
+			}
+			p.Log.ManagementLog.Errorf("Get Request Body error: %+v", err)
+			c.JSON(http.StatusInternalServerError, problemDetail)
+			return
 		}
-		logger.ManagementLog.Errorf("Get Request Body error: %+v", err)
-		c.JSON(http.StatusInternalServerError, problemDetail)
-		return
-	}
 
-	// step 2: convert requestBody to openapi models
-	err = openapi.Deserialize(&nfprofile, requestBody, "application/json")
-	if err != nil {
-		problemDetail := "[Request Body] " + err.Error()
-		rsp := models.ProblemDetails{
-			Title:  "Malformed request syntax",
-			Status: http.StatusBadRequest,
-			Detail: problemDetail,
+		// step 2: convert requestBody to openapi models
+		err = openapi.Deserialize(&nfprofile, requestBody, "application/json")
+		if err != nil {
+			problemDetail := "[Request Body] " + err.Error()
+			rsp := models.ProblemDetails{
+				Title:  "Malformed request syntax",
+				Status: http.StatusBadRequest,
+				Detail: problemDetail,
+			}
+			p.Log.ManagementLog.Errorln(problemDetail)
+			c.JSON(http.StatusBadRequest, rsp)
+			return
 		}
-		logger.ManagementLog.Errorln(problemDetail)
-		c.JSON(http.StatusBadRequest, rsp)
-		return
-	}
-	logger.ManagementLog.Debugln("Deserialize json data in the struct NfProfile")
+		p.Log.ManagementLog.Debugln("Deserialize json data in the struct NfProfile")
 
-	// step 3: encapsulate the request by httpwrapper package
-	req := httpwrapper.NewRequest(c.Request, nfprofile)
+		// step 3: encapsulate the request by httpwrapper package
+		req := httpwrapper.NewRequest(c.Request, nfprofile)
 
-	// step 4: call producer
-	httpResponse := producer.HandleNFRegisterRequest(req)
+		// step 4: call producer
+		httpResponse := p.HandleNFRegisterRequest(c.Request.Context(), req)
 
-	for key, val := range httpResponse.Header {
-		c.Header(key, val[0])
-	}
+		for key, val := range httpResponse.Header {
+			c.Header(key, val[0])
+		}
 
-	responseBody, err := openapi.Serialize(httpResponse.Body, "application/json")
-	if err != nil {
-		logger.ManagementLog.Warnln(err)
-		problemDetails := models.ProblemDetails{
-			Status: http.StatusInternalServerError,
-			Cause:  "SYSTEM_FAILURE",
-			Detail: err.Error(),
+		responseBody, err := openapi.Serialize(httpResponse.Body, "application/json")
+		if err != nil {
+			p.Log.ManagementLog.Warnln(err)
+			problemDetails := models.ProblemDetails{
+				Status: http.StatusInternalServerError,
+				Cause:  "SYSTEM_FAILURE",
+				Detail: err.Error(),
+			}
+			c.JSON(http.StatusInternalServerError, problemDetails)
+		} else {
+			c.Data(httpResponse.Status, "application/json", responseBody)
 		}
-		c.JSON(http.StatusInternalServerError, problemDetails)
-	} else {
-		c.Data(httpResponse.Status, "application/json", responseBody)
 	}
 }
 
 // HTTPUpdateNFInstance Update NF Instance profile
-func HTTPUpdateNFInstance(c *gin.Context) {
-	// step 1: retrieve http request body
-	requestBody, err := c.GetRawData()
-	if err != nil {
-		problemDetail := models.ProblemDetails{
-			Title:  "System failure",
-			Status: http.StatusInternalServerError,
-			Detail: err.Error(),
-			Cause:  "SYSTEM_FAILURE",
+func HTTPUpdateNFInstance(p *producer.Producer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// step 1: retrieve http request body
+		requestBody, err := c.GetRawData()
+		if err != nil {
+			problemDetail := models.ProblemDetails{
+				Title:  "System failure",
+				Status: http.StatusInternalServerError,
+				Detail: err.Error(),
+				Cause:  "SYSTEM_FAILURE",
+			}
+			p.Log.ManagementLog.Errorf("Get Request Body error: %+v", err)
+			c.JSON(http.StatusInternalServerError, problemDetail)
+			return
 		}
-		logger.ManagementLog.Errorf("Get Request Body error: %+v", err)
-		c.JSON(http.StatusInternalServerError, problemDetail)
-		return
-	}
-
-	req := httpwrapper.NewRequest(c.Request, nil)
-	req.Params["nfInstanceID"] = c.Params.ByName("nfInstanceID")
-	req.Body = requestBody
-
-	httpResponse := producer.HandleUpdateNFInstanceRequest(req)
 
-	responseBody, err := openapi.Serialize(httpResponse.Body, "application/json")
-	if err != nil {
-		logger.ManagementLog.Warnln(err)
-		problemDetails := models.ProblemDetails{
-			Status: http.StatusInternalServerError,
-			Cause:  "SERIALIZATION_FAILURE",
-			Detail: err.Error(),
+		req := httpwrapper.NewRequest(c.Request, nil)
+		req.Params["nfInstanceID"] = c.Params.ByName("nfInstanceID")
+		req.Body = requestBody
+
+		httpResponse := p.HandleUpdateNFInstanceRequest(c.Request.Context(), req)
+
+		responseBody, err := openapi.Serialize(httpResponse.Body, "application/json")
+		if err != nil {
+			p.Log.ManagementLog.Warnln(err)
+			problemDetails := models.ProblemDetails{
+				Status: http.StatusInternalServerError,
+				Cause:  "SERIALIZATION_FAILURE",
+				Detail: err.Error(),
+			}
+			c.JSON(http.StatusInternalServerError, problemDetails)
+		} else {
+			c.Data(httpResponse.Status, "application/json", responseBody)
 		}
-		c.JSON(http.StatusInternalServerError, problemDetails)
-	} else {
-		c.Data(httpResponse.Status, "application/json", responseBody)
 	}
 }
@@ -17,7 +17,6 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/omec-project/nrf/logger"
 	"github.com/omec-project/nrf/producer"
 	"github.com/omec-project/openapi"
 	"github.com/omec-project/openapi/models"
@@ -25,22 +24,24 @@ import (
 )
 
 // GetNFInstances - Retrieves a collection of NF Instances
-func HTTPGetNFInstances(c *gin.Context) {
-	req := httpwrapper.NewRequest(c.Request, nil)
-	req.Query = c.Request.URL.Query()
+func HTTPGetNFInstances(p *producer.Producer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req := httpwrapper.NewRequest(c.Request, nil)
+		req.Query = c.Request.URL.Query()
 
-	httpResponse := producer.HandleGetNFInstancesRequest(req)
+		httpResponse := p.HandleGetNFInstancesRequest(c.Request.Context(), req)
 
-	responseBody, err := openapi.Serialize(httpResponse.Body, "application/json")
-	if err != nil {
-		logger.ManagementLog.Warnln(err)
-		problemDetails := models.ProblemDetails{
-			Status: http.StatusInternalServerError,
-			Cause:  "SYSTEM_FAILURE",
-			Detail: err.Error(),
+		responseBody, err := openapi.Serialize(httpResponse.Body, "application/json")
+		if err != nil {
+			p.Log.ManagementLog.Warnln(err)
+			problemDetails := models.ProblemDetails{
+				Status: http.StatusInternalServerError,
+				Cause:  "SYSTEM_FAILURE",
+				Detail: err.Error(),
+			}
+			c.JSON(http.StatusInternalServerError, problemDetails)
+		} else {
+			c.Data(httpResponse.Status, "application/json", responseBody)
 		}
-		c.JSON(http.StatusInternalServerError, problemDetails)
-	} else {
-		c.Data(httpResponse.Status, "application/json", responseBody)
 	}
 }
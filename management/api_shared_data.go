@@ -0,0 +1,101 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omec-project/nrf/producer"
+	"github.com/omec-project/openapi"
+	"github.com/omec-project/openapi/models"
+	"github.com/omec-project/util/httpwrapper"
+)
+
+// HTTPGetSharedDataList - Read all the shared data
+func HTTPGetSharedDataList(p *producer.Producer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req := httpwrapper.NewRequest(c.Request, nil)
+
+		httpResponse := p.HandleGetSharedDataListRequest(c.Request.Context(), req)
+		sendSharedDataResponse(c, p, httpResponse)
+	}
+}
+
+// HTTPGetSharedData - Read the given shared data
+func HTTPGetSharedData(p *producer.Producer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req := httpwrapper.NewRequest(c.Request, nil)
+		req.Params["sharedDataID"] = c.Params.ByName("sharedDataID")
+
+		httpResponse := p.HandleGetSharedDataRequest(c.Request.Context(), req)
+		sendSharedDataResponse(c, p, httpResponse)
+	}
+}
+
+// HTTPPutSharedData - Create or replace the given shared data
+func HTTPPutSharedData(p *producer.Producer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var sharedData producer.SharedData
+
+		requestBody, err := c.GetRawData()
+		if err != nil {
+			problemDetail := models.ProblemDetails{
+				Title:  "System failure",
+				Status: http.StatusInternalServerError,
+				Detail: err.Error(),
+				Cause:  "SYSTEM_FAILURE",
+			}
+			p.Log.ManagementLog.Errorf("Get Request Body error: %+v", err)
+			c.JSON(http.StatusInternalServerError, problemDetail)
+			return
+		}
+
+		err = openapi.Deserialize(&sharedData, requestBody, "application/json")
+		if err != nil {
+			problemDetail := "[Request Body] " + err.Error()
+			rsp := models.ProblemDetails{
+				Title:  "Malformed request syntax",
+				Status: http.StatusBadRequest,
+				Detail: problemDetail,
+			}
+			p.Log.ManagementLog.Errorln(problemDetail)
+			c.JSON(http.StatusBadRequest, rsp)
+			return
+		}
+
+		req := httpwrapper.NewRequest(c.Request, sharedData)
+		req.Params["sharedDataID"] = c.Params.ByName("sharedDataID")
+
+		httpResponse := p.HandlePutSharedDataRequest(c.Request.Context(), req)
+		sendSharedDataResponse(c, p, httpResponse)
+	}
+}
+
+// HTTPDeleteSharedData - Delete the given shared data
+func HTTPDeleteSharedData(p *producer.Producer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req := httpwrapper.NewRequest(c.Request, nil)
+		req.Params["sharedDataID"] = c.Params.ByName("sharedDataID")
+
+		httpResponse := p.HandleDeleteSharedDataRequest(c.Request.Context(), req)
+		sendSharedDataResponse(c, p, httpResponse)
+	}
+}
+
+func sendSharedDataResponse(c *gin.Context, p *producer.Producer, httpResponse *httpwrapper.Response) {
+	responseBody, err := openapi.Serialize(httpResponse.Body, "application/json")
+	if err != nil {
+		p.Log.ManagementLog.Warnln(err)
+		problemDetails := models.ProblemDetails{
+			Status: http.StatusInternalServerError,
+			Cause:  "SYSTEM_FAILURE",
+			Detail: err.Error(),
+		}
+		c.JSON(http.StatusInternalServerError, problemDetails)
+	} else {
+		c.Data(httpResponse.Status, "application/json", responseBody)
+	}
+}
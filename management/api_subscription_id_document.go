@@ -17,7 +17,6 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/omec-project/nrf/logger"
 	"github.com/omec-project/nrf/producer"
 	"github.com/omec-project/openapi"
 	"github.com/omec-project/openapi/models"
@@ -25,56 +24,60 @@ import (
 )
 
 // RemoveSubscription - Deletes a subscription
-func HTTPRemoveSubscription(c *gin.Context) {
-	req := httpwrapper.NewRequest(c.Request, nil)
-	req.Params["subscriptionID"] = c.Params.ByName("subscriptionID")
+func HTTPRemoveSubscription(p *producer.Producer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req := httpwrapper.NewRequest(c.Request, nil)
+		req.Params["subscriptionID"] = c.Params.ByName("subscriptionID")
 
-	httpResponse := producer.HandleRemoveSubscriptionRequest(req)
+		httpResponse := p.HandleRemoveSubscriptionRequest(c.Request.Context(), req)
 
-	responseBody, err := openapi.Serialize(httpResponse.Body, "application/json")
-	if err != nil {
-		logger.ManagementLog.Warnln(err)
-		problemDetails := models.ProblemDetails{
-			Status: http.StatusInternalServerError,
-			Cause:  "SYSTEM_FAILURE",
-			Detail: err.Error(),
+		responseBody, err := openapi.Serialize(httpResponse.Body, "application/json")
+		if err != nil {
+			p.Log.ManagementLog.Warnln(err)
+			problemDetails := models.ProblemDetails{
+				Status: http.StatusInternalServerError,
+				Cause:  "SYSTEM_FAILURE",
+				Detail: err.Error(),
+			}
+			c.JSON(http.StatusInternalServerError, problemDetails)
+		} else {
+			c.Data(httpResponse.Status, "application/json", responseBody)
 		}
-		c.JSON(http.StatusInternalServerError, problemDetails)
-	} else {
-		c.Data(httpResponse.Status, "application/json", responseBody)
 	}
 }
 
 // UpdateSubscription - Updates a subscription
-func HTTPUpdateSubscription(c *gin.Context) {
-	requestBody, err := c.GetRawData()
-	if err != nil {
-		problemDetail := models.ProblemDetails{
-			Title:  "System failure",
-			Status: http.StatusInternalServerError,
-			Detail: err.Error(),
-			Cause:  "SYSTEM_FAILURE",
+func HTTPUpdateSubscription(p *producer.Producer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestBody, err := c.GetRawData()
+		if err != nil {
+			problemDetail := models.ProblemDetails{
+				Title:  "System failure",
+				Status: http.StatusInternalServerError,
+				Detail: err.Error(),
+				Cause:  "SYSTEM_FAILURE",
+			}
+			p.Log.ManagementLog.Errorf("Get Request Body error: %+v", err)
+			c.JSON(http.StatusInternalServerError, problemDetail)
+			return
 		}
-		logger.ManagementLog.Errorf("Get Request Body error: %+v", err)
-		c.JSON(http.StatusInternalServerError, problemDetail)
-		return
-	}
 
-	req := httpwrapper.NewRequest(c.Request, nil)
-	req.Params["subscriptionID"] = c.Params.ByName("subscriptionID")
-	req.Body = requestBody
+		req := httpwrapper.NewRequest(c.Request, nil)
+		req.Params["subscriptionID"] = c.Params.ByName("subscriptionID")
+		req.Body = requestBody
 
-	httpResponse := producer.HandleUpdateSubscriptionRequest(req)
-	responseBody, err := openapi.Serialize(httpResponse.Body, "application/json")
-	if err != nil {
-		logger.ManagementLog.Warnln(err)
-		problemDetails := models.ProblemDetails{
-			Status: http.StatusInternalServerError,
-			Cause:  "SYSTEM_FAILURE",
-			Detail: err.Error(),
+		httpResponse := p.HandleUpdateSubscriptionRequest(c.Request.Context(), req)
+		responseBody, err := openapi.Serialize(httpResponse.Body, "application/json")
+		if err != nil {
+			p.Log.ManagementLog.Warnln(err)
+			problemDetails := models.ProblemDetails{
+				Status: http.StatusInternalServerError,
+				Cause:  "SYSTEM_FAILURE",
+				Detail: err.Error(),
+			}
+			c.JSON(http.StatusInternalServerError, problemDetails)
+		} else {
+			c.Data(httpResponse.Status, "application/json", responseBody)
 		}
-		c.JSON(http.StatusInternalServerError, problemDetails)
-	} else {
-		c.Data(httpResponse.Status, "application/json", responseBody)
 	}
 }
@@ -17,7 +17,6 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/omec-project/nrf/logger"
 	"github.com/omec-project/nrf/producer"
 	"github.com/omec-project/openapi"
 	"github.com/omec-project/openapi/models"
@@ -27,50 +26,52 @@ import (
 // Provide SubsciptionId for each request (add by one each time)
 
 // CreateSubscription - Create a new subscription
-func HTTPCreateSubscription(c *gin.Context) {
-	var subscription models.NrfSubscriptionData
+func HTTPCreateSubscription(p *producer.Producer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var subscription models.NrfSubscriptionData
 
-	// step 1: retrieve http request body
-	requestBody, err := c.GetRawData()
-	if err != nil {
-		problemDetail := models.ProblemDetails{
-			Title:  "System failure",
-			Status: http.StatusInternalServerError,
-			Detail: err.Error(),
-			Cause:  "SYSTEM_FAILURE",
+		// step 1: retrieve http request body
+		requestBody, err := c.GetRawData()
+		if err != nil {
+			problemDetail := models.ProblemDetails{
+				Title:  "System failure",
+				Status: http.StatusInternalServerError,
+				Detail: err.Error(),
+				Cause:  "SYSTEM_FAILURE",
+			}
+			p.Log.ManagementLog.Errorf("Get Request Body error: %+v", err)
+			c.JSON(http.StatusInternalServerError, problemDetail)
+			return
 		}
-		logger.ManagementLog.Errorf("Get Request Body error: %+v", err)
-		c.JSON(http.StatusInternalServerError, problemDetail)
-		return
-	}
 
-	// step 2: convert requestBody to openapi models
-	err = openapi.Deserialize(&subscription, requestBody, "application/json")
-	if err != nil {
-		problemDetail := "[Request Body] " + err.Error()
-		rsp := models.ProblemDetails{
-			Title:  "Malformed request syntax",
-			Status: http.StatusBadRequest,
-			Detail: problemDetail,
+		// step 2: convert requestBody to openapi models
+		err = openapi.Deserialize(&subscription, requestBody, "application/json")
+		if err != nil {
+			problemDetail := "[Request Body] " + err.Error()
+			rsp := models.ProblemDetails{
+				Title:  "Malformed request syntax",
+				Status: http.StatusBadRequest,
+				Detail: problemDetail,
+			}
+			p.Log.ManagementLog.Errorln(problemDetail)
+			c.JSON(http.StatusBadRequest, rsp)
+			return
 		}
-		logger.ManagementLog.Errorln(problemDetail)
-		c.JSON(http.StatusBadRequest, rsp)
-		return
-	}
 
-	req := httpwrapper.NewRequest(c.Request, subscription)
+		req := httpwrapper.NewRequest(c.Request, subscription)
 
-	httpResponse := producer.HandleCreateSubscriptionRequest(req)
-	responseBody, err := openapi.Serialize(httpResponse.Body, "application/json")
-	if err != nil {
-		logger.ManagementLog.Errorln(err)
-		problemDetails := models.ProblemDetails{
-			Status: http.StatusInternalServerError,
-			Cause:  "SYSTEM_FAILURE",
-			Detail: err.Error(),
+		httpResponse := p.HandleCreateSubscriptionRequest(c.Request.Context(), req)
+		responseBody, err := openapi.Serialize(httpResponse.Body, "application/json")
+		if err != nil {
+			p.Log.ManagementLog.Errorln(err)
+			problemDetails := models.ProblemDetails{
+				Status: http.StatusInternalServerError,
+				Cause:  "SYSTEM_FAILURE",
+				Detail: err.Error(),
+			}
+			c.JSON(http.StatusInternalServerError, problemDetails)
+		} else {
+			c.Data(httpResponse.Status, "application/json", responseBody)
 		}
-		c.JSON(http.StatusInternalServerError, problemDetails)
-	} else {
-		c.Data(httpResponse.Status, "application/json", responseBody)
 	}
 }
@@ -20,7 +20,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/omec-project/nrf/factory"
-	"github.com/omec-project/nrf/logger"
+	"github.com/omec-project/nrf/producer"
 	utilLogger "github.com/omec-project/util/logger"
 )
 
@@ -40,16 +40,16 @@ type Route struct {
 type Routes []Route
 
 // NewRouter returns a new router.
-func NewRouter() *gin.Engine {
-	router := utilLogger.NewGinWithZap(logger.GinLog)
-	AddService(router)
+func NewRouter(p *producer.Producer) *gin.Engine {
+	router := utilLogger.NewGinWithZap(p.Log.GinLog)
+	AddService(router, p)
 	return router
 }
 
-func AddService(engine *gin.Engine) *gin.RouterGroup {
+func AddService(engine *gin.Engine, p *producer.Producer) *gin.RouterGroup {
 	group := engine.Group(factory.NRF_NFM_RES_URI_PREFIX)
 
-	for _, route := range routes {
+	for _, route := range getRoutes(p) {
 		switch route.Method {
 		case "GET":
 			group.GET(route.Pattern, route.HandlerFunc)
@@ -72,67 +72,97 @@ func Index(c *gin.Context) {
 	c.String(http.StatusOK, "Hello World!")
 }
 
-var routes = Routes{
-	{
-		"Index",
-		"GET",
-		"/",
-		Index,
-	},
-
-	{
-		"DeregisterNFInstance",
-		strings.ToUpper("Delete"),
-		"/nf-instances/:nfInstanceID",
-		HTTPDeregisterNFInstance,
-	},
-
-	{
-		"GetNFInstance",
-		strings.ToUpper("Get"),
-		"/nf-instances/:nfInstanceID",
-		HTTPGetNFInstance,
-	},
-
-	{
-		"RegisterNFInstance",
-		strings.ToUpper("Put"),
-		"/nf-instances/:nfInstanceID",
-		HTTPRegisterNFInstance,
-	},
-
-	{
-		"UpdateNFInstance",
-		strings.ToUpper("Patch"),
-		"/nf-instances/:nfInstanceID",
-		HTTPUpdateNFInstance,
-	},
-
-	{
-		"GetNFInstances",
-		strings.ToUpper("Get"),
-		"/nf-instances",
-		HTTPGetNFInstances,
-	},
-
-	{
-		"RemoveSubscription",
-		strings.ToUpper("Delete"),
-		"/subscriptions/:subscriptionID",
-		HTTPRemoveSubscription,
-	},
-
-	{
-		"UpdateSubscription",
-		strings.ToUpper("Patch"),
-		"/subscriptions/:subscriptionID",
-		HTTPUpdateSubscription,
-	},
-
-	{
-		"CreateSubscription",
-		strings.ToUpper("Post"),
-		"/subscriptions",
-		HTTPCreateSubscription,
-	},
+func getRoutes(p *producer.Producer) Routes {
+	return Routes{
+		{
+			"Index",
+			"GET",
+			"/",
+			Index,
+		},
+
+		{
+			"DeregisterNFInstance",
+			strings.ToUpper("Delete"),
+			"/nf-instances/:nfInstanceID",
+			HTTPDeregisterNFInstance(p),
+		},
+
+		{
+			"GetNFInstance",
+			strings.ToUpper("Get"),
+			"/nf-instances/:nfInstanceID",
+			HTTPGetNFInstance(p),
+		},
+
+		{
+			"RegisterNFInstance",
+			strings.ToUpper("Put"),
+			"/nf-instances/:nfInstanceID",
+			HTTPRegisterNFInstance(p),
+		},
+
+		{
+			"UpdateNFInstance",
+			strings.ToUpper("Patch"),
+			"/nf-instances/:nfInstanceID",
+			HTTPUpdateNFInstance(p),
+		},
+
+		{
+			"GetNFInstances",
+			strings.ToUpper("Get"),
+			"/nf-instances",
+			HTTPGetNFInstances(p),
+		},
+
+		{
+			"RemoveSubscription",
+			strings.ToUpper("Delete"),
+			"/subscriptions/:subscriptionID",
+			HTTPRemoveSubscription(p),
+		},
+
+		{
+			"UpdateSubscription",
+			strings.ToUpper("Patch"),
+			"/subscriptions/:subscriptionID",
+			HTTPUpdateSubscription(p),
+		},
+
+		{
+			"CreateSubscription",
+			strings.ToUpper("Post"),
+			"/subscriptions",
+			HTTPCreateSubscription(p),
+		},
+
+		{
+			"GetSharedDataList",
+			strings.ToUpper("Get"),
+			"/shared-data",
+			HTTPGetSharedDataList(p),
+		},
+
+		{
+			"GetSharedData",
+			strings.ToUpper("Get"),
+			"/shared-data/:sharedDataID",
+			HTTPGetSharedData(p),
+		},
+
+		{
+			"PutSharedData",
+			strings.ToUpper("Put"),
+			"/shared-data/:sharedDataID",
+			HTTPPutSharedData(p),
+		},
+
+		{
+			"DeleteSharedData",
+			strings.ToUpper("Delete"),
+			"/shared-data/:sharedDataID",
+			HTTPDeleteSharedData(p),
+		},
+	}
 }
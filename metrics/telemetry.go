@@ -9,8 +9,10 @@ package metrics
 
 import (
 	"net/http"
+	"strconv"
+	"sync"
+	"time"
 
-	"github.com/omec-project/nrf/logger"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -20,9 +22,37 @@ type NrfStats struct {
 	nrfRegistrations *prometheus.CounterVec
 	nrfSubscriptions *prometheus.CounterVec
 	nrfNfInstances   *prometheus.CounterVec
+
+	registeredNfInstances *prometheus.GaugeVec
+	activeSubscriptions   *prometheus.GaugeVec
+
+	// mu guards the label sets of the registry gauges, the series of which
+	// are deleted once they are no longer counted
+	mu                  sync.Mutex
+	registeredKeys      map[NfInstanceKey]bool
+	subscriptionNfTypes map[string]bool
+
+	sbiRequestDuration       *prometheus.HistogramVec
+	storageOperationDuration *prometheus.HistogramVec
+
+	nfStatusNotifications *prometheus.CounterVec
+	heartbeats            *prometheus.CounterVec
+	nfExpiries            *prometheus.CounterVec
+
+	storageConnected     prometheus.Gauge
+	storageReconnections prometheus.Counter
+	changeStreamRestarts prometheus.Counter
+	clusterLeader        prometheus.Gauge
+	leadershipChanges    prometheus.Counter
+	federationSyncs      *prometheus.CounterVec
+	discoveryForwards    *prometheus.CounterVec
 }
 
-var nrfStats *NrfStats
+// NfInstanceKey identifies the registered NF instances counted together
+type NfInstanceKey struct {
+	NfType   string
+	NfStatus string
+}
 
 func initNrfStats() *NrfStats {
 	return &NrfStats{
@@ -38,49 +68,273 @@ func initNrfStats() *NrfStats {
 			Name: "nrf_nf_instances",
 			Help: "Counter of total NRF instances queries",
 		}, []string{"request_nf_type", "target_nf_type", "result"}),
+		registeredNfInstances: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "nrf_registered_nf_instances",
+			Help: "Number of NF instances currently registered",
+		}, []string{"nf_type", "nf_status"}),
+		activeSubscriptions: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "nrf_active_subscriptions",
+			Help: "Number of NF status subscriptions currently active",
+		}, []string{"request_nf_type"}),
+		sbiRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "nrf_sbi_request_duration_seconds",
+			Help:    "Duration of the SBI requests served by NRF",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		storageOperationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "nrf_storage_operation_duration_seconds",
+			Help:    "Duration of the storage operations of NRF",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"operation", "collection", "result"}),
+		nfStatusNotifications: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "nrf_nf_status_notifications",
+			Help: "Counter of total NF status notifications sent by NRF",
+		}, []string{"event", "result"}),
+		heartbeats: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "nrf_heartbeats",
+			Help: "Counter of total heartbeats received, the NF profile updates of the status and load only",
+		}, []string{"nf_type", "result"}),
+		nfExpiries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "nrf_nf_expiries",
+			Help: "Counter of total NF instances removed after missing their heartbeats",
+		}, []string{"nf_type"}),
+		storageConnected: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "nrf_storage_connected",
+			Help: "Whether the storage answered the last connection probe, 1, or not, 0",
+		}),
+		storageReconnections: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "nrf_storage_reconnections",
+			Help: "Counter of total connections to the storage re-established after it went down",
+		}),
+		changeStreamRestarts: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "nrf_change_stream_restarts",
+			Help: "Counter of total restarts of the storage change stream after it failed",
+		}),
+		clusterLeader: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "nrf_cluster_leader",
+			Help: "Whether this replica is the leader running the singleton jobs, 1, or not, 0",
+		}),
+		leadershipChanges: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "nrf_cluster_leadership_changes",
+			Help: "Counter of total leaderships acquired or lost by this replica",
+		}),
+		federationSyncs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "nrf_federation_syncs",
+			Help: "Counter of total copies of the NF profiles of a peer NRF",
+		}, []string{"peer", "result"}),
+		discoveryForwards: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "nrf_discovery_forwards",
+			Help: "Counter of total discovery queries relayed to another NRF",
+		}, []string{"rule", "result"}),
 	}
 }
 
-func (ps *NrfStats) register() error {
-	if err := prometheus.Register(ps.nrfRegistrations); err != nil {
-		return err
-	}
-	if err := prometheus.Register(ps.nrfSubscriptions); err != nil {
-		return err
-	}
-	if err := prometheus.Register(ps.nrfNfInstances); err != nil {
-		return err
+func (ps *NrfStats) register(registerer prometheus.Registerer) error {
+	for _, collector := range []prometheus.Collector{
+		ps.nrfRegistrations,
+		ps.nrfSubscriptions,
+		ps.nrfNfInstances,
+		ps.registeredNfInstances,
+		ps.activeSubscriptions,
+		ps.sbiRequestDuration,
+		ps.storageOperationDuration,
+		ps.nfStatusNotifications,
+		ps.heartbeats,
+		ps.nfExpiries,
+		ps.storageConnected,
+		ps.storageReconnections,
+		ps.changeStreamRestarts,
+		ps.clusterLeader,
+		ps.leadershipChanges,
+		ps.federationSyncs,
+		ps.discoveryForwards,
+	} {
+		if err := registerer.Register(collector); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func init() {
-	nrfStats = initNrfStats()
-
-	if err := nrfStats.register(); err != nil {
-		logger.InitLog.Errorln("NRF Stats register failed")
+// NewNrfStats creates the NRF stats and registers them with registerer
+func NewNrfStats(registerer prometheus.Registerer) (*NrfStats, error) {
+	nrfStats := initNrfStats()
+	if err := nrfStats.register(registerer); err != nil {
+		return nil, err
 	}
+	return nrfStats, nil
 }
 
-// InitMetrics initialises NRF metrics
-func InitMetrics() {
-	http.Handle("/metrics", promhttp.Handler())
-	if err := http.ListenAndServe(":8080", nil); err != nil {
-		logger.InitLog.Errorf("Could not open metrics port: %v", err)
-	}
+// Handler returns the handler exposing the metrics collected by gatherer
+func Handler(gatherer prometheus.Gatherer) http.Handler {
+	return promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})
 }
 
 // IncrementNrfRegistrationsStats increments number of total NRF registrations
-func IncrementNrfRegistrationsStats(queryType, nfType, result string) {
-	nrfStats.nrfRegistrations.WithLabelValues(queryType, nfType, result).Inc()
+func (ps *NrfStats) IncrementNrfRegistrationsStats(queryType, nfType, result string) {
+	if ps == nil {
+		return
+	}
+	ps.nrfRegistrations.WithLabelValues(queryType, nfType, result).Inc()
 }
 
 // IncrementNrfSubscriptionsStats increments number of total NRF subscriptions
-func IncrementNrfSubscriptionsStats(queryType, requestNfType, result string) {
-	nrfStats.nrfSubscriptions.WithLabelValues(queryType, requestNfType, result).Inc()
+func (ps *NrfStats) IncrementNrfSubscriptionsStats(queryType, requestNfType, result string) {
+	if ps == nil {
+		return
+	}
+	ps.nrfSubscriptions.WithLabelValues(queryType, requestNfType, result).Inc()
 }
 
 // IncrementNrfNfInstancesStats increments number of total NRF queries
-func IncrementNrfNfInstancesStats(requestNfType, targetNfType, result string) {
-	nrfStats.nrfNfInstances.WithLabelValues(requestNfType, targetNfType, result).Inc()
+func (ps *NrfStats) IncrementNrfNfInstancesStats(requestNfType, targetNfType, result string) {
+	if ps == nil {
+		return
+	}
+	ps.nrfNfInstances.WithLabelValues(requestNfType, targetNfType, result).Inc()
+}
+
+// SetRegisteredNfInstances replaces the number of registered NF instances
+func (ps *NrfStats) SetRegisteredNfInstances(counts map[NfInstanceKey]int) {
+	if ps == nil {
+		return
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	// the series are replaced in place, so that no scrape sees them missing
+	for key, count := range counts {
+		ps.registeredNfInstances.WithLabelValues(key.NfType, key.NfStatus).Set(float64(count))
+	}
+	for key := range ps.registeredKeys {
+		if _, ok := counts[key]; !ok {
+			ps.registeredNfInstances.DeleteLabelValues(key.NfType, key.NfStatus)
+		}
+	}
+	ps.registeredKeys = make(map[NfInstanceKey]bool, len(counts))
+	for key := range counts {
+		ps.registeredKeys[key] = true
+	}
+}
+
+// SetActiveSubscriptions replaces the number of active subscriptions, keyed by
+// requester NF type
+func (ps *NrfStats) SetActiveSubscriptions(counts map[string]int) {
+	if ps == nil {
+		return
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	for requestNfType, count := range counts {
+		ps.activeSubscriptions.WithLabelValues(requestNfType).Set(float64(count))
+	}
+	for requestNfType := range ps.subscriptionNfTypes {
+		if _, ok := counts[requestNfType]; !ok {
+			ps.activeSubscriptions.DeleteLabelValues(requestNfType)
+		}
+	}
+	ps.subscriptionNfTypes = make(map[string]bool, len(counts))
+	for requestNfType := range counts {
+		ps.subscriptionNfTypes[requestNfType] = true
+	}
+}
+
+// ObserveSbiRequest records the duration of an SBI request
+func (ps *NrfStats) ObserveSbiRequest(method, route string, status int, duration time.Duration) {
+	if ps == nil {
+		return
+	}
+	ps.sbiRequestDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(duration.Seconds())
+}
+
+// ObserveStorageOperation records the duration of a storage operation
+func (ps *NrfStats) ObserveStorageOperation(operation, collection, result string, duration time.Duration) {
+	if ps == nil {
+		return
+	}
+	ps.storageOperationDuration.WithLabelValues(operation, collection, result).Observe(duration.Seconds())
+}
+
+// IncrementNfStatusNotificationsStats increments number of total NF status notifications
+func (ps *NrfStats) IncrementNfStatusNotificationsStats(event, result string) {
+	if ps == nil {
+		return
+	}
+	ps.nfStatusNotifications.WithLabelValues(event, result).Inc()
+}
+
+// IncrementHeartbeatsStats increments number of total heartbeats
+func (ps *NrfStats) IncrementHeartbeatsStats(nfType, result string) {
+	if ps == nil {
+		return
+	}
+	ps.heartbeats.WithLabelValues(nfType, result).Inc()
+}
+
+// IncrementNfExpiriesStats increments number of total NF instance expiries
+func (ps *NrfStats) IncrementNfExpiriesStats(nfType string) {
+	if ps == nil {
+		return
+	}
+	ps.nfExpiries.WithLabelValues(nfType).Inc()
+}
+
+// SetStorageConnected records whether the storage answered the last
+// connection probe
+func (ps *NrfStats) SetStorageConnected(connected bool) {
+	if ps == nil {
+		return
+	}
+	if connected {
+		ps.storageConnected.Set(1)
+	} else {
+		ps.storageConnected.Set(0)
+	}
+}
+
+// IncrementStorageReconnectionsStats increments number of total storage reconnections
+func (ps *NrfStats) IncrementStorageReconnectionsStats() {
+	if ps == nil {
+		return
+	}
+	ps.storageReconnections.Inc()
+}
+
+// IncrementChangeStreamRestartsStats increments number of total change stream restarts
+func (ps *NrfStats) IncrementChangeStreamRestartsStats() {
+	if ps == nil {
+		return
+	}
+	ps.changeStreamRestarts.Inc()
+}
+
+// SetClusterLeader records whether this replica is the leader, counting the
+// leadership changes
+func (ps *NrfStats) SetClusterLeader(leader bool) {
+	if ps == nil {
+		return
+	}
+	ps.leadershipChanges.Inc()
+	if leader {
+		ps.clusterLeader.Set(1)
+	} else {
+		ps.clusterLeader.Set(0)
+	}
+}
+
+// IncrementFederationSyncsStats increments number of total copies of the NF
+// profiles of peer
+func (ps *NrfStats) IncrementFederationSyncsStats(peer, result string) {
+	if ps == nil {
+		return
+	}
+	ps.federationSyncs.With(prometheus.Labels{"peer": peer, "result": result}).Inc()
+}
+
+// IncrementDiscoveryForwardsStats increments number of total discovery queries
+// relayed by rule
+func (ps *NrfStats) IncrementDiscoveryForwardsStats(rule, result string) {
+	if ps == nil {
+		return
+	}
+	ps.discoveryForwards.With(prometheus.Labels{"rule": rule, "result": result}).Inc()
 }
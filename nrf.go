@@ -42,7 +42,5 @@ func action(ctx context.Context, c *cli.Command) error {
 		return fmt.Errorf("failed to initialize")
 	}
 
-	NRF.Start()
-
-	return nil
+	return NRF.Start()
 }
@@ -14,15 +14,16 @@ import (
 	"strings"
 	"time"
 
-	"github.com/omec-project/nrf/factory"
 	"github.com/omec-project/openapi/models"
 )
 
 const nfconfigPlmnEndpoint = "/nfconfig/plmn"
 
-var FetchPlmnConfig = func() ([]models.PlmnId, error) {
-	plmnConfigEndpoint := factory.NrfConfig.Configuration.WebuiUri + nfconfigPlmnEndpoint
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// FetchPlmnConfig fetches the supported PLMNs from the webconsole at webuiUri
+// with client
+func FetchPlmnConfig(ctx context.Context, client *http.Client, webuiUri string) ([]models.PlmnId, error) {
+	plmnConfigEndpoint := webuiUri + nfconfigPlmnEndpoint
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, plmnConfigEndpoint, nil)
@@ -31,7 +32,6 @@ var FetchPlmnConfig = func() ([]models.PlmnId, error) {
 	}
 	req.Header.Set("Accept", "application/json")
 
-	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("HTTP GET %v failed: %w", plmnConfigEndpoint, err)
@@ -10,6 +10,7 @@
 package polling
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -17,7 +18,6 @@ import (
 	"strings"
 	"testing"
 
-	"github.com/omec-project/nrf/factory"
 	"github.com/omec-project/openapi/models"
 	"github.com/stretchr/testify/assert"
 )
@@ -84,7 +84,6 @@ func TestFetchPlmnConfig(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			originalNrfConfig := factory.NrfConfig
 			handler := func(w http.ResponseWriter, r *http.Request) {
 				accept := r.Header.Get("Accept")
 				assert.Equal(t, "application/json", accept)
@@ -94,16 +93,8 @@ func TestFetchPlmnConfig(t *testing.T) {
 				_, _ = w.Write([]byte(tc.responseBody))
 			}
 			server := httptest.NewServer(http.HandlerFunc(handler))
-			defer func() {
-				factory.NrfConfig = originalNrfConfig
-				server.Close()
-			}()
-			factory.NrfConfig = factory.Config{
-				Configuration: &factory.Configuration{
-					WebuiUri: server.URL,
-				},
-			}
-			fetchedConfig, err := FetchPlmnConfig()
+			defer server.Close()
+			fetchedConfig, err := FetchPlmnConfig(context.Background(), server.Client(), server.URL)
 
 			if tc.expectedError == "" {
 				if err != nil {
@@ -120,8 +111,6 @@ func TestFetchPlmnConfig(t *testing.T) {
 					t.Errorf("expected error `%v`, got `%v`", tc.expectedError, err)
 				}
 			}
-
-			factory.NrfConfig.Configuration = nil
 		})
 	}
 }
@@ -9,18 +9,17 @@ import (
 	"net/http"
 
 	jwt "github.com/golang-jwt/jwt/v5"
-	"github.com/omec-project/nrf/logger"
 	"github.com/omec-project/openapi/models"
 	"github.com/omec-project/util/httpwrapper"
 )
 
-func HandleAccessTokenRequest(request *httpwrapper.Request) *httpwrapper.Response {
+func (p *Producer) HandleAccessTokenRequest(request *httpwrapper.Request) *httpwrapper.Response {
 	// Param of AccessTokenRsp
-	logger.AccessTokenLog.Infoln("Handle AccessTokenRequest")
+	p.Log.AccessTokenLog.Infoln("Handle AccessTokenRequest")
 
 	accessTokenReq := request.Body.(models.AccessTokenReq)
 
-	response, errResponse := AccessTokenProcedure(accessTokenReq)
+	response, errResponse := p.AccessTokenProcedure(accessTokenReq)
 
 	if response != nil {
 		// status code is based on SPEC, and option headers
@@ -35,10 +34,10 @@ func HandleAccessTokenRequest(request *httpwrapper.Request) *httpwrapper.Respons
 	return httpwrapper.NewResponse(http.StatusForbidden, nil, problemDetails)
 }
 
-func AccessTokenProcedure(request models.AccessTokenReq) (response *models.AccessTokenRsp,
+func (p *Producer) AccessTokenProcedure(request models.AccessTokenReq) (response *models.AccessTokenRsp,
 	errResponse *models.AccessTokenErr,
 ) {
-	logger.AccessTokenLog.Infoln("In AccessTokenProcedure")
+	p.Log.AccessTokenLog.Infoln("In AccessTokenProcedure")
 
 	var expiration int32 = 1000
 	scope := request.Scope
@@ -46,7 +45,7 @@ func AccessTokenProcedure(request models.AccessTokenReq) (response *models.Acces
 
 	// Create AccessToken
 	accessTokenClaims := models.AccessTokenClaims{
-		Iss:              "1234567",                  // TODO: NF instance id of the NRF
+		Iss:              p.GetNrfNfProfile().NfInstanceId,
 		Sub:              request.NfInstanceId,       // nfInstanceId of service consumer
 		Aud:              request.TargetNfInstanceId, // nfInstanceId of service producer
 		Scope:            request.Scope,              // TODO: the name of the NF services for which the
@@ -58,7 +57,7 @@ func AccessTokenProcedure(request models.AccessTokenReq) (response *models.Acces
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, accessTokenClaims)
 	accessToken, err := token.SignedString(mySigningKey)
 	if err != nil {
-		logger.AccessTokenLog.Warnln("Signed string error: ", err)
+		p.Log.AccessTokenLog.Warnln("Signed string error: ", err)
 		errResponse = &models.AccessTokenErr{
 			Error: "invalid_request",
 		}
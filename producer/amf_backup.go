@@ -0,0 +1,102 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package producer
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/url"
+	"slices"
+
+	"github.com/omec-project/openapi/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// backupAmfKey is the customInfo key marking the AMFs answered as backup
+	// of the AMF serving the GUAMI of a discovery query, with the cause of
+	// the failover
+	backupAmfKey     = "backupAmf"
+	backupAmfFailure = "AMF_FAILURE"
+	backupAmfRemoval = "AMF_REMOVAL"
+)
+
+// backupInfoFields are the amfInfo attributes listing the GUAMIs an AMF is
+// the backup of, by failover cause
+var backupInfoFields = map[string]string{
+	backupAmfFailure: "amfInfo.backupInfoAmfFailure",
+	backupAmfRemoval: "amfInfo.backupInfoAmfRemoval",
+}
+
+// amfBackupFailover returns, when no REGISTERED AMF serves the GUAMI of the
+// discovery query, the REGISTERED AMFs which are its backup and match the
+// other query parameters, marked as such (TS 23.501 5.21.2). A SUSPENDED AMF
+// has failed, so that the backups for AMF failure are preferred, while an
+// AMF which is gone was most likely removed, so that the backups for AMF
+// removal are. nfProfilesRaw is returned otherwise.
+func (p *Producer) amfBackupFailover(ctx context.Context, queryParameters url.Values, nfProfilesRaw []map[string]interface{}) (
+	[]map[string]interface{}, error,
+) {
+	if queryParameters.Get("target-nf-type") != string(models.NfType_AMF) || queryParameters.Get("guami") == "" ||
+		slices.ContainsFunc(nfProfilesRaw, isRegistered) {
+		return nfProfilesRaw, nil
+	}
+	var guami models.Guami
+	if err := json.Unmarshal([]byte(queryParameters.Get("guami")), &guami); err != nil {
+		p.Log.DiscoveryLog.Warnln("Unmarshal Error in guami: ", err)
+		return nfProfilesRaw, nil
+	}
+	guamiByteArray, err := bson.Marshal(guami)
+	if err != nil {
+		return nil, err
+	}
+	guamiBsonM := bson.M{}
+	if err = bson.Unmarshal(guamiByteArray, &guamiBsonM); err != nil {
+		return nil, err
+	}
+
+	causes := []string{backupAmfRemoval, backupAmfFailure}
+	if len(nfProfilesRaw) != 0 {
+		causes = []string{backupAmfFailure, backupAmfRemoval}
+	}
+	backupQuery := maps.Clone(queryParameters)
+	backupQuery.Del("guami")
+	for _, cause := range causes {
+		filter := p.buildFilter(backupQuery)
+		filter["$and"] = append(filter["$and"].([]bson.M),
+			bson.M{backupInfoFields[cause]: bson.M{"$elemMatch": guamiBsonM}},
+			bson.M{"nfStatus": string(models.NfStatus_REGISTERED)},
+		)
+		backups, err := p.DB.RestfulAPIGetMany(ctx, "NfProfile", filter)
+		if err != nil {
+			return nil, err
+		}
+		if len(backups) == 0 {
+			continue
+		}
+		p.Log.DiscoveryLog.Infof("no AMF serves GUAMI %s, answering %d backup AMFs for %s",
+			queryParameters.Get("guami"), len(backups), cause)
+		marked := make([]map[string]interface{}, len(backups))
+		for i, backup := range backups {
+			marked[i] = markBackupAmf(backup, cause)
+		}
+		return marked, nil
+	}
+	return nfProfilesRaw, nil
+}
+
+// markBackupAmf returns the stored profile of a backup AMF with the cause of
+// the failover in its customInfo
+func markBackupAmf(profile map[string]interface{}, cause string) map[string]interface{} {
+	marked := maps.Clone(profile)
+	customInfo := map[string]interface{}{}
+	if existing, ok := profile["customInfo"].(map[string]interface{}); ok {
+		maps.Copy(customInfo, existing)
+	}
+	customInfo[backupAmfKey] = cause
+	marked["customInfo"] = customInfo
+	return marked
+}
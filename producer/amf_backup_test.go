@@ -0,0 +1,103 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package producer_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// BackupAmfMockMongoDBClient answers primary to the queries by GUAMI, and the
+// backup AMFs to the queries by backup information
+type BackupAmfMockMongoDBClient struct {
+	MockMongoDBClient
+	primary        []map[string]interface{}
+	failureBackups []map[string]interface{}
+	removalBackups []map[string]interface{}
+}
+
+func (db *BackupAmfMockMongoDBClient) RestfulAPIGetMany(ctx context.Context, collName string, filter bson.M) ([]map[string]interface{}, error) {
+	raw, _ := json.Marshal(filter)
+	switch {
+	case strings.Contains(string(raw), `"amfInfo.backupInfoAmfFailure":{"$elemMatch":{"amfId":"cafe00"`):
+		return db.failureBackups, nil
+	case strings.Contains(string(raw), `"amfInfo.backupInfoAmfRemoval":{"$elemMatch":{"amfId":"cafe00"`):
+		return db.removalBackups, nil
+	case strings.Contains(string(raw), `"amfInfo.guamiList":{"$elemMatch":{"amfId":"cafe00"`):
+		return db.primary, nil
+	}
+	return nil, nil
+}
+
+func TestNFDiscoveryProcedureBackupAmf(t *testing.T) {
+	failureBackup := map[string]interface{}{"nfInstanceId": "amf-2", "nfType": "AMF", "nfStatus": "REGISTERED"}
+	removalBackup := map[string]interface{}{"nfInstanceId": "amf-3", "nfType": "AMF", "nfStatus": "REGISTERED"}
+	testCases := []struct {
+		name           string
+		primary        []map[string]interface{}
+		removalBackups []map[string]interface{}
+		expected       string
+		backupAmf      interface{}
+	}{
+		{
+			name:           "Primary registered",
+			primary:        []map[string]interface{}{{"nfInstanceId": "amf-1", "nfType": "AMF", "nfStatus": "REGISTERED"}},
+			removalBackups: []map[string]interface{}{removalBackup},
+			expected:       "amf-1",
+		},
+		{
+			name:           "Primary suspended",
+			primary:        []map[string]interface{}{{"nfInstanceId": "amf-1", "nfType": "AMF", "nfStatus": "SUSPENDED"}},
+			removalBackups: []map[string]interface{}{removalBackup},
+			expected:       "amf-2",
+			backupAmf:      "AMF_FAILURE",
+		},
+		{
+			name:           "Primary removed",
+			removalBackups: []map[string]interface{}{removalBackup},
+			expected:       "amf-3",
+			backupAmf:      "AMF_REMOVAL",
+		},
+		{
+			name:      "Primary removed without backup for removal",
+			expected:  "amf-2",
+			backupAmf: "AMF_FAILURE",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := &BackupAmfMockMongoDBClient{
+				primary:        tc.primary,
+				failureBackups: []map[string]interface{}{failureBackup},
+				removalBackups: tc.removalBackups,
+			}
+			p := newTestProducer(t, db)
+			query := url.Values{
+				"target-nf-type":    []string{"AMF"},
+				"requester-nf-type": []string{"SMF"},
+				"guami":             []string{`{"plmnId":{"mcc":"208","mnc":"93"},"amfId":"cafe00"}`},
+			}
+			response, problemDetails := p.NFDiscoveryProcedure(context.Background(), query)
+			if problemDetails != nil {
+				t.Fatalf("unexpected error: %+v", problemDetails)
+			}
+			if len(response.NfInstances) != 1 || response.NfInstances[0].NfInstanceId != tc.expected {
+				t.Fatalf("expected %s, got %+v", tc.expected, response.NfInstances)
+			}
+			if backupAmf := response.NfInstances[0].CustomInfo["backupAmf"]; fmt.Sprint(backupAmf) != fmt.Sprint(tc.backupAmf) {
+				t.Errorf("expected the AMF to be marked with %v, got %v", tc.backupAmf, backupAmf)
+			}
+			if _, ok := failureBackup["customInfo"]; ok {
+				t.Error("expected the stored profile not to be marked")
+			}
+		})
+	}
+}
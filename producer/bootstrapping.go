@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package producer
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/omec-project/nrf/factory"
+	"github.com/omec-project/openapi/models"
+	"github.com/omec-project/util/httpwrapper"
+)
+
+// BootstrappingStatus tells whether the NRF can serve the NFs (TS 29.510
+// 6.4.6.3.3)
+type BootstrappingStatus string
+
+const (
+	BootstrappingStatus_OPERATIVE     BootstrappingStatus = "OPERATIVE"
+	BootstrappingStatus_NON_OPERATIVE BootstrappingStatus = "NON_OPERATIVE"
+)
+
+// BootstrappingInfo is the answer of Nnrf_Bootstrapping (TS 29.510 6.4.6.2.2):
+// the status of the NRF and the links to its services, keyed by service name
+type BootstrappingInfo struct {
+	Status BootstrappingStatus                `json:"status,omitempty"`
+	Links  map[string]models.LinksValueSchema `json:"_links"`
+}
+
+// oauth2LinkName is the key of the link to the access token endpoint
+const oauth2LinkName = "oauth2"
+
+func (p *Producer) HandleBootstrappingRequest(request *httpwrapper.Request) *httpwrapper.Response {
+	p.Log.HandlerLog.Infoln("Handle BootstrappingRequest")
+
+	return httpwrapper.NewResponse(http.StatusOK, nil, p.BootstrappingProcedure())
+}
+
+// ProvidesService tells whether the NRF provides serviceName, an NRF service
+// in the serviceNameList. The server mounts the routes of NFManagement and
+// NFDiscovery only when they are provided.
+func (p *Producer) ProvidesService(serviceName models.ServiceName) bool {
+	return slices.Contains(p.Config.Configuration.ServiceNameList, string(serviceName))
+}
+
+// BootstrappingProcedure returns the status of the NRF and the links to the
+// services it provides: NFManagement and NFDiscovery when they are in the
+// serviceNameList, and the access token endpoint, which is always served
+func (p *Producer) BootstrappingProcedure() *BootstrappingInfo {
+	status := BootstrappingStatus_OPERATIVE
+	if p.IsOperative != nil && !p.IsOperative() {
+		status = BootstrappingStatus_NON_OPERATIVE
+	}
+	apiRoot := p.Config.GetSbiUri()
+	links := map[string]models.LinksValueSchema{
+		oauth2LinkName: {Href: apiRoot + factory.NRF_ACCESS_TOKEN_URI},
+	}
+	if p.ProvidesService(models.ServiceName_NNRF_NFM) {
+		links[string(models.ServiceName_NNRF_NFM)] = models.LinksValueSchema{Href: apiRoot + factory.NRF_NFM_RES_URI_PREFIX}
+	}
+	if p.ProvidesService(models.ServiceName_NNRF_DISC) {
+		links[string(models.ServiceName_NNRF_DISC)] = models.LinksValueSchema{Href: apiRoot + factory.NRF_DISC_RES_URI_PREFIX}
+	}
+	return &BootstrappingInfo{Status: status, Links: links}
+}
@@ -0,0 +1,51 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package producer_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/omec-project/openapi/models"
+)
+
+func TestBootstrappingProcedureServices(t *testing.T) {
+	testCases := []struct {
+		name            string
+		serviceNameList []string
+		expectedLinks   []string
+	}{
+		{
+			name:            "All services",
+			serviceNameList: []string{"nnrf-nfm", "nnrf-disc"},
+			expectedLinks:   []string{"nnrf-disc", "nnrf-nfm", "oauth2"},
+		},
+		{
+			name:            "Discovery disabled",
+			serviceNameList: []string{"nnrf-nfm"},
+			expectedLinks:   []string{"nnrf-nfm", "oauth2"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newTestProducer(t, &MockMongoDBClient{})
+			p.Config.Configuration.ServiceNameList = tc.serviceNameList
+
+			var links []string
+			for name := range p.BootstrappingProcedure().Links {
+				links = append(links, name)
+			}
+			slices.Sort(links)
+			if !slices.Equal(links, tc.expectedLinks) {
+				t.Errorf("expected the links %v, got %v", tc.expectedLinks, links)
+			}
+			for _, serviceName := range []models.ServiceName{models.ServiceName_NNRF_NFM, models.ServiceName_NNRF_DISC} {
+				if provided := p.ProvidesService(serviceName); provided != slices.Contains(tc.expectedLinks, string(serviceName)) {
+					t.Errorf("expected %s to be provided: %v, got %v", serviceName, !provided, provided)
+				}
+			}
+		})
+	}
+}
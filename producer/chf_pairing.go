@@ -0,0 +1,100 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package producer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// chfPair returns the primaryChfInstance and secondaryChfInstance of the
+// chfInfo of the stored profile. A primary CHF gives its secondary, and a
+// secondary CHF its primary.
+func chfPair(profile map[string]interface{}) (primary, secondary string) {
+	var chfInfo struct {
+		PrimaryChfInstance   string `json:"primaryChfInstance"`
+		SecondaryChfInstance string `json:"secondaryChfInstance"`
+	}
+	raw, err := json.Marshal(profile["chfInfo"])
+	if err != nil || json.Unmarshal(raw, &chfInfo) != nil {
+		return "", ""
+	}
+	return chfInfo.PrimaryChfInstance, chfInfo.SecondaryChfInstance
+}
+
+// pairChfs returns the CHFs of nfProfilesRaw grouped as primary and secondary
+// pairs, the primary first, so that charging uses a consistent pair. The
+// partner of a CHF is answered with it even when it does not serve the
+// subscriber or the PLMN of the query, but only when the requester is
+// allowed to discover it. A CHF of a pair which is not REGISTERED is left
+// out, so that the secondary is promoted when the primary is unavailable,
+// unless neither is REGISTERED.
+func (p *Producer) pairChfs(ctx context.Context, queryParameters url.Values, nfProfilesRaw []map[string]interface{}) (
+	[]map[string]interface{}, error,
+) {
+	profiles := make(map[string]map[string]interface{}, len(nfProfilesRaw))
+	for _, profile := range nfProfilesRaw {
+		profiles[fmt.Sprint(profile["nfInstanceId"])] = profile
+	}
+	var missing []string
+	for _, profile := range nfProfilesRaw {
+		primary, secondary := chfPair(profile)
+		for _, partner := range []string{primary, secondary} {
+			if partner != "" && profiles[partner] == nil && !slices.Contains(missing, partner) {
+				missing = append(missing, partner)
+			}
+		}
+	}
+	if len(missing) != 0 {
+		partnerQuery := maps.Clone(queryParameters)
+		partnerQuery.Del("target-nf-instance-id")
+		partnerQuery.Del("chf-supported-plmn")
+		partnerQuery.Del("supi")
+		partnerQuery.Del("gpsi")
+		filter := p.buildFilter(partnerQuery)
+		filter["$and"] = append(filter["$and"].([]bson.M), bson.M{"nfInstanceId": bson.M{"$in": missing}})
+		partners, err := p.DB.RestfulAPIGetMany(ctx, "NfProfile", filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, partner := range partners {
+			profiles[fmt.Sprint(partner["nfInstanceId"])] = partner
+		}
+	}
+
+	paired := make([]map[string]interface{}, 0, len(nfProfilesRaw))
+	answered := map[string]bool{}
+	for _, profile := range nfProfilesRaw {
+		pair := []map[string]interface{}{profile}
+		if primary, secondary := chfPair(profile); secondary != "" && profiles[secondary] != nil {
+			pair = append(pair, profiles[secondary])
+		} else if primary != "" && profiles[primary] != nil {
+			pair = []map[string]interface{}{profiles[primary], profile}
+		}
+		if live := slices.DeleteFunc(slices.Clone(pair), func(chf map[string]interface{}) bool {
+			return !isRegistered(chf)
+		}); len(live) != 0 {
+			if len(live) < len(pair) && len(pair) == 2 && !isRegistered(pair[0]) {
+				p.Log.DiscoveryLog.Infof("primary CHF %v is not available, promoting its secondary %v",
+					pair[0]["nfInstanceId"], pair[1]["nfInstanceId"])
+			}
+			pair = live
+		}
+		for _, chf := range pair {
+			nfInstanceId := fmt.Sprint(chf["nfInstanceId"])
+			if !answered[nfInstanceId] {
+				answered[nfInstanceId] = true
+				paired = append(paired, chf)
+			}
+		}
+	}
+	return paired, nil
+}
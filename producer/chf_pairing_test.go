@@ -0,0 +1,142 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package producer_test
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/omec-project/nrf/producer"
+	"github.com/omec-project/openapi/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ChfPairMockMongoDBClient answers matched to the discovery queries, and the
+// stored CHFs allowing the requester NF type to the queries of partners by NF
+// instance id, whose filter it records
+type ChfPairMockMongoDBClient struct {
+	MockMongoDBClient
+	matched       []map[string]interface{}
+	stored        map[string]map[string]interface{}
+	partnerFilter bson.M
+}
+
+func (db *ChfPairMockMongoDBClient) RestfulAPIGetMany(ctx context.Context, collName string, filter bson.M) ([]map[string]interface{}, error) {
+	var nfInstanceIds []string
+	var requesterNfType string
+	for _, clause := range filter["$and"].([]bson.M) {
+		if condition, ok := clause["nfInstanceId"].(bson.M); ok {
+			nfInstanceIds = condition["$in"].([]string)
+		}
+		if alternatives, ok := clause["$or"].([]bson.M); ok {
+			if nfType, ok := alternatives[0]["allowedNfTypes"].(string); ok {
+				requesterNfType = nfType
+			}
+		}
+	}
+	if nfInstanceIds == nil {
+		return db.matched, nil
+	}
+	db.partnerFilter = filter
+	var partners []map[string]interface{}
+	for _, nfInstanceId := range nfInstanceIds {
+		profile, ok := db.stored[nfInstanceId]
+		if !ok {
+			continue
+		}
+		if allowed, ok := profile["allowedNfTypes"].([]string); ok && !slices.Contains(allowed, requesterNfType) {
+			continue
+		}
+		partners = append(partners, profile)
+	}
+	return partners, nil
+}
+
+func chfProfile(nfInstanceId, nfStatus, partnerRole, partner string) map[string]interface{} {
+	profile := map[string]interface{}{"nfInstanceId": nfInstanceId, "nfType": "CHF", "nfStatus": nfStatus}
+	if partnerRole != "" {
+		profile["chfInfo"] = map[string]interface{}{partnerRole: partner}
+	}
+	return profile
+}
+
+func TestNFDiscoveryProcedureChfPairs(t *testing.T) {
+	stored := map[string]map[string]interface{}{
+		"chf-p1": chfProfile("chf-p1", "REGISTERED", "secondaryChfInstance", "chf-s1"),
+		"chf-s1": chfProfile("chf-s1", "REGISTERED", "primaryChfInstance", "chf-p1"),
+		"chf-p2": chfProfile("chf-p2", "SUSPENDED", "secondaryChfInstance", "chf-s2"),
+		"chf-s2": chfProfile("chf-s2", "REGISTERED", "primaryChfInstance", "chf-p2"),
+		"chf-3":  chfProfile("chf-3", "REGISTERED", "", ""),
+		"chf-p4": chfProfile("chf-p4", "REGISTERED", "secondaryChfInstance", "chf-s4"),
+		"chf-s4": chfProfile("chf-s4", "REGISTERED", "primaryChfInstance", "chf-p4"),
+	}
+	stored["chf-s4"]["allowedNfTypes"] = []string{"AMF"}
+	testCases := []struct {
+		name     string
+		matched  []string
+		expected string
+	}{
+		{name: "Secondary included", matched: []string{"chf-p1"}, expected: "[chf-p1 chf-s1]"},
+		{name: "Primary first", matched: []string{"chf-s1", "chf-p1"}, expected: "[chf-p1 chf-s1]"},
+		{name: "Secondary promoted", matched: []string{"chf-p2"}, expected: "[chf-s2]"},
+		{name: "Unpaired", matched: []string{"chf-3", "chf-s2"}, expected: "[chf-3 chf-s2]"},
+		{name: "Partner not allowed", matched: []string{"chf-p4"}, expected: "[chf-p4]"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := &ChfPairMockMongoDBClient{stored: stored}
+			for _, nfInstanceId := range tc.matched {
+				db.matched = append(db.matched, stored[nfInstanceId])
+			}
+			p := newTestProducer(t, db)
+			query := url.Values{
+				"target-nf-type":     []string{"CHF"},
+				"requester-nf-type":  []string{"SMF"},
+				"chf-supported-plmn": []string{`{"mcc":"208","mnc":"93"}`},
+			}
+			response, problemDetails := p.NFDiscoveryProcedure(context.Background(), query)
+			if problemDetails != nil {
+				t.Fatalf("unexpected error: %+v", problemDetails)
+			}
+			var instances []string
+			for _, profile := range response.NfInstances {
+				instances = append(instances, profile.NfInstanceId)
+			}
+			if fmt.Sprint(instances) != tc.expected {
+				t.Errorf("expected %s, got %v", tc.expected, instances)
+			}
+			// the partners need not serve the PLMN of the query
+			if strings.Contains(fmt.Sprint(db.partnerFilter), "plmnRangeList") {
+				t.Errorf("expected the partners not to be filtered by chf-supported-plmn, got %v", db.partnerFilter)
+			}
+		})
+	}
+}
+
+func TestNFRegisterProcedureChfPair(t *testing.T) {
+	db := &SharedDataMockMongoDBClient{collections: map[string]map[string]map[string]interface{}{}}
+	p := newTestProducer(t, db)
+	p.FetchPlmnConfig = func(context.Context) ([]models.PlmnId, error) {
+		return []models.PlmnId{{Mcc: "208", Mnc: "93"}}, nil
+	}
+	secondary := "chf-s1"
+	nf := models.NfProfile{
+		NfInstanceId: "chf-p1",
+		NfType:       models.NfType_CHF,
+		NfStatus:     models.NfStatus_REGISTERED,
+		ChfInfo:      &models.ChfInfo{SecondaryChfInstance: &secondary},
+	}
+	if _, _, problemDetails := p.NFRegisterProcedure(context.Background(), producer.NfProfileRegistration{NfProfile: nf}); problemDetails != nil {
+		t.Fatalf("unexpected registration failure: %+v", problemDetails)
+	}
+	chfInfo, _ := db.document("NfProfile", "chf-p1")["chfInfo"].(map[string]interface{})
+	if chfInfo["secondaryChfInstance"] != secondary {
+		t.Errorf("expected the secondary CHF to be stored, got %v", chfInfo)
+	}
+}
@@ -0,0 +1,56 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package producer
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/omec-project/nrf/factory"
+	"github.com/omec-project/nrf/federation"
+	"github.com/omec-project/openapi/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// addFederatedProfiles adds to the local profiles matching filter the ones
+// mirrored from the peer NRFs, following the discovery policy of the
+// federation. A local profile wins over a mirrored one of the same NF
+// instance. The NRFs are not answered the mirrored profiles, which would make
+// the peers mirror each other's mirrors.
+func (p *Producer) addFederatedProfiles(ctx context.Context, queryParameters url.Values, filter bson.M,
+	local []map[string]interface{},
+) ([]map[string]interface{}, error) {
+	config := p.Config.GetFederation()
+	if config == nil || queryParameters.Get("requester-nf-type") == string(models.NfType_NRF) {
+		return local, nil
+	}
+	if config.Discovery != factory.NRF_FEDERATION_MERGED && len(local) != 0 {
+		return local, nil
+	}
+	mirrored, err := p.DB.RestfulAPIGetMany(ctx, federation.CollName, filter)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(local))
+	for _, profile := range local {
+		known[fmt.Sprint(profile["nfInstanceId"])] = true
+	}
+	now := time.Now()
+	profiles := local
+	for _, profile := range mirrored {
+		if federation.Expired(profile, now) {
+			continue
+		}
+		nfInstanceId := fmt.Sprint(profile["nfInstanceId"])
+		if known[nfInstanceId] {
+			continue
+		}
+		known[nfInstanceId] = true
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
@@ -6,6 +6,7 @@
 package producer
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"math/big"
@@ -16,10 +17,7 @@ import (
 	"strings"
 	"time"
 
-	"github.com/omec-project/nrf/context"
-	"github.com/omec-project/nrf/dbadapter"
-	"github.com/omec-project/nrf/logger"
-	stats "github.com/omec-project/nrf/metrics"
+	nrfContext "github.com/omec-project/nrf/context"
 	"github.com/omec-project/nrf/util"
 	"github.com/omec-project/openapi/models"
 	"github.com/omec-project/util/httpwrapper"
@@ -27,31 +25,31 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func HandleNFDiscoveryRequest(request *httpwrapper.Request) *httpwrapper.Response {
+func (p *Producer) HandleNFDiscoveryRequest(ctx context.Context, request *httpwrapper.Request) *httpwrapper.Response {
 	// Get all query parameters
-	logger.DiscoveryLog.Infoln("Handle NFDiscoveryRequest")
+	p.Log.DiscoveryLog.Infoln("Handle NFDiscoveryRequest")
 
-	response, problemDetails := NFDiscoveryProcedure(request.Query)
+	response, problemDetails := p.NFDiscoveryProcedure(ctx, request.Query)
 	requesterNfType, targetNfType := GetRequesterAndTargetNfTypeGivenQueryParameters(request.Query)
 	// Send Response
 	// step 4: process the return value from step 3
 	if response != nil {
 		// status code is based on SPEC, and option headers
-		stats.IncrementNrfNfInstancesStats(requesterNfType, targetNfType, "SUCCESS")
+		p.Metrics.IncrementNrfNfInstancesStats(requesterNfType, targetNfType, "SUCCESS")
 		return httpwrapper.NewResponse(http.StatusOK, nil, response)
 	} else if problemDetails != nil {
-		stats.IncrementNrfNfInstancesStats(requesterNfType, targetNfType, "FAILURE")
+		p.Metrics.IncrementNrfNfInstancesStats(requesterNfType, targetNfType, "FAILURE")
 		return httpwrapper.NewResponse(int(problemDetails.Status), nil, problemDetails)
 	}
 	problemDetails = &models.ProblemDetails{
 		Status: http.StatusForbidden,
 		Cause:  "UNSPECIFIED",
 	}
-	stats.IncrementNrfNfInstancesStats(requesterNfType, targetNfType, "FAILURE")
+	p.Metrics.IncrementNrfNfInstancesStats(requesterNfType, targetNfType, "FAILURE")
 	return httpwrapper.NewResponse(http.StatusForbidden, nil, problemDetails)
 }
 
-func NFDiscoveryProcedure(queryParameters url.Values) (response *models.SearchResult,
+func (p *Producer) NFDiscoveryProcedure(ctx context.Context, queryParameters url.Values) (response *models.SearchResult,
 	problemDetails *models.ProblemDetails,
 ) {
 	if queryParameters["target-nf-type"] == nil || queryParameters["requester-nf-type"] == nil {
@@ -63,6 +61,20 @@ func NFDiscoveryProcedure(queryParameters url.Values) (response *models.SearchRe
 		return nil, problemDetails
 	}
 
+	if p.Forwarder != nil {
+		if err := p.Forwarder.CheckHnrfUri(queryParameters); err != nil {
+			return nil, &models.ProblemDetails{
+				Title:  "Invalid Parameter",
+				Status: http.StatusBadRequest,
+				Cause:  "INVALID_QUERY_PARAM",
+				Detail: "hnrf-uri " + err.Error(),
+				InvalidParams: []models.InvalidParam{
+					{Param: "hnrf-uri", Reason: err.Error()},
+				},
+			}
+		}
+	}
+
 	if queryParameters["complexQuery"] != nil {
 		// IF SUPPORT COMPLEX QUERY
 		// translate raw data to complexQuery structure
@@ -70,7 +82,7 @@ func NFDiscoveryProcedure(queryParameters url.Values) (response *models.SearchRe
 		complexQueryStruct := &models.ComplexQuery{}
 		err := json.Unmarshal([]byte(complexQuery), complexQueryStruct)
 		if err != nil {
-			logger.DiscoveryLog.Warnln("UnMasrhal complexQuery Error: ", err)
+			p.Log.DiscoveryLog.Warnln("UnMasrhal complexQuery Error: ", err)
 		}
 		// Check either CNF or DNF
 		if complexQueryStruct.CNf != nil && complexQueryStruct.DNf != nil {
@@ -89,29 +101,61 @@ func NFDiscoveryProcedure(queryParameters url.Values) (response *models.SearchRe
 	// Check ComplexQuery (FOR REPORT PROBLEM!)
 
 	// Build Query Filter
-	filter := buildFilter(queryParameters)
-	logger.DiscoveryLog.Debugln("query filter:", filter)
+	filter := p.buildFilter(queryParameters)
+	p.Log.DiscoveryLog.Debugln("query filter:", filter)
 
 	// Use the filter to find documents
-	nfProfilesRaw, _ := dbadapter.DBClient.RestfulAPIGetMany("NfProfile", filter)
+	nfProfilesRaw, err := p.DB.RestfulAPIGetMany(ctx, "NfProfile", filter)
+	if err != nil {
+		p.Log.DiscoveryLog.Errorln("DB error in NFDiscoveryProcedure: ", err)
+		return nil, storageProblemDetails("SYSTEM_FAILURE", err)
+	}
+	nfProfilesRaw, err = p.nfSetFailover(ctx, queryParameters, nfProfilesRaw)
+	if err != nil {
+		p.Log.DiscoveryLog.Errorln("DB error in NFDiscoveryProcedure: ", err)
+		return nil, storageProblemDetails("SYSTEM_FAILURE", err)
+	}
+	nfProfilesRaw, err = p.amfBackupFailover(ctx, queryParameters, nfProfilesRaw)
+	if err != nil {
+		p.Log.DiscoveryLog.Errorln("DB error in NFDiscoveryProcedure: ", err)
+		return nil, storageProblemDetails("SYSTEM_FAILURE", err)
+	}
+	if queryParameters["target-nf-type"][0] == "CHF" {
+		nfProfilesRaw, err = p.pairChfs(ctx, queryParameters, nfProfilesRaw)
+		if err != nil {
+			p.Log.DiscoveryLog.Errorln("DB error in NFDiscoveryProcedure: ", err)
+			return nil, storageProblemDetails("SYSTEM_FAILURE", err)
+		}
+	}
+	nfProfilesRaw, err = p.addFederatedProfiles(ctx, queryParameters, filter, nfProfilesRaw)
+	if err != nil {
+		p.Log.DiscoveryLog.Errorln("DB error in NFDiscoveryProcedure: ", err)
+		return nil, storageProblemDetails("SYSTEM_FAILURE", err)
+	}
 
 	// nfProfile data for response
 	var nfProfilesStruct []models.NfProfile
 
-	nfProfilesStruct, err := util.Decode(nfProfilesRaw, time.RFC3339)
+	nfProfilesStruct, err = util.Decode(nfProfilesRaw, time.RFC3339)
 	if err != nil {
-		logger.DiscoveryLog.Warnln("NF Profile Raw decode error: ", nfProfilesStruct)
+		p.Log.DiscoveryLog.Warnln("NF Profile Raw decode error: ", nfProfilesStruct)
+	}
+	if queryParameters["target-nf-set-id"] != nil || queryParameters["target-nf-service-set-id"] != nil {
+		preferRegistered(nfProfilesStruct)
+	}
+	if queryParameters["target-nf-type"][0] == "UPF" {
+		rankUpfs(nfProfilesStruct, queryParameters.Get("preferred-locality"))
 	}
 
 	// sort nfprofiles based on timestamp
 	sort.Slice(nfProfilesRaw, func(i, j int) bool {
-		var updatedTimeVal time.Time
-		if nfProfilesRaw[i]["expireAt"] == nil {
-			return false
+		// profiles without expireAt, such as the NRF's own, never expire and sort last
+		expireAtI, okI := nfProfilesRaw[i]["expireAt"].(primitive.DateTime)
+		expireAtJ, okJ := nfProfilesRaw[j]["expireAt"].(primitive.DateTime)
+		if !okI || !okJ {
+			return okI && !okJ
 		}
-		updatedTimeVal = nfProfilesRaw[j]["expireAt"].(primitive.DateTime).Time()
-
-		return nfProfilesRaw[i]["expireAt"].(primitive.DateTime).Time().Before(updatedTimeVal)
+		return expireAtI.Time().Before(expireAtJ.Time())
 	})
 
 	// handle ipv4 & ipv6
@@ -121,25 +165,25 @@ func NFDiscoveryProcedure(queryParameters url.Values) (response *models.SearchRe
 				for j := range *nfProfile.BsfInfo.Ipv4AddressRanges {
 					ipv4IntStart, err := strconv.Atoi((((*(*nfProfilesStruct[i].BsfInfo).Ipv4AddressRanges)[j]).Start))
 					if err != nil {
-						logger.DiscoveryLog.Warnln("ipv4IntStart Atoi Error: ", err)
+						p.Log.DiscoveryLog.Warnln("ipv4IntStart Atoi Error: ", err)
 					}
-					((*(*nfProfilesStruct[i].BsfInfo).Ipv4AddressRanges)[j]).Start = context.Ipv4IntToIpv4String(int64(ipv4IntStart))
+					((*(*nfProfilesStruct[i].BsfInfo).Ipv4AddressRanges)[j]).Start = nrfContext.Ipv4IntToIpv4String(int64(ipv4IntStart))
 					ipv4IntEnd, err := strconv.Atoi((((*(*nfProfilesStruct[i].BsfInfo).Ipv4AddressRanges)[j]).End))
 					if err != nil {
-						logger.DiscoveryLog.Warnln("ipv4IntEnd Atoi Error: ", err)
+						p.Log.DiscoveryLog.Warnln("ipv4IntEnd Atoi Error: ", err)
 					}
-					((*(*nfProfilesStruct[i].BsfInfo).Ipv4AddressRanges)[j]).End = context.Ipv4IntToIpv4String(int64(ipv4IntEnd))
+					((*(*nfProfilesStruct[i].BsfInfo).Ipv4AddressRanges)[j]).End = nrfContext.Ipv4IntToIpv4String(int64(ipv4IntEnd))
 				}
 			}
 			if nfProfile.BsfInfo.Ipv6PrefixRanges != nil {
 				for j := range *nfProfile.BsfInfo.Ipv6PrefixRanges {
 					ipv6IntStart := new(big.Int)
 					ipv6IntStart.SetString(((*(*nfProfilesStruct[i].BsfInfo).Ipv6PrefixRanges)[j]).Start, 10)
-					((*(*nfProfilesStruct[i].BsfInfo).Ipv6PrefixRanges)[j]).Start = context.Ipv6IntToIpv6String(ipv6IntStart)
+					((*(*nfProfilesStruct[i].BsfInfo).Ipv6PrefixRanges)[j]).Start = nrfContext.Ipv6IntToIpv6String(ipv6IntStart)
 
 					ipv6IntEnd := new(big.Int)
 					ipv6IntEnd.SetString(((*(*nfProfilesStruct[i].BsfInfo).Ipv6PrefixRanges)[j]).End, 10)
-					((*(*nfProfilesStruct[i].BsfInfo).Ipv6PrefixRanges)[j]).End = context.Ipv6IntToIpv6String(ipv6IntEnd)
+					((*(*nfProfilesStruct[i].BsfInfo).Ipv6PrefixRanges)[j]).End = nrfContext.Ipv6IntToIpv6String(ipv6IntEnd)
 				}
 			}
 		}
@@ -149,11 +193,17 @@ func NFDiscoveryProcedure(queryParameters url.Values) (response *models.SearchRe
 		ValidityPeriod: 100,
 		NfInstances:    nfProfilesStruct,
 	}
+	if p.Forwarder != nil {
+		searchResult = p.Forwarder.Forward(ctx, queryParameters, searchResult)
+	}
+	if p.isInterPlmn(ctx, queryParameters) {
+		searchResult = interPlmnResult(searchResult)
+	}
 
 	return searchResult, nil
 }
 
-func buildFilter(queryParameters url.Values) bson.M {
+func (p *Producer) buildFilter(queryParameters url.Values) bson.M {
 	// build the filter
 	filter := bson.M{
 		"$and": []bson.M{},
@@ -238,38 +288,15 @@ func buildFilter(queryParameters url.Values) bson.M {
 	// Mcc: Pattern: '^[0-9]{3}$'
 	// Mnc: Pattern: '^[0-9]{2,3}$'
 	if queryParameters["target-plmn-list"] != nil {
-		targetPlmnList := queryParameters["target-plmn-list"][0]
-		targetPlmnListSplit := strings.Split(targetPlmnList, ",")
+		targetPlmnList, err := nrfContext.ParsePlmnList(queryParameters["target-plmn-list"][0])
+		if err != nil {
+			p.Log.DiscoveryLog.Warnln("invalid target-plmn-list:", err)
+		}
 		var targetPlmnListBsonArray bson.A
-
-		var temptargetPlmn string
-		for i, v := range targetPlmnListSplit {
-			if i%2 == 0 {
-				temptargetPlmn = v
-			} else {
-				temptargetPlmn += ","
-				temptargetPlmn += v
-
-				targetPlmnListtruct := &models.PlmnId{}
-				err := json.Unmarshal([]byte(temptargetPlmn), targetPlmnListtruct)
-				if err != nil {
-					logger.DiscoveryLog.Warnln("Unmarshal Error in targetPlmnListtruct: ", err)
-				}
-
-				targetPlmnByteArray, err := bson.Marshal(targetPlmnListtruct)
-				if err != nil {
-					logger.DiscoveryLog.Warnln("Unmarshal Error in targetPlmnListtruct: ", err)
-				}
-
-				targetPlmnBsonM := bson.M{}
-				err = bson.Unmarshal(targetPlmnByteArray, &targetPlmnBsonM)
-				if err != nil {
-					logger.DiscoveryLog.Errorln("unmarshal error in targetPlmnBsonM:", err)
-				}
-				logger.DiscoveryLog.Debugln("temp target Plmn:", temptargetPlmn)
-
-				targetPlmnListBsonArray = append(targetPlmnListBsonArray, bson.M{"plmnList": bson.M{"$elemMatch": targetPlmnBsonM}})
-			}
+		for _, targetPlmn := range targetPlmnList {
+			targetPlmnListBsonArray = append(targetPlmnListBsonArray, bson.M{
+				"plmnList": bson.M{"$elemMatch": bson.M{"mcc": targetPlmn.Mcc, "mnc": targetPlmn.Mnc}},
+			})
 		}
 
 		targetPlmnListFilter := bson.M{
@@ -280,10 +307,25 @@ func buildFilter(queryParameters url.Values) bson.M {
 	}
 
 	// [Query-6] requester-plmn-list
-	// if queryParameters["requester-plmn-list"] != nil {
-	// requesterPlmnPist := queryParameters["requester-plmn-list"][0]
-	// TODO
-	// }
+	// the NF instances without allowedPlmns allow any PLMN
+	if queryParameters["requester-plmn-list"] != nil {
+		requesterPlmnList, err := nrfContext.ParsePlmnList(queryParameters["requester-plmn-list"][0])
+		if err != nil {
+			p.Log.DiscoveryLog.Warnln("invalid requester-plmn-list:", err)
+		}
+		requesterPlmnListBsonArray := []bson.M{
+			{"allowedPlmns": bson.M{"$exists": false}},
+		}
+		for _, requesterPlmn := range requesterPlmnList {
+			requesterPlmnListBsonArray = append(requesterPlmnListBsonArray, bson.M{
+				"allowedPlmns": bson.M{"$elemMatch": bson.M{"mcc": requesterPlmn.Mcc, "mnc": requesterPlmn.Mnc}},
+			})
+		}
+		requesterPlmnListFilter := bson.M{
+			"$or": requesterPlmnListBsonArray,
+		}
+		filter["$and"] = append(filter["$and"].([]bson.M), requesterPlmnListFilter)
+	}
 
 	// [Query-7] target-nf-instance-id
 	if queryParameters["target-nf-instance-id"] != nil {
@@ -324,18 +366,18 @@ func buildFilter(queryParameters url.Values) bson.M {
 				snssaiStruct := &models.Snssai{}
 				err := json.Unmarshal([]byte(tempSnssai), snssaiStruct)
 				if err != nil {
-					logger.DiscoveryLog.Warnln("Unmarshal Error in snssaiStruct", err)
+					p.Log.DiscoveryLog.Warnln("Unmarshal Error in snssaiStruct", err)
 				}
 
 				snssaiByteArray, err := bson.Marshal(snssaiStruct)
 				if err != nil {
-					logger.DiscoveryLog.Warnln("Unmarshal Error in snssaiStruct", err)
+					p.Log.DiscoveryLog.Warnln("Unmarshal Error in snssaiStruct", err)
 				}
 
 				snssaiBsonM := bson.M{}
 				err = bson.Unmarshal(snssaiByteArray, &snssaiBsonM)
 				if err != nil {
-					logger.DiscoveryLog.Warnln("Unmarshal Error in snssaiBsonM", err)
+					p.Log.DiscoveryLog.Warnln("Unmarshal Error in snssaiBsonM", err)
 				}
 
 				snssaisBsonArray = append(snssaisBsonArray, bson.M{"sNssais": bson.M{"$elemMatch": snssaiBsonM}})
@@ -369,7 +411,8 @@ func buildFilter(queryParameters url.Values) bson.M {
 	}
 
 	// [Query-12] dnn
-	if queryParameters["dnn"] != nil {
+	// the DNN of a UPF is matched by upfSelectionFilter
+	if queryParameters["dnn"] != nil && targetNfType != "UPF" {
 		dnn := queryParameters["dnn"][0]
 		var dnnFilter bson.M
 		switch targetNfType {
@@ -385,18 +428,6 @@ func buildFilter(queryParameters url.Values) bson.M {
 					},
 				},
 			}
-		case "UPF":
-			dnnFilter = bson.M{
-				"upfInfo.sNssaiUpfInfoList": bson.M{
-					"$elemMatch": bson.M{
-						"dnnUpfInfoList": bson.M{
-							"$elemMatch": bson.M{
-								"dnn": dnn,
-							},
-						},
-					},
-				},
-			}
 		case "BSF":
 			dnnFilter = bson.M{
 				"$or": []bson.M{
@@ -456,18 +487,18 @@ func buildFilter(queryParameters url.Values) bson.M {
 		taiStruct := &models.Tai{}
 		err := json.Unmarshal([]byte(tai), taiStruct)
 		if err != nil {
-			logger.DiscoveryLog.Warnln("Unmarshal Error in taiStruct: ", err)
+			p.Log.DiscoveryLog.Warnln("Unmarshal Error in taiStruct: ", err)
 		}
 
 		taiByteArray, err := bson.Marshal(taiStruct)
 		if err != nil {
-			logger.DiscoveryLog.Warnln("Unmarshal Error in taiByteArray: ", err)
+			p.Log.DiscoveryLog.Warnln("Unmarshal Error in taiByteArray: ", err)
 		}
 
 		taiBsonM := bson.M{}
 		err = bson.Unmarshal(taiByteArray, &taiBsonM)
 		if err != nil {
-			logger.DiscoveryLog.Warnln("Unmarshal Error in taiByteArray: ", err)
+			p.Log.DiscoveryLog.Warnln("Unmarshal Error in taiByteArray: ", err)
 		}
 		switch targetNfType {
 		case "SMF":
@@ -517,18 +548,18 @@ func buildFilter(queryParameters url.Values) bson.M {
 			guamiStruct := &models.Guami{}
 			err := json.Unmarshal([]byte(guami), guamiStruct)
 			if err != nil {
-				logger.DiscoveryLog.Warnln("Unmarshal Error in guamiStruct: ", err)
+				p.Log.DiscoveryLog.Warnln("Unmarshal Error in guamiStruct: ", err)
 			}
 
 			guamiByteArray, err := bson.Marshal(guamiStruct)
 			if err != nil {
-				logger.DiscoveryLog.Warnln("Unmarshal Error in guamiByteArray: ", err)
+				p.Log.DiscoveryLog.Warnln("Unmarshal Error in guamiByteArray: ", err)
 			}
 
 			guamiBsonM := bson.M{}
 			err = bson.Unmarshal(guamiByteArray, &guamiBsonM)
 			if err != nil {
-				logger.DiscoveryLog.Warnln("Unmarshal Error in guamiByteArray: ", err)
+				p.Log.DiscoveryLog.Warnln("Unmarshal Error in guamiByteArray: ", err)
 			}
 
 			guamiFilter := bson.M{
@@ -683,7 +714,7 @@ func buildFilter(queryParameters url.Values) bson.M {
 		var ueIpv4AddressFilter bson.M
 		if targetNfType == "BSF" {
 			ueIpv4Address := queryParameters["ue-ipv4-address"][0]
-			ueIpv4AddressNumber := context.Ipv4ToInt(ueIpv4Address)
+			ueIpv4AddressNumber := nrfContext.Ipv4ToInt(ueIpv4Address)
 			ueIpv4AddressFilter = bson.M{
 				"$or": []bson.M{
 					{
@@ -735,7 +766,7 @@ func buildFilter(queryParameters url.Values) bson.M {
 		var ueIpv6PrefixFilter bson.M
 		if targetNfType == "BSF" {
 			ueIpv6Prefix := queryParameters["ue-ipv6-prefix"][0]
-			ueIpv6PrefixNumber := context.Ipv6ToInt(ueIpv6Prefix)
+			ueIpv6PrefixNumber := nrfContext.Ipv6ToInt(ueIpv6Prefix)
 			ueIpv6PrefixFilter = bson.M{
 				"$or": []bson.M{
 					{
@@ -880,7 +911,7 @@ func buildFilter(queryParameters url.Values) bson.M {
 		var externalGroupIdentityFilter bson.M
 		externalGroupIdentity := queryParameters["external-group-identity"][0]
 
-		encodedGroupId := context.EncodeGroupId(externalGroupIdentity)
+		encodedGroupId := nrfContext.EncodeGroupId(externalGroupIdentity)
 		switch targetNfType {
 		case "UDM":
 			externalGroupIdentityFilter = bson.M{
@@ -1036,44 +1067,9 @@ func buildFilter(queryParameters url.Values) bson.M {
 		filter["$and"] = append(filter["$and"].([]bson.M), groupIdListFilter)
 	}
 
-	// [Query-29] dnai-list
-	if queryParameters["dnai-list"] != nil {
-		var dnaiFilter bson.M
-		dnaiList := queryParameters["dnai-list"][0]
-		dnaiListSplit := strings.Split(dnaiList, ",")
-		var dnaiListBsonArray bson.A
-
-		for _, v := range dnaiListSplit {
-			dnaiListBsonArray = append(dnaiListBsonArray, v)
-		}
-		if targetNfType == "UPF" {
-			dnaiFilter = bson.M{
-				"upfInfo.sNssaiUpfInfoList": bson.M{
-					"$elemMatch": bson.M{
-						"dnnUpfInfoList": bson.M{
-							"$elemMatch": bson.M{
-								"dnaiList": bson.M{
-									"$in": dnaiListBsonArray,
-								},
-							},
-						},
-					},
-				},
-			}
-		}
-		filter["$and"] = append(filter["$and"].([]bson.M), dnaiFilter)
-	}
-
-	// [Query-30] upf-iwk-eps-ind
-	if queryParameters["upf-iwk-eps-ind"] != nil {
-		var upfIwkEpsIndFilter bson.M
-		// upfIwkEpsInd := queryParameters["upf-iwk-eps-ind"][0]
-		if targetNfType == "UPF" {
-			upfIwkEpsIndFilter = bson.M{
-				"upfInfo.iwkEpsInd": true,
-			}
-		}
-		filter["$and"] = append(filter["$and"].([]bson.M), upfIwkEpsIndFilter)
+	// [Query-29] dnai-list, [Query-30] upf-iwk-eps-ind
+	if targetNfType == "UPF" {
+		filter["$and"] = append(filter["$and"].([]bson.M), p.upfSelectionFilter(queryParameters)...)
 	}
 
 	// [Query-31] chf-supported-plmn
@@ -1083,7 +1079,7 @@ func buildFilter(queryParameters url.Values) bson.M {
 		chfSupportedPlmnStruct := &models.PlmnId{}
 		err := json.Unmarshal([]byte(chfSupportedPlmn), chfSupportedPlmnStruct)
 		if err != nil {
-			logger.DiscoveryLog.Warnln("Unmarshal Error in chfSupportedPlmnStruct: ", err)
+			p.Log.DiscoveryLog.Warnln("Unmarshal Error in chfSupportedPlmnStruct: ", err)
 		}
 
 		encodedchfSupportedPlmn := chfSupportedPlmnStruct.Mcc + chfSupportedPlmnStruct.Mnc
@@ -1116,7 +1112,8 @@ func buildFilter(queryParameters url.Values) bson.M {
 
 	// [Query-32]  preferred-locality
 	// TODO: if no match
-	if queryParameters["preferred-locality"] != nil {
+	// the UPFs of the preferred locality are ranked first by rankUpfs
+	if queryParameters["preferred-locality"] != nil && targetNfType != "UPF" {
 		preferredLocality := queryParameters["preferred-locality"][0]
 		preferredLocalityFilter := bson.M{
 			"locality": preferredLocality,
@@ -1162,11 +1159,31 @@ func buildFilter(queryParameters url.Values) bson.M {
 		complexQueryStruct := &models.ComplexQuery{}
 		err := json.Unmarshal([]byte(complexQuery), complexQueryStruct)
 		if err != nil {
-			logger.DiscoveryLog.Warnln("Unmarshal Error in complexQuery: ", err)
+			p.Log.DiscoveryLog.Warnln("Unmarshal Error in complexQuery: ", err)
 		}
-		complexQueryFilter := complexQueryFilter(complexQueryStruct)
+		complexQueryFilter := p.complexQueryFilter(complexQueryStruct)
 		filter["$and"] = append(filter["$and"].([]bson.M), complexQueryFilter)
 	}
+
+	// [Query-36] target-nf-set-id
+	if queryParameters["target-nf-set-id"] != nil {
+		nfSetIdFilter := bson.M{
+			"nfSetIdList": queryParameters["target-nf-set-id"][0],
+		}
+		filter["$and"] = append(filter["$and"].([]bson.M), nfSetIdFilter)
+	}
+
+	// [Query-37] target-nf-service-set-id
+	if queryParameters["target-nf-service-set-id"] != nil {
+		nfServiceSetIdFilter := bson.M{
+			"nfServices": bson.M{
+				"$elemMatch": bson.M{
+					nfServiceSetIdListField: queryParameters["target-nf-service-set-id"][0],
+				},
+			},
+		}
+		filter["$and"] = append(filter["$and"].([]bson.M), nfServiceSetIdFilter)
+	}
 	return filter
 }
 
@@ -1180,7 +1197,7 @@ type AtomElem struct {
 	negative bool
 }
 
-func complexQueryFilter(complexQueryParameter *models.ComplexQuery) bson.M {
+func (p *Producer) complexQueryFilter(complexQueryParameter *models.ComplexQuery) bson.M {
 	complexQueryType := ""
 	if complexQueryParameter.CNf != nil {
 		complexQueryType = COMPLEX_QUERY_TYPE_CNF
@@ -1201,7 +1218,7 @@ func complexQueryFilter(complexQueryParameter *models.ComplexQuery) bson.M {
 			for _, atom := range cnfUnit.CnfUnit {
 				queryParameters[atom.Attr] = &AtomElem{value: atom.Value, negative: atom.Negative}
 			}
-			cnfUnitFilter = complexQueryFilterSubprocess(queryParameters, complexQueryType)
+			cnfUnitFilter = p.complexQueryFilterSubprocess(queryParameters, complexQueryType)
 
 			filter["$and"] = append(filter["$and"].([]bson.M), cnfUnitFilter)
 		}
@@ -1213,7 +1230,7 @@ func complexQueryFilter(complexQueryParameter *models.ComplexQuery) bson.M {
 	return filter
 }
 
-func complexQueryFilterSubprocess(queryParameters map[string]*AtomElem, complexQueryType string) bson.M {
+func (p *Producer) complexQueryFilterSubprocess(queryParameters map[string]*AtomElem, complexQueryType string) bson.M {
 	var filter bson.M
 	var logicalOperator string
 
@@ -1342,18 +1359,18 @@ func complexQueryFilterSubprocess(queryParameters map[string]*AtomElem, complexQ
 				targetPlmnListtruct := &models.PlmnId{}
 				err := json.Unmarshal([]byte(temptargetPlmn), targetPlmnListtruct)
 				if err != nil {
-					logger.DiscoveryLog.Warnln("Unmarshal Error in targetPlmnListstruct: ", err)
+					p.Log.DiscoveryLog.Warnln("Unmarshal Error in targetPlmnListstruct: ", err)
 				}
 
 				targetPlmnByteArray, err := bson.Marshal(targetPlmnListtruct)
 				if err != nil {
-					logger.DiscoveryLog.Warnln("Unmarshal Error in targetPlmnByteArray: ", err)
+					p.Log.DiscoveryLog.Warnln("Unmarshal Error in targetPlmnByteArray: ", err)
 				}
 
 				targetPlmnBsonM := bson.M{}
 				err = bson.Unmarshal(targetPlmnByteArray, &targetPlmnBsonM)
 				if err != nil {
-					logger.DiscoveryLog.Warnln("Unmarshal Error in targetPlmnBsonM: ", err)
+					p.Log.DiscoveryLog.Warnln("Unmarshal Error in targetPlmnBsonM: ", err)
 				}
 
 				targetPlmnListBsonArray = append(targetPlmnListBsonArray, targetPlmnBsonM)
@@ -1439,18 +1456,18 @@ func complexQueryFilterSubprocess(queryParameters map[string]*AtomElem, complexQ
 				snssaiStruct := &models.Snssai{}
 				err := json.Unmarshal([]byte(tempSnssai), snssaiStruct)
 				if err != nil {
-					logger.DiscoveryLog.Warnln("Unmarshal Error in snssaiStruct: ", err)
+					p.Log.DiscoveryLog.Warnln("Unmarshal Error in snssaiStruct: ", err)
 				}
 
 				snssaiByteArray, err := bson.Marshal(snssaiStruct)
 				if err != nil {
-					logger.DiscoveryLog.Warnln("Unmarshal Error in snssaiByteArray: ", err)
+					p.Log.DiscoveryLog.Warnln("Unmarshal Error in snssaiByteArray: ", err)
 				}
 
 				snssaiBsonM := bson.M{}
 				err = bson.Unmarshal(snssaiByteArray, &snssaiBsonM)
 				if err != nil {
-					logger.DiscoveryLog.Warnln("Unmarshal Error in snssaiBsonM: ", err)
+					p.Log.DiscoveryLog.Warnln("Unmarshal Error in snssaiBsonM: ", err)
 				}
 
 				snssaisBsonArray = append(snssaisBsonArray, snssaiBsonM)
@@ -1576,18 +1593,18 @@ func complexQueryFilterSubprocess(queryParameters map[string]*AtomElem, complexQ
 		taiStruct := &models.Tai{}
 		err := json.Unmarshal([]byte(tempTai), taiStruct)
 		if err != nil {
-			logger.DiscoveryLog.Warnln("Unmarshal Error in taiStruct: ", err)
+			p.Log.DiscoveryLog.Warnln("Unmarshal Error in taiStruct: ", err)
 		}
 
 		taiByteArray, err := bson.Marshal(taiStruct)
 		if err != nil {
-			logger.DiscoveryLog.Warnln("Unmarshal Error in taiByteArray: ", err)
+			p.Log.DiscoveryLog.Warnln("Unmarshal Error in taiByteArray: ", err)
 		}
 
 		taiBsonM := bson.M{}
 		err = bson.Unmarshal(taiByteArray, &taiBsonM)
 		if err != nil {
-			logger.DiscoveryLog.Warnln("Unmarshal Error in taiByteArray: ", err)
+			p.Log.DiscoveryLog.Warnln("Unmarshal Error in taiByteArray: ", err)
 		}
 		switch targetNfType {
 		case "SMF":
@@ -1669,18 +1686,18 @@ func complexQueryFilterSubprocess(queryParameters map[string]*AtomElem, complexQ
 			guamiStruct := &models.Guami{}
 			err := json.Unmarshal([]byte(tempguami), guamiStruct)
 			if err != nil {
-				logger.DiscoveryLog.Warnln("Unmarshal Error in guamiStruct: ", err)
+				p.Log.DiscoveryLog.Warnln("Unmarshal Error in guamiStruct: ", err)
 			}
 
 			guamiByteArray, err := bson.Marshal(guamiStruct)
 			if err != nil {
-				logger.DiscoveryLog.Warnln("Unmarshal Error in guamiByteArray: ", err)
+				p.Log.DiscoveryLog.Warnln("Unmarshal Error in guamiByteArray: ", err)
 			}
 
 			guamiBsonM := bson.M{}
 			err = bson.Unmarshal(guamiByteArray, &guamiBsonM)
 			if err != nil {
-				logger.DiscoveryLog.Warnln("Unmarshal Error in guamiByteArray: ", err)
+				p.Log.DiscoveryLog.Warnln("Unmarshal Error in guamiByteArray: ", err)
 			}
 
 			guamiFilter = bson.M{
@@ -1806,7 +1823,7 @@ func complexQueryFilterSubprocess(queryParameters map[string]*AtomElem, complexQ
 		var ueIpv4AddressFilter bson.M
 		if targetNfType == "BSF" {
 			ueIpv4Address := queryParameters["ue-ipv4-address"].value
-			ueIpv4AddressNumber := context.Ipv4ToInt(ueIpv4Address)
+			ueIpv4AddressNumber := nrfContext.Ipv4ToInt(ueIpv4Address)
 			ueIpv4AddressFilter = bson.M{
 				"bsfInfo": bson.M{
 					"$elemMatch": bson.M{
@@ -1858,7 +1875,7 @@ func complexQueryFilterSubprocess(queryParameters map[string]*AtomElem, complexQ
 		var ueIpv6PrefixFilter bson.M
 		if targetNfType == "BSF" {
 			ueIpv6Prefix := queryParameters["ue-ipv6-prefix"].value
-			ueIpv6PrefixNumber := context.Ipv6ToInt(ueIpv6Prefix)
+			ueIpv6PrefixNumber := nrfContext.Ipv6ToInt(ueIpv6Prefix)
 			ueIpv6PrefixFilter = bson.M{
 				"bsfInfo": bson.M{
 					"$elemMatch": bson.M{
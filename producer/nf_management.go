@@ -10,46 +10,53 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
 	"time"
 
-	"github.com/mitchellh/mapstructure"
 	nrfContext "github.com/omec-project/nrf/context"
 	"github.com/omec-project/nrf/dbadapter"
 	"github.com/omec-project/nrf/factory"
-	"github.com/omec-project/nrf/logger"
-	stats "github.com/omec-project/nrf/metrics"
+	"github.com/omec-project/nrf/tracing"
 	"github.com/omec-project/nrf/util"
+	"github.com/omec-project/nrf/webhook"
 	"github.com/omec-project/openapi/Nnrf_NFManagement"
 	"github.com/omec-project/openapi/models"
 	"github.com/omec-project/util/httpwrapper"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func HandleNFDeregisterRequest(request *httpwrapper.Request) *httpwrapper.Response {
-	logger.ManagementLog.Infoln("Handle NFDeregisterRequest")
+func (p *Producer) HandleNFDeregisterRequest(ctx context.Context, request *httpwrapper.Request) *httpwrapper.Response {
+	p.Log.ManagementLog.Infoln("Handle NFDeregisterRequest")
 	nfInstanceId := request.Params["nfInstanceID"]
 
-	nfType, problemDetails := NFDeregisterProcedure(nfInstanceId)
+	nfType, problemDetails := p.NFDeregisterProcedure(ctx, nfInstanceId)
 
 	if problemDetails != nil {
-		logger.ManagementLog.Debugln("deregister failure")
-		stats.IncrementNrfRegistrationsStats("deregister", nfType, "FAILURE")
+		p.Log.ManagementLog.Debugln("deregister failure")
+		p.Metrics.IncrementNrfRegistrationsStats("deregister", nfType, "FAILURE")
 		return httpwrapper.NewResponse(int(problemDetails.Status), nil, problemDetails)
 	} else {
-		logger.ManagementLog.Debugln("deregister Success")
-		stats.IncrementNrfRegistrationsStats("deregister", nfType, "SUCCESS")
+		p.Log.ManagementLog.Debugln("deregister Success")
+		p.Metrics.IncrementNrfRegistrationsStats("deregister", nfType, "SUCCESS")
 		return httpwrapper.NewResponse(http.StatusNoContent, nil, nil)
 	}
 }
 
-func HandleGetNFInstanceRequest(request *httpwrapper.Request) *httpwrapper.Response {
-	logger.ManagementLog.Infoln("Handle GetNFInstanceRequest")
+func (p *Producer) HandleGetNFInstanceRequest(ctx context.Context, request *httpwrapper.Request) *httpwrapper.Response {
+	p.Log.ManagementLog.Infoln("Handle GetNFInstanceRequest")
 	nfInstanceId := request.Params["nfInstanceID"]
 
-	response := GetNFInstanceProcedure(nfInstanceId)
+	response, problemDetails := p.GetNFInstanceProcedure(ctx, nfInstanceId)
 
-	if response != nil {
+	if problemDetails != nil {
+		return httpwrapper.NewResponse(int(problemDetails.Status), nil, problemDetails)
+	} else if response != nil {
 		return httpwrapper.NewResponse(http.StatusOK, nil, response)
 	} else {
 		problemDetails := &models.ProblemDetails{
@@ -60,165 +67,269 @@ func HandleGetNFInstanceRequest(request *httpwrapper.Request) *httpwrapper.Respo
 	}
 }
 
-func HandleNFRegisterRequest(request *httpwrapper.Request) *httpwrapper.Response {
-	logger.ManagementLog.Infoln("Handle NFRegisterRequest")
-	nfProfile := request.Body.(models.NfProfile)
+func (p *Producer) HandleNFRegisterRequest(ctx context.Context, request *httpwrapper.Request) *httpwrapper.Response {
+	p.Log.ManagementLog.Infoln("Handle NFRegisterRequest")
+	registration := request.Body.(NfProfileRegistration)
+	nfProfile := registration.NfProfile
 
-	header, response, problemDetails := NFRegisterProcedure(nfProfile)
+	header, response, problemDetails := p.NFRegisterProcedure(ctx, registration)
 
 	if response != nil {
-		logger.ManagementLog.Debugln("register success")
-		stats.IncrementNrfRegistrationsStats("register", string(nfProfile.NfType), "SUCCESS")
+		p.Log.ManagementLog.Debugln("register success")
+		p.Metrics.IncrementNrfRegistrationsStats("register", string(nfProfile.NfType), "SUCCESS")
 		return httpwrapper.NewResponse(http.StatusCreated, header, response)
 	} else if problemDetails != nil {
-		logger.ManagementLog.Debugln("register failed")
-		stats.IncrementNrfRegistrationsStats("register", string(nfProfile.NfType), "FAILURE")
+		p.Log.ManagementLog.Debugln("register failed")
+		p.Metrics.IncrementNrfRegistrationsStats("register", string(nfProfile.NfType), "FAILURE")
 		return httpwrapper.NewResponse(int(problemDetails.Status), nil, problemDetails)
 	}
 	problemDetails = &models.ProblemDetails{
 		Status: http.StatusForbidden,
 		Cause:  "UNSPECIFIED",
 	}
-	logger.ManagementLog.Debugln("register failed")
-	stats.IncrementNrfRegistrationsStats("register", string(nfProfile.NfType), "FAILURE")
+	p.Log.ManagementLog.Debugln("register failed")
+	p.Metrics.IncrementNrfRegistrationsStats("register", string(nfProfile.NfType), "FAILURE")
 	return httpwrapper.NewResponse(http.StatusForbidden, nil, problemDetails)
 }
 
-func HandleUpdateNFInstanceRequest(request *httpwrapper.Request) *httpwrapper.Response {
-	logger.ManagementLog.Infoln("Handle UpdateNFInstanceRequest")
+func (p *Producer) HandleUpdateNFInstanceRequest(ctx context.Context, request *httpwrapper.Request) *httpwrapper.Response {
+	p.Log.ManagementLog.Infoln("Handle UpdateNFInstanceRequest")
 	nfInstanceID := request.Params["nfInstanceID"]
 	if nfInstanceID == "" {
-		logger.ManagementLog.Errorln("nfInstanceID is missing")
+		p.Log.ManagementLog.Errorln("nfInstanceID is missing")
 		return httpwrapper.NewResponse(http.StatusBadRequest, nil, map[string]string{"error": "Missing nfInstanceID"})
 	}
 
-	patchJSON, ok := request.Body.([]byte)
+	mediaType, problemDetails := p.checkPatchContentType(request.Header.Get("Content-Type"))
+	if problemDetails != nil {
+		p.Metrics.IncrementNrfRegistrationsStats("update", p.GetNfTypeByNfInstanceID(ctx, nfInstanceID), "FAILURE")
+		return httpwrapper.NewResponse(int(problemDetails.Status), nil, problemDetails)
+	}
+
+	patchBody, ok := request.Body.([]byte)
 	if !ok {
-		logger.ManagementLog.Errorln("invalid body format")
+		p.Log.ManagementLog.Errorln("invalid body format")
 		return httpwrapper.NewResponse(http.StatusBadRequest, nil, map[string]string{"error": "Invalid body format"})
 	}
 
-	response, err := updateNFInstanceProcedure(nfInstanceID, patchJSON)
-	if err != nil {
-		logger.ManagementLog.Errorln("updateNFInstanceProcedure failed:", err)
-		return httpwrapper.NewResponse(http.StatusInternalServerError, nil, map[string]string{"error": "Update procedure failed"})
+	heartbeat := isHeartbeat(mediaType, patchBody)
+	response, problemDetails := p.updateNFInstanceProcedure(ctx, nfInstanceID, mediaType, patchBody)
+	if problemDetails != nil {
+		p.Log.ManagementLog.Errorln("updateNFInstanceProcedure failed:", problemDetails.Detail)
+		nfType := p.GetNfTypeByNfInstanceID(ctx, nfInstanceID)
+		p.Metrics.IncrementNrfRegistrationsStats("update", nfType, "FAILURE")
+		if heartbeat {
+			p.Metrics.IncrementHeartbeatsStats(nfType, "FAILURE")
+		}
+		return httpwrapper.NewResponse(int(problemDetails.Status), nil, problemDetails)
 	}
 
 	if response == nil {
-		logger.ManagementLog.Errorln("received nil response after update procedure")
+		p.Log.ManagementLog.Errorln("received nil response after update procedure")
 		return httpwrapper.NewResponse(http.StatusInternalServerError, nil, map[string]string{"error": "Update procedure returned nil response"})
 	}
 
 	nfType, ok := response["nfType"].(string)
 	if !ok {
-		logger.ManagementLog.Warnln("response missing 'nfType' or wrong format")
+		p.Log.ManagementLog.Warnln("response missing 'nfType' or wrong format")
 		nfType = "unknown"
 	}
 
-	stats.IncrementNrfRegistrationsStats("update", nfType, "SUCCESS")
+	p.Metrics.IncrementNrfRegistrationsStats("update", nfType, "SUCCESS")
+	if heartbeat {
+		p.Metrics.IncrementHeartbeatsStats(nfType, "SUCCESS")
+	}
 	return httpwrapper.NewResponse(http.StatusOK, nil, response)
 }
 
-func HandleGetNFInstancesRequest(request *httpwrapper.Request) *httpwrapper.Response {
-	logger.ManagementLog.Infoln("Handle GetNFInstancesRequest")
-	nfType := request.Query.Get("nf-type")
-	limit, err := strconv.Atoi(request.Query.Get("limit"))
-	if err != nil {
-		logger.ManagementLog.Errorln("Error in string conversion: ", limit)
-		problemDetails := models.ProblemDetails{
-			Title:  "Invalid Parameter",
-			Status: http.StatusBadRequest,
-			Detail: err.Error(),
-		}
+// NFInstancesQuery holds the query parameters of an NFListRetrieval request
+type NFInstancesQuery struct {
+	NfType       string
+	NfStatus     string
+	Limit        int
+	PageNumber   int
+	PageSize     int
+	FullProfiles bool
+}
 
+func (p *Producer) HandleGetNFInstancesRequest(ctx context.Context, request *httpwrapper.Request) *httpwrapper.Response {
+	p.Log.ManagementLog.Infoln("Handle GetNFInstancesRequest")
+	query, problemDetails := parseNFInstancesQuery(request.Query)
+	if problemDetails != nil {
+		p.Log.ManagementLog.Errorln("invalid GetNFInstances query:", problemDetails.Detail)
 		return httpwrapper.NewResponse(int(problemDetails.Status), nil, problemDetails)
 	}
 
-	response, problemDetails := GetNFInstancesProcedure(nfType, limit)
+	response, problemDetails := p.GetNFInstancesProcedure(ctx, query)
 	if response != nil {
-		logger.ManagementLog.Debugln("GetNFInstances success")
+		p.Log.ManagementLog.Debugln("GetNFInstances success")
 		return httpwrapper.NewResponse(http.StatusOK, nil, response)
 	} else if problemDetails != nil {
-		logger.ManagementLog.Debugln("GetNFInstances failed")
+		p.Log.ManagementLog.Debugln("GetNFInstances failed")
 		return httpwrapper.NewResponse(int(problemDetails.Status), nil, problemDetails)
 	}
 	problemDetails = &models.ProblemDetails{
 		Status: http.StatusForbidden,
 		Cause:  "UNSPECIFIED",
 	}
-	logger.ManagementLog.Debugln("GetNFInstances failed")
+	p.Log.ManagementLog.Debugln("GetNFInstances failed")
 	return httpwrapper.NewResponse(http.StatusForbidden, nil, problemDetails)
 }
 
-func HandleRemoveSubscriptionRequest(request *httpwrapper.Request) *httpwrapper.Response {
-	logger.ManagementLog.Infoln("Handle RemoveSubscription")
+func parseNFInstancesQuery(queryParameters url.Values) (NFInstancesQuery, *models.ProblemDetails) {
+	query := NFInstancesQuery{
+		NfType:   queryParameters.Get("nf-type"),
+		NfStatus: queryParameters.Get("nf-status"),
+	}
+	var invalidParams []models.InvalidParam
+
+	positiveInt := func(name string) int {
+		value := queryParameters.Get(name)
+		if value == "" {
+			return 0
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			invalidParams = append(invalidParams, models.InvalidParam{
+				Param:  name,
+				Reason: "must be a positive integer",
+			})
+			return 0
+		}
+		return n
+	}
+	query.Limit = positiveInt("limit")
+	query.PageNumber = positiveInt("page-number")
+	query.PageSize = positiveInt("page-size")
+
+	if query.PageNumber != 0 && query.PageSize == 0 {
+		invalidParams = append(invalidParams, models.InvalidParam{
+			Param:  "page-size",
+			Reason: "required when page-number is present",
+		})
+	}
+	if query.PageNumber == 0 {
+		query.PageNumber = 1
+	}
+
+	switch models.NfStatus(query.NfStatus) {
+	case "", models.NfStatus_REGISTERED, models.NfStatus_SUSPENDED, models.NfStatus_UNDISCOVERABLE:
+	default:
+		invalidParams = append(invalidParams, models.InvalidParam{
+			Param:  "nf-status",
+			Reason: "unknown NF status " + query.NfStatus,
+		})
+	}
+
+	if fullProfiles := queryParameters.Get("full-profiles"); fullProfiles != "" {
+		value, err := strconv.ParseBool(fullProfiles)
+		if err != nil {
+			invalidParams = append(invalidParams, models.InvalidParam{
+				Param:  "full-profiles",
+				Reason: "must be a boolean",
+			})
+		}
+		query.FullProfiles = value
+	}
+
+	if len(invalidParams) != 0 {
+		return query, &models.ProblemDetails{
+			Title:         "Invalid Parameter",
+			Status:        http.StatusBadRequest,
+			Cause:         "INVALID_QUERY_PARAM",
+			Detail:        invalidParams[0].Param + " " + invalidParams[0].Reason,
+			InvalidParams: invalidParams,
+		}
+	}
+	return query, nil
+}
+
+func (p *Producer) HandleRemoveSubscriptionRequest(ctx context.Context, request *httpwrapper.Request) *httpwrapper.Response {
+	p.Log.ManagementLog.Infoln("Handle RemoveSubscription")
 	subscriptionID := request.Params["subscriptionID"]
 
-	nfType := GetNfTypeBySubscriptionID(request.Params["subscriptionID"])
-	RemoveSubscriptionProcedure(subscriptionID)
-	stats.IncrementNrfSubscriptionsStats("unsubscribe", nfType, "SUCCESS")
+	nfType := p.GetNfTypeBySubscriptionID(ctx, request.Params["subscriptionID"])
+	if problemDetails := p.RemoveSubscriptionProcedure(ctx, subscriptionID); problemDetails != nil {
+		p.Metrics.IncrementNrfSubscriptionsStats("unsubscribe", nfType, "FAILURE")
+		return httpwrapper.NewResponse(int(problemDetails.Status), nil, problemDetails)
+	}
+	p.Metrics.IncrementNrfSubscriptionsStats("unsubscribe", nfType, "SUCCESS")
 
 	return httpwrapper.NewResponse(http.StatusNoContent, nil, nil)
 }
 
-func HandleUpdateSubscriptionRequest(request *httpwrapper.Request) *httpwrapper.Response {
-	logger.ManagementLog.Infoln("Handle UpdateSubscription")
+func (p *Producer) HandleUpdateSubscriptionRequest(ctx context.Context, request *httpwrapper.Request) *httpwrapper.Response {
+	p.Log.ManagementLog.Infoln("Handle UpdateSubscription")
 	subscriptionID := request.Params["subscriptionID"]
-	patchJSON := request.Body.([]byte)
+	patchBody := request.Body.([]byte)
 
-	nfType := GetNfTypeBySubscriptionID(subscriptionID)
-	response := UpdateSubscriptionProcedure(subscriptionID, patchJSON)
+	nfType := p.GetNfTypeBySubscriptionID(ctx, subscriptionID)
+	mediaType, problemDetails := p.checkPatchContentType(request.Header.Get("Content-Type"))
+	if problemDetails != nil {
+		p.Metrics.IncrementNrfSubscriptionsStats("update", nfType, "FAILURE")
+		return httpwrapper.NewResponse(int(problemDetails.Status), nil, problemDetails)
+	}
+
+	response, problemDetails := p.UpdateSubscriptionProcedure(ctx, subscriptionID, mediaType, patchBody)
 
 	if response != nil {
-		stats.IncrementNrfSubscriptionsStats("update", nfType, "SUCCESS")
+		p.Metrics.IncrementNrfSubscriptionsStats("update", nfType, "SUCCESS")
 		return httpwrapper.NewResponse(http.StatusOK, nil, response)
+	} else if problemDetails != nil {
+		p.Metrics.IncrementNrfSubscriptionsStats("update", nfType, "FAILURE")
+		return httpwrapper.NewResponse(int(problemDetails.Status), nil, problemDetails)
 	} else {
-		stats.IncrementNrfSubscriptionsStats("update", nfType, "FAILURE")
+		p.Metrics.IncrementNrfSubscriptionsStats("update", nfType, "FAILURE")
 		return httpwrapper.NewResponse(http.StatusNoContent, nil, nil)
 	}
 }
 
-func HandleCreateSubscriptionRequest(request *httpwrapper.Request) *httpwrapper.Response {
-	logger.ManagementLog.Infoln("Handle CreateSubscriptionRequest")
+func (p *Producer) HandleCreateSubscriptionRequest(ctx context.Context, request *httpwrapper.Request) *httpwrapper.Response {
+	p.Log.ManagementLog.Infoln("Handle CreateSubscriptionRequest")
 	subscription := request.Body.(models.NrfSubscriptionData)
 
-	response, problemDetails := CreateSubscriptionProcedure(subscription)
+	response, problemDetails := p.CreateSubscriptionProcedure(ctx, subscription)
 	if response != nil {
-		logger.ManagementLog.Debugln("CreateSubscription success")
-		stats.IncrementNrfSubscriptionsStats("subscribe", string(subscription.ReqNfType), "SUCCESS")
+		p.Log.ManagementLog.Debugln("CreateSubscription success")
+		p.Metrics.IncrementNrfSubscriptionsStats("subscribe", string(subscription.ReqNfType), "SUCCESS")
 		return httpwrapper.NewResponse(http.StatusCreated, nil, response)
 	} else if problemDetails != nil {
-		logger.ManagementLog.Debugln("CreateSubscription failed")
-		stats.IncrementNrfSubscriptionsStats("subscribe", string(subscription.ReqNfType), "FAILURE")
+		p.Log.ManagementLog.Debugln("CreateSubscription failed")
+		p.Metrics.IncrementNrfSubscriptionsStats("subscribe", string(subscription.ReqNfType), "FAILURE")
 		return httpwrapper.NewResponse(int(problemDetails.Status), nil, problemDetails)
 	}
 	problemDetails = &models.ProblemDetails{
 		Status: http.StatusForbidden,
 		Cause:  "UNSPECIFIED",
 	}
-	logger.ManagementLog.Debugln("CreateSubscription failed")
-	stats.IncrementNrfSubscriptionsStats("subscribe", string(subscription.ReqNfType), "FAILURE")
+	p.Log.ManagementLog.Debugln("CreateSubscription failed")
+	p.Metrics.IncrementNrfSubscriptionsStats("subscribe", string(subscription.ReqNfType), "FAILURE")
 	return httpwrapper.NewResponse(http.StatusForbidden, nil, problemDetails)
 }
 
-func CreateSubscriptionProcedure(subscription models.NrfSubscriptionData) (response bson.M,
+func (p *Producer) CreateSubscriptionProcedure(ctx context.Context, subscription models.NrfSubscriptionData) (response bson.M,
 	problemDetails *models.ProblemDetails,
 ) {
-	subscription.SubscriptionId = nrfContext.SetsubscriptionId()
+	subscription.SubscriptionId = p.SetsubscriptionId()
 
 	tmp, err := json.Marshal(subscription)
 	if err != nil {
-		logger.ManagementLog.Errorln("Marshal error in CreateSubscriptionProcedure: ", err)
+		p.Log.ManagementLog.Errorln("Marshal error in CreateSubscriptionProcedure: ", err)
 	}
 	putData := bson.M{}
 	err = json.Unmarshal(tmp, &putData)
 	if err != nil {
-		logger.ManagementLog.Errorln("Unmarshal error in CreateSubscriptionProcedure: ", err)
+		p.Log.ManagementLog.Errorln("Unmarshal error in CreateSubscriptionProcedure: ", err)
 	}
 
 	// TODO: need to store Condition !
-	if ok, _ := dbadapter.DBClient.RestfulAPIPost("Subscriptions", bson.M{"subscriptionId": subscription.SubscriptionId},
-		putData); !ok { // subscription id not exist before
+	ok, err := p.DB.RestfulAPIPost(ctx, "Subscriptions", bson.M{"subscriptionId": subscription.SubscriptionId}, putData)
+	if err != nil {
+		p.Log.ManagementLog.Errorln("DB error in CreateSubscriptionProcedure: ", err)
+		return nil, storageProblemDetails("SYSTEM_FAILURE", err)
+	}
+	if !ok { // subscription id not exist before
+		p.RegistryChanged()
 		return putData, nil
 	} else {
 		problemDetails = &models.ProblemDetails{
@@ -230,47 +341,90 @@ func CreateSubscriptionProcedure(subscription models.NrfSubscriptionData) (respo
 	}
 }
 
-func UpdateSubscriptionProcedure(subscriptionID string, patchJSON []byte) (response map[string]interface{}) {
+func (p *Producer) UpdateSubscriptionProcedure(ctx context.Context, subscriptionID string, mediaType string, patchBody []byte) (response map[string]interface{},
+	problemDetails *models.ProblemDetails,
+) {
 	collName := "Subscriptions"
 	filter := bson.M{"subscriptionId": subscriptionID}
 
-	err := dbadapter.DBClient.RestfulAPIJSONPatch(collName, filter, patchJSON)
-	if err == nil {
-		response, _ = dbadapter.DBClient.RestfulAPIGetOne(collName, filter)
-		return response
-	} else {
-		logger.ManagementLog.Warnln("Error UpdateSubscriptionProcedure: ", err)
-		return nil
+	original, err := p.DB.RestfulAPIGetOne(ctx, collName, filter)
+	if err != nil {
+		p.Log.ManagementLog.Warnln("Error UpdateSubscriptionProcedure: ", err)
+		return nil, storageProblemDetails("SYSTEM_FAILURE", err)
+	}
+	if original == nil {
+		return nil, &models.ProblemDetails{
+			Status: http.StatusNotFound,
+			Cause:  "SUBSCRIPTION_NOT_FOUND",
+			Detail: "subscription " + subscriptionID + " not found",
+		}
 	}
+
+	patched, err := applyPatch(mediaType, original, patchBody)
+	if err != nil {
+		p.Log.ManagementLog.Warnln("Error UpdateSubscriptionProcedure: ", err)
+		return nil, &models.ProblemDetails{
+			Title:  "Malformed request syntax",
+			Status: http.StatusBadRequest,
+			Cause:  "INVALID_PATCH",
+			Detail: err.Error(),
+		}
+	}
+	if err = validatePatchedSubscription(subscriptionID, patched); err != nil {
+		p.Log.ManagementLog.Warnln("Error UpdateSubscriptionProcedure: ", err)
+		return nil, &models.ProblemDetails{
+			Title:  "Invalid patched subscription",
+			Status: http.StatusBadRequest,
+			Cause:  "MANDATORY_IE_INCORRECT",
+			Detail: err.Error(),
+		}
+	}
+
+	if _, err = p.DB.RestfulAPIReplaceOne(ctx, collName, filter, patched); err != nil {
+		p.Log.ManagementLog.Warnln("Error UpdateSubscriptionProcedure: ", err)
+		return nil, storageProblemDetails("SYSTEM_FAILURE", err)
+	}
+	return patched, nil
 }
 
-func RemoveSubscriptionProcedure(subscriptionID string) {
+func (p *Producer) RemoveSubscriptionProcedure(ctx context.Context, subscriptionID string) *models.ProblemDetails {
 	collName := "Subscriptions"
 	filter := bson.M{"subscriptionId": subscriptionID}
-	logger.ManagementLog.Infoln("removing SubscriptionId:", subscriptionID)
+	p.Log.ManagementLog.Infoln("removing SubscriptionId:", subscriptionID)
 
-	err := dbadapter.DBClient.RestfulAPIDeleteMany(collName, filter)
+	err := p.DB.RestfulAPIDeleteMany(ctx, collName, filter)
 	if err != nil {
-		logger.ManagementLog.Errorf("failed to remove subscription with ID %s: %v", subscriptionID, err)
-		return
+		p.Log.ManagementLog.Errorf("failed to remove subscription with ID %s: %v", subscriptionID, err)
+		return storageProblemDetails("SYSTEM_FAILURE", err)
 	}
-	logger.ManagementLog.Infof("removed subscription with ID %s", subscriptionID)
+	p.RegistryChanged()
+	p.Log.ManagementLog.Infof("removed subscription with ID %s", subscriptionID)
+	return nil
 }
 
-func GetNFInstancesProcedure(nfType string, limit int) (response *nrfContext.UriList,
+// GetNFInstancesProcedure lists the registered NF instances matching the
+// query. The list is always derived from the NfProfile collection so that it
+// reflects registrations, deregistrations and expiries as they happen.
+func (p *Producer) GetNFInstancesProcedure(ctx context.Context, query NFInstancesQuery) (response *nrfContext.UriList,
 	problemDetail *models.ProblemDetails,
 ) {
-	// nfType := c.Query("nf-type")
-	// limit, err := strconv.Atoi(c.Query("limit"))
-	collName := "urilist"
-	filter := bson.M{"nfType": nfType}
-
-	UL, _ := dbadapter.DBClient.RestfulAPIGetOne(collName, filter)
-	logger.ManagementLog.Infoln("UL: ", UL)
-	originalUL := &nrfContext.UriList{}
-	err := mapstructure.Decode(UL, originalUL)
+	collName := "NfProfile"
+	filter := bson.M{}
+	if query.NfType != "" {
+		filter["nfType"] = query.NfType
+	}
+	if query.NfStatus != "" {
+		filter["nfStatus"] = query.NfStatus
+	}
+
+	nfProfilesRaw, err := p.DB.RestfulAPIGetMany(ctx, collName, filter)
+	if err != nil {
+		p.Log.ManagementLog.Errorln("DB error in GetNFInstancesProcedure: ", err)
+		return nil, storageProblemDetails("SYSTEM_FAILURE", err)
+	}
+	nfProfiles, err := util.Decode(nfProfilesRaw, time.RFC3339)
 	if err != nil {
-		logger.ManagementLog.Errorln("Decode error in GetNFInstancesProcedure: ", err)
+		p.Log.ManagementLog.Errorln("Decode error in GetNFInstancesProcedure: ", err)
 		problemDetail := &models.ProblemDetails{
 			Title:  "System failure",
 			Status: http.StatusInternalServerError,
@@ -279,190 +433,291 @@ func GetNFInstancesProcedure(nfType string, limit int) (response *nrfContext.Uri
 		}
 		return nil, problemDetail
 	}
-	nrfContext.NnrfUriListLimit(originalUL, limit)
-	// c.JSON(http.StatusOK, originalUL)
-	return originalUL, nil
-}
 
-func NFDeleteAll(nfType string) (problemDetails *models.ProblemDetails) {
-	collName := "NfProfile"
-	filter := bson.M{"nfType": nfType}
+	// a stable order is required for the pages to be consistent
+	sort.Slice(nfProfiles, func(i, j int) bool {
+		return nfProfiles[i].NfInstanceId < nfProfiles[j].NfInstanceId
+	})
+	totalItemCount := len(nfProfiles)
+	if query.Limit > 0 && query.Limit < len(nfProfiles) {
+		nfProfiles = nfProfiles[:query.Limit]
+	}
 
-	err := dbadapter.DBClient.RestfulAPIDeleteMany(collName, filter)
-	if err != nil {
-		logger.ManagementLog.Errorln("failed to delete NF profiles of type %s: %v", nfType, err)
-		problemDetails = &models.ProblemDetails{
-			Title:  "NF Profiles Deletion Failed",
-			Status: 500,
-			Detail: err.Error(),
+	response = &nrfContext.UriList{
+		NfType:         models.NfType(query.NfType),
+		TotalItemCount: int32(totalItemCount),
+		Link: nrfContext.Links{
+			Self: &models.Link{Href: p.getNFInstancesPageUri(query, query.PageNumber)},
+			Item: []models.Link{},
+		},
+	}
+
+	if query.PageSize > 0 {
+		start := (query.PageNumber - 1) * query.PageSize
+		end := start + query.PageSize
+		if start > len(nfProfiles) {
+			start = len(nfProfiles)
 		}
-		return problemDetails
+		if end > len(nfProfiles) {
+			end = len(nfProfiles)
+		}
+		if query.PageNumber > 1 {
+			response.Link.Prev = &models.Link{Href: p.getNFInstancesPageUri(query, query.PageNumber-1)}
+		}
+		if end < len(nfProfiles) {
+			response.Link.Next = &models.Link{Href: p.getNFInstancesPageUri(query, query.PageNumber+1)}
+		}
+		nfProfiles = nfProfiles[start:end]
 	}
 
-	logger.ManagementLog.Infoln("successfully deleted NF profiles of type %s", nfType)
+	for _, nfProfile := range nfProfiles {
+		response.Link.Item = append(response.Link.Item, models.Link{
+			Href: p.GetNfInstanceURI(nfProfile.NfInstanceId),
+		})
+	}
+	if query.FullProfiles {
+		response.NfInstances = nfProfiles
+	}
+	return response, nil
+}
+
+// getNFInstancesPageUri builds the URI of the given page of an NFListRetrieval query
+func (p *Producer) getNFInstancesPageUri(query NFInstancesQuery, pageNumber int) string {
+	queryParameters := url.Values{}
+	if query.NfType != "" {
+		queryParameters.Set("nf-type", query.NfType)
+	}
+	if query.NfStatus != "" {
+		queryParameters.Set("nf-status", query.NfStatus)
+	}
+	if query.Limit > 0 {
+		queryParameters.Set("limit", strconv.Itoa(query.Limit))
+	}
+	if query.PageSize > 0 {
+		queryParameters.Set("page-number", strconv.Itoa(pageNumber))
+		queryParameters.Set("page-size", strconv.Itoa(query.PageSize))
+	}
+	if query.FullProfiles {
+		queryParameters.Set("full-profiles", "true")
+	}
+	uri := p.Config.GetSbiUri() + factory.NRF_NFM_RES_URI_PREFIX + "/nf-instances"
+	if encoded := queryParameters.Encode(); encoded != "" {
+		uri += "?" + encoded
+	}
+	return uri
+}
+
+func (p *Producer) NFDeleteAll(ctx context.Context, nfType string) (problemDetails *models.ProblemDetails) {
+	if err := p.nfDeleteAll(ctx, nfType); err != nil {
+		return storageProblemDetails("NF_DELETE_ERROR", err)
+	}
 	return nil
 }
 
-func NFDeregisterProcedure(nfInstanceID string) (nfType string, problemDetails *models.ProblemDetails) {
+func (p *Producer) nfDeleteAll(ctx context.Context, nfType string) error {
 	collName := "NfProfile"
-	filter := bson.M{"nfInstanceId": nfInstanceID}
-	nfType = GetNfTypeByNfInstanceID(nfInstanceID)
+	// never remove the NRF's own profile
+	filter := bson.M{"nfType": nfType, "nfInstanceId": bson.M{"$ne": p.GetNrfNfProfile().NfInstanceId}}
 
-	nfProfilesRaw, err := dbadapter.DBClient.RestfulAPIGetMany(collName, filter)
+	err := p.DB.RestfulAPIDeleteMany(ctx, collName, filter)
 	if err != nil {
-		logger.ManagementLog.Warnln("error fetching NF profiles:", err)
-		problemDetails = &models.ProblemDetails{
-			Status: http.StatusInternalServerError,
-			Cause:  "FETCH_ERROR",
-			Detail: err.Error(),
-		}
-		return "", problemDetails
+		p.Log.ManagementLog.Errorf("failed to delete NF profiles of type %s: %v", nfType, err)
+		return err
 	}
 
-	time.Sleep(time.Duration(1) * time.Second)
+	p.Log.ManagementLog.Infof("successfully deleted NF profiles of type %s", nfType)
+	return nil
+}
 
-	deleteManyErr := dbadapter.DBClient.RestfulAPIDeleteMany(collName, filter)
-	if deleteManyErr != nil {
-		logger.ManagementLog.Warnln("error in deleting NF profiles:", deleteManyErr)
-		problemDetails = &models.ProblemDetails{
-			Status: http.StatusInternalServerError,
-			Cause:  "NF_DELETE_ERROR",
-			Detail: deleteManyErr.Error(),
+// NFDeregisterProcedure removes the profile of the NF instance and its
+// subscriptions, and enqueues the DEREGISTERED notifications, in one
+// transaction
+func (p *Producer) NFDeregisterProcedure(ctx context.Context, nfInstanceID string) (nfType string, problemDetails *models.ProblemDetails) {
+	collName := "NfProfile"
+	filter := bson.M{"nfInstanceId": nfInstanceID}
+	nfInstanceUri := p.GetNfInstanceURI(nfInstanceID)
+
+	var nfProfiles []models.NfProfile
+	var notificationIds []string
+	err := dbadapter.RunTransaction(ctx, p.DB, func(ctx context.Context) error {
+		nfProfilesRaw, err := p.DB.RestfulAPIGetMany(ctx, collName, filter)
+		if err != nil {
+			p.Log.ManagementLog.Warnln("error fetching NF profiles:", err)
+			return &stepError{cause: "FETCH_ERROR", err: err}
+		}
+		// nfProfile data for response
+		nfProfiles, err = util.Decode(nfProfilesRaw, time.RFC3339)
+		if err != nil {
+			p.Log.ManagementLog.Warnln("Time decode error: ", err)
+			return &stepError{cause: "NOTIFICATION_ERROR", err: err}
 		}
-		return "", problemDetails
-	}
 
-	// nfProfile data for response
-	nfProfiles, err := util.Decode(nfProfilesRaw, time.RFC3339)
-	if err != nil {
-		logger.ManagementLog.Warnln("Time decode error: ", err)
-		problemDetails = &models.ProblemDetails{
-			Status: http.StatusInternalServerError,
-			Cause:  "NOTIFICATION_ERROR",
-			Detail: err.Error(),
+		if err = p.DB.RestfulAPIDeleteMany(ctx, collName, filter); err != nil {
+			p.Log.ManagementLog.Warnln("error in deleting NF profiles:", err)
+			return &stepError{cause: "NF_DELETE_ERROR", err: err}
 		}
-		return "", problemDetails
-	}
 
-	// NF Down Notification to other instances of same NfType
-	if len(nfProfiles) != 0 {
-		sendNFDownNotification(nfProfiles[0], nfInstanceID)
-		uriList := nrfContext.GetNotificationUri(nfProfiles[0])
-		nfInstanceUri := nrfContext.GetNfInstanceURI(nfInstanceID)
-		// set info for NotificationData
-		Notification_event := models.NotificationEventType_DEREGISTERED
-		for _, uri := range uriList {
-			logger.ManagementLog.Infof("status Notification Uri: %v", uri)
-			problemDetails = SendNFStatusNotify(Notification_event, nfInstanceUri, uri)
-			if problemDetails != nil {
-				logger.ManagementLog.Infoln("error in status notify", problemDetails)
+		// NF Down Notification to other instances of same NfType
+		notificationIds = nil
+		if len(nfProfiles) != 0 {
+			uriList := p.notificationUris(ctx, nfProfiles[0], nfProfilesRaw[0])
+			notificationIds, err = p.enqueueNotifications(ctx, models.NotificationEventType_DEREGISTERED, nfInstanceUri, uriList)
+			if err != nil {
+				p.Log.ManagementLog.Warnln("error in enqueuing status notifications:", err)
+				return &stepError{cause: "NOTIFICATION_ERROR", err: err}
 			}
 		}
-	}
 
-	// delete subscriptions of deregistered NF instance
-	filter = bson.M{"subscrCond.nfInstanceId": nfInstanceID}
-	deleteErr := dbadapter.DBClient.RestfulAPIDeleteMany("Subscriptions", filter)
-	if deleteErr != nil {
-		logger.ManagementLog.Warnln("error in deleting subscriptions:", deleteErr)
-		problemDetails = &models.ProblemDetails{
-			Status: http.StatusInternalServerError,
-			Cause:  "SUBSCRIPTION_DELETE_ERROR",
-			Detail: deleteErr.Error(),
+		// delete subscriptions of deregistered NF instance
+		err = p.DB.RestfulAPIDeleteMany(ctx, "Subscriptions", bson.M{"subscrCond.nfInstanceId": nfInstanceID})
+		if err != nil {
+			p.Log.ManagementLog.Warnln("error in deleting subscriptions:", err)
+			return &stepError{cause: "SUBSCRIPTION_DELETE_ERROR", err: err}
 		}
-		return "", problemDetails
+		return nil
+	})
+	if err != nil {
+		return "", transactionProblemDetails(err)
 	}
 
-	return nfType, nil
-}
-
-func sendNFDownNotification(nfProfile models.NfProfile, nfInstanceID string) {
-	if nfProfile.NfType == models.NfType_AMF {
-		url := "http://amf:29518" + "/namf-oam/v1/amfInstanceDown/" + nfInstanceID
-		req, err := http.NewRequest(http.MethodPost, url, nil)
-		if err != nil {
-			logger.ManagementLog.Infoln("Error in creating request ", err)
-		}
-		req.Header.Set("Content-Type", "application/json")
-		client := &http.Client{}
-		_, err = client.Do(req)
-		if err != nil {
-			logger.ManagementLog.Infoln("Errored when sending request to the server", err)
-		}
+	p.RegistryChanged()
+	if len(nfProfiles) == 0 {
+		return "UNKNOWN_NF", nil
 	}
+	p.Webhooks.NotifyNfDown(ctx, webhook.EventDeregistered, nfInstanceID, nfProfiles[0].NfType)
+	p.deliverEnqueuedNotifications(ctx, notificationIds)
+	return string(nfProfiles[0].NfType), nil
 }
 
-func updateNFInstanceProcedure(nfInstanceID string, patchJSON []byte) (response map[string]interface{}, err error) {
+func (p *Producer) updateNFInstanceProcedure(ctx context.Context, nfInstanceID string, mediaType string, patchBody []byte) (response map[string]interface{},
+	problemDetails *models.ProblemDetails,
+) {
 	// Validation for NF Instance ID
 	if nfInstanceID == "" {
-		logger.ManagementLog.Errorln("nf Instance ID is required")
-		return nil, fmt.Errorf("NF Instance ID is required")
+		p.Log.ManagementLog.Errorln("nf Instance ID is required")
+		return nil, &models.ProblemDetails{
+			Status: http.StatusBadRequest,
+			Cause:  "MANDATORY_IE_MISSING",
+			Detail: "NF Instance ID is required",
+		}
 	}
 	collName := "NfProfile"
 	filter := bson.M{"nfInstanceId": nfInstanceID}
 
-	// Patch the existing NF Instance
-	patchError := dbadapter.DBClient.RestfulAPIJSONPatch(collName, filter, patchJSON)
-	if patchError != nil {
-		logger.ManagementLog.Errorln("patch error in UpdateNFInstanceProcedure:", patchError)
-		return nil, fmt.Errorf("patch error: %v", patchError)
+	// Get the existing NF Instance
+	nf, getErr := p.DB.RestfulAPIGetOne(ctx, collName, filter)
+	if getErr != nil {
+		p.Log.ManagementLog.Errorln("failed to get NF instance:", getErr)
+		return nil, storageProblemDetails("SYSTEM_FAILURE", fmt.Errorf("failed to get NF instance: %w", getErr))
 	}
-	// Get the updated NF Instance
-	nf, getErr := dbadapter.DBClient.RestfulAPIGetOne(collName, filter)
-	if getErr != nil || nf == nil {
-		logger.ManagementLog.Errorln("failed to get NF instance:", getErr)
-		return nil, fmt.Errorf("failed to get NF instance: %v", getErr)
+	if nf == nil {
+		p.Log.ManagementLog.Errorf("nf instance [%s] not found", nfInstanceID)
+		return nil, &models.ProblemDetails{
+			Status: http.StatusNotFound,
+			Cause:  "RESOURCE_NOT_FOUND",
+			Detail: "NF instance " + nfInstanceID + " not found",
+		}
 	}
 
-	nfProfilesRaw := []map[string]interface{}{nf}
+	previousNfStatus := nf["nfStatus"]
+	original := nf
 
-	// Decode NF instance
-	nfProfiles, decodeErr := util.Decode(nfProfilesRaw, time.RFC3339)
-	if decodeErr != nil {
-		logger.ManagementLog.Errorln("decoding error:", decodeErr)
-		return nil, fmt.Errorf("decoding error: %v", decodeErr)
+	// Patch a copy of the NF Instance and validate the result before persisting it
+	nf, patchErr := applyPatch(mediaType, nf, patchBody)
+	if patchErr != nil {
+		p.Log.ManagementLog.Errorln("patch error in UpdateNFInstanceProcedure:", patchErr)
+		return nil, &models.ProblemDetails{
+			Title:  "Malformed request syntax",
+			Status: http.StatusBadRequest,
+			Cause:  "INVALID_PATCH",
+			Detail: fmt.Sprintf("patch error: %v", patchErr),
+		}
+	}
+	if invalidParams := validatePatchedNfProfile(nfInstanceID, original["nfType"], nf); len(invalidParams) != 0 {
+		p.Log.ManagementLog.Errorln("patched NF profile is invalid:", invalidParams)
+		return nil, invalidNfProfileProblem("Invalid patched NF profile", invalidParams)
 	}
 
-	if len(nfProfiles) == 0 {
-		// Handle empty decoded profiles case
-		logger.ManagementLog.Errorln("decoded NF profiles are empty")
-		return nil, fmt.Errorf("decoded NF profiles are empty")
+	if problemDetails := p.reapplySharedData(ctx, original, nf); problemDetails != nil {
+		p.Log.ManagementLog.Errorln("patched NF profile refers to unknown shared data:", problemDetails.InvalidParams)
+		return nil, problemDetails
 	}
 
+	nfType := fmt.Sprint(nf["nfType"])
+
 	// Update expiry time if enabled
 	// Currently we are using 3 times the hearbeat timer as the expiry time interval.
 	// We should update it to be configurable : TBD
-	if factory.NrfConfig.Configuration.NfProfileExpiryEnable {
-		timein := time.Now().Local().Add(time.Second * time.Duration(factory.NrfConfig.Configuration.NfKeepAliveTime*3))
+	if p.Config.Configuration.NfProfileExpiryEnable {
+		timein := time.Now().Local().Add(time.Second * time.Duration(p.Config.GetNfKeepAliveTime()*3))
 		nf["expireAt"] = timein
 	}
 	// Put the updated NF instance
-	_, putErr := dbadapter.DBClient.RestfulAPIPutOne(collName, filter, nf)
+	_, putErr := p.DB.RestfulAPIReplaceOne(ctx, collName, filter, nf)
 	if putErr != nil {
-		logger.ManagementLog.Errorf("nf profile [%s] update failed: %v", nfProfiles[0].NfType, putErr)
-		return nil, fmt.Errorf("NF profile update is failed: %v", putErr)
+		p.Log.ManagementLog.Errorf("nf profile [%s] update failed: %v", nfType, putErr)
+		return nil, storageProblemDetails("SYSTEM_FAILURE", fmt.Errorf("NF profile update is failed: %w", putErr))
 	}
 
-	logger.ManagementLog.Infof("nf profile [%s] update success", nfProfiles[0].NfType)
+	p.RegistryChanged()
+
+	if nf["nfStatus"] == string(models.NfStatus_SUSPENDED) && previousNfStatus != string(models.NfStatus_SUSPENDED) {
+		p.Webhooks.NotifyNfDown(ctx, webhook.EventSuspended, nfInstanceID, models.NfType(nfType))
+	}
+
+	p.Log.ManagementLog.Infof("nf profile [%s] update success", nfType)
+	delete(nf, sharedAttributesField)
 	return nf, nil
 }
 
-func GetNFInstanceProcedure(nfInstanceID string) (response map[string]interface{}) {
+func (p *Producer) GetNFInstanceProcedure(ctx context.Context, nfInstanceID string) (response map[string]interface{},
+	problemDetails *models.ProblemDetails,
+) {
 	collName := "NfProfile"
 	filter := bson.M{"nfInstanceId": nfInstanceID}
-	response, _ = dbadapter.DBClient.RestfulAPIGetOne(collName, filter)
-
-	return response
+	response, err := p.DB.RestfulAPIGetOne(ctx, collName, filter)
+	if err != nil {
+		p.Log.ManagementLog.Errorln("DB error in GetNFInstanceProcedure: ", err)
+		return nil, storageProblemDetails("SYSTEM_FAILURE", err)
+	}
+	// the attributes of the shared data are answered as the profile's own
+	delete(response, sharedAttributesField)
+	return response, nil
 }
 
-func NFRegisterProcedure(nfProfile models.NfProfile) (header http.Header, response bson.M,
-	problemDetails *models.ProblemDetails,
+// NFRegisterProcedure registers the NF profile of registration, completed
+// with the attributes of the shared data it refers to that it does not have
+func (p *Producer) NFRegisterProcedure(ctx context.Context, registration NfProfileRegistration) (header http.Header,
+	response bson.M, problemDetails *models.ProblemDetails,
 ) {
-	logger.ManagementLog.Debugln("[NRF] In NFRegisterProcedure")
+	p.Log.ManagementLog.Debugln("[NRF] In NFRegisterProcedure")
+	nfProfile, sharedDataIdList := registration.NfProfile, registration.SharedDataIdList
+	var sharedAttributes []string
+	if len(sharedDataIdList) != 0 {
+		fragments, problemDetails := p.resolveSharedData(ctx, sharedDataIdList)
+		if problemDetails != nil {
+			p.Log.ManagementLog.Errorln("NfProfile refers to unknown shared data:", problemDetails.InvalidParams)
+			return nil, nil, problemDetails
+		}
+		var err error
+		if nfProfile, sharedAttributes, err = withSharedData(nfProfile, fragments); err != nil {
+			p.Log.ManagementLog.Errorln("NfProfile with shared data is invalid:", err)
+			return nil, nil, &models.ProblemDetails{
+				Title:  nfProfile.NfInstanceId,
+				Status: http.StatusBadRequest,
+				Cause:  "MANDATORY_IE_INCORRECT",
+				Detail: err.Error(),
+			}
+		}
+	}
+	if invalidParams := nfProfileInvalidParams(nfProfile); len(invalidParams) != 0 {
+		p.Log.ManagementLog.Errorln("NfProfile is invalid:", invalidParams)
+		return nil, nil, invalidNfProfileProblem(nfProfile.NfInstanceId, invalidParams)
+	}
 	var nf models.NfProfile
-	err := nrfContext.NnrfNFManagementDataModel(&nf, nfProfile)
+	err := p.NnrfNFManagementDataModel(ctx, &nf, nfProfile)
 	if err != nil {
-		logger.ManagementLog.Errorln("NfProfile Validation failed.", err)
+		p.Log.ManagementLog.Errorln("NfProfile Validation failed.", err)
 		str1 := fmt.Sprint(nfProfile.HeartBeatTimer)
 		problemDetails = &models.ProblemDetails{
 			Title:  nfProfile.NfInstanceId,
@@ -473,17 +728,24 @@ func NFRegisterProcedure(nfProfile models.NfProfile) (header http.Header, respon
 	}
 
 	// make location header
-	locationHeaderValue := nrfContext.SetLocationHeader(nfProfile)
+	locationHeaderValue := p.GetNfInstanceURI(nfProfile.NfInstanceId)
 
 	// Marshal nf to bson
 	tmp, err := json.Marshal(nf)
 	if err != nil {
-		logger.ManagementLog.Errorln("Marshal error in NFRegisterProcedure: ", err)
+		p.Log.ManagementLog.Errorln("Marshal error in NFRegisterProcedure: ", err)
 	}
 	putData := bson.M{}
 	err = json.Unmarshal(tmp, &putData)
 	if err != nil {
-		logger.ManagementLog.Errorln("Unmarshal error in NFRegisterProcedure: ", err)
+		p.Log.ManagementLog.Errorln("Unmarshal error in NFRegisterProcedure: ", err)
+	}
+	setServiceSetIdLists(putData, registration.NfServiceSetIdLists)
+	if len(sharedDataIdList) != 0 {
+		putData[sharedDataIdListField] = sharedDataIdList
+		if sharedAttributes != nil {
+			putData[sharedAttributesField] = sharedAttributes
+		}
 	}
 
 	// set db info
@@ -491,63 +753,66 @@ func NFRegisterProcedure(nfProfile models.NfProfile) (header http.Header, respon
 	nfInstanceId := nf.NfInstanceId
 	filter := bson.M{"nfInstanceId": nfInstanceId}
 
-	// fallback to older approach
-	if !factory.NrfConfig.Configuration.NfProfileExpiryEnable {
-		NFDeleteAll(string(nf.NfType))
-	} else {
-		timein := time.Now().Local().Add(time.Second * time.Duration(nf.HeartBeatTimer*3))
-		putData["expireAt"] = timein
-		nfs, _ := dbadapter.DBClient.RestfulAPIGetOne(collName, filter)
-		if len(nfs) == 0 {
-			putData["createdAt"] = time.Now()
-		}
-	}
-
-	// Update NF Profile case
-	if ok, _ := dbadapter.DBClient.RestfulAPIPutOne(collName, filter, putData); ok { // true insert
-		logger.ManagementLog.Infoln("RestfulAPIPutOne True Insert")
-		uriList := nrfContext.GetNotificationUri(nf)
-
-		// set info for NotificationData
-		Notification_event := models.NotificationEventType_PROFILE_CHANGED
-		nfInstanceUri := locationHeaderValue
-
-		// receive the rsp from handler
-		for _, uri := range uriList {
-			problemDetails = SendNFStatusNotify(Notification_event, nfInstanceUri, uri)
-			if problemDetails != nil {
-				return nil, nil, problemDetails
+	// the profile is stored, and the notifications of its registration
+	// enqueued, in one transaction
+	var notificationIds []string
+	err = dbadapter.RunTransaction(ctx, p.DB, func(ctx context.Context) error {
+		// fallback to older approach
+		if !p.Config.Configuration.NfProfileExpiryEnable {
+			if err := p.nfDeleteAll(ctx, string(nf.NfType)); err != nil {
+				return &stepError{cause: "NF_DELETE_ERROR", err: err}
+			}
+		} else {
+			timein := time.Now().Local().Add(time.Second * time.Duration(nf.HeartBeatTimer*3))
+			putData["expireAt"] = timein
+			nfs, getErr := p.DB.RestfulAPIGetOne(ctx, collName, filter)
+			if getErr != nil {
+				p.Log.ManagementLog.Errorln("DB error in NFRegisterProcedure: ", getErr)
+				return getErr
+			}
+			if len(nfs) == 0 {
+				putData["createdAt"] = time.Now()
 			}
 		}
 
-		header = make(http.Header)
-		header.Add("Location", locationHeaderValue)
-		return header, putData, nil
-	} else { // Create NF Profile case
-		logger.ManagementLog.Infoln("Create NF Profile ", nfProfile.NfType)
-		uriList := nrfContext.GetNotificationUri(nf)
+		ok, err := p.DB.RestfulAPIPutOne(ctx, collName, filter, putData)
+		if err != nil {
+			p.Log.ManagementLog.Errorln("DB error in NFRegisterProcedure: ", err)
+			return err
+		}
 		// set info for NotificationData
 		Notification_event := models.NotificationEventType_REGISTERED
-		nfInstanceUri := locationHeaderValue
-
-		for _, uri := range uriList {
-			problemDetails = SendNFStatusNotify(Notification_event, nfInstanceUri, uri)
-			if problemDetails != nil {
-				return nil, nil, problemDetails
-			}
+		if ok { // Update NF Profile case
+			p.Log.ManagementLog.Infoln("RestfulAPIPutOne True Insert")
+			Notification_event = models.NotificationEventType_PROFILE_CHANGED
+		} else { // Create NF Profile case
+			p.Log.ManagementLog.Infoln("Create NF Profile ", nfProfile.NfType)
 		}
-
-		header = make(http.Header)
-		header.Add("Location", locationHeaderValue)
-		logger.ManagementLog.Infoln("Location header: ", locationHeaderValue)
-		return header, putData, nil
+		uriList := p.notificationUris(ctx, nf, putData)
+		notificationIds, err = p.enqueueNotifications(ctx, Notification_event, locationHeaderValue, uriList)
+		if err != nil {
+			p.Log.ManagementLog.Errorln("error in enqueuing status notifications: ", err)
+			return &stepError{cause: "NOTIFICATION_ERROR", err: err}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, transactionProblemDetails(err)
 	}
+	p.RegistryChanged()
+	p.deliverEnqueuedNotifications(ctx, notificationIds)
+	delete(putData, sharedAttributesField)
+
+	header = make(http.Header)
+	header.Add("Location", locationHeaderValue)
+	p.Log.ManagementLog.Infoln("Location header: ", locationHeaderValue)
+	return header, putData, nil
 }
 
-func GetNfTypeBySubscriptionID(subscriptionID string) (nfType string) {
+func (p *Producer) GetNfTypeBySubscriptionID(ctx context.Context, subscriptionID string) (nfType string) {
 	collName := "Subscriptions"
 	filter := bson.M{"subscriptionId": subscriptionID}
-	response, err := dbadapter.DBClient.RestfulAPIGetOne(collName, filter)
+	response, err := p.DB.RestfulAPIGetOne(ctx, collName, filter)
 	if err != nil {
 		return "UNKNOWN_NF"
 	}
@@ -557,10 +822,10 @@ func GetNfTypeBySubscriptionID(subscriptionID string) (nfType string) {
 	return "UNKNOWN_NF"
 }
 
-func GetNfTypeByNfInstanceID(nfInstanceID string) (nfType string) {
+func (p *Producer) GetNfTypeByNfInstanceID(ctx context.Context, nfInstanceID string) (nfType string) {
 	collName := "NfProfile"
 	filter := bson.M{"nfInstanceId": nfInstanceID}
-	response, err := dbadapter.DBClient.RestfulAPIGetOne(collName, filter)
+	response, err := p.DB.RestfulAPIGetOne(ctx, collName, filter)
 	if err != nil {
 		return "UNKNOWN_NF"
 	}
@@ -570,23 +835,40 @@ func GetNfTypeByNfInstanceID(nfInstanceID string) (nfType string) {
 	return "UNKNOWN_NF"
 }
 
-func SendNFStatusNotify(Notification_event models.NotificationEventType, nfInstanceUri string,
+func (p *Producer) SendNFStatusNotify(ctx context.Context, Notification_event models.NotificationEventType, nfInstanceUri string,
 	url string,
 ) *models.ProblemDetails {
+	ctx, span := p.Tracer().Start(ctx, "NFStatusNotify", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("url.full", url),
+			attribute.String("nrf.notification.event", string(Notification_event)),
+		))
+	defer span.End()
+
 	// Set client and set url
 	configuration := Nnrf_NFManagement.NewConfiguration()
 	// url = fmt.Sprintf("%s%s", url, "/notification")
 
 	configuration.SetBasePathNoGroup(url)
+	// the transport of the openapi client cannot be instrumented, the trace
+	// context is propagated in its default headers instead
+	traceHeaders := http.Header{}
+	tracing.Propagators().Inject(ctx, propagation.HeaderCarrier(traceHeaders))
+	for key := range traceHeaders {
+		configuration.AddDefaultHeader(key, traceHeaders.Get(key))
+	}
 	notifcationData := models.NotificationData{
 		Event:         Notification_event,
 		NfInstanceUri: nfInstanceUri,
 	}
 	client := Nnrf_NFManagement.NewAPIClient(configuration)
 
-	res, err := client.NotificationApi.NotificationPost(context.TODO(), notifcationData)
+	res, err := client.NotificationApi.NotificationPost(ctx, notifcationData)
 	if err != nil {
-		logger.ManagementLog.Infof("Notify fail: %v", err)
+		p.Log.ManagementLog.Infof("Notify fail: %v", err)
+		p.Metrics.IncrementNfStatusNotificationsStats(string(Notification_event), "FAILURE")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		problemDetails := &models.ProblemDetails{
 			Status: http.StatusInternalServerError,
 			Cause:  "NOTIFICATION_ERROR",
@@ -597,11 +879,14 @@ func SendNFStatusNotify(Notification_event models.NotificationEventType, nfInsta
 	if res != nil {
 		defer func() {
 			if resCloseErr := res.Body.Close(); resCloseErr != nil {
-				logger.ManagementLog.Errorf("NotificationApi response body cannot close: %+v", resCloseErr)
+				p.Log.ManagementLog.Errorf("NotificationApi response body cannot close: %+v", resCloseErr)
 			}
 		}()
+		span.SetAttributes(attribute.Int("http.response.status_code", res.StatusCode))
 		if status := res.StatusCode; status != http.StatusNoContent && status != http.StatusOK {
-			logger.ManagementLog.Warnln("Error status in NotificationPost: ", status)
+			p.Log.ManagementLog.Warnln("Error status in NotificationPost: ", status)
+			p.Metrics.IncrementNfStatusNotificationsStats(string(Notification_event), "FAILURE")
+			span.SetStatus(codes.Error, http.StatusText(status))
 			problemDetails := &models.ProblemDetails{
 				Status: int32(status),
 				Cause:  "NOTIFICATION_ERROR",
@@ -609,5 +894,6 @@ func SendNFStatusNotify(Notification_event models.NotificationEventType, nfInsta
 			return problemDetails
 		}
 	}
+	p.Metrics.IncrementNfStatusNotificationsStats(string(Notification_event), "SUCCESS")
 	return nil
 }
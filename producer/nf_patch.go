@@ -0,0 +1,185 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package producer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/omec-project/openapi/models"
+)
+
+const (
+	// JsonPatchContentType is the media type of an RFC 6902 JSON Patch document
+	JsonPatchContentType = "application/json-patch+json"
+	// MergePatchContentType is the media type of an RFC 7386 JSON Merge Patch document
+	MergePatchContentType = "application/merge-patch+json"
+)
+
+// storage-only fields which are not part of the resource representation and
+// must survive a patch untouched
+var patchProtectedFields = []string{"_id", "expireAt", "createdAt", sharedAttributesField}
+
+// checkPatchContentType returns the media type of a PATCH request, or a 415
+// ProblemDetails when it is neither JSON Patch nor JSON Merge Patch
+func (p *Producer) checkPatchContentType(contentType string) (string, *models.ProblemDetails) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil && (mediaType == JsonPatchContentType || mediaType == MergePatchContentType) {
+		return mediaType, nil
+	}
+	p.Log.ManagementLog.Warnf("unsupported PATCH Content-Type: [%s]", contentType)
+	return "", &models.ProblemDetails{
+		Title:  "Unsupported Media Type",
+		Status: http.StatusUnsupportedMediaType,
+		Cause:  "UNSUPPORTED_MEDIA_TYPE",
+		Detail: fmt.Sprintf("Content-Type must be %s or %s", JsonPatchContentType, MergePatchContentType),
+	}
+}
+
+// heartbeatAttributes are the NF profile attributes an NF may update in a
+// heartbeat, TS 29.510 5.2.2.3.2
+var heartbeatAttributes = map[string]bool{"nfStatus": true, "load": true, "loadTimeStamp": true}
+
+// isHeartbeat reports whether the patch of an NF profile is a heartbeat, i.e.
+// only updates the status and the load of the NF
+func isHeartbeat(mediaType string, patchBody []byte) bool {
+	var attributes []string
+	switch mediaType {
+	case JsonPatchContentType:
+		var patchItems []models.PatchItem
+		if err := json.Unmarshal(patchBody, &patchItems); err != nil {
+			return false
+		}
+		for _, patchItem := range patchItems {
+			attributes = append(attributes, strings.TrimPrefix(patchItem.Path, "/"))
+		}
+	case MergePatchContentType:
+		var mergePatch map[string]interface{}
+		if err := json.Unmarshal(patchBody, &mergePatch); err != nil {
+			return false
+		}
+		for attribute := range mergePatch {
+			attributes = append(attributes, attribute)
+		}
+	}
+	if len(attributes) == 0 {
+		return false
+	}
+	for _, attribute := range attributes {
+		if !heartbeatAttributes[attribute] {
+			return false
+		}
+	}
+	return true
+}
+
+// applyPatch applies patchBody to a copy of original according to the given
+// media type. The original document is left untouched so that the patched
+// result can be validated before it is persisted.
+func applyPatch(mediaType string, original map[string]interface{}, patchBody []byte) (map[string]interface{}, error) {
+	document := make(map[string]interface{}, len(original))
+	protected := make(map[string]interface{})
+	for key, value := range original {
+		document[key] = value
+	}
+	for _, key := range patchProtectedFields {
+		if value, ok := document[key]; ok {
+			protected[key] = value
+			delete(document, key)
+		}
+	}
+
+	originalJSON, err := json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+
+	var patchedJSON []byte
+	switch mediaType {
+	case JsonPatchContentType:
+		patch, decodeErr := jsonpatch.DecodePatch(patchBody)
+		if decodeErr != nil {
+			return nil, decodeErr
+		}
+		patchedJSON, err = patch.Apply(originalJSON)
+	case MergePatchContentType:
+		patchedJSON, err = jsonpatch.MergePatch(originalJSON, patchBody)
+	default:
+		return nil, fmt.Errorf("unsupported patch media type: %s", mediaType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	patched := map[string]interface{}{}
+	if err = json.Unmarshal(patchedJSON, &patched); err != nil {
+		return nil, err
+	}
+	for key, value := range protected {
+		patched[key] = value
+	}
+	return patched, nil
+}
+
+// decodePatchedDocument converts a patched document, minus its storage-only
+// fields, into the given openapi model
+func decodePatchedDocument(patched map[string]interface{}, target interface{}) error {
+	document := make(map[string]interface{}, len(patched))
+	for key, value := range patched {
+		document[key] = value
+	}
+	for _, key := range patchProtectedFields {
+		delete(document, key)
+	}
+	raw, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, target)
+}
+
+// validatePatchedNfProfile returns the invalid attributes of a patched NF
+// profile, validated as a registered one, which must remain the profile of
+// the same NF instance and NF type
+func validatePatchedNfProfile(nfInstanceID string, nfType interface{}, patched map[string]interface{}) []models.InvalidParam {
+	var nfProfile models.NfProfile
+	if err := decodePatchedDocument(patched, &nfProfile); err != nil {
+		param := "nfProfile"
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			param = typeErr.Field
+		}
+		return []models.InvalidParam{{Param: param, Reason: "is malformed: " + err.Error()}}
+	}
+	invalidParams := nfProfileInvalidParams(nfProfile)
+	if nfProfile.NfInstanceId != nfInstanceID {
+		invalidParams = append(invalidParams, models.InvalidParam{Param: "nfInstanceId", Reason: "cannot be modified"})
+	}
+	if nfProfile.NfType != "" && string(nfProfile.NfType) != fmt.Sprint(nfType) {
+		invalidParams = append(invalidParams, models.InvalidParam{Param: "nfType", Reason: "cannot be modified"})
+	}
+	return invalidParams
+}
+
+// validatePatchedSubscription checks that a patched subscription is still a
+// valid NrfSubscriptionData for the same subscription
+func validatePatchedSubscription(subscriptionID string, patched map[string]interface{}) error {
+	var subscription models.NrfSubscriptionData
+	if err := decodePatchedDocument(patched, &subscription); err != nil {
+		return fmt.Errorf("patched document is not a valid NrfSubscriptionData: %v", err)
+	}
+	if subscription.SubscriptionId != subscriptionID {
+		return fmt.Errorf("subscriptionId cannot be modified")
+	}
+	if subscription.NfStatusNotificationUri == "" {
+		return fmt.Errorf("nfStatusNotificationUri field is required")
+	}
+	return nil
+}
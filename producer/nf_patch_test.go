@@ -0,0 +1,347 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package producer_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/omec-project/nrf/metrics"
+	"github.com/omec-project/nrf/producer"
+	"github.com/omec-project/openapi/models"
+	"github.com/omec-project/util/httpwrapper"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type PatchMockMongoDBClient struct {
+	MockMongoDBClient
+	stored map[string]interface{}
+	put    map[string]interface{}
+}
+
+func (db *PatchMockMongoDBClient) RestfulAPIGetOne(ctx context.Context, collName string, filter bson.M) (map[string]interface{}, error) {
+	return db.stored, nil
+}
+
+func (db *PatchMockMongoDBClient) RestfulAPIReplaceOne(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}) (bool, error) {
+	db.put = putData
+	return true, nil
+}
+
+func newPatchRequest(contentType string, body string) *httpwrapper.Request {
+	httpReq := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(body))
+	if contentType != "" {
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+	req := httpwrapper.NewRequest(httpReq, nil)
+	req.Body = []byte(body)
+	return req
+}
+
+func TestHandleUpdateSubscriptionRequestContentTypes(t *testing.T) {
+	testCases := []struct {
+		name           string
+		contentType    string
+		body           string
+		expectedStatus int
+		expectedUri    string
+	}{
+		{
+			name:           "JSON Patch",
+			contentType:    producer.JsonPatchContentType,
+			body:           `[{"op":"replace","path":"/nfStatusNotificationUri","value":"http://new"}]`,
+			expectedStatus: http.StatusOK,
+			expectedUri:    "http://new",
+		},
+		{
+			name:           "JSON Merge Patch",
+			contentType:    producer.MergePatchContentType + "; charset=utf-8",
+			body:           `{"nfStatusNotificationUri":"http://merged"}`,
+			expectedStatus: http.StatusOK,
+			expectedUri:    "http://merged",
+		},
+		{
+			name:           "Unsupported Content-Type",
+			contentType:    "application/json",
+			body:           `{"nfStatusNotificationUri":"http://merged"}`,
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:           "Patch removing a mandatory attribute",
+			contentType:    producer.MergePatchContentType,
+			body:           `{"nfStatusNotificationUri":null}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Patch modifying the subscription id",
+			contentType:    producer.JsonPatchContentType,
+			body:           `[{"op":"replace","path":"/subscriptionId","value":"2"}]`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mock := &PatchMockMongoDBClient{
+				stored: map[string]interface{}{
+					"subscriptionId":          "1",
+					"nfStatusNotificationUri": "http://old",
+					"reqNfType":               "AMF",
+				},
+			}
+			p := newTestProducer(t, mock)
+
+			req := newPatchRequest(tc.contentType, tc.body)
+			req.Params["subscriptionID"] = "1"
+			rsp := p.HandleUpdateSubscriptionRequest(context.Background(), req)
+			if rsp.Status != tc.expectedStatus {
+				t.Fatalf("Expected status %d, got %d (%+v)", tc.expectedStatus, rsp.Status, rsp.Body)
+			}
+			if tc.expectedStatus != http.StatusOK {
+				if mock.put != nil {
+					t.Errorf("Expected nothing to be persisted, got %v", mock.put)
+				}
+				return
+			}
+			if mock.put["nfStatusNotificationUri"] != tc.expectedUri {
+				t.Errorf("Expected persisted uri %s, got %v", tc.expectedUri, mock.put["nfStatusNotificationUri"])
+			}
+		})
+	}
+}
+
+func TestHandleUpdateNFInstanceRequestValidation(t *testing.T) {
+	testCases := []struct {
+		name           string
+		contentType    string
+		body           string
+		expectedStatus int
+		invalidParam   string
+	}{
+		{
+			name:           "JSON Patch heartbeat",
+			contentType:    producer.JsonPatchContentType,
+			body:           `[{"op":"replace","path":"/nfStatus","value":"REGISTERED"}]`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "JSON Merge Patch load update",
+			contentType:    producer.MergePatchContentType,
+			body:           `{"load":50}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Merge patch removing nfType",
+			contentType:    producer.MergePatchContentType,
+			body:           `{"nfType":null}`,
+			expectedStatus: http.StatusBadRequest,
+			invalidParam:   "nfType",
+		},
+		{
+			name:           "Patch setting an unknown NF status",
+			contentType:    producer.MergePatchContentType,
+			body:           `{"nfStatus":"BOGUS"}`,
+			expectedStatus: http.StatusBadRequest,
+			invalidParam:   "nfStatus",
+		},
+		{
+			name:           "Patch changing the NF type",
+			contentType:    producer.JsonPatchContentType,
+			body:           `[{"op":"replace","path":"/nfType","value":"SMF"}]`,
+			expectedStatus: http.StatusBadRequest,
+			invalidParam:   "nfType",
+		},
+		{
+			name:           "Patch setting an unknown NF type",
+			contentType:    producer.MergePatchContentType,
+			body:           `{"nfType":"BOGUS"}`,
+			expectedStatus: http.StatusBadRequest,
+			invalidParam:   "nfType",
+		},
+		{
+			name:           "Patch adding a non-numeric priority",
+			contentType:    producer.JsonPatchContentType,
+			body:           `[{"op":"add","path":"/priority","value":"high"}]`,
+			expectedStatus: http.StatusBadRequest,
+			invalidParam:   "priority",
+		},
+		{
+			name:           "Patch adding an invalid IPv4 address",
+			contentType:    producer.MergePatchContentType,
+			body:           `{"ipv4Addresses":["10.0.0.1","not-an-address"]}`,
+			expectedStatus: http.StatusBadRequest,
+			invalidParam:   "ipv4Addresses[1]",
+		},
+		{
+			name:           "Patch modifying the NF instance id",
+			contentType:    producer.MergePatchContentType,
+			body:           `{"nfInstanceId":"instance-2"}`,
+			expectedStatus: http.StatusBadRequest,
+			invalidParam:   "nfInstanceId",
+		},
+		{
+			name:           "Malformed JSON Patch",
+			contentType:    producer.JsonPatchContentType,
+			body:           `{"op":"replace"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Missing Content-Type",
+			contentType:    "",
+			body:           `{"load":50}`,
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mock := &PatchMockMongoDBClient{
+				stored: map[string]interface{}{
+					"nfInstanceId": "instance-1",
+					"nfType":       "AMF",
+					"nfStatus":     "REGISTERED",
+				},
+			}
+			p := newTestProducer(t, mock)
+
+			req := newPatchRequest(tc.contentType, tc.body)
+			req.Params["nfInstanceID"] = "instance-1"
+			rsp := p.HandleUpdateNFInstanceRequest(context.Background(), req)
+			if rsp.Status != tc.expectedStatus {
+				t.Fatalf("Expected status %d, got %d (%+v)", tc.expectedStatus, rsp.Status, rsp.Body)
+			}
+			if tc.expectedStatus != http.StatusOK && mock.put != nil {
+				t.Errorf("Expected nothing to be persisted, got %v", mock.put)
+			}
+			if tc.invalidParam == "" {
+				return
+			}
+			problemDetails, ok := rsp.Body.(*models.ProblemDetails)
+			if !ok || len(problemDetails.InvalidParams) == 0 || problemDetails.InvalidParams[0].Param != tc.invalidParam {
+				t.Errorf("Expected the invalid parameter %s, got %+v", tc.invalidParam, rsp.Body)
+			}
+		})
+	}
+}
+
+func TestHandleUpdateNFInstanceRequestHeartbeats(t *testing.T) {
+	testCases := []struct {
+		name               string
+		contentType        string
+		body               string
+		expectedHeartbeats float64
+	}{
+		{
+			name:               "JSON Patch heartbeat",
+			contentType:        producer.JsonPatchContentType,
+			body:               `[{"op":"replace","path":"/nfStatus","value":"REGISTERED"}]`,
+			expectedHeartbeats: 1,
+		},
+		{
+			name:               "JSON Merge Patch heartbeat with load",
+			contentType:        producer.MergePatchContentType,
+			body:               `{"nfStatus":"REGISTERED","load":20}`,
+			expectedHeartbeats: 1,
+		},
+		{
+			name:        "profile update",
+			contentType: producer.JsonPatchContentType,
+			body:        `[{"op":"replace","path":"/nfStatus","value":"REGISTERED"},{"op":"add","path":"/fqdn","value":"amf.example"}]`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mock := &PatchMockMongoDBClient{
+				stored: map[string]interface{}{
+					"nfInstanceId": "1",
+					"nfType":       "AMF",
+					"nfStatus":     "REGISTERED",
+					"plmnList":     []interface{}{map[string]interface{}{"mcc": "208", "mnc": "93"}},
+				},
+			}
+			p := newTestProducer(t, mock)
+			registry := prometheus.NewRegistry()
+			stats, err := metrics.NewNrfStats(registry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p.Metrics = stats
+
+			req := newPatchRequest(tc.contentType, tc.body)
+			req.Params["nfInstanceID"] = "1"
+			if rsp := p.HandleUpdateNFInstanceRequest(context.Background(), req); rsp.Status != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d (%+v)", http.StatusOK, rsp.Status, rsp.Body)
+			}
+			if got := counterValue(t, registry, "nrf_heartbeats"); got != tc.expectedHeartbeats {
+				t.Errorf("Expected %v heartbeats, got %v", tc.expectedHeartbeats, got)
+			}
+		})
+	}
+}
+
+func TestHandleUpdateRequestsRemoveStoredAttributes(t *testing.T) {
+	db := &SharedDataMockMongoDBClient{collections: map[string]map[string]map[string]interface{}{
+		"NfProfile": {"1": {
+			"nfInstanceId": "1",
+			"nfType":       "AMF",
+			"nfStatus":     "REGISTERED",
+			"fqdn":         "amf.example",
+			"locality":     "east",
+		}},
+		"Subscriptions": {"1": {
+			"subscriptionId":          "1",
+			"nfStatusNotificationUri": "http://subscriber",
+			"reqNfFqdn":               "smf.example",
+		}},
+	}}
+	p := newTestProducer(t, db)
+
+	req := newPatchRequest(producer.JsonPatchContentType, `[{"op":"remove","path":"/fqdn"}]`)
+	req.Params["nfInstanceID"] = "1"
+	if rsp := p.HandleUpdateNFInstanceRequest(context.Background(), req); rsp.Status != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d (%+v)", http.StatusOK, rsp.Status, rsp.Body)
+	}
+	req = newPatchRequest(producer.MergePatchContentType, `{"locality":null}`)
+	req.Params["nfInstanceID"] = "1"
+	if rsp := p.HandleUpdateNFInstanceRequest(context.Background(), req); rsp.Status != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d (%+v)", http.StatusOK, rsp.Status, rsp.Body)
+	}
+	profile := db.document("NfProfile", "1")
+	for _, attribute := range []string{"fqdn", "locality"} {
+		if _, ok := profile[attribute]; ok {
+			t.Errorf("Expected %s to be removed from the stored profile, got %v", attribute, profile)
+		}
+	}
+
+	req = newPatchRequest(producer.MergePatchContentType, `{"reqNfFqdn":null}`)
+	req.Params["subscriptionID"] = "1"
+	if rsp := p.HandleUpdateSubscriptionRequest(context.Background(), req); rsp.Status != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d (%+v)", http.StatusOK, rsp.Status, rsp.Body)
+	}
+	if subscription := db.document("Subscriptions", "1"); subscription["reqNfFqdn"] != nil {
+		t.Errorf("Expected reqNfFqdn to be removed from the stored subscription, got %v", subscription)
+	}
+}
+
+// counterValue sums the values of the counter name across its labels
+func counterValue(t *testing.T, registry *prometheus.Registry, name string) float64 {
+	t.Helper()
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := 0.0
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			total += metric.GetCounter().GetValue()
+		}
+	}
+	return total
+}
@@ -6,18 +6,25 @@
 package producer_test
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/google/uuid"
+	nrfContext "github.com/omec-project/nrf/context"
 	"github.com/omec-project/nrf/dbadapter"
 	"github.com/omec-project/nrf/factory"
+	"github.com/omec-project/nrf/forwarding"
 	"github.com/omec-project/nrf/logger"
-	"github.com/omec-project/nrf/polling"
 	"github.com/omec-project/nrf/producer"
 	"github.com/omec-project/openapi/models"
+	"github.com/omec-project/util/httpwrapper"
 	"go.mongodb.org/mongo-driver/bson"
 )
 
@@ -25,16 +32,22 @@ type MockMongoDBClient struct {
 	dbadapter.DBInterface
 }
 
-func init() {
-	factory.InitConfigFactory("../nrfTest/nrfcfg.yaml")
+// newTestProducer returns a producer using the test configuration and db as storage
+func newTestProducer(t *testing.T, db dbadapter.DBInterface) *producer.Producer {
+	t.Helper()
+	config, err := factory.ReadConfig("../nrfTest/nrfcfg.yaml")
+	if err != nil {
+		t.Fatalf("failed to read test configuration: %v", err)
+	}
+	return producer.New(nrfContext.New(config, db, logger.Default()))
 }
 
-func (db *MockMongoDBClient) RestfulAPIGetOne(collName string, filter bson.M) (map[string]interface{}, error) {
+func (db *MockMongoDBClient) RestfulAPIGetOne(ctx context.Context, collName string, filter bson.M) (map[string]interface{}, error) {
 	logger.HandlerLog.Infoln("called Mock RestfulAPIGetOne")
 	return nil, nil
 }
 
-func (db *MockMongoDBClient) RestfulAPIGetMany(collName string, filter bson.M) ([]map[string]interface{}, error) {
+func (db *MockMongoDBClient) RestfulAPIGetMany(ctx context.Context, collName string, filter bson.M) ([]map[string]interface{}, error) {
 	logger.HandlerLog.Infoln("called Mock RestfulAPIGetMany")
 	return nil, nil
 }
@@ -44,51 +57,66 @@ func (db *MockMongoDBClient) PutOneWithTimeout(collName string, filter bson.M, p
 	return true
 }
 
-func (db *MockMongoDBClient) RestfulAPIPutOne(collName string, filter bson.M, putData map[string]interface{}) (bool, error) {
+func (db *MockMongoDBClient) RestfulAPIPutOne(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}) (bool, error) {
 	logger.HandlerLog.Infoln("called Mock RestfulAPIPutOne")
 	return true, nil
 }
 
-func (db *MockMongoDBClient) RestfulAPIPutOneNotUpdate(collName string, filter bson.M, putData map[string]interface{}) (bool, error) {
+func (db *MockMongoDBClient) RestfulAPIReplaceOne(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}) (bool, error) {
+	logger.HandlerLog.Infoln("called Mock RestfulAPIReplaceOne")
+	return true, nil
+}
+
+func (db *MockMongoDBClient) RestfulAPIPutOneIf(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}, upsert bool) (bool, error) {
+	logger.HandlerLog.Infoln("called Mock RestfulAPIPutOneIf")
+	return true, nil
+}
+
+func (db *MockMongoDBClient) RestfulAPIPutOneNotUpdate(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}) (bool, error) {
 	logger.HandlerLog.Infoln("called Mock RestfulAPIPutOneNotUpdate")
 	return true, nil
 }
 
-func (db *MockMongoDBClient) RestfulAPIPutMany(collName string, filterArray []bson.M, putDataArray []map[string]interface{}) error {
+func (db *MockMongoDBClient) RestfulAPIPutMany(ctx context.Context, collName string, filterArray []bson.M, putDataArray []map[string]interface{}) error {
 	logger.HandlerLog.Infoln("called Mock RestfulAPIPutMany")
 	return nil
 }
 
-func (db *MockMongoDBClient) RestfulAPIDeleteOne(collName string, filter bson.M) error {
+func (db *MockMongoDBClient) RestfulAPIDeleteOne(ctx context.Context, collName string, filter bson.M) error {
 	logger.HandlerLog.Infoln("called Mock RestfulAPIDeleteOne")
 	return nil
 }
 
-func (db *MockMongoDBClient) RestfulAPIDeleteMany(collName string, filter bson.M) error {
+func (db *MockMongoDBClient) RestfulAPIDeleteOneIf(ctx context.Context, collName string, filter bson.M) (bool, error) {
+	logger.HandlerLog.Infoln("called Mock RestfulAPIDeleteOneIf")
+	return true, nil
+}
+
+func (db *MockMongoDBClient) RestfulAPIDeleteMany(ctx context.Context, collName string, filter bson.M) error {
 	logger.HandlerLog.Infoln("called Mock RestfulAPIDeleteMany")
 	return nil
 }
 
-func (db *MockMongoDBClient) RestfulAPIMergePatch(collName string, filter bson.M, patchData map[string]interface{}) error {
+func (db *MockMongoDBClient) RestfulAPIMergePatch(ctx context.Context, collName string, filter bson.M, patchData map[string]interface{}) error {
 	logger.HandlerLog.Infoln("called Mock RestfulAPIMergePatch")
 	return nil
 }
 
-func (db *MockMongoDBClient) RestfulAPIJSONPatch(collName string, filter bson.M, patchJSON []byte) error {
+func (db *MockMongoDBClient) RestfulAPIJSONPatch(ctx context.Context, collName string, filter bson.M, patchJSON []byte) error {
 	return nil
 }
 
-func (db *MockMongoDBClient) RestfulAPIJSONPatchExtend(collName string, filter bson.M, patchJSON []byte, dataName string) error {
+func (db *MockMongoDBClient) RestfulAPIJSONPatchExtend(ctx context.Context, collName string, filter bson.M, patchJSON []byte, dataName string) error {
 	logger.HandlerLog.Infoln("called Mock RestfulAPIJSONPatchExtend")
 	return nil
 }
 
-func (db *MockMongoDBClient) RestfulAPIPost(collName string, filter bson.M, postData map[string]interface{}) (bool, error) {
+func (db *MockMongoDBClient) RestfulAPIPost(ctx context.Context, collName string, filter bson.M, postData map[string]interface{}) (bool, error) {
 	logger.HandlerLog.Infoln("called Mock RestfulAPIPost")
 	return true, nil
 }
 
-func (db *MockMongoDBClient) RestfulAPIPostMany(collName string, filter bson.M, postDataArray []interface{}) bool {
+func (db *MockMongoDBClient) RestfulAPIPostMany(ctx context.Context, collName string, filter bson.M, postDataArray []interface{}) bool {
 	logger.HandlerLog.Infoln("called Mock RestfulAPIPost")
 	return true
 }
@@ -178,23 +206,18 @@ func TestNFRegisterProcedureSuccess(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			webconsoleCalled := false
-			originalDBClient := dbadapter.DBClient
-			originalFetchPlmnConfig := polling.FetchPlmnConfig
-			defer func() {
-				dbadapter.DBClient = originalDBClient
-				polling.FetchPlmnConfig = originalFetchPlmnConfig
-			}()
-			polling.FetchPlmnConfig = func() ([]models.PlmnId, error) {
+			fetchPlmnConfig := func(context.Context) ([]models.PlmnId, error) {
 				webconsoleCalled = true
 				return tc.nrfPlmnList, nil
 			}
-			dbadapter.DBClient = &MockMongoDBClient{}
+			p := newTestProducer(t, &MockMongoDBClient{})
+			p.FetchPlmnConfig = fetchPlmnConfig
 			var nf models.NfProfile
 			nf.NfType = models.NfType_AUSF
 			nf.NfInstanceId = uuid.New().String()
 			nf.NfStatus = models.NfStatus_REGISTERED
 			nf.PlmnList = tc.nfPlmnList
-			_, data, err := producer.NFRegisterProcedure(nf)
+			_, data, err := p.NFRegisterProcedure(context.Background(), producer.NfProfileRegistration{NfProfile: nf})
 			if err != nil {
 				t.Errorf("failed to register NF: %v", err)
 			}
@@ -231,23 +254,18 @@ func TestNFRegisterProcedureFailure(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			webconsoleCalled := false
-			originalDBClient := dbadapter.DBClient
-			originalFetchPlmnConfig := polling.FetchPlmnConfig
-			defer func() {
-				dbadapter.DBClient = originalDBClient
-				polling.FetchPlmnConfig = originalFetchPlmnConfig
-			}()
-			polling.FetchPlmnConfig = func() ([]models.PlmnId, error) {
+			fetchPlmnConfig := func(context.Context) ([]models.PlmnId, error) {
 				webconsoleCalled = true
 				return tc.nrfPlmnList, nil
 			}
-			dbadapter.DBClient = &MockMongoDBClient{}
+			p := newTestProducer(t, &MockMongoDBClient{})
+			p.FetchPlmnConfig = fetchPlmnConfig
 			var nf models.NfProfile
 			nf.NfType = models.NfType_AUSF
 			nf.NfInstanceId = uuid.New().String()
 			nf.NfStatus = models.NfStatus_REGISTERED
 			nf.PlmnList = tc.nfPlmnList
-			_, data, err := producer.NFRegisterProcedure(nf)
+			_, data, err := p.NFRegisterProcedure(context.Background(), producer.NfProfileRegistration{NfProfile: nf})
 			if err == nil {
 				t.Errorf("Expected error, got: %v", data)
 			}
@@ -258,23 +276,332 @@ func TestNFRegisterProcedureFailure(t *testing.T) {
 	}
 }
 
+func TestNFRegisterProcedureInvalidProfile(t *testing.T) {
+	testCases := []struct {
+		name         string
+		nf           models.NfProfile
+		invalidParam string
+	}{
+		{
+			name:         "Unknown NF type",
+			nf:           models.NfProfile{NfType: "BOGUS", NfStatus: models.NfStatus_REGISTERED},
+			invalidParam: "nfType",
+		},
+		{
+			name:         "Unknown NF status",
+			nf:           models.NfProfile{NfType: models.NfType_AUSF, NfStatus: "BOGUS"},
+			invalidParam: "nfStatus",
+		},
+		{
+			name: "Invalid IPv6 address",
+			nf: models.NfProfile{
+				NfType: models.NfType_AUSF, NfStatus: models.NfStatus_REGISTERED, Ipv6Addresses: []string{"10.0.0.1"},
+			},
+			invalidParam: "ipv6Addresses[0]",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newTestProducer(t, &MockMongoDBClient{})
+			tc.nf.NfInstanceId = uuid.New().String()
+			_, data, problemDetails := p.NFRegisterProcedure(context.Background(), producer.NfProfileRegistration{NfProfile: tc.nf})
+			if problemDetails == nil {
+				t.Fatalf("Expected the registration to be rejected, got: %v", data)
+			}
+			if problemDetails.Status != http.StatusBadRequest || len(problemDetails.InvalidParams) == 0 ||
+				problemDetails.InvalidParams[0].Param != tc.invalidParam {
+				t.Errorf("Expected 400 with the invalid parameter %s, got %+v", tc.invalidParam, problemDetails)
+			}
+		})
+	}
+}
+
 func TestNFRegisterProcedureFailureNoProvidedPlmnListAndWebconsoleUnreachable(t *testing.T) {
-	originalDBClient := dbadapter.DBClient
-	originalFetchPlmnConfig := polling.FetchPlmnConfig
-	defer func() {
-		dbadapter.DBClient = originalDBClient
-		polling.FetchPlmnConfig = originalFetchPlmnConfig
-	}()
-	polling.FetchPlmnConfig = func() ([]models.PlmnId, error) {
+	fetchPlmnConfig := func(context.Context) ([]models.PlmnId, error) {
 		return nil, errors.New("http error")
 	}
-	dbadapter.DBClient = &MockMongoDBClient{}
+	p := newTestProducer(t, &MockMongoDBClient{})
+	p.FetchPlmnConfig = fetchPlmnConfig
 	var nf models.NfProfile
 	nf.NfType = models.NfType_AUSF
 	nf.NfInstanceId = uuid.New().String()
 	nf.NfStatus = models.NfStatus_REGISTERED
-	_, data, err := producer.NFRegisterProcedure(nf)
+	_, data, err := p.NFRegisterProcedure(context.Background(), producer.NfProfileRegistration{NfProfile: nf})
 	if err == nil {
 		t.Errorf("Expected error, got: %v", data)
 	}
 }
+
+type ListMockMongoDBClient struct {
+	MockMongoDBClient
+	profiles []map[string]interface{}
+	filter   bson.M
+}
+
+func (db *ListMockMongoDBClient) RestfulAPIGetMany(ctx context.Context, collName string, filter bson.M) ([]map[string]interface{}, error) {
+	db.filter = filter
+	return db.profiles, nil
+}
+
+func TestHandleGetNFInstancesRequest(t *testing.T) {
+	mock := &ListMockMongoDBClient{}
+	for _, id := range []string{"c", "a", "e", "b", "d"} {
+		mock.profiles = append(mock.profiles, map[string]interface{}{
+			"nfInstanceId": id,
+			"nfType":       "UPF",
+			"nfStatus":     "REGISTERED",
+		})
+	}
+	p := newTestProducer(t, mock)
+
+	testCases := []struct {
+		name           string
+		query          url.Values
+		expectedStatus int
+		expectedItems  []string
+		expectPrev     bool
+		expectNext     bool
+	}{
+		{
+			name:           "no query parameters",
+			query:          url.Values{},
+			expectedStatus: http.StatusOK,
+			expectedItems:  []string{"a", "b", "c", "d", "e"},
+		},
+		{
+			name:           "limit",
+			query:          url.Values{"nf-type": {"UPF"}, "limit": {"2"}},
+			expectedStatus: http.StatusOK,
+			expectedItems:  []string{"a", "b"},
+		},
+		{
+			name:           "middle page",
+			query:          url.Values{"page-number": {"2"}, "page-size": {"2"}},
+			expectedStatus: http.StatusOK,
+			expectedItems:  []string{"c", "d"},
+			expectPrev:     true,
+			expectNext:     true,
+		},
+		{
+			name:           "last page",
+			query:          url.Values{"page-number": {"3"}, "page-size": {"2"}},
+			expectedStatus: http.StatusOK,
+			expectedItems:  []string{"e"},
+			expectPrev:     true,
+		},
+		{
+			name:           "invalid limit",
+			query:          url.Values{"limit": {"abc"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid nf-status",
+			query:          url.Values{"nf-status": {"DOWN"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httpwrapper.NewRequest(httptest.NewRequest(http.MethodGet, "/nf-instances", nil), nil)
+			req.Query = tc.query
+			rsp := p.HandleGetNFInstancesRequest(context.Background(), req)
+			if rsp.Status != tc.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tc.expectedStatus, rsp.Status)
+			}
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+			uriList := rsp.Body.(*nrfContext.UriList)
+			if len(uriList.Link.Item) != len(tc.expectedItems) {
+				t.Fatalf("Expected %d items, got %d", len(tc.expectedItems), len(uriList.Link.Item))
+			}
+			for i, id := range tc.expectedItems {
+				if !strings.HasSuffix(uriList.Link.Item[i].Href, "/nf-instances/"+id) {
+					t.Errorf("Expected item %d to be %s, got %s", i, id, uriList.Link.Item[i].Href)
+				}
+			}
+			if (uriList.Link.Prev != nil) != tc.expectPrev {
+				t.Errorf("Expected prev link: %v, got %+v", tc.expectPrev, uriList.Link.Prev)
+			}
+			if (uriList.Link.Next != nil) != tc.expectNext {
+				t.Errorf("Expected next link: %v, got %+v", tc.expectNext, uriList.Link.Next)
+			}
+			if uriList.TotalItemCount != int32(len(mock.profiles)) {
+				t.Errorf("Expected totalItemCount %d, got %d", len(mock.profiles), uriList.TotalItemCount)
+			}
+		})
+	}
+}
+
+func TestGetNFInstancesProcedureFilters(t *testing.T) {
+	mock := &ListMockMongoDBClient{
+		profiles: []map[string]interface{}{
+			{"nfInstanceId": "a", "nfType": "AMF", "nfStatus": "SUSPENDED"},
+		},
+	}
+	p := newTestProducer(t, mock)
+
+	response, problemDetails := p.GetNFInstancesProcedure(context.Background(), producer.NFInstancesQuery{
+		NfType:       "AMF",
+		NfStatus:     "SUSPENDED",
+		PageNumber:   1,
+		FullProfiles: true,
+	})
+	if problemDetails != nil {
+		t.Fatalf("Unexpected error: %+v", problemDetails)
+	}
+	expectedFilter := bson.M{"nfType": "AMF", "nfStatus": "SUSPENDED"}
+	if !reflect.DeepEqual(expectedFilter, mock.filter) {
+		t.Errorf("Expected filter %v, got %v", expectedFilter, mock.filter)
+	}
+	if len(response.NfInstances) != 1 || response.NfInstances[0].NfInstanceId != "a" {
+		t.Errorf("Expected full profile of instance a, got %+v", response.NfInstances)
+	}
+}
+
+func TestBuildNrfInfo(t *testing.T) {
+	mock := &ListMockMongoDBClient{
+		profiles: []map[string]interface{}{
+			{"nfInstanceId": "amf-1", "nfType": "AMF", "nfStatus": "REGISTERED", "amfInfo": map[string]interface{}{"amfSetId": "1"}},
+			{"nfInstanceId": "smf-1", "nfType": "SMF", "nfStatus": "REGISTERED", "smfInfo": map[string]interface{}{"pgwFqdn": "pgw"}},
+			{"nfInstanceId": "nssf-1", "nfType": "NSSF", "nfStatus": "REGISTERED"},
+		},
+	}
+	p := newTestProducer(t, mock)
+
+	nrfInfo, err := p.BuildNrfInfo(context.Background())
+	if err != nil {
+		t.Fatalf("BuildNrfInfo failed: %v", err)
+	}
+	expectedFilter := bson.M{"nfType": bson.M{"$ne": "NRF"}}
+	if !reflect.DeepEqual(expectedFilter, mock.filter) {
+		t.Errorf("Expected filter %v, got %v", expectedFilter, mock.filter)
+	}
+	if nrfInfo.ServedAmfInfo["amf-1"].AmfSetId != "1" {
+		t.Errorf("Expected AMF info of amf-1, got %+v", nrfInfo.ServedAmfInfo)
+	}
+	if nrfInfo.ServedSmfInfo["smf-1"].PgwFqdn != "pgw" {
+		t.Errorf("Expected SMF info of smf-1, got %+v", nrfInfo.ServedSmfInfo)
+	}
+	if nrfInfo.ServedUdrInfo != nil || len(nrfInfo.ServedAmfInfo) != 1 {
+		t.Errorf("Unexpected NRF info %+v", nrfInfo)
+	}
+}
+
+func TestNFDiscoveryProcedureWithNrfProfile(t *testing.T) {
+	p := newTestProducer(t, &ListMockMongoDBClient{
+		profiles: []map[string]interface{}{
+			{"nfInstanceId": "nrf-1", "nfType": "NRF", "nfStatus": "REGISTERED"},
+			{"nfInstanceId": "nrf-2", "nfType": "NRF", "nfStatus": "REGISTERED"},
+		},
+	})
+
+	query := url.Values{
+		"target-nf-type":    []string{"NRF"},
+		"requester-nf-type": []string{"AMF"},
+	}
+	response, problemDetails := p.NFDiscoveryProcedure(context.Background(), query)
+	if problemDetails != nil {
+		t.Fatalf("Unexpected error: %+v", problemDetails)
+	}
+	if len(response.NfInstances) != 2 {
+		t.Errorf("Expected the NRF profiles, got %+v", response.NfInstances)
+	}
+}
+
+func TestNFDiscoveryProcedureHnrfUri(t *testing.T) {
+	p := newTestProducer(t, &MockMongoDBClient{})
+	p.Config.Configuration.DiscoveryForwarding = &factory.DiscoveryForwarding{Rules: []factory.ForwardingRule{
+		{Name: "visited", HnrfUri: true, HnrfHosts: []string{"nrf.home"}},
+	}}
+	var err error
+	if p.Forwarder, err = forwarding.New(p.NRFContext); err != nil {
+		t.Fatalf("failed to create the forwarder: %v", err)
+	}
+
+	query := url.Values{
+		"target-nf-type":    []string{"AUSF"},
+		"requester-nf-type": []string{"AMF"},
+		"hnrf-uri":          []string{"http://169.254.169.254:29510"},
+	}
+	_, problemDetails := p.NFDiscoveryProcedure(context.Background(), query)
+	if problemDetails == nil || problemDetails.Status != http.StatusBadRequest ||
+		problemDetails.Cause != "INVALID_QUERY_PARAM" || len(problemDetails.InvalidParams) != 1 ||
+		problemDetails.InvalidParams[0].Param != "hnrf-uri" {
+		t.Fatalf("Expected the hnrf-uri to be rejected, got %+v", problemDetails)
+	}
+}
+
+func TestNFDiscoveryProcedureInterPlmn(t *testing.T) {
+	mock := &ListMockMongoDBClient{
+		profiles: []map[string]interface{}{
+			{
+				"nfInstanceId": "udm-1", "nfType": "UDM", "nfStatus": "REGISTERED",
+				"fqdn": "udm.internal", "interPlmnFqdn": "udm.5gc.mnc001.mcc208.3gppnetwork.org",
+				"ipv4Addresses": []interface{}{"10.0.0.1"},
+				"nfServices": []interface{}{
+					map[string]interface{}{
+						"serviceInstanceId": "0", "serviceName": "nudm-sdm", "fqdn": "udm.internal",
+						"ipEndPoints": []interface{}{map[string]interface{}{"ipv4Address": "10.0.0.1", "port": 80}},
+					},
+				},
+			},
+			{"nfInstanceId": "udm-2", "nfType": "UDM", "nfStatus": "REGISTERED", "fqdn": "udm-2.internal"},
+		},
+	}
+	p := newTestProducer(t, mock)
+	p.FetchPlmnConfig = func(context.Context) ([]models.PlmnId, error) {
+		return []models.PlmnId{{Mcc: "208", Mnc: "01"}}, nil
+	}
+
+	testCases := []struct {
+		name          string
+		requesterPlmn string
+		interPlmn     bool
+	}{
+		{name: "Serving PLMN", requesterPlmn: `[{"mcc":"208","mnc":"01"}]`},
+		{name: "Other PLMN", requesterPlmn: `{"mcc":"001","mnc":"01"}`, interPlmn: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query := url.Values{
+				"target-nf-type":      []string{"UDM"},
+				"requester-nf-type":   []string{"AMF"},
+				"requester-plmn-list": []string{tc.requesterPlmn},
+			}
+			response, problemDetails := p.NFDiscoveryProcedure(context.Background(), query)
+			if problemDetails != nil {
+				t.Fatalf("Unexpected error: %+v", problemDetails)
+			}
+			if !strings.Contains(mustJSON(t, mock.filter), `"allowedPlmns":{"$exists":false}`) {
+				t.Errorf("Expected the filter to match the allowedPlmns, got %v", mock.filter)
+			}
+			if !tc.interPlmn {
+				if len(response.NfInstances) != 2 || response.NfInstances[0].Fqdn != "udm.internal" {
+					t.Errorf("Expected the profiles as registered, got %+v", response.NfInstances)
+				}
+				return
+			}
+			if len(response.NfInstances) != 1 {
+				t.Fatalf("Expected the profile with an inter-PLMN FQDN only, got %+v", response.NfInstances)
+			}
+			profile := response.NfInstances[0]
+			if profile.Fqdn != "udm.5gc.mnc001.mcc208.3gppnetwork.org" || profile.Ipv4Addresses != nil {
+				t.Errorf("Expected the profile to be addressed by its inter-PLMN FQDN, got %+v", profile)
+			}
+			service := (*profile.NfServices)[0]
+			if service.Fqdn != profile.Fqdn || service.IpEndPoints != nil {
+				t.Errorf("Expected the service to be addressed by the inter-PLMN FQDN, got %+v", service)
+			}
+		})
+	}
+}
+
+func mustJSON(t *testing.T, value interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatalf("failed to marshal %v: %v", value, err)
+	}
+	return string(data)
+}
@@ -0,0 +1,170 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package producer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"sort"
+
+	"github.com/omec-project/openapi/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const nfServiceSetIdListField = "nfServiceSetIdList"
+
+// UnmarshalJSON decodes the NF profile of a registration, and the
+// nfServiceSetIdList of its services, from nfServices or nfServiceList
+func (r *NfProfileRegistration) UnmarshalJSON(data []byte) error {
+	type registration NfProfileRegistration
+	var decoded registration
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	var services struct {
+		NfServices []struct {
+			ServiceInstanceId  string   `json:"serviceInstanceId"`
+			NfServiceSetIdList []string `json:"nfServiceSetIdList"`
+		} `json:"nfServices"`
+		NfServiceList map[string]struct {
+			NfServiceSetIdList []string `json:"nfServiceSetIdList"`
+		} `json:"nfServiceList"`
+	}
+	if err := json.Unmarshal(data, &services); err != nil {
+		return err
+	}
+	decoded.NfServiceSetIdLists = nil
+	setIdList := func(serviceInstanceId string, nfServiceSetIdList []string) {
+		if len(nfServiceSetIdList) == 0 {
+			return
+		}
+		if decoded.NfServiceSetIdLists == nil {
+			decoded.NfServiceSetIdLists = map[string][]string{}
+		}
+		decoded.NfServiceSetIdLists[serviceInstanceId] = nfServiceSetIdList
+	}
+	for _, service := range services.NfServices {
+		setIdList(service.ServiceInstanceId, service.NfServiceSetIdList)
+	}
+	for serviceInstanceId, service := range services.NfServiceList {
+		setIdList(serviceInstanceId, service.NfServiceSetIdList)
+	}
+	*r = NfProfileRegistration(decoded)
+	return nil
+}
+
+// setServiceSetIdLists sets the nfServiceSetIdList of the services of the
+// stored profile, in nfServices and nfServiceList
+func setServiceSetIdLists(profile map[string]interface{}, nfServiceSetIdLists map[string][]string) {
+	if len(nfServiceSetIdLists) == 0 {
+		return
+	}
+	services, _ := profile["nfServices"].([]interface{})
+	for _, service := range services {
+		if service, ok := service.(map[string]interface{}); ok {
+			if list, ok := nfServiceSetIdLists[fmt.Sprint(service["serviceInstanceId"])]; ok {
+				service[nfServiceSetIdListField] = list
+			}
+		}
+	}
+	serviceList, _ := profile["nfServiceList"].(map[string]interface{})
+	for serviceInstanceId, service := range serviceList {
+		if service, ok := service.(map[string]interface{}); ok {
+			if list, ok := nfServiceSetIdLists[serviceInstanceId]; ok {
+				service[nfServiceSetIdListField] = list
+			}
+		}
+	}
+}
+
+// nfServiceSetIds returns the NF service sets of the services of the stored
+// profile. The services are encoded first, as those read from the storage
+// are BSON arrays and documents.
+func nfServiceSetIds(profile map[string]interface{}) []string {
+	var services []struct {
+		NfServiceSetIdList []string `json:"nfServiceSetIdList"`
+	}
+	raw, err := json.Marshal(profile["nfServices"])
+	if err != nil || json.Unmarshal(raw, &services) != nil {
+		return nil
+	}
+	var nfServiceSetIds []string
+	for _, service := range services {
+		for _, nfServiceSetId := range service.NfServiceSetIdList {
+			if !slices.Contains(nfServiceSetIds, nfServiceSetId) {
+				nfServiceSetIds = append(nfServiceSetIds, nfServiceSetId)
+			}
+		}
+	}
+	return nfServiceSetIds
+}
+
+// notificationUris returns the notification URIs of the subscriptions
+// matching the stored profile, nfProfile once decoded, including those to
+// the NF service sets of its services
+func (p *Producer) notificationUris(ctx context.Context, nfProfile models.NfProfile, profile map[string]interface{}) []string {
+	uriList := p.GetNotificationUri(ctx, nfProfile)
+	return append(uriList, p.GetServiceSetNotificationUri(ctx, nfServiceSetIds(profile))...)
+}
+
+func isRegistered(profile map[string]interface{}) bool {
+	return profile["nfStatus"] == string(models.NfStatus_REGISTERED)
+}
+
+// nfSetFailover returns, when the NF instance of target-nf-instance-id is not
+// found or not REGISTERED, the REGISTERED members of its NF sets matching the
+// other query parameters, so that the requester fails over within the set.
+// The NF sets are those of target-nf-set-id, or else those the instance
+// registered. nfProfilesRaw is returned otherwise.
+func (p *Producer) nfSetFailover(ctx context.Context, queryParameters url.Values, nfProfilesRaw []map[string]interface{}) (
+	[]map[string]interface{}, error,
+) {
+	nfInstanceId := queryParameters.Get("target-nf-instance-id")
+	if nfInstanceId == "" || slices.ContainsFunc(nfProfilesRaw, isRegistered) {
+		return nfProfilesRaw, nil
+	}
+	nfSetIds := queryParameters["target-nf-set-id"]
+	if len(nfSetIds) == 0 {
+		profile, err := p.DB.RestfulAPIGetOne(ctx, "NfProfile", bson.M{"nfInstanceId": nfInstanceId})
+		if err != nil {
+			return nil, err
+		}
+		nfSetIds = stringList(profile["nfSetIdList"])
+	}
+	if len(nfSetIds) == 0 {
+		return nfProfilesRaw, nil
+	}
+
+	memberQuery := maps.Clone(queryParameters)
+	memberQuery.Del("target-nf-instance-id")
+	memberQuery.Del("target-nf-set-id")
+	filter := p.buildFilter(memberQuery)
+	filter["$and"] = append(filter["$and"].([]bson.M),
+		bson.M{"nfSetIdList": bson.M{"$in": nfSetIds}},
+		bson.M{"nfStatus": string(models.NfStatus_REGISTERED)},
+	)
+	members, err := p.DB.RestfulAPIGetMany(ctx, "NfProfile", filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return nfProfilesRaw, nil
+	}
+	p.Log.DiscoveryLog.Infof("NF instance %s is not available, answering %d members of its NF sets %v",
+		nfInstanceId, len(members), nfSetIds)
+	return members, nil
+}
+
+// preferRegistered orders the REGISTERED profiles first, so that the
+// requester of an NF set or NF service set picks a live member
+func preferRegistered(nfProfiles []models.NfProfile) {
+	sort.SliceStable(nfProfiles, func(i, j int) bool {
+		return nfProfiles[i].NfStatus == models.NfStatus_REGISTERED && nfProfiles[j].NfStatus != models.NfStatus_REGISTERED
+	})
+}
@@ -0,0 +1,246 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package producer_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/omec-project/nrf/producer"
+	"github.com/omec-project/openapi/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SetMockMongoDBClient stores documents as SharedDataMockMongoDBClient, and
+// subscribes nfSetUri to the NF sets and serviceSetUri to the NF service sets
+type SetMockMongoDBClient struct {
+	SharedDataMockMongoDBClient
+	nfSetUri      string
+	serviceSetUri string
+}
+
+func (db *SetMockMongoDBClient) RestfulAPIGetMany(ctx context.Context, collName string, filter bson.M) ([]map[string]interface{}, error) {
+	if collName != "Subscriptions" {
+		return db.SharedDataMockMongoDBClient.RestfulAPIGetMany(ctx, collName, filter)
+	}
+	raw, _ := json.Marshal(filter)
+	switch {
+	case strings.Contains(string(raw), `{"subscrCond":{"nfSetId":"set1.smfset.5gc.mnc01.mcc208"}}`):
+		return []map[string]interface{}{{"subscriptionId": "1", "nfStatusNotificationUri": db.nfSetUri}}, nil
+	case strings.Contains(string(raw), `"subscrCond.nfServiceSetId":{"$in":["set1.sn1.nsmf-pdusession.smfset.5gc.mnc01.mcc208"]}`):
+		return []map[string]interface{}{{"subscriptionId": "2", "nfStatusNotificationUri": db.serviceSetUri}}, nil
+	}
+	return nil, nil
+}
+
+func TestNFRegisterProcedureSets(t *testing.T) {
+	notifications := make(chan string, 2)
+	newSetSubscriber := func(name string) string {
+		subscriber := newSubscriber(func(w http.ResponseWriter, r *http.Request) {
+			notifications <- name
+			w.WriteHeader(http.StatusNoContent)
+		})
+		t.Cleanup(subscriber.Close)
+		return subscriber.URL
+	}
+	db := &SetMockMongoDBClient{
+		SharedDataMockMongoDBClient: SharedDataMockMongoDBClient{
+			collections: map[string]map[string]map[string]interface{}{},
+		},
+		nfSetUri:      newSetSubscriber("nfSet"),
+		serviceSetUri: newSetSubscriber("serviceSet"),
+	}
+	p := newTestProducer(t, db)
+	p.FetchPlmnConfig = func(context.Context) ([]models.PlmnId, error) {
+		return []models.PlmnId{{Mcc: "208", Mnc: "01"}}, nil
+	}
+
+	var registration producer.NfProfileRegistration
+	err := json.Unmarshal([]byte(`{
+		"nfInstanceId": "smf-1", "nfType": "SMF", "nfStatus": "REGISTERED",
+		"nfSetIdList": ["set1.smfset.5gc.mnc01.mcc208"],
+		"nfServices": [{
+			"serviceInstanceId": "0", "serviceName": "nsmf-pdusession", "scheme": "http",
+			"nfServiceStatus": "REGISTERED",
+			"nfServiceSetIdList": ["set1.sn1.nsmf-pdusession.smfset.5gc.mnc01.mcc208"]
+		}]
+	}`), &registration)
+	if err != nil {
+		t.Fatalf("unexpected decoding failure: %v", err)
+	}
+	if _, _, problemDetails := p.NFRegisterProcedure(context.Background(), registration); problemDetails != nil {
+		t.Fatalf("unexpected registration failure: %+v", problemDetails)
+	}
+
+	stored := db.document("NfProfile", "smf-1")
+	if fmt.Sprint(stored["nfSetIdList"]) != "[set1.smfset.5gc.mnc01.mcc208]" {
+		t.Errorf("expected the NF set to be stored, got %v", stored["nfSetIdList"])
+	}
+	service := stored["nfServices"].([]interface{})[0].(map[string]interface{})
+	if fmt.Sprint(service["nfServiceSetIdList"]) != "[set1.sn1.nsmf-pdusession.smfset.5gc.mnc01.mcc208]" {
+		t.Errorf("expected the NF service set to be stored, got %v", service)
+	}
+
+	// the subscribers to the NF set and to the NF service set are notified
+	received := map[string]bool{<-notifications: true, <-notifications: true}
+	if !received["nfSet"] || !received["serviceSet"] {
+		t.Errorf("expected the NF set and NF service set subscribers to be notified, got %v", received)
+	}
+}
+
+// FailoverMockMongoDBClient answers smf-1, which is SUSPENDED, to the queries
+// of its NF instance, and the other members of its NF set to the others. The
+// profiles are answered as the storage decodes them when decoded is set.
+type FailoverMockMongoDBClient struct {
+	MockMongoDBClient
+	filters []string
+	decoded bool
+}
+
+var failoverProfiles = []map[string]interface{}{
+	{"nfInstanceId": "smf-1", "nfType": "SMF", "nfStatus": "SUSPENDED", "nfSetIdList": []interface{}{"set1"}},
+	{"nfInstanceId": "smf-2", "nfType": "SMF", "nfStatus": "REGISTERED", "nfSetIdList": []interface{}{"set1"}},
+}
+
+// bsonDecoded returns profile as the storage decodes it, with its arrays as
+// primitive.A
+func bsonDecoded(profile map[string]interface{}) map[string]interface{} {
+	raw, _ := bson.Marshal(profile)
+	decoded := map[string]interface{}{}
+	_ = bson.Unmarshal(raw, &decoded)
+	return decoded
+}
+
+func (db *FailoverMockMongoDBClient) answer(profiles []map[string]interface{}) []map[string]interface{} {
+	if !db.decoded {
+		return profiles
+	}
+	answered := make([]map[string]interface{}, 0, len(profiles))
+	for _, profile := range profiles {
+		answered = append(answered, bsonDecoded(profile))
+	}
+	return answered
+}
+
+func (db *FailoverMockMongoDBClient) RestfulAPIGetOne(ctx context.Context, collName string, filter bson.M) (map[string]interface{}, error) {
+	return db.answer(failoverProfiles[:1])[0], nil
+}
+
+func (db *FailoverMockMongoDBClient) RestfulAPIGetMany(ctx context.Context, collName string, filter bson.M) ([]map[string]interface{}, error) {
+	raw, _ := json.Marshal(filter)
+	db.filters = append(db.filters, string(raw))
+	switch {
+	case strings.Contains(string(raw), `"nfInstanceId":"smf-1"`):
+		return db.answer(failoverProfiles[:1]), nil
+	case strings.Contains(string(raw), `"nfSetIdList":{"$in":["set1"]}`):
+		return db.answer(failoverProfiles[1:]), nil
+	}
+	return db.answer(failoverProfiles), nil
+}
+
+func TestNFDiscoveryProcedureSets(t *testing.T) {
+	testCases := []struct {
+		name      string
+		query     url.Values
+		instances []string
+		filter    string
+	}{
+		{
+			name:      "Instance down",
+			query:     url.Values{"target-nf-instance-id": []string{"smf-1"}},
+			instances: []string{"smf-2"},
+			filter:    `"nfStatus":"REGISTERED"`,
+		},
+		{
+			name:      "NF set",
+			query:     url.Values{"target-nf-set-id": []string{"set1"}},
+			instances: []string{"smf-2", "smf-1"},
+			filter:    `{"nfSetIdList":"set1"}`,
+		},
+		{
+			name:      "NF service set",
+			query:     url.Values{"target-nf-service-set-id": []string{"set1.sn1"}},
+			instances: []string{"smf-2", "smf-1"},
+			filter:    `{"nfServices":{"$elemMatch":{"nfServiceSetIdList":"set1.sn1"}}}`,
+		},
+	}
+	for _, tc := range testCases {
+		for _, decoded := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/decoded=%v", tc.name, decoded), func(t *testing.T) {
+				db := &FailoverMockMongoDBClient{decoded: decoded}
+				p := newTestProducer(t, db)
+				tc.query["target-nf-type"] = []string{"SMF"}
+				tc.query["requester-nf-type"] = []string{"AMF"}
+				response, problemDetails := p.NFDiscoveryProcedure(context.Background(), tc.query)
+				if problemDetails != nil {
+					t.Fatalf("unexpected error: %+v", problemDetails)
+				}
+				var instances []string
+				for _, profile := range response.NfInstances {
+					instances = append(instances, profile.NfInstanceId)
+				}
+				if fmt.Sprint(instances) != fmt.Sprint(tc.instances) {
+					t.Errorf("expected %v, got %v", tc.instances, instances)
+				}
+				if !strings.Contains(db.filters[len(db.filters)-1], tc.filter) {
+					t.Errorf("expected the filter to contain %s, got %v", tc.filter, db.filters)
+				}
+			})
+		}
+	}
+}
+
+func TestNFDeregisterProcedureSetsOfDecodedProfile(t *testing.T) {
+	if _, ok := bsonDecoded(failoverProfiles[0])["nfSetIdList"].(primitive.A); !ok {
+		t.Fatal("expected the arrays of the decoded profile to be primitive.A")
+	}
+	notifications := make(chan string, 2)
+	newSetSubscriber := func(name string) string {
+		subscriber := newSubscriber(func(w http.ResponseWriter, r *http.Request) {
+			notifications <- name
+			w.WriteHeader(http.StatusNoContent)
+		})
+		t.Cleanup(subscriber.Close)
+		return subscriber.URL
+	}
+	db := &SetMockMongoDBClient{
+		SharedDataMockMongoDBClient: SharedDataMockMongoDBClient{
+			collections: map[string]map[string]map[string]interface{}{
+				"NfProfile": {"smf-1": bsonDecoded(map[string]interface{}{
+					"nfInstanceId": "smf-1", "nfType": "SMF", "nfStatus": "REGISTERED",
+					"nfSetIdList": []string{"set1.smfset.5gc.mnc01.mcc208"},
+					"nfServices": []map[string]interface{}{{
+						"serviceInstanceId": "0", "serviceName": "nsmf-pdusession", "scheme": "http",
+						"nfServiceStatus":    "REGISTERED",
+						"nfServiceSetIdList": []string{"set1.sn1.nsmf-pdusession.smfset.5gc.mnc01.mcc208"},
+					}},
+				})},
+			},
+		},
+		nfSetUri:      newSetSubscriber("nfSet"),
+		serviceSetUri: newSetSubscriber("serviceSet"),
+	}
+	p := newTestProducer(t, db)
+
+	if _, problemDetails := p.NFDeregisterProcedure(context.Background(), "smf-1"); problemDetails != nil {
+		t.Fatalf("unexpected deregistration failure: %+v", problemDetails)
+	}
+	received := map[string]bool{}
+	for len(received) < 2 {
+		select {
+		case name := <-notifications:
+			received[name] = true
+		case <-time.After(time.Second):
+			t.Fatalf("expected the NF set and NF service set subscribers to be notified, got %v", received)
+		}
+	}
+}
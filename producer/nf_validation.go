@@ -0,0 +1,84 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package producer
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/omec-project/openapi/models"
+)
+
+// nfTypes are the NF types an NF profile may have, TS 29.510 6.1.6.3.3
+var nfTypes = map[models.NfType]bool{
+	models.NfType_NRF: true, models.NfType_UDM: true, models.NfType_AMF: true, models.NfType_SMF: true,
+	models.NfType_AUSF: true, models.NfType_NEF: true, models.NfType_PCF: true, models.NfType_SMSF: true,
+	models.NfType_NSSF: true, models.NfType_UDR: true, models.NfType_LMF: true, models.NfType_GMLC: true,
+	models.NfType__5_G_EIR: true, models.NfType_SEPP: true, models.NfType_UPF: true, models.NfType_N3_IWF: true,
+	models.NfType_AF: true, models.NfType_UDSF: true, models.NfType_BSF: true, models.NfType_CHF: true,
+	models.NfType_NWDAF: true,
+}
+
+// nfProfileInvalidParams returns the attributes of an NF profile which are
+// missing or out of their range, TS 29.510 6.1.6.2.2. It is the validation
+// of the registrations and of the patched NF profiles.
+func nfProfileInvalidParams(nfProfile models.NfProfile) []models.InvalidParam {
+	var invalidParams []models.InvalidParam
+	invalid := func(param, reason string) {
+		invalidParams = append(invalidParams, models.InvalidParam{Param: param, Reason: reason})
+	}
+	if nfProfile.NfInstanceId == "" {
+		invalid("nfInstanceId", "is required")
+	}
+	switch {
+	case nfProfile.NfType == "":
+		invalid("nfType", "is required")
+	case !nfTypes[nfProfile.NfType]:
+		invalid("nfType", "unknown NF type "+string(nfProfile.NfType))
+	}
+	switch nfProfile.NfStatus {
+	case models.NfStatus_REGISTERED, models.NfStatus_SUSPENDED, models.NfStatus_UNDISCOVERABLE:
+	case "":
+		invalid("nfStatus", "is required")
+	default:
+		invalid("nfStatus", "unknown NF status "+string(nfProfile.NfStatus))
+	}
+	if nfProfile.HeartBeatTimer < 0 {
+		invalid("heartBeatTimer", "cannot be negative")
+	}
+	if nfProfile.Priority < 0 || nfProfile.Priority > 65535 {
+		invalid("priority", "must be between 0 and 65535")
+	}
+	if nfProfile.Capacity < 0 || nfProfile.Capacity > 65535 {
+		invalid("capacity", "must be between 0 and 65535")
+	}
+	if nfProfile.Load < 0 || nfProfile.Load > 100 {
+		invalid("load", "must be between 0 and 100")
+	}
+	for i, address := range nfProfile.Ipv4Addresses {
+		if ip := net.ParseIP(address); ip == nil || ip.To4() == nil {
+			invalid(fmt.Sprintf("ipv4Addresses[%d]", i), "invalid IPv4 address "+address)
+		}
+	}
+	for i, address := range nfProfile.Ipv6Addresses {
+		if ip := net.ParseIP(address); ip == nil || ip.To4() != nil {
+			invalid(fmt.Sprintf("ipv6Addresses[%d]", i), "invalid IPv6 address "+address)
+		}
+	}
+	return invalidParams
+}
+
+// invalidNfProfileProblem returns the 400 ProblemDetails of an NF profile
+// with invalid attributes
+func invalidNfProfileProblem(title string, invalidParams []models.InvalidParam) *models.ProblemDetails {
+	return &models.ProblemDetails{
+		Title:         title,
+		Status:        http.StatusBadRequest,
+		Cause:         "MANDATORY_IE_INCORRECT",
+		Detail:        invalidParams[0].Param + " " + invalidParams[0].Reason,
+		InvalidParams: invalidParams,
+	}
+}
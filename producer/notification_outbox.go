@@ -0,0 +1,200 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package producer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/omec-project/nrf/dbadapter"
+	"github.com/omec-project/openapi/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// The NF status notifications are not sent by the procedures changing the
+// registry: each of them is recorded in the notification outbox, in the
+// transaction of the change. A committed change is so notified exactly once
+// to each subscriber, and an aborted one never. The notifications are then
+// delivered at least once: right after the commit, and again by
+// RunNotificationOutbox until the subscriber accepts them.
+
+const (
+	// notificationOutboxCollName is the collection of the notifications to
+	// deliver
+	notificationOutboxCollName = "NotificationOutbox"
+	// notificationOutboxInterval is the time between two deliveries of the
+	// pending notifications
+	notificationOutboxInterval = 5 * time.Second
+	// notificationOutboxBatch bounds the notifications delivered at a time
+	notificationOutboxBatch = 100
+	// notificationMaxAttempts bounds the attempts to deliver a notification,
+	// after which it is dropped
+	notificationMaxAttempts = 10
+	// notificationClaimDuration is the time a notification is claimed for by
+	// the NRF instance delivering it, after which another one may deliver it
+	notificationClaimDuration = 30 * time.Second
+	// notificationDeliveryTimeout bounds the delivery of a notification, so
+	// that it ends while the notification is still claimed
+	notificationDeliveryTimeout = 10 * time.Second
+)
+
+// notificationRetryBackoff is the delay between two attempts to deliver a
+// notification
+var notificationRetryBackoff = dbadapter.Backoff{Initial: time.Second, Max: 5 * time.Minute}
+
+// outboxNotification is a notification of the outbox
+type outboxNotification struct {
+	id              string
+	event           models.NotificationEventType
+	nfInstanceUri   string
+	notificationUri string
+	attempts        int
+	nextAttemptAt   time.Time
+}
+
+func parseOutboxNotification(document map[string]interface{}) outboxNotification {
+	notification := outboxNotification{
+		id:              fmt.Sprint(document["outboxId"]),
+		event:           models.NotificationEventType(fmt.Sprint(document["event"])),
+		nfInstanceUri:   fmt.Sprint(document["nfInstanceUri"]),
+		notificationUri: fmt.Sprint(document["notificationUri"]),
+	}
+	switch attempts := document["attempts"].(type) {
+	case int32:
+		notification.attempts = int(attempts)
+	case int64:
+		notification.attempts = int(attempts)
+	case float64:
+		notification.attempts = int(attempts)
+	case int:
+		notification.attempts = attempts
+	}
+	notification.nextAttemptAt = dbadapter.ToTime(document["nextAttemptAt"])
+	return notification
+}
+
+// enqueueNotifications records the notification of event on the NF instance
+// at nfInstanceUri to each of notificationUris. It is to be called in the
+// transaction of the change notified, and returns the ids of the
+// notifications.
+func (p *Producer) enqueueNotifications(ctx context.Context, event models.NotificationEventType, nfInstanceUri string,
+	notificationUris []string,
+) ([]string, error) {
+	ids := make([]string, 0, len(notificationUris))
+	now := time.Now()
+	for _, notificationUri := range notificationUris {
+		id := uuid.New().String()
+		notification := bson.M{
+			"outboxId":        id,
+			"event":           string(event),
+			"nfInstanceUri":   nfInstanceUri,
+			"notificationUri": notificationUri,
+			"createdAt":       now,
+			"attempts":        0,
+			"nextAttemptAt":   now,
+		}
+		if _, err := p.DB.RestfulAPIPutOne(ctx, notificationOutboxCollName, bson.M{"outboxId": id}, notification); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// claimNotification claims a due notification by postponing its next
+// attempt, so that no other NRF instance delivers it meanwhile. The claim is
+// one conditional update, which a single NRF instance makes even without
+// transaction. It returns whether the notification was claimed.
+func (p *Producer) claimNotification(ctx context.Context, notification *outboxNotification) (bool, error) {
+	now := time.Now()
+	if notification.nextAttemptAt.After(now) {
+		return false, nil
+	}
+	filter := bson.M{"outboxId": notification.id, "nextAttemptAt": bson.M{"$lte": now}}
+	update := bson.M{
+		"attempts":      notification.attempts + 1,
+		"nextAttemptAt": now.Add(notificationClaimDuration),
+	}
+	claimed, err := p.DB.RestfulAPIPutOneIf(ctx, notificationOutboxCollName, filter, update, false)
+	if err != nil || !claimed {
+		return false, err
+	}
+	notification.attempts++
+	return true, nil
+}
+
+// deliverNotifications sends the due notifications matching filter, claiming
+// each of them before its delivery. The ones accepted by their subscriber, or
+// failed too many times, are removed from the outbox, the others are
+// attempted again later.
+func (p *Producer) deliverNotifications(ctx context.Context, filter bson.M) {
+	documents, err := p.DB.RestfulAPIGetMany(ctx, notificationOutboxCollName, filter)
+	if err != nil {
+		p.Log.ManagementLog.Warnln("failed to read the notifications of the outbox:", err)
+		return
+	}
+	delivered := 0
+	for _, document := range documents {
+		if delivered == notificationOutboxBatch {
+			return
+		}
+		notification := parseOutboxNotification(document)
+		claimed, err := p.claimNotification(ctx, &notification)
+		if err != nil {
+			p.Log.ManagementLog.Warnf("failed to claim notification %s of the outbox: %v", notification.id, err)
+			return
+		}
+		if !claimed {
+			continue
+		}
+		delivered++
+		deliveryCtx, cancel := context.WithTimeout(ctx, notificationDeliveryTimeout)
+		problemDetails := p.SendNFStatusNotify(deliveryCtx, notification.event, notification.nfInstanceUri, notification.notificationUri)
+		cancel()
+		if problemDetails != nil {
+			if notification.attempts < notificationMaxAttempts {
+				p.Log.ManagementLog.Infof("status notification to %s failed, attempt %d: %v", notification.notificationUri,
+					notification.attempts, problemDetails)
+				retry := bson.M{"nextAttemptAt": time.Now().Add(notificationRetryBackoff.Delay(notification.attempts))}
+				if _, err = p.DB.RestfulAPIPutOneIf(ctx, notificationOutboxCollName, bson.M{"outboxId": notification.id}, retry, false); err != nil {
+					p.Log.ManagementLog.Warnf("failed to postpone notification %s of the outbox: %v", notification.id, err)
+				}
+				continue
+			}
+			p.Log.ManagementLog.Warnf("status notification to %s dropped after %d attempts: %v", notification.notificationUri,
+				notification.attempts, problemDetails)
+		}
+		if err = p.DB.RestfulAPIDeleteOne(ctx, notificationOutboxCollName, bson.M{"outboxId": notification.id}); err != nil {
+			p.Log.ManagementLog.Warnf("failed to remove notification %s from the outbox: %v", notification.id, err)
+		}
+	}
+}
+
+// deliverEnqueuedNotifications sends the notifications enqueued by a
+// committed change. It is done on behalf of the request, but does not fail it.
+func (p *Producer) deliverEnqueuedNotifications(ctx context.Context, ids []string) {
+	if len(ids) == 0 {
+		return
+	}
+	p.deliverNotifications(context.WithoutCancel(ctx), bson.M{"outboxId": bson.M{"$in": ids}})
+}
+
+// RunNotificationOutbox delivers the notifications of the outbox which were
+// not delivered when their change was committed, e.g. because their
+// subscriber or the NRF instance was down, until stop is closed
+func (p *Producer) RunNotificationOutbox(stop <-chan struct{}) {
+	ticker := time.NewTicker(notificationOutboxInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		p.deliverNotifications(context.Background(), bson.M{"nextAttemptAt": bson.M{"$lte": time.Now()}})
+	}
+}
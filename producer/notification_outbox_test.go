@@ -0,0 +1,269 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package producer_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// newSubscriber serves handler as the notification endpoint of a subscriber,
+// over HTTP/2 without TLS as NRF notifies
+func newSubscriber(handler http.HandlerFunc) *httptest.Server {
+	return httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+}
+
+// TransactionalMockMongoDBClient stores one NF profile, a subscription to its
+// NF type and the notification outbox, and rolls them back when a
+// transaction fails. afterOutboxRead is called once the outbox is read, to
+// change it as another NRF instance would meanwhile.
+type TransactionalMockMongoDBClient struct {
+	MockMongoDBClient
+	notificationUri        string
+	failSubscriptionDelete bool
+	afterOutboxRead        func(outbox map[string]map[string]interface{})
+
+	mu      sync.Mutex
+	profile map[string]interface{}
+	outbox  map[string]map[string]interface{}
+}
+
+func newTransactionalMockMongoDBClient(notificationUri string) *TransactionalMockMongoDBClient {
+	return &TransactionalMockMongoDBClient{
+		notificationUri: notificationUri,
+		profile:         map[string]interface{}{"nfInstanceId": "amf-1", "nfType": "AMF", "nfStatus": "REGISTERED"},
+		outbox:          map[string]map[string]interface{}{},
+	}
+}
+
+func (db *TransactionalMockMongoDBClient) RunTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	db.mu.Lock()
+	profile := db.profile
+	outbox := map[string]map[string]interface{}{}
+	for id, notification := range db.outbox {
+		outbox[id] = maps.Clone(notification)
+	}
+	db.mu.Unlock()
+
+	err := fn(ctx)
+	if err != nil {
+		db.mu.Lock()
+		db.profile = profile
+		db.outbox = outbox
+		db.mu.Unlock()
+	}
+	return err
+}
+
+func (db *TransactionalMockMongoDBClient) RestfulAPIGetMany(ctx context.Context, collName string, filter bson.M) ([]map[string]interface{}, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	switch collName {
+	case "NfProfile":
+		if db.profile == nil {
+			return nil, nil
+		}
+		return []map[string]interface{}{maps.Clone(db.profile)}, nil
+	case "Subscriptions":
+		if condition, ok := filter["subscrCond"].(bson.M); ok && condition["nfType"] != nil {
+			return []map[string]interface{}{{"subscriptionId": "1", "nfStatusNotificationUri": db.notificationUri}}, nil
+		}
+		return nil, nil
+	case "NotificationOutbox":
+		var notifications []map[string]interface{}
+		for _, notification := range db.outbox {
+			notifications = append(notifications, maps.Clone(notification))
+		}
+		if db.afterOutboxRead != nil {
+			db.afterOutboxRead(db.outbox)
+		}
+		return notifications, nil
+	}
+	return nil, nil
+}
+
+func (db *TransactionalMockMongoDBClient) RestfulAPIPutOne(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if collName != "NotificationOutbox" {
+		return false, nil
+	}
+	id := fmt.Sprint(filter["outboxId"])
+	notification, existed := db.outbox[id]
+	if !existed {
+		notification = map[string]interface{}{}
+		db.outbox[id] = notification
+	}
+	maps.Copy(notification, putData)
+	return existed, nil
+}
+
+// RestfulAPIPutOneIf updates a notification of the outbox, when it is due by
+// the nextAttemptAt of filter
+func (db *TransactionalMockMongoDBClient) RestfulAPIPutOneIf(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}, upsert bool) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	notification, ok := db.outbox[fmt.Sprint(filter["outboxId"])]
+	if collName != "NotificationOutbox" || !ok {
+		return false, nil
+	}
+	if condition, ok := filter["nextAttemptAt"].(bson.M); ok {
+		if notification["nextAttemptAt"].(time.Time).After(condition["$lte"].(time.Time)) {
+			return false, nil
+		}
+	}
+	maps.Copy(notification, putData)
+	return true, nil
+}
+
+func (db *TransactionalMockMongoDBClient) RestfulAPIDeleteOne(ctx context.Context, collName string, filter bson.M) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if collName == "NotificationOutbox" {
+		delete(db.outbox, fmt.Sprint(filter["outboxId"]))
+	}
+	return nil
+}
+
+func (db *TransactionalMockMongoDBClient) RestfulAPIDeleteMany(ctx context.Context, collName string, filter bson.M) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	switch collName {
+	case "NfProfile":
+		db.profile = nil
+	case "Subscriptions":
+		if db.failSubscriptionDelete {
+			return errors.New("write conflict")
+		}
+	}
+	return nil
+}
+
+func (db *TransactionalMockMongoDBClient) outboxSize() int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return len(db.outbox)
+}
+
+func TestNFDeregisterProcedureRollsBack(t *testing.T) {
+	var notified atomic.Int32
+	subscriber := newSubscriber(func(w http.ResponseWriter, r *http.Request) {
+		notified.Add(1)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	defer subscriber.Close()
+	db := newTransactionalMockMongoDBClient(subscriber.URL)
+	db.failSubscriptionDelete = true
+	p := newTestProducer(t, db)
+
+	_, problemDetails := p.NFDeregisterProcedure(context.Background(), "amf-1")
+	if problemDetails == nil || problemDetails.Cause != "SUBSCRIPTION_DELETE_ERROR" {
+		t.Fatalf("expected the deregistration to fail with SUBSCRIPTION_DELETE_ERROR, got %+v", problemDetails)
+	}
+	if db.profile == nil {
+		t.Error("expected the NF profile removal to be rolled back")
+	}
+	if size := db.outboxSize(); size != 0 {
+		t.Errorf("expected no notification to be enqueued, got %d", size)
+	}
+	if notified.Load() != 0 {
+		t.Error("expected no notification to be sent for an aborted deregistration")
+	}
+}
+
+func TestNFDeregisterProcedureOutbox(t *testing.T) {
+	var available atomic.Bool
+	var notified atomic.Int32
+	subscriber := newSubscriber(func(w http.ResponseWriter, r *http.Request) {
+		notified.Add(1)
+		if !available.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	defer subscriber.Close()
+
+	// a notification the subscriber fails stays in the outbox
+	db := newTransactionalMockMongoDBClient(subscriber.URL)
+	p := newTestProducer(t, db)
+	nfType, problemDetails := p.NFDeregisterProcedure(context.Background(), "amf-1")
+	if problemDetails != nil {
+		t.Fatalf("unexpected deregistration failure: %+v", problemDetails)
+	}
+	if nfType != "AMF" {
+		t.Errorf("expected the NF type AMF, got %s", nfType)
+	}
+	if notified.Load() != 1 {
+		t.Errorf("expected 1 notification attempt, got %d", notified.Load())
+	}
+	if size := db.outboxSize(); size != 1 {
+		t.Fatalf("expected the failed notification to stay in the outbox, got %d", size)
+	}
+	for _, notification := range db.outbox {
+		if notification["event"] != "NF_DEREGISTERED" || fmt.Sprint(notification["attempts"]) != "1" {
+			t.Errorf("unexpected notification in the outbox: %v", notification)
+		}
+	}
+
+	// a delivered notification is removed from the outbox
+	available.Store(true)
+	db = newTransactionalMockMongoDBClient(subscriber.URL)
+	p = newTestProducer(t, db)
+	if _, problemDetails = p.NFDeregisterProcedure(context.Background(), "amf-1"); problemDetails != nil {
+		t.Fatalf("unexpected deregistration failure: %+v", problemDetails)
+	}
+	if notified.Load() != 2 {
+		t.Errorf("expected 2 notification attempts, got %d", notified.Load())
+	}
+	if size := db.outboxSize(); size != 0 {
+		t.Errorf("expected the delivered notification to be removed from the outbox, got %d", size)
+	}
+}
+
+func TestNotificationClaimedElsewhere(t *testing.T) {
+	var notified atomic.Int32
+	subscriber := newSubscriber(func(w http.ResponseWriter, r *http.Request) {
+		notified.Add(1)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	defer subscriber.Close()
+	db := newTransactionalMockMongoDBClient(subscriber.URL)
+	// another NRF instance claims the notification once it is read
+	db.afterOutboxRead = func(outbox map[string]map[string]interface{}) {
+		for _, notification := range outbox {
+			notification["attempts"] = 1
+			notification["nextAttemptAt"] = time.Now().Add(time.Minute)
+		}
+	}
+	p := newTestProducer(t, db)
+
+	if _, problemDetails := p.NFDeregisterProcedure(context.Background(), "amf-1"); problemDetails != nil {
+		t.Fatalf("unexpected deregistration failure: %+v", problemDetails)
+	}
+	if notified.Load() != 0 {
+		t.Errorf("expected the notification claimed by another NRF instance not to be sent, got %d", notified.Load())
+	}
+	if size := db.outboxSize(); size != 1 {
+		t.Fatalf("expected the notification to stay in the outbox of its claimer, got %d", size)
+	}
+	for _, notification := range db.outbox {
+		if fmt.Sprint(notification["attempts"]) != "1" {
+			t.Errorf("expected the attempt of the other NRF instance only, got %v", notification["attempts"])
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package producer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	nrfContext "github.com/omec-project/nrf/context"
+	"github.com/omec-project/nrf/dbadapter"
+	"github.com/omec-project/nrf/forwarding"
+	"github.com/omec-project/openapi/models"
+)
+
+// Producer implements the NRF SBI procedures of one NRF instance
+type Producer struct {
+	*nrfContext.NRFContext
+	// Forwarder relays the discovery queries to other NRFs, none when nil
+	Forwarder *forwarding.Forwarder
+}
+
+// New creates the producer of the NRF instance described by nrfCtx
+func New(nrfCtx *nrfContext.NRFContext) *Producer {
+	return &Producer{NRFContext: nrfCtx}
+}
+
+// storageProblemDetails describes the failure err of a storage operation.
+// The deadline of the request or the timeout of the operation expiring
+// answers 504, and the request being cancelled or the storage being down
+// 503, so that the consumer can retry on another NRF. Any other failure
+// answers 500 with cause.
+func storageProblemDetails(cause string, err error) *models.ProblemDetails {
+	problemDetails := &models.ProblemDetails{
+		Status: http.StatusInternalServerError,
+		Cause:  cause,
+		Detail: err.Error(),
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		problemDetails.Title = "Storage timeout"
+		problemDetails.Status = http.StatusGatewayTimeout
+		problemDetails.Cause = "TIMED_OUT_REQUEST"
+	case errors.Is(err, dbadapter.ErrStorageUnavailable):
+		problemDetails.Title = "Storage unavailable"
+		problemDetails.Status = http.StatusServiceUnavailable
+		problemDetails.Cause = "STORAGE_UNAVAILABLE"
+	case errors.Is(err, context.Canceled):
+		problemDetails.Title = "Request cancelled"
+		problemDetails.Status = http.StatusServiceUnavailable
+	}
+	return problemDetails
+}
+
+// stepError is the failure of a step of a procedure run in a transaction,
+// with the cause the procedure fails with
+type stepError struct {
+	cause string
+	err   error
+}
+
+func (e *stepError) Error() string {
+	return e.err.Error()
+}
+
+func (e *stepError) Unwrap() error {
+	return e.err
+}
+
+// transactionProblemDetails describes the failure err of the transaction of
+// a procedure, with the cause of its failed step
+func transactionProblemDetails(err error) *models.ProblemDetails {
+	var failed *stepError
+	if errors.As(err, &failed) {
+		return storageProblemDetails(failed.cause, err)
+	}
+	return storageProblemDetails("SYSTEM_FAILURE", err)
+}
@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package producer
+
+import (
+	"context"
+	"net/url"
+	"slices"
+
+	nrfContext "github.com/omec-project/nrf/context"
+	"github.com/omec-project/openapi/models"
+)
+
+// isInterPlmn tells whether the discovery query comes from another PLMN: its
+// requester-plmn-list holds none of the serving PLMNs. A query is taken as
+// intra-PLMN when the serving PLMNs are unknown.
+func (p *Producer) isInterPlmn(ctx context.Context, queryParameters url.Values) bool {
+	if queryParameters.Get("requester-plmn-list") == "" {
+		return false
+	}
+	requesterPlmns, err := nrfContext.ParsePlmnList(queryParameters.Get("requester-plmn-list"))
+	if err != nil || len(requesterPlmns) == 0 {
+		return false
+	}
+	servingPlmns, err := p.ServingPlmns(ctx)
+	if err != nil {
+		p.Log.DiscoveryLog.Warnf("discovery query taken as intra-PLMN: %v", err)
+		return false
+	}
+	return !slices.ContainsFunc(requesterPlmns, func(plmn models.PlmnId) bool {
+		return slices.Contains(servingPlmns, plmn)
+	})
+}
+
+// interPlmnResult returns the profiles of result as seen from another PLMN:
+// they are addressed by their inter-PLMN FQDN, and their IP addresses, which
+// are reachable within the PLMN only, are removed. The profiles without
+// inter-PLMN FQDN cannot be reached from another PLMN and are left out.
+func interPlmnResult(result *models.SearchResult) *models.SearchResult {
+	interPlmn := *result
+	interPlmn.NfInstances = []models.NfProfile{}
+	for _, profile := range result.NfInstances {
+		if profile.InterPlmnFqdn == "" {
+			continue
+		}
+		profile.Fqdn = profile.InterPlmnFqdn
+		profile.Ipv4Addresses = nil
+		profile.Ipv6Addresses = nil
+		if profile.NfServices != nil {
+			services := make([]models.NfService, len(*profile.NfServices))
+			for i, service := range *profile.NfServices {
+				if service.InterPlmnFqdn != "" {
+					service.Fqdn = service.InterPlmnFqdn
+				} else {
+					service.Fqdn = profile.InterPlmnFqdn
+				}
+				service.IpEndPoints = nil
+				services[i] = service
+			}
+			profile.NfServices = &services
+		}
+		interPlmn.NfInstances = append(interPlmn.NfInstances, profile)
+	}
+	return &interPlmn
+}
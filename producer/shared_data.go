@@ -0,0 +1,462 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package producer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/omec-project/nrf/dbadapter"
+	"github.com/omec-project/nrf/util"
+	"github.com/omec-project/openapi/models"
+	"github.com/omec-project/util/httpwrapper"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// sharedDataCollName is the collection of the shared data
+	sharedDataCollName = "SharedData"
+	// sharedDataIdListField is the NF profile attribute listing the shared
+	// data the profile refers to
+	sharedDataIdListField = "sharedDataIdList"
+	// sharedAttributesField records, in the stored NF profiles, the attributes
+	// taken from their shared data, so that they are replaced when the
+	// shared data change
+	sharedAttributesField = "sharedDataAttributes"
+)
+
+// sharedDataProtectedAttributes are the attributes which identify an NF
+// instance and cannot be shared
+var sharedDataProtectedAttributes = []string{"nfInstanceId", "nfType", "nfStatus", sharedDataIdListField}
+
+// SharedData is a fragment of NF profile shared by the NF instances listing
+// its sharedDataId in their sharedDataIdList (TS 29.510 6.1.6.2.2)
+type SharedData struct {
+	SharedDataId string `json:"sharedDataId"`
+	// SharedProfileData are the NF profile attributes shared
+	SharedProfileData map[string]interface{} `json:"sharedProfileData"`
+}
+
+// NfProfileRegistration is the NF profile of a registration, with the shared
+// data it refers to and the NF service sets of its services
+type NfProfileRegistration struct {
+	models.NfProfile
+	SharedDataIdList []string `json:"sharedDataIdList,omitempty"`
+	// NfServiceSetIdLists are the nfServiceSetIdList of the services, by
+	// serviceInstanceId, which models.NfService does not hold
+	NfServiceSetIdLists map[string][]string `json:"-"`
+}
+
+func (p *Producer) HandleGetSharedDataListRequest(ctx context.Context, request *httpwrapper.Request) *httpwrapper.Response {
+	p.Log.ManagementLog.Infoln("Handle GetSharedDataListRequest")
+
+	response, problemDetails := p.GetSharedDataListProcedure(ctx)
+	if problemDetails != nil {
+		return httpwrapper.NewResponse(int(problemDetails.Status), nil, problemDetails)
+	}
+	return httpwrapper.NewResponse(http.StatusOK, nil, response)
+}
+
+func (p *Producer) HandleGetSharedDataRequest(ctx context.Context, request *httpwrapper.Request) *httpwrapper.Response {
+	p.Log.ManagementLog.Infoln("Handle GetSharedDataRequest")
+
+	response, problemDetails := p.GetSharedDataProcedure(ctx, request.Params["sharedDataID"])
+	if problemDetails != nil {
+		return httpwrapper.NewResponse(int(problemDetails.Status), nil, problemDetails)
+	}
+	return httpwrapper.NewResponse(http.StatusOK, nil, response)
+}
+
+func (p *Producer) HandlePutSharedDataRequest(ctx context.Context, request *httpwrapper.Request) *httpwrapper.Response {
+	p.Log.ManagementLog.Infoln("Handle PutSharedDataRequest")
+	sharedData := request.Body.(SharedData)
+
+	created, problemDetails := p.PutSharedDataProcedure(ctx, request.Params["sharedDataID"], sharedData)
+	if problemDetails != nil {
+		return httpwrapper.NewResponse(int(problemDetails.Status), nil, problemDetails)
+	}
+	if created {
+		return httpwrapper.NewResponse(http.StatusCreated, nil, sharedData)
+	}
+	return httpwrapper.NewResponse(http.StatusOK, nil, sharedData)
+}
+
+func (p *Producer) HandleDeleteSharedDataRequest(ctx context.Context, request *httpwrapper.Request) *httpwrapper.Response {
+	p.Log.ManagementLog.Infoln("Handle DeleteSharedDataRequest")
+
+	problemDetails := p.DeleteSharedDataProcedure(ctx, request.Params["sharedDataID"])
+	if problemDetails != nil {
+		return httpwrapper.NewResponse(int(problemDetails.Status), nil, problemDetails)
+	}
+	return httpwrapper.NewResponse(http.StatusNoContent, nil, nil)
+}
+
+// GetSharedDataListProcedure returns all the shared data
+func (p *Producer) GetSharedDataListProcedure(ctx context.Context) ([]SharedData, *models.ProblemDetails) {
+	documents, err := p.DB.RestfulAPIGetMany(ctx, sharedDataCollName, bson.M{})
+	if err != nil {
+		p.Log.ManagementLog.Errorln("DB error in GetSharedDataListProcedure:", err)
+		return nil, storageProblemDetails("SYSTEM_FAILURE", err)
+	}
+	sharedDataList := make([]SharedData, 0, len(documents))
+	for _, document := range documents {
+		sharedDataList = append(sharedDataList, toSharedData(document))
+	}
+	return sharedDataList, nil
+}
+
+// GetSharedDataProcedure returns the shared data sharedDataID
+func (p *Producer) GetSharedDataProcedure(ctx context.Context, sharedDataID string) (*SharedData, *models.ProblemDetails) {
+	document, err := p.DB.RestfulAPIGetOne(ctx, sharedDataCollName, bson.M{"sharedDataId": sharedDataID})
+	if err != nil {
+		p.Log.ManagementLog.Errorln("DB error in GetSharedDataProcedure:", err)
+		return nil, storageProblemDetails("SYSTEM_FAILURE", err)
+	}
+	if len(document) == 0 {
+		return nil, sharedDataNotFound(sharedDataID)
+	}
+	sharedData := toSharedData(document)
+	return &sharedData, nil
+}
+
+// PutSharedDataProcedure creates or replaces the shared data sharedDataID,
+// and reports whether it was created. The NF profiles referring to it are
+// updated, and their subscribers notified of the change, in the same
+// transaction.
+func (p *Producer) PutSharedDataProcedure(ctx context.Context, sharedDataID string, sharedData SharedData) (created bool,
+	problemDetails *models.ProblemDetails,
+) {
+	if sharedData.SharedDataId == "" {
+		sharedData.SharedDataId = sharedDataID
+	}
+	if err := validateSharedData(sharedDataID, sharedData); err != nil {
+		p.Log.ManagementLog.Errorln("shared data is invalid:", err)
+		return false, &models.ProblemDetails{
+			Title:  "Invalid shared data",
+			Status: http.StatusBadRequest,
+			Cause:  "MANDATORY_IE_INCORRECT",
+			Detail: err.Error(),
+		}
+	}
+	filter := bson.M{"sharedDataId": sharedDataID}
+	putData := bson.M{"sharedDataId": sharedDataID, "sharedProfileData": sharedData.SharedProfileData}
+
+	var notificationIds []string
+	err := dbadapter.RunTransaction(ctx, p.DB, func(ctx context.Context) error {
+		existed, err := p.DB.RestfulAPIPutOne(ctx, sharedDataCollName, filter, putData)
+		if err != nil {
+			return err
+		}
+		created = !existed
+		notificationIds, err = p.refreshSharedDataProfiles(ctx, sharedDataID)
+		return err
+	})
+	if err != nil {
+		p.Log.ManagementLog.Errorln("DB error in PutSharedDataProcedure:", err)
+		return false, transactionProblemDetails(err)
+	}
+	p.RegistryChanged()
+	p.deliverEnqueuedNotifications(ctx, notificationIds)
+	return created, nil
+}
+
+// DeleteSharedDataProcedure deletes the shared data sharedDataID, which no
+// NF profile may refer to. The references are checked in the transaction of
+// the deletion, so that no profile registered meanwhile is left referring to
+// deleted shared data.
+func (p *Producer) DeleteSharedDataProcedure(ctx context.Context, sharedDataID string) *models.ProblemDetails {
+	filter := bson.M{"sharedDataId": sharedDataID}
+	var found bool
+	var references int
+	err := dbadapter.RunTransaction(ctx, p.DB, func(ctx context.Context) error {
+		document, err := p.DB.RestfulAPIGetOne(ctx, sharedDataCollName, filter)
+		if err != nil {
+			return err
+		}
+		if found = len(document) != 0; !found {
+			return nil
+		}
+		profiles, err := p.DB.RestfulAPIGetMany(ctx, "NfProfile", bson.M{sharedDataIdListField: sharedDataID})
+		if err != nil {
+			return err
+		}
+		if references = len(profiles); references != 0 {
+			return nil
+		}
+		return p.DB.RestfulAPIDeleteOne(ctx, sharedDataCollName, filter)
+	})
+	if err != nil {
+		p.Log.ManagementLog.Errorln("DB error in DeleteSharedDataProcedure:", err)
+		return transactionProblemDetails(err)
+	}
+	if !found {
+		return sharedDataNotFound(sharedDataID)
+	}
+	if references != 0 {
+		return &models.ProblemDetails{
+			Title:  "Shared data in use",
+			Status: http.StatusConflict,
+			Cause:  "SHARED_DATA_IN_USE",
+			Detail: fmt.Sprintf("shared data %s is referred to by %d NF instances", sharedDataID, references),
+		}
+	}
+	return nil
+}
+
+// refreshSharedDataProfiles applies the shared data again to the NF profiles
+// referring to sharedDataID, and enqueues the PROFILE_CHANGED notifications
+// of the profiles which changed
+func (p *Producer) refreshSharedDataProfiles(ctx context.Context, sharedDataID string) ([]string, error) {
+	profiles, err := p.DB.RestfulAPIGetMany(ctx, "NfProfile", bson.M{sharedDataIdListField: sharedDataID})
+	if err != nil {
+		return nil, err
+	}
+	var notificationIds []string
+	for _, profile := range profiles {
+		refreshed := make(map[string]interface{}, len(profile))
+		for key, value := range profile {
+			refreshed[key] = value
+		}
+		stripSharedData(refreshed)
+		fragments, err := p.getSharedData(ctx, stringList(refreshed[sharedDataIdListField]))
+		if err != nil {
+			return nil, err
+		}
+		applySharedData(refreshed, fragments)
+		if sameDocument(profile, refreshed) {
+			continue
+		}
+		nfInstanceId := fmt.Sprint(profile["nfInstanceId"])
+		if _, err = p.DB.RestfulAPIReplaceOne(ctx, "NfProfile", bson.M{"nfInstanceId": nfInstanceId}, refreshed); err != nil {
+			return nil, err
+		}
+		nfProfiles, err := util.Decode([]map[string]interface{}{refreshed}, time.RFC3339)
+		if err != nil || len(nfProfiles) == 0 {
+			return nil, fmt.Errorf("NF profile %s cannot be decoded: %v", nfInstanceId, err)
+		}
+		ids, err := p.enqueueNotifications(ctx, models.NotificationEventType_PROFILE_CHANGED,
+			p.GetNfInstanceURI(nfInstanceId), p.notificationUris(ctx, nfProfiles[0], refreshed))
+		if err != nil {
+			return nil, &stepError{cause: "NOTIFICATION_ERROR", err: err}
+		}
+		notificationIds = append(notificationIds, ids...)
+	}
+	return notificationIds, nil
+}
+
+// getSharedData returns the shared data of sharedDataIds, in their order. The
+// shared data which do not exist are left out.
+func (p *Producer) getSharedData(ctx context.Context, sharedDataIds []string) ([]SharedData, error) {
+	if len(sharedDataIds) == 0 {
+		return nil, nil
+	}
+	documents, err := p.DB.RestfulAPIGetMany(ctx, sharedDataCollName, bson.M{"sharedDataId": bson.M{"$in": sharedDataIds}})
+	if err != nil {
+		return nil, err
+	}
+	byId := make(map[string]SharedData, len(documents))
+	for _, document := range documents {
+		sharedData := toSharedData(document)
+		byId[sharedData.SharedDataId] = sharedData
+	}
+	fragments := make([]SharedData, 0, len(sharedDataIds))
+	for _, sharedDataId := range sharedDataIds {
+		if sharedData, ok := byId[sharedDataId]; ok {
+			fragments = append(fragments, sharedData)
+		}
+	}
+	return fragments, nil
+}
+
+// resolveSharedData returns the shared data of sharedDataIds, or the
+// ProblemDetails of the ones which do not exist
+func (p *Producer) resolveSharedData(ctx context.Context, sharedDataIds []string) ([]SharedData, *models.ProblemDetails) {
+	fragments, err := p.getSharedData(ctx, sharedDataIds)
+	if err != nil {
+		return nil, storageProblemDetails("SYSTEM_FAILURE", err)
+	}
+	var invalidParams []models.InvalidParam
+	for _, sharedDataId := range sharedDataIds {
+		if !slices.ContainsFunc(fragments, func(sharedData SharedData) bool { return sharedData.SharedDataId == sharedDataId }) {
+			invalidParams = append(invalidParams, models.InvalidParam{
+				Param:  sharedDataIdListField,
+				Reason: "unknown shared data " + sharedDataId,
+			})
+		}
+	}
+	if len(invalidParams) == 0 {
+		return fragments, nil
+	}
+	problemDetails := &models.ProblemDetails{
+		Title:         "Unknown shared data",
+		Status:        http.StatusBadRequest,
+		Cause:         "MANDATORY_IE_INCORRECT",
+		InvalidParams: invalidParams,
+	}
+	return nil, problemDetails
+}
+
+// applySharedData adds to profile the attributes of fragments it does not
+// have, the attributes of the profile winning over the shared ones and the
+// first fragment over the next ones. The attributes added are recorded in
+// profile.
+func applySharedData(profile map[string]interface{}, fragments []SharedData) {
+	var added []string
+	for _, sharedData := range fragments {
+		for attribute, value := range sharedData.SharedProfileData {
+			if _, ok := profile[attribute]; ok || slices.Contains(sharedDataProtectedAttributes, attribute) {
+				continue
+			}
+			profile[attribute] = value
+			added = append(added, attribute)
+		}
+	}
+	if len(added) == 0 {
+		delete(profile, sharedAttributesField)
+		return
+	}
+	slices.Sort(added)
+	profile[sharedAttributesField] = added
+}
+
+// withSharedData returns nfProfile completed with the attributes of fragments
+// it does not have, and the attributes added
+func withSharedData(nfProfile models.NfProfile, fragments []SharedData) (models.NfProfile, []string, error) {
+	raw, err := json.Marshal(nfProfile)
+	if err != nil {
+		return nfProfile, nil, err
+	}
+	profile := map[string]interface{}{}
+	if err = json.Unmarshal(raw, &profile); err != nil {
+		return nfProfile, nil, err
+	}
+	applySharedData(profile, fragments)
+	sharedAttributes := stringList(profile[sharedAttributesField])
+	delete(profile, sharedAttributesField)
+	if raw, err = json.Marshal(profile); err != nil {
+		return nfProfile, nil, err
+	}
+	var completed models.NfProfile
+	if err = json.Unmarshal(raw, &completed); err != nil {
+		return nfProfile, nil, fmt.Errorf("shared data do not fit the NF profile: %v", err)
+	}
+	return completed, sharedAttributes, nil
+}
+
+// stripSharedData removes from profile the attributes taken from its shared
+// data
+func stripSharedData(profile map[string]interface{}) {
+	for _, attribute := range stringList(profile[sharedAttributesField]) {
+		delete(profile, attribute)
+	}
+	delete(profile, sharedAttributesField)
+}
+
+// reapplySharedData applies the shared data to patched, the NF profile
+// original once patched. The shared attributes the patch changed become
+// attributes of the profile.
+func (p *Producer) reapplySharedData(ctx context.Context, original, patched map[string]interface{}) *models.ProblemDetails {
+	sharedDataIds := stringList(patched[sharedDataIdListField])
+	if len(sharedDataIds) == 0 && original[sharedAttributesField] == nil {
+		return nil
+	}
+	for _, attribute := range stringList(original[sharedAttributesField]) {
+		if sameValue(original[attribute], patched[attribute]) {
+			delete(patched, attribute)
+		}
+	}
+	delete(patched, sharedAttributesField)
+	fragments, problemDetails := p.resolveSharedData(ctx, sharedDataIds)
+	if problemDetails != nil {
+		return problemDetails
+	}
+	applySharedData(patched, fragments)
+	return nil
+}
+
+// validateSharedData checks that sharedData is a fragment of NF profile which
+// can be stored as sharedDataID
+func validateSharedData(sharedDataID string, sharedData SharedData) error {
+	if sharedDataID == "" {
+		return fmt.Errorf("sharedDataId is required")
+	}
+	if sharedData.SharedDataId != sharedDataID {
+		return fmt.Errorf("sharedDataId %s does not match the resource %s", sharedData.SharedDataId, sharedDataID)
+	}
+	if len(sharedData.SharedProfileData) == 0 {
+		return fmt.Errorf("sharedProfileData is required")
+	}
+	for _, attribute := range sharedDataProtectedAttributes {
+		if _, ok := sharedData.SharedProfileData[attribute]; ok {
+			return fmt.Errorf("%s cannot be shared", attribute)
+		}
+	}
+	raw, err := json.Marshal(sharedData.SharedProfileData)
+	if err != nil {
+		return err
+	}
+	var nfProfile models.NfProfile
+	if err = json.Unmarshal(raw, &nfProfile); err != nil {
+		return fmt.Errorf("sharedProfileData is not a valid NfProfile fragment: %v", err)
+	}
+	return nil
+}
+
+func sharedDataNotFound(sharedDataID string) *models.ProblemDetails {
+	return &models.ProblemDetails{
+		Status: http.StatusNotFound,
+		Cause:  "RESOURCE_NOT_FOUND",
+		Detail: "shared data " + sharedDataID + " not found",
+	}
+}
+
+// toSharedData converts a stored shared data document
+func toSharedData(document map[string]interface{}) SharedData {
+	sharedData := SharedData{SharedDataId: fmt.Sprint(document["sharedDataId"])}
+	raw, err := json.Marshal(document["sharedProfileData"])
+	if err == nil {
+		_ = json.Unmarshal(raw, &sharedData.SharedProfileData)
+	}
+	return sharedData
+}
+
+// stringList returns the strings of a stored array
+func stringList(value interface{}) []string {
+	var list []string
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	if err = json.Unmarshal(raw, &list); err != nil {
+		return nil
+	}
+	return list
+}
+
+// sameValue tells whether two stored values are equal once encoded, as the
+// documents read from the storage and the patched ones differ in types
+func sameValue(a, b interface{}) bool {
+	rawA, errA := json.Marshal(a)
+	rawB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(rawA, rawB)
+}
+
+// sameDocument tells whether two stored documents have the same attributes
+func sameDocument(a, b map[string]interface{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if !sameValue(value, b[key]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,352 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package producer_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/omec-project/nrf/producer"
+	"github.com/omec-project/openapi/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// SharedDataMockMongoDBClient stores documents by collection and id, and
+// subscribes notificationUri to every NF type. RestfulAPIPutOne sets the
+// fields of putData on the stored document, as $set does.
+type SharedDataMockMongoDBClient struct {
+	MockMongoDBClient
+	notificationUri string
+
+	mu          sync.Mutex
+	collections map[string]map[string]map[string]interface{}
+}
+
+var sharedDataMockIdFields = map[string]string{
+	"NfProfile":          "nfInstanceId",
+	"SharedData":         "sharedDataId",
+	"NotificationOutbox": "outboxId",
+	"Subscriptions":      "subscriptionId",
+}
+
+func (db *SharedDataMockMongoDBClient) matches(document map[string]interface{}, filter bson.M) bool {
+	for key, expected := range filter {
+		value := document[key]
+		if condition, ok := expected.(bson.M); ok {
+			if bound, ok := condition["$lte"].(time.Time); ok {
+				if at, ok := value.(time.Time); !ok || at.After(bound) {
+					return false
+				}
+				continue
+			}
+			in, _ := condition["$in"].([]string)
+			if !slices.Contains(in, fmt.Sprint(value)) {
+				return false
+			}
+			continue
+		}
+		if list, ok := value.([]string); ok {
+			if !slices.Contains(list, fmt.Sprint(expected)) {
+				return false
+			}
+			continue
+		}
+		if list, ok := value.([]interface{}); ok {
+			if !slices.Contains(list, expected) {
+				return false
+			}
+			continue
+		}
+		if fmt.Sprint(value) != fmt.Sprint(expected) {
+			return false
+		}
+	}
+	return true
+}
+
+func (db *SharedDataMockMongoDBClient) RestfulAPIGetOne(ctx context.Context, collName string, filter bson.M) (map[string]interface{}, error) {
+	documents, err := db.RestfulAPIGetMany(ctx, collName, filter)
+	if err != nil || len(documents) == 0 {
+		return nil, err
+	}
+	return documents[0], nil
+}
+
+func (db *SharedDataMockMongoDBClient) RestfulAPIGetMany(ctx context.Context, collName string, filter bson.M) ([]map[string]interface{}, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if collName == "Subscriptions" && filter["subscriptionId"] == nil {
+		if condition, ok := filter["subscrCond"].(bson.M); ok && condition["nfType"] != nil {
+			return []map[string]interface{}{{"subscriptionId": "1", "nfStatusNotificationUri": db.notificationUri}}, nil
+		}
+		return nil, nil
+	}
+	var documents []map[string]interface{}
+	for _, document := range db.collections[collName] {
+		if db.matches(document, filter) {
+			documents = append(documents, maps.Clone(document))
+		}
+	}
+	return documents, nil
+}
+
+func (db *SharedDataMockMongoDBClient) RestfulAPIPutOne(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.collections[collName] == nil {
+		db.collections[collName] = map[string]map[string]interface{}{}
+	}
+	id := fmt.Sprint(filter[sharedDataMockIdFields[collName]])
+	document, existed := db.collections[collName][id]
+	if !existed {
+		document = map[string]interface{}{}
+		db.collections[collName][id] = document
+	}
+	maps.Copy(document, putData)
+	return existed, nil
+}
+
+func (db *SharedDataMockMongoDBClient) RestfulAPIPutOneIf(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}, upsert bool) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	document, ok := db.collections[collName][fmt.Sprint(filter[sharedDataMockIdFields[collName]])]
+	if !ok || !db.matches(document, filter) {
+		return false, nil
+	}
+	maps.Copy(document, putData)
+	return true, nil
+}
+
+func (db *SharedDataMockMongoDBClient) RestfulAPIReplaceOne(ctx context.Context, collName string, filter bson.M, putData map[string]interface{}) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.collections[collName] == nil {
+		db.collections[collName] = map[string]map[string]interface{}{}
+	}
+	id := fmt.Sprint(filter[sharedDataMockIdFields[collName]])
+	_, existed := db.collections[collName][id]
+	db.collections[collName][id] = maps.Clone(putData)
+	return existed, nil
+}
+
+func (db *SharedDataMockMongoDBClient) RestfulAPIDeleteOne(ctx context.Context, collName string, filter bson.M) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	delete(db.collections[collName], fmt.Sprint(filter[sharedDataMockIdFields[collName]]))
+	return nil
+}
+
+func (db *SharedDataMockMongoDBClient) document(collName, id string) map[string]interface{} {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.collections[collName][id]
+}
+
+func TestSharedData(t *testing.T) {
+	notifications := make(chan models.NotificationData, 4)
+	subscriber := newSubscriber(func(w http.ResponseWriter, r *http.Request) {
+		var notification models.NotificationData
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &notification)
+		notifications <- notification
+		w.WriteHeader(http.StatusNoContent)
+	})
+	defer subscriber.Close()
+	db := &SharedDataMockMongoDBClient{
+		notificationUri: subscriber.URL,
+		collections:     map[string]map[string]map[string]interface{}{},
+	}
+	p := newTestProducer(t, db)
+	p.FetchPlmnConfig = func(context.Context) ([]models.PlmnId, error) {
+		t.Error("expected the PLMNs of the shared data to be used")
+		return nil, nil
+	}
+
+	created, problemDetails := p.PutSharedDataProcedure(context.Background(), "upf-common", sharedUpfData("1"))
+	if problemDetails != nil || !created {
+		t.Fatalf("expected the shared data to be created, got %+v", problemDetails)
+	}
+	_, problemDetails = p.PutSharedDataProcedure(context.Background(), "invalid", sharedDataOf(map[string]interface{}{
+		"nfType": "SMF",
+	}))
+	if problemDetails == nil || problemDetails.Status != http.StatusBadRequest {
+		t.Errorf("expected shared data with nfType to be rejected, got %+v", problemDetails)
+	}
+
+	// the profile is registered with the attributes of the shared data it
+	// does not have
+	nf := models.NfProfile{
+		NfInstanceId: "upf-1",
+		NfType:       models.NfType_UPF,
+		NfStatus:     models.NfStatus_REGISTERED,
+		Fqdn:         "upf-1.internal",
+	}
+	_, response, problemDetails := p.NFRegisterProcedure(context.Background(), producer.NfProfileRegistration{
+		NfProfile: nf, SharedDataIdList: []string{"upf-common"},
+	})
+	if problemDetails != nil {
+		t.Fatalf("unexpected registration failure: %+v", problemDetails)
+	}
+	if _, ok := response["sharedDataAttributes"]; ok {
+		t.Error("expected the shared attributes not to be answered")
+	}
+	stored := db.document("NfProfile", "upf-1")
+	if fmt.Sprint(stored["sharedDataAttributes"]) != "[plmnList sNssais]" || stored["fqdn"] != "upf-1.internal" {
+		t.Errorf("expected the profile to be stored with the shared PLMNs and S-NSSAIs, got %v", stored)
+	}
+	profile, problemDetails := p.GetNFInstanceProcedure(context.Background(), "upf-1")
+	if problemDetails != nil || profile["sNssais"] == nil || profile["sharedDataAttributes"] != nil {
+		t.Errorf("expected the profile with the shared S-NSSAIs, got %v", profile)
+	}
+
+	_, _, problemDetails = p.NFRegisterProcedure(context.Background(), producer.NfProfileRegistration{
+		NfProfile: nf, SharedDataIdList: []string{"upf-common", "unknown"},
+	})
+	if problemDetails == nil || len(problemDetails.InvalidParams) != 1 {
+		t.Errorf("expected the unknown shared data to be reported, got %+v", problemDetails)
+	}
+
+	// changing the shared data changes the profiles referring to it
+	created, problemDetails = p.PutSharedDataProcedure(context.Background(), "upf-common", sharedUpfData("2"))
+	if problemDetails != nil || created {
+		t.Fatalf("expected the shared data to be replaced, got %+v", problemDetails)
+	}
+	stored = db.document("NfProfile", "upf-1")
+	if sNssais := fmt.Sprint(stored["sNssais"]); sNssais != "[map[sd:000002 sst:1]]" {
+		t.Errorf("expected the profile to have the new S-NSSAIs, got %s", sNssais)
+	}
+	notification := <-notifications
+	if notification.Event != models.NotificationEventType_REGISTERED {
+		t.Errorf("expected the registration notification, got %s", notification.Event)
+	}
+	notification = <-notifications
+	if notification.Event != models.NotificationEventType_PROFILE_CHANGED {
+		t.Errorf("expected a PROFILE_CHANGED notification, got %s", notification.Event)
+	}
+
+	// an attribute the NF patches becomes its own
+	req := newPatchRequest("application/merge-patch+json", `{"sNssais":[{"sst":2}]}`)
+	req.Params["nfInstanceID"] = "upf-1"
+	if rsp := p.HandleUpdateNFInstanceRequest(context.Background(), req); rsp.Status != http.StatusOK {
+		t.Fatalf("unexpected update failure: %+v", rsp.Body)
+	}
+	if _, problemDetails = p.PutSharedDataProcedure(context.Background(), "upf-common", sharedUpfData("3")); problemDetails != nil {
+		t.Fatalf("unexpected shared data failure: %+v", problemDetails)
+	}
+	stored = db.document("NfProfile", "upf-1")
+	if fmt.Sprint(stored["sNssais"]) != "[map[sst:2]]" || fmt.Sprint(stored["sharedDataAttributes"]) != "[plmnList]" {
+		t.Errorf("expected the patched S-NSSAIs to be kept, got %v", stored)
+	}
+
+	// an attribute removed from the shared data is removed from the profiles
+	if _, problemDetails = p.PutSharedDataProcedure(context.Background(), "upf-common", sharedDataOf(map[string]interface{}{
+		"sNssais": []interface{}{map[string]interface{}{"sst": 1}},
+	})); problemDetails != nil {
+		t.Fatalf("unexpected shared data failure: %+v", problemDetails)
+	}
+	stored = db.document("NfProfile", "upf-1")
+	if _, ok := stored["plmnList"]; ok || stored["sharedDataAttributes"] != nil {
+		t.Errorf("expected the shared PLMNs to be removed, got %v", stored)
+	}
+
+	problemDetails = p.DeleteSharedDataProcedure(context.Background(), "upf-common")
+	if problemDetails == nil || problemDetails.Status != http.StatusConflict {
+		t.Errorf("expected shared data in use not to be deleted, got %+v", problemDetails)
+	}
+}
+
+func sharedDataOf(sharedProfileData map[string]interface{}) producer.SharedData {
+	return producer.SharedData{SharedProfileData: sharedProfileData}
+}
+
+func sharedUpfData(sd string) producer.SharedData {
+	return sharedDataOf(map[string]interface{}{
+		"sNssais":  []interface{}{map[string]interface{}{"sst": 1, "sd": "00000" + sd}},
+		"plmnList": []interface{}{map[string]interface{}{"mcc": "208", "mnc": "93"}},
+	})
+}
+
+type transactionKey struct{}
+
+// SharedDataTransactionMockMongoDBClient runs transactions with a marked
+// context, and records the operations on shared data run outside them
+type SharedDataTransactionMockMongoDBClient struct {
+	*SharedDataMockMongoDBClient
+	outside []string
+}
+
+func (db *SharedDataTransactionMockMongoDBClient) RunTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(context.WithValue(ctx, transactionKey{}, true))
+}
+
+func (db *SharedDataTransactionMockMongoDBClient) record(ctx context.Context, operation string) {
+	if ctx.Value(transactionKey{}) == nil {
+		db.outside = append(db.outside, operation)
+	}
+}
+
+func (db *SharedDataTransactionMockMongoDBClient) RestfulAPIGetOne(ctx context.Context, collName string, filter bson.M) (map[string]interface{}, error) {
+	db.record(ctx, "GetOne "+collName)
+	return db.SharedDataMockMongoDBClient.RestfulAPIGetOne(ctx, collName, filter)
+}
+
+func (db *SharedDataTransactionMockMongoDBClient) RestfulAPIGetMany(ctx context.Context, collName string, filter bson.M) ([]map[string]interface{}, error) {
+	db.record(ctx, "GetMany "+collName)
+	return db.SharedDataMockMongoDBClient.RestfulAPIGetMany(ctx, collName, filter)
+}
+
+func (db *SharedDataTransactionMockMongoDBClient) RestfulAPIDeleteOne(ctx context.Context, collName string, filter bson.M) error {
+	db.record(ctx, "DeleteOne "+collName)
+	return db.SharedDataMockMongoDBClient.RestfulAPIDeleteOne(ctx, collName, filter)
+}
+
+func TestDeleteSharedDataProcedure(t *testing.T) {
+	db := &SharedDataTransactionMockMongoDBClient{SharedDataMockMongoDBClient: &SharedDataMockMongoDBClient{
+		collections: map[string]map[string]map[string]interface{}{
+			"SharedData": {
+				"used":   {"sharedDataId": "used"},
+				"unused": {"sharedDataId": "unused"},
+			},
+			"NfProfile": {
+				"upf-1": {"nfInstanceId": "upf-1", "sharedDataIdList": []string{"used"}},
+			},
+		},
+	}}
+	p := newTestProducer(t, db)
+
+	testCases := []struct {
+		sharedDataId   string
+		expectedStatus int32
+	}{
+		{sharedDataId: "used", expectedStatus: http.StatusConflict},
+		{sharedDataId: "unknown", expectedStatus: http.StatusNotFound},
+		{sharedDataId: "unused"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.sharedDataId, func(t *testing.T) {
+			problemDetails := p.DeleteSharedDataProcedure(context.Background(), tc.sharedDataId)
+			if tc.expectedStatus == 0 && problemDetails != nil {
+				t.Fatalf("unexpected error: %+v", problemDetails)
+			}
+			if tc.expectedStatus != 0 && (problemDetails == nil || problemDetails.Status != tc.expectedStatus) {
+				t.Fatalf("expected status %d, got %+v", tc.expectedStatus, problemDetails)
+			}
+		})
+	}
+	if db.document("SharedData", "used") == nil || db.document("SharedData", "unused") != nil {
+		t.Errorf("expected only the unused shared data to be deleted, got %v", db.collections["SharedData"])
+	}
+	if len(db.outside) != 0 {
+		t.Errorf("expected the references to be checked in the transaction of the deletion, got %v outside it", db.outside)
+	}
+}
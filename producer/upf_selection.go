@@ -0,0 +1,132 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package producer
+
+import (
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/omec-project/openapi/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// upfSelectionFilter returns the filters selecting the UPFs of an SMF query.
+// The S-NSSAIs of snssais, the DNN of dnn, the DNAIs of dnai-list and the PDU
+// session types of pdu-session-types are matched against the same item of
+// the sNssaiUpfInfoList, so that the UPF serves them together. The UPF must
+// also match upf-iwk-eps-ind, atsss-capability and interface-types.
+func (p *Producer) upfSelectionFilter(queryParameters url.Values) []bson.M {
+	var filters []bson.M
+
+	snssaiUpfInfoItem := bson.M{}
+	if queryParameters["snssais"] != nil {
+		var snssais []models.Snssai
+		if err := json.Unmarshal([]byte("["+queryParameters["snssais"][0]+"]"), &snssais); err != nil {
+			p.Log.DiscoveryLog.Warnln("Unmarshal Error in snssais: ", err)
+		}
+		var snssaiBsonArray bson.A
+		for _, snssai := range snssais {
+			snssaiBsonM := bson.M{"sNssai.sst": snssai.Sst}
+			if snssai.Sd != "" {
+				snssaiBsonM["sNssai.sd"] = snssai.Sd
+			}
+			snssaiBsonArray = append(snssaiBsonArray, snssaiBsonM)
+		}
+		if snssaiBsonArray != nil {
+			snssaiUpfInfoItem["$or"] = snssaiBsonArray
+		}
+	}
+
+	dnnUpfInfoItem := bson.M{}
+	if queryParameters["dnn"] != nil {
+		dnnUpfInfoItem["dnn"] = queryParameters["dnn"][0]
+	}
+	if queryParameters["dnai-list"] != nil {
+		dnnUpfInfoItem["dnaiList"] = bson.M{
+			"$in": strings.Split(queryParameters["dnai-list"][0], ","),
+		}
+	}
+	if queryParameters["pdu-session-types"] != nil {
+		pduSessionTypes := strings.Split(queryParameters["pdu-session-types"][0], ",")
+		// the PDU session types of the DNN, or else those of the UPF, are
+		// supported, and all of them when neither is given
+		dnnUpfInfoItem["$or"] = bson.A{
+			bson.M{"pduSessionTypes": bson.M{"$in": pduSessionTypes}},
+			bson.M{"pduSessionTypes": bson.M{"$exists": false}},
+		}
+		filters = append(filters, bson.M{
+			"$or": bson.A{
+				bson.M{"upfInfo.pduSessionTypes": bson.M{"$in": pduSessionTypes}},
+				bson.M{"upfInfo.pduSessionTypes": bson.M{"$exists": false}},
+			},
+		})
+	}
+	if len(dnnUpfInfoItem) != 0 {
+		snssaiUpfInfoItem["dnnUpfInfoList"] = bson.M{"$elemMatch": dnnUpfInfoItem}
+	}
+	if len(snssaiUpfInfoItem) != 0 {
+		filters = append(filters, bson.M{
+			"upfInfo.sNssaiUpfInfoList": bson.M{"$elemMatch": snssaiUpfInfoItem},
+		})
+	}
+
+	if queryParameters["upf-iwk-eps-ind"] != nil {
+		upfIwkEpsInd, err := strconv.ParseBool(queryParameters["upf-iwk-eps-ind"][0])
+		if err != nil {
+			p.Log.DiscoveryLog.Warnln("ParseBool Error in upf-iwk-eps-ind: ", err)
+		} else if upfIwkEpsInd {
+			filters = append(filters, bson.M{"upfInfo.iwkEpsInd": true})
+		} else {
+			filters = append(filters, bson.M{"upfInfo.iwkEpsInd": bson.M{"$ne": true}})
+		}
+	}
+
+	if queryParameters["atsss-capability"] != nil {
+		atsssCapability := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(queryParameters["atsss-capability"][0]), &atsssCapability); err != nil {
+			p.Log.DiscoveryLog.Warnln("Unmarshal Error in atsss-capability: ", err)
+		}
+		for capability, required := range atsssCapability {
+			if required == true {
+				filters = append(filters, bson.M{"upfInfo.atsssCapability." + capability: true})
+			}
+		}
+	}
+
+	if queryParameters["interface-types"] != nil {
+		for _, interfaceType := range strings.Split(queryParameters["interface-types"][0], ",") {
+			filters = append(filters, bson.M{
+				"$or": bson.A{
+					bson.M{"upfInfo.interfaceUpfInfoList.interfaceType": interfaceType},
+					bson.M{"upfInfo.sNssaiUpfInfoList.interfaceUpfInfoList.interfaceType": interfaceType},
+				},
+			})
+		}
+	}
+	return filters
+}
+
+// rankUpfs orders the UPFs of preferredLocality first, and then by priority,
+// the lower values first. The priority of the upfInfo, when given, takes
+// precedence over the one of the profile.
+func rankUpfs(nfProfiles []models.NfProfile, preferredLocality string) {
+	priority := func(nfProfile models.NfProfile) int32 {
+		if nfProfile.UpfInfo != nil && nfProfile.UpfInfo.Priority != nil {
+			return *nfProfile.UpfInfo.Priority
+		}
+		return nfProfile.Priority
+	}
+	sort.SliceStable(nfProfiles, func(i, j int) bool {
+		preferredI := preferredLocality != "" && nfProfiles[i].Locality == preferredLocality
+		preferredJ := preferredLocality != "" && nfProfiles[j].Locality == preferredLocality
+		if preferredI != preferredJ {
+			return preferredI
+		}
+		return priority(nfProfiles[i]) < priority(nfProfiles[j])
+	})
+}
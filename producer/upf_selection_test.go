@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package producer_test
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestNFDiscoveryProcedureUpfSelection(t *testing.T) {
+	mock := &ListMockMongoDBClient{
+		profiles: []map[string]interface{}{
+			{"nfInstanceId": "upf-a", "nfType": "UPF", "nfStatus": "REGISTERED", "locality": "east", "priority": 1},
+			{
+				"nfInstanceId": "upf-b", "nfType": "UPF", "nfStatus": "REGISTERED", "locality": "west",
+				"upfInfo": map[string]interface{}{"priority": 5},
+			},
+			{"nfInstanceId": "upf-c", "nfType": "UPF", "nfStatus": "REGISTERED", "locality": "west", "priority": 2},
+		},
+	}
+	p := newTestProducer(t, mock)
+	query := url.Values{
+		"target-nf-type":     []string{"UPF"},
+		"requester-nf-type":  []string{"SMF"},
+		"snssais":            []string{`{"sst":1,"sd":"010203"}`},
+		"dnn":                []string{"internet"},
+		"dnai-list":          []string{"dnai1"},
+		"pdu-session-types":  []string{"IPV4"},
+		"upf-iwk-eps-ind":    []string{"false"},
+		"atsss-capability":   []string{`{"mptcp":true,"atsssLL":false}`},
+		"interface-types":    []string{"N3"},
+		"preferred-locality": []string{"west"},
+	}
+	response, problemDetails := p.NFDiscoveryProcedure(context.Background(), query)
+	if problemDetails != nil {
+		t.Fatalf("unexpected error: %+v", problemDetails)
+	}
+
+	filter := mustJSON(t, mock.filter)
+	for _, expected := range []string{
+		// the S-NSSAI, DNN, DNAI and PDU session type are served together
+		`{"upfInfo.sNssaiUpfInfoList":{"$elemMatch":{"$or":[{"sNssai.sd":"010203","sNssai.sst":1}],` +
+			`"dnnUpfInfoList":{"$elemMatch":{"$or":[{"pduSessionTypes":{"$in":["IPV4"]}},{"pduSessionTypes":{"$exists":false}}],` +
+			`"dnaiList":{"$in":["dnai1"]},"dnn":"internet"}}}}}`,
+		`{"upfInfo.iwkEpsInd":{"$ne":true}}`,
+		`{"upfInfo.atsssCapability.mptcp":true}`,
+		`{"upfInfo.interfaceUpfInfoList.interfaceType":"N3"}`,
+	} {
+		if !strings.Contains(filter, expected) {
+			t.Errorf("expected the filter to contain %s, got %s", expected, filter)
+		}
+	}
+	if strings.Contains(filter, `"locality"`) || strings.Contains(filter, "atsssLL") {
+		t.Errorf("expected the locality and the unrequested capabilities not to be filtered, got %s", filter)
+	}
+
+	var instances []string
+	for _, profile := range response.NfInstances {
+		instances = append(instances, profile.NfInstanceId)
+	}
+	if fmt.Sprint(instances) != "[upf-c upf-b upf-a]" {
+		t.Errorf("expected the UPFs of the preferred locality first, by priority, got %v", instances)
+	}
+}
@@ -0,0 +1,152 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package service
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+	"time"
+
+	"github.com/omec-project/nrf/factory"
+	"github.com/omec-project/nrf/logger"
+	"github.com/omec-project/nrf/metrics"
+)
+
+// AdminHandler returns the handler of the admin listener, serving the
+// metrics, health, profiling and administration endpoints of the NRF
+func (s *Server) AdminHandler() http.Handler {
+	admin := s.config.Configuration.Admin
+	if admin == nil {
+		admin = &factory.Admin{}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", metrics.Handler(s.registry))
+	mux.Handle("GET /healthz", s.health.LivenessHandler())
+	mux.Handle("GET /readyz", s.health.ReadinessHandler())
+	mux.HandleFunc("GET /admin/nrf-profile", s.serveNrfProfile)
+	mux.HandleFunc("GET /admin/cluster", s.serveCluster)
+	// the level of an injected logger is owned by the embedding process
+	if s.log == logger.Default() {
+		mux.Handle("GET /admin/log-level", logger.LevelHandler())
+		if admin.LogLevelUpdate {
+			mux.Handle("PUT /admin/log-level", logger.LevelHandler())
+		}
+	}
+	if admin.Pprof {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	return requireAdminAuth(admin.Auth, mux)
+}
+
+// serveNrfProfile serves the NRF profile as published in the registry
+func (s *Server) serveNrfProfile(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.nrfCtx.GetNrfNfProfile()); err != nil {
+		s.log.InitLog.Warnf("failed to write the NRF profile: %+v", err)
+	}
+}
+
+// clusterStatus is the view of a replica on the NRF replicas
+type clusterStatus struct {
+	ReplicaId string `json:"replicaId"`
+	Leader    string `json:"leader,omitempty"`
+	IsLeader  bool   `json:"isLeader"`
+}
+
+// serveCluster serves the identity of the replica and the leader it sees
+func (s *Server) serveCluster(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	status := clusterStatus{
+		ReplicaId: s.elector.ReplicaId(),
+		Leader:    s.elector.Leader(),
+		IsLeader:  s.elector.IsLeader(),
+	}
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		s.log.InitLog.Warnf("failed to write the cluster status: %+v", err)
+	}
+}
+
+// requireAdminAuth rejects the requests without the credentials of auth,
+// when set
+func requireAdminAuth(auth *factory.AdminAuth, next http.Handler) http.Handler {
+	if auth == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if adminAuthorized(auth, r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if auth.Username != "" {
+			w.Header().Add("WWW-Authenticate", `Basic realm="nrf-admin"`)
+		}
+		if auth.BearerToken != "" {
+			w.Header().Add("WWW-Authenticate", `Bearer realm="nrf-admin"`)
+		}
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	})
+}
+
+func adminAuthorized(auth *factory.AdminAuth, r *http.Request) bool {
+	if auth.Username != "" {
+		if username, password, ok := r.BasicAuth(); ok {
+			return secureEqual(username, auth.Username) && secureEqual(password, auth.Password)
+		}
+	}
+	if auth.BearerToken != "" {
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			return secureEqual(token, auth.BearerToken)
+		}
+	}
+	return false
+}
+
+// secureEqual compares credentials in a time independent of their content
+func secureEqual(given, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(given), []byte(expected)) == 1
+}
+
+// listenAdmin binds the admin listener, so that an address already in use or
+// an invalid certificate fails the startup of the NRF
+func (s *Server) listenAdmin() (*http.Server, net.Listener, error) {
+	bindAddr := s.config.GetAdminBindingAddr()
+	server := &http.Server{
+		Addr:              bindAddr,
+		Handler:           s.AdminHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	listener, err := net.Listen("tcp", bindAddr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("admin listener failed: %w", err)
+	}
+	if admin := s.config.Configuration.Admin; admin != nil && admin.TLS != nil {
+		cert, err := tls.LoadX509KeyPair(admin.TLS.PEM, admin.TLS.Key)
+		if err != nil {
+			listener.Close()
+			return nil, nil, fmt.Errorf("admin listener failed: %w", err)
+		}
+		server.TLSConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+		listener = tls.NewListener(listener, server.TLSConfig)
+	}
+	if admin := s.config.Configuration.Admin; admin != nil && admin.LogLevelUpdate && admin.Auth == nil {
+		s.log.InitLog.Warnln("the log level can be changed on the admin listener without authentication")
+	}
+	s.log.InitLog.Infof("admin listener bound to [%s]", listener.Addr())
+	return server, listener, nil
+}
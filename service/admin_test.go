@@ -0,0 +1,154 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package service_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/omec-project/nrf/factory"
+	"github.com/omec-project/nrf/service"
+	"github.com/omec-project/openapi/models"
+	"go.uber.org/zap"
+)
+
+func TestAdminHandlerAuth(t *testing.T) {
+	server := newTestServer(t, "admin", service.WithLogger(zap.NewNop()))
+	server.Context().Config.Configuration.Admin = &factory.Admin{
+		Auth: &factory.AdminAuth{Username: "oam", Password: "secret", BearerToken: "token"},
+	}
+	if err := server.Context().RefreshRegistryMetrics(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	handler := server.AdminHandler()
+
+	testCases := []struct {
+		name         string
+		setAuth      func(req *http.Request)
+		expectedCode int
+	}{
+		{
+			name:         "no credentials",
+			setAuth:      func(req *http.Request) {},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "basic credentials",
+			setAuth:      func(req *http.Request) { req.SetBasicAuth("oam", "secret") },
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "wrong password",
+			setAuth:      func(req *http.Request) { req.SetBasicAuth("oam", "guess") },
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "bearer token",
+			setAuth:      func(req *http.Request) { req.Header.Set("Authorization", "Bearer token") },
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "wrong bearer token",
+			setAuth:      func(req *http.Request) { req.Header.Set("Authorization", "Bearer guess") },
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			tc.setAuth(req)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tc.expectedCode {
+				t.Fatalf("expected status %d, got %d", tc.expectedCode, rec.Code)
+			}
+			if tc.expectedCode == http.StatusUnauthorized && len(rec.Header().Values("WWW-Authenticate")) != 2 {
+				t.Errorf("expected basic and bearer challenges, got %v", rec.Header().Values("WWW-Authenticate"))
+			}
+			if tc.expectedCode == http.StatusOK && !strings.Contains(rec.Body.String(), "nrf_registered_nf_instances") {
+				t.Errorf("expected the NRF metrics, got %s", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestAdminHandlerRoutes(t *testing.T) {
+	testCases := []struct {
+		name         string
+		pprof        bool
+		path         string
+		expectedCode int
+	}{
+		{name: "readiness", path: "/readyz", expectedCode: http.StatusServiceUnavailable},
+		{name: "liveness", path: "/healthz", expectedCode: http.StatusOK},
+		{name: "NRF profile", path: "/admin/nrf-profile", expectedCode: http.StatusOK},
+		{name: "cluster", path: "/admin/cluster", expectedCode: http.StatusOK},
+		{name: "pprof disabled", path: "/debug/pprof/", expectedCode: http.StatusNotFound},
+		{name: "pprof enabled", pprof: true, path: "/debug/pprof/", expectedCode: http.StatusOK},
+		{name: "injected logger level", path: "/admin/log-level", expectedCode: http.StatusNotFound},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newTestServer(t, "admin", service.WithLogger(zap.NewNop()))
+			server.Context().Config.Configuration.Admin = &factory.Admin{Pprof: tc.pprof}
+			server.Context().FetchPlmnConfig = func(context.Context) ([]models.PlmnId, error) { return nil, nil }
+
+			rec := httptest.NewRecorder()
+			server.AdminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			if rec.Code != tc.expectedCode {
+				t.Errorf("expected status %d, got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestAdminHandlerLogLevel(t *testing.T) {
+	testCases := []struct {
+		name           string
+		logLevelUpdate bool
+		method         string
+		expectedCode   int
+	}{
+		{name: "read", method: http.MethodGet, expectedCode: http.StatusOK},
+		{name: "update disabled", method: http.MethodPut, expectedCode: http.StatusMethodNotAllowed},
+		{name: "update enabled", logLevelUpdate: true, method: http.MethodPut, expectedCode: http.StatusOK},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// the process-wide logger, whose level is set back to the current one
+			server := newTestServer(t, "admin")
+			server.Context().Config.Configuration.Admin = &factory.Admin{LogLevelUpdate: tc.logLevelUpdate}
+			handler := server.AdminHandler()
+
+			current := httptest.NewRecorder()
+			handler.ServeHTTP(current, httptest.NewRequest(http.MethodGet, "/admin/log-level", nil))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(tc.method, "/admin/log-level", strings.NewReader(current.Body.String())))
+			if rec.Code != tc.expectedCode {
+				t.Errorf("expected status %d, got %d: %s", tc.expectedCode, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestRunAdminBindFailure(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	server := newTestServer(t, "admin", service.WithLogger(zap.NewNop()))
+	server.Context().Config.Configuration.Admin = &factory.Admin{BindingAddr: listener.Addr().String()}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err = server.Run(ctx); err == nil || !strings.Contains(err.Error(), "admin listener") {
+		t.Errorf("expected the admin listener bind failure, got %v", err)
+	}
+}
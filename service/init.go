@@ -8,6 +8,7 @@ package service
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
@@ -15,34 +16,22 @@ import (
 	"path/filepath"
 	"sync"
 	"syscall"
-	"time"
 
-	"github.com/omec-project/nrf/accesstoken"
-	"github.com/omec-project/nrf/context"
-	"github.com/omec-project/nrf/dbadapter"
-	"github.com/omec-project/nrf/discovery"
 	"github.com/omec-project/nrf/factory"
 	"github.com/omec-project/nrf/logger"
-	"github.com/omec-project/nrf/management"
-	"github.com/omec-project/nrf/metrics"
 	openapiLogger "github.com/omec-project/openapi/logger"
-	"github.com/omec-project/util/http2_util"
 	utilLogger "github.com/omec-project/util/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/urfave/cli/v3"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
-type NRF struct{}
-
-type (
-	// Config information.
-	Config struct {
-		cfg string
-	}
-)
-
-var config Config
+// NRF is the command line wrapper of the NRF Server
+type NRF struct {
+	config *factory.Config
+}
 
 var nrfCLi = []cli.Flag{
 	&cli.StringFlag{
@@ -63,45 +52,41 @@ func (*NRF) GetCliCmd() (flags []cli.Flag) {
 }
 
 func (nrf *NRF) Initialize(c *cli.Command) error {
-	config = Config{
-		cfg: c.String("cfg"),
-	}
-
-	absPath, err := filepath.Abs(config.cfg)
+	absPath, err := filepath.Abs(c.String("cfg"))
 	if err != nil {
 		logger.CfgLog.Errorln(err)
 		return err
 	}
 
-	if err := factory.InitConfigFactory(absPath); err != nil {
+	config, err := factory.ReadConfig(absPath)
+	if err != nil {
 		return err
 	}
+	nrf.config = config
 
 	nrf.setLogLevel()
 
-	if err := factory.CheckConfigVersion(); err != nil {
+	if err := config.CheckConfigVersion(); err != nil {
 		return err
 	}
 
-	factory.NrfConfig.CfgLocation = absPath
-
-	context.InitNrfContext()
+	config.CfgLocation = absPath
 
 	return nil
 }
 
 func (nrf *NRF) setLogLevel() {
-	if factory.NrfConfig.Logger == nil {
+	if nrf.config.Logger == nil {
 		initLog.Warnln("NRF config without log level setting!!!")
 		return
 	}
 
-	if factory.NrfConfig.Logger.NRF != nil {
-		if factory.NrfConfig.Logger.NRF.DebugLevel != "" {
-			level, err := zapcore.ParseLevel(factory.NrfConfig.Logger.NRF.DebugLevel)
+	if nrf.config.Logger.NRF != nil {
+		if nrf.config.Logger.NRF.DebugLevel != "" {
+			level, err := zapcore.ParseLevel(nrf.config.Logger.NRF.DebugLevel)
 			if err != nil {
 				initLog.Warnf("NRF Log level [%s] is invalid, set to [info] level",
-					factory.NrfConfig.Logger.NRF.DebugLevel)
+					nrf.config.Logger.NRF.DebugLevel)
 				logger.SetLogLevel(zap.InfoLevel)
 			} else {
 				initLog.Infof("NRF Log level is set to [%s] level", level)
@@ -113,11 +98,11 @@ func (nrf *NRF) setLogLevel() {
 		}
 	}
 
-	if factory.NrfConfig.Logger.OpenApi != nil {
-		if factory.NrfConfig.Logger.OpenApi.DebugLevel != "" {
-			if _, err := zapcore.ParseLevel(factory.NrfConfig.Logger.OpenApi.DebugLevel); err != nil {
+	if nrf.config.Logger.OpenApi != nil {
+		if nrf.config.Logger.OpenApi.DebugLevel != "" {
+			if _, err := zapcore.ParseLevel(nrf.config.Logger.OpenApi.DebugLevel); err != nil {
 				openapiLogger.OpenapiLog.Warnf("OpenAPI Log level [%s] is invalid, set to [info] level",
-					factory.NrfConfig.Logger.OpenApi.DebugLevel)
+					nrf.config.Logger.OpenApi.DebugLevel)
 				logger.SetLogLevel(zap.InfoLevel)
 			}
 		} else {
@@ -126,11 +111,11 @@ func (nrf *NRF) setLogLevel() {
 		}
 	}
 
-	if factory.NrfConfig.Logger.MongoDBLibrary != nil {
-		if factory.NrfConfig.Logger.MongoDBLibrary.DebugLevel != "" {
-			if level, err := zapcore.ParseLevel(factory.NrfConfig.Logger.MongoDBLibrary.DebugLevel); err != nil {
+	if nrf.config.Logger.MongoDBLibrary != nil {
+		if nrf.config.Logger.MongoDBLibrary.DebugLevel != "" {
+			if level, err := zapcore.ParseLevel(nrf.config.Logger.MongoDBLibrary.DebugLevel); err != nil {
 				utilLogger.AppLog.Warnf("MongoDBLibrary Log level [%s] is invalid, set to [info] level",
-					factory.NrfConfig.Logger.MongoDBLibrary.DebugLevel)
+					nrf.config.Logger.MongoDBLibrary.DebugLevel)
 				utilLogger.SetLogLevel(zap.InfoLevel)
 			} else {
 				utilLogger.SetLogLevel(level)
@@ -155,57 +140,20 @@ func (nrf *NRF) FilterCli(c *cli.Command) (args []string) {
 	return args
 }
 
-func (nrf *NRF) Start() {
-	initLog.Infoln("server started")
-	config := factory.NrfConfig.Configuration
-	dbadapter.ConnectToDBClient(config.MongoDBName, config.MongoDBUrl, config.MongoDBStreamEnable, config.NfProfileExpiryEnable)
-
-	router := utilLogger.NewGinWithZap(logger.GinLog)
-
-	accesstoken.AddService(router)
-	discovery.AddService(router)
-	management.AddService(router)
-
-	go metrics.InitMetrics()
-
-	signalChannel := make(chan os.Signal, 1)
-	signal.Notify(signalChannel, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-signalChannel
-		// Waiting for other NFs to deregister
-		time.Sleep(2 * time.Second)
-		nrf.Terminate()
-		os.Exit(0)
-	}()
-
-	bindAddr := factory.NrfConfig.GetSbiBindingAddr()
-	initLog.Infof("binding addr: [%s]", bindAddr)
-	sslLog := filepath.Dir(factory.NrfConfig.CfgLocation) + "/sslkey.log"
-	server, err := http2_util.NewServer(bindAddr, sslLog, router)
-
-	if server == nil {
-		initLog.Errorf("initialize HTTP server failed: %+v", err)
-		return
-	}
+// Start runs the NRF Server until SIGINT or SIGTERM is received
+func (nrf *NRF) Start() error {
+	// keep exposing the Go runtime and process metrics along with the NRF ones
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
 
+	server, err := New(nrf.config, WithPrometheusRegistry(registry))
 	if err != nil {
-		initLog.Warnf("initialize HTTP server: +%v", err)
-	}
-
-	serverScheme := factory.NrfConfig.GetSbiScheme()
-	switch serverScheme {
-	case "http":
-		err = server.ListenAndServe()
-	case "https":
-		err = server.ListenAndServeTLS(config.Sbi.TLS.PEM, config.Sbi.TLS.Key)
-	default:
-		logger.InitLog.Fatalf("HTTP server setup failed: invalid server scheme %+v", serverScheme)
-		return
+		return err
 	}
 
-	if err != nil {
-		initLog.Fatalf("HTTP server setup failed: %+v", err)
-	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return server.Run(ctx)
 }
 
 func (nrf *NRF) Exec(c *cli.Command) error {
@@ -258,8 +206,3 @@ func (nrf *NRF) Exec(c *cli.Command) error {
 
 	return err
 }
-
-func (nrf *NRF) Terminate() {
-	logger.InitLog.Infoln("terminating NRF")
-	logger.InitLog.Infoln("NRF terminated")
-}
@@ -0,0 +1,553 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omec-project/nrf/accesstoken"
+	"github.com/omec-project/nrf/bootstrapping"
+	"github.com/omec-project/nrf/cluster"
+	nrfContext "github.com/omec-project/nrf/context"
+	"github.com/omec-project/nrf/dbadapter"
+	"github.com/omec-project/nrf/discovery"
+	"github.com/omec-project/nrf/factory"
+	"github.com/omec-project/nrf/federation"
+	"github.com/omec-project/nrf/forwarding"
+	"github.com/omec-project/nrf/health"
+	"github.com/omec-project/nrf/logger"
+	"github.com/omec-project/nrf/management"
+	"github.com/omec-project/nrf/metrics"
+	"github.com/omec-project/nrf/producer"
+	"github.com/omec-project/nrf/tracing"
+	"github.com/omec-project/nrf/webhook"
+	"github.com/omec-project/openapi/models"
+	"github.com/omec-project/util/http2_util"
+	utilLogger "github.com/omec-project/util/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const (
+	// teardownTimeout bounds the time spent stopping the admin listener and
+	// disconnecting from the storage, once the SBI server is drained
+	teardownTimeout = 5 * time.Second
+	// publishRetryInterval is the time between two attempts to publish the
+	// NRF profile, the NRF is not ready until it succeeds
+	publishRetryInterval = 5 * time.Second
+	// leaderElection is the election of the replica running the jobs done
+	// once for all the replicas
+	leaderElection = "nrf-leader"
+)
+
+// Server is an NRF instance. Several servers can run in the same process,
+// each with its own configuration, storage, loggers and metrics registry.
+type Server struct {
+	config   *factory.Config
+	log      *logger.Logger
+	registry *prometheus.Registry
+	db       dbadapter.DBInterface
+	// mongoDB is the storage created, and owned, by the server when none
+	// was injected
+	mongoDB *dbadapter.MongoDBClient
+	// supervisor probes the storage when it can be pinged
+	supervisor *dbadapter.Supervisor
+	nrfCtx     *nrfContext.NRFContext
+	producer   *producer.Producer
+	// elector elects the replica running the jobs done once for all the
+	// replicas sharing the storage
+	elector     *cluster.Elector
+	electorDone chan struct{}
+	health      *health.Checker
+	router      *gin.Engine
+	// federator mirrors the registries of the peer NRFs, nil without peers
+	federator *federation.Federator
+
+	tracerProvider trace.TracerProvider
+	// shutdownTracing flushes the spans of the tracer provider created, and
+	// owned, by the server when none was injected
+	shutdownTracing func(context.Context) error
+}
+
+// Option customises a Server
+type Option func(*Server)
+
+// WithStorage makes the server use db instead of connecting to the MongoDB
+// database of the configuration. The server does not disconnect db.
+func WithStorage(db dbadapter.DBInterface) Option {
+	return func(s *Server) {
+		s.db = db
+	}
+}
+
+// WithLogger makes the server log to log instead of the process-wide logger
+func WithLogger(log *zap.Logger) Option {
+	return func(s *Server) {
+		s.log = logger.New(log)
+	}
+}
+
+// WithPrometheusRegistry makes the server register its metrics with, and
+// serve them from, registry instead of a registry of its own
+func WithPrometheusRegistry(registry *prometheus.Registry) Option {
+	return func(s *Server) {
+		s.registry = registry
+	}
+}
+
+// WithTracerProvider makes the server trace its SBI procedures with provider
+// instead of the exporter of the configuration. The server does not shut
+// provider down.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(s *Server) {
+		s.tracerProvider = provider
+	}
+}
+
+// New creates an NRF instance from config
+func New(config *factory.Config, opts ...Option) (*Server, error) {
+	if config == nil || config.Configuration == nil {
+		return nil, fmt.Errorf("NRF configuration is required")
+	}
+	s := &Server{
+		config: config,
+		log:    logger.Default(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.registry == nil {
+		s.registry = prometheus.NewRegistry()
+	}
+
+	configuration := config.Configuration
+	stats, err := metrics.NewNrfStats(s.registry)
+	if err != nil {
+		return nil, fmt.Errorf("NRF stats register failed: %w", err)
+	}
+	if s.db == nil {
+		mongoDB, err := dbadapter.NewMongoDBClient(configuration.MongoDBName, configuration.MongoDBUrl, stats, s.log)
+		if err != nil {
+			return nil, err
+		}
+		s.db = mongoDB
+		s.mongoDB = mongoDB
+	}
+	s.shutdownTracing = func(context.Context) error { return nil }
+	if s.tracerProvider == nil {
+		s.tracerProvider, s.shutdownTracing, err = tracing.NewTracerProvider(context.Background(), configuration.Tracing)
+		if err != nil {
+			return nil, err
+		}
+	}
+	webhooks, err := webhook.NewDispatcher(configuration.NfDownHooks, s.log, s.tracerProvider)
+	if err != nil {
+		_ = s.shutdownTracing(context.Background())
+		return nil, err
+	}
+
+	db := s.db
+	if pinger, ok := s.db.(dbadapter.Pinger); ok {
+		s.supervisor = dbadapter.NewSupervisor(pinger, dbadapter.DefaultBackoff, stats, s.log)
+		db = dbadapter.NewSupervisedDB(db, s.supervisor)
+	}
+	db = dbadapter.NewDeadlineDB(db, config.GetStorageTimeouts())
+	s.nrfCtx = nrfContext.New(config, dbadapter.NewInstrumentedDB(db, stats, s.tracerProvider), s.log)
+	s.nrfCtx.Metrics = stats
+	s.nrfCtx.Webhooks = webhooks
+	s.nrfCtx.TracerProvider = s.tracerProvider
+	if s.supervisor != nil {
+		s.nrfCtx.IsOperative = func() bool {
+			open, _ := s.supervisor.Open()
+			return !open
+		}
+	}
+
+	clusterConfig := config.GetCluster()
+	replicaId := clusterConfig.ReplicaId
+	if replicaId == "" {
+		replicaId = cluster.NewReplicaId()
+	}
+	s.elector = cluster.NewElector(s.nrfCtx.DB, leaderElection, replicaId, clusterConfig.LeaseDuration, stats, s.log)
+	s.nrfCtx.IsLeader = s.elector.IsLeader
+	// every replica refreshes its view of the registry on the changes made by
+	// any of them
+	if notifier, ok := s.db.(dbadapter.ChangeNotifier); ok {
+		s.nrfCtx.WatchRegistry(notifier)
+	}
+
+	s.health = health.NewChecker(s.healthChecks()...)
+
+	s.producer = producer.New(s.nrfCtx)
+	p := s.producer
+	p.Forwarder, err = forwarding.New(s.nrfCtx)
+	if err != nil {
+		_ = s.shutdownTracing(context.Background())
+		return nil, err
+	}
+	s.router = utilLogger.NewGinWithZap(s.log.GinLog)
+	s.router.Use(otelgin.Middleware(tracing.ServiceName,
+		otelgin.WithTracerProvider(s.tracerProvider),
+		otelgin.WithPropagators(tracing.Propagators()),
+		otelgin.WithFilter(isSbiRequest)))
+	s.router.Use(observeSbiRequests(stats))
+	if s.supervisor != nil {
+		s.router.Use(rejectWhileStorageDown(s.supervisor))
+	}
+	s.router.GET("/healthz", gin.WrapF(s.health.LivenessHandler()))
+	s.router.GET("/readyz", gin.WrapF(s.health.ReadinessHandler()))
+	accesstoken.AddService(s.router, p)
+	bootstrapping.AddService(s.router, p)
+	// the services left out of the serviceNameList are neither advertised
+	// nor served
+	if p.ProvidesService(models.ServiceName_NNRF_DISC) {
+		discovery.AddService(s.router, p)
+	}
+	if p.ProvidesService(models.ServiceName_NNRF_NFM) {
+		management.AddService(s.router, p)
+	}
+	s.federator, err = federation.New(s.nrfCtx)
+	if err != nil {
+		_ = s.shutdownTracing(context.Background())
+		return nil, err
+	}
+	if s.federator != nil {
+		s.federator.AddService(s.router)
+	}
+
+	return s, nil
+}
+
+// Handler returns the handler serving the SBI of the NRF
+func (s *Server) Handler() http.Handler {
+	return s.router
+}
+
+// Health returns the checker of the dependencies of the NRF instance
+func (s *Server) Health() *health.Checker {
+	return s.health
+}
+
+// Supervisor returns the supervisor of the storage connection, nil when the
+// storage cannot be probed
+func (s *Server) Supervisor() *dbadapter.Supervisor {
+	return s.supervisor
+}
+
+// Context returns the state of the NRF instance
+func (s *Server) Context() *nrfContext.NRFContext {
+	return s.nrfCtx
+}
+
+// Run prepares the storage, publishes the NRF profile and serves the SBI
+// until ctx is done. It then drains the SBI connections, within the
+// configured shutdown timeout, and tears down the background workers.
+func (s *Server) Run(ctx context.Context) error {
+	s.log.InitLog.Infoln("server started")
+	adminServer, adminListener, err := s.listenAdmin()
+	if err != nil {
+		return err
+	}
+	// the admin listener serves the health endpoints while MongoDB is awaited
+	go func() {
+		if err := adminServer.Serve(adminListener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.log.InitLog.Errorf("admin listener stopped: %v", err)
+		}
+	}()
+	stopCh := make(chan struct{})
+	if s.supervisor != nil {
+		go s.supervisor.Run(stopCh)
+	}
+
+	configuration := s.config.Configuration
+	if s.mongoDB != nil {
+		if err = s.mongoDB.Setup(ctx, configuration.MongoDBStreamEnable, configuration.NfProfileExpiryEnable); err != nil {
+			s.teardown(adminServer, stopCh)
+			if ctx.Err() != nil {
+				// stopped before MongoDB could be reached
+				return nil
+			}
+			return fmt.Errorf("MongoDB setup failed: %w", err)
+		}
+	}
+
+	// the NF instance listing is derived from NfProfile, drop the stale per-nfType lists
+	if err = s.nrfCtx.RemoveLegacyUriList(ctx); err != nil {
+		s.log.InitLog.Warnf("urilist reconciliation failed: %+v", err)
+	}
+
+	s.electorDone = make(chan struct{})
+	go func() {
+		defer close(s.electorDone)
+		s.elector.Run(stopCh)
+	}()
+	go s.elector.RunWhileLeader(stopCh, s.runLeaderJobs)
+	go s.publishNrfProfile(stopCh)
+	go s.nrfCtx.RunRegistryRefresher(stopCh)
+
+	bindAddr := s.config.GetSbiBindingAddr()
+	s.log.InitLog.Infof("binding addr: [%s]", bindAddr)
+	sslLog := ""
+	if s.config.CfgLocation != "" {
+		sslLog = filepath.Dir(s.config.CfgLocation) + "/sslkey.log"
+	}
+	server, err := http2_util.NewServer(bindAddr, sslLog, s.router)
+
+	if server == nil {
+		s.log.InitLog.Errorf("initialize HTTP server failed: %+v", err)
+		s.teardown(adminServer, stopCh)
+		return err
+	}
+
+	if err != nil {
+		s.log.InitLog.Warnf("initialize HTTP server: +%v", err)
+	}
+
+	serverScheme := s.config.GetSbiScheme()
+	if serverScheme != "http" && serverScheme != "https" {
+		s.teardown(adminServer, stopCh)
+		return fmt.Errorf("HTTP server setup failed: invalid server scheme %+v", serverScheme)
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		if serverScheme == "https" {
+			serverErr <- server.ListenAndServeTLS(configuration.Sbi.TLS.PEM, configuration.Sbi.TLS.Key)
+		} else {
+			serverErr <- server.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err = <-serverErr:
+		s.log.InitLog.Errorf("HTTP server setup failed: %+v", err)
+		s.teardown(adminServer, stopCh)
+		return err
+	case <-ctx.Done():
+		s.log.InitLog.Infoln("shutting down")
+	}
+
+	return s.shutdown(server, adminServer, stopCh)
+}
+
+// publishNrfProfile publishes the NRF profile, retrying until it succeeds or
+// stop is closed
+func (s *Server) publishNrfProfile(stop <-chan struct{}) {
+	for {
+		err := s.nrfCtx.PublishNrfProfile(context.Background())
+		if err == nil {
+			return
+		}
+		s.log.InitLog.Errorf("failed to publish the NRF profile, retrying in %v: %+v", publishRetryInterval, err)
+		select {
+		case <-stop:
+			return
+		case <-time.After(publishRetryInterval):
+		}
+	}
+}
+
+// observeSbiRequests records the duration of the SBI requests, by route
+// rather than by path to bound the number of series
+func observeSbiRequests(stats *metrics.NrfStats) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		stats.ObserveSbiRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
+
+// rejectWhileStorageDown answers the NF management and discovery requests
+// 503 while the storage is down, rather than have them wait for the storage
+// timeouts. Retry-After tells the consumers when the storage is probed again.
+func rejectWhileStorageDown(supervisor *dbadapter.Supervisor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if !strings.HasPrefix(path, factory.NRF_NFM_RES_URI_PREFIX) && !strings.HasPrefix(path, factory.NRF_DISC_RES_URI_PREFIX) {
+			c.Next()
+			return
+		}
+		open, retryAfter := supervisor.Open()
+		if !open {
+			c.Next()
+			return
+		}
+		detail := dbadapter.ErrStorageUnavailable.Error()
+		if _, _, err := supervisor.Status(); err != nil {
+			detail = err.Error()
+		}
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(max(retryAfter.Seconds(), 1)))))
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, models.ProblemDetails{
+			Title:  "Storage unavailable",
+			Status: http.StatusServiceUnavailable,
+			Cause:  "STORAGE_UNAVAILABLE",
+			Detail: detail,
+		})
+	}
+}
+
+// isSbiRequest excludes the health probes from the traces
+func isSbiRequest(r *http.Request) bool {
+	return r.URL.Path != "/healthz" && r.URL.Path != "/readyz"
+}
+
+// healthChecks returns the checks of the dependencies of the NRF: the NRF is
+// ready once the storage is reachable and the NRF profile is published
+func (s *Server) healthChecks() []health.Check {
+	checks := []health.Check{
+		{
+			Name:     "storage",
+			Critical: true,
+			Probe: func(ctx context.Context) (string, error) {
+				pinger, ok := s.db.(dbadapter.Pinger)
+				if !ok {
+					return "storage does not support ping", nil
+				}
+				state, since, err := s.supervisor.Status()
+				if state == dbadapter.StateDisconnected {
+					_, retryAfter := s.supervisor.Open()
+					return "", fmt.Errorf("%s since %s, next attempt in %v: %w", state,
+						since.UTC().Format(time.RFC3339), retryAfter.Round(time.Second), err)
+				}
+				if err = pinger.Ping(ctx); err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("%s since %s", state, since.UTC().Format(time.RFC3339)), nil
+			},
+		},
+		{
+			Name:     "nrfProfile",
+			Critical: true,
+			Probe: func(ctx context.Context) (string, error) {
+				if !s.nrfCtx.NrfProfilePublished() {
+					return "", errors.New("NRF profile not published")
+				}
+				return "nfInstanceId " + s.nrfCtx.GetNrfNfProfile().NfInstanceId, nil
+			},
+		},
+		{
+			Name: "webuiPlmnConfig",
+			Probe: func(ctx context.Context) (string, error) {
+				plmnList, err := s.nrfCtx.FetchPlmnConfig(ctx)
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("%d PLMN(s) configured", len(plmnList)), nil
+			},
+		},
+	}
+	configuration := s.config.Configuration
+	if s.mongoDB != nil && configuration.MongoDBStreamEnable {
+		checks = append(checks, health.Check{
+			Name: "changeStream",
+			Probe: func(ctx context.Context) (string, error) {
+				if !s.mongoDB.ChangeStreamAlive() {
+					return "", errors.New("change stream not running")
+				}
+				return "", nil
+			},
+		})
+	}
+	if s.config.GetSbiScheme() == "https" && configuration.Sbi != nil && configuration.Sbi.TLS != nil {
+		checks = append(checks, health.Check{
+			Name:  "tlsCertificate",
+			Probe: health.CertificateProbe(configuration.Sbi.TLS.PEM),
+		})
+	}
+	return checks
+}
+
+// runLeaderJobs runs the jobs done once for all the replicas, while this
+// replica is their leader
+func (s *Server) runLeaderJobs(stop <-chan struct{}) {
+	// the new leader stores the NRF profile, which the previous one may have
+	// left behind the registry
+	s.nrfCtx.RegistryChanged()
+	jobs := []func(stop <-chan struct{}){s.producer.RunNotificationOutbox}
+	if s.config.Configuration.NfProfileExpiryEnable {
+		jobs = append(jobs, s.nrfCtx.RunExpirySweeper)
+	}
+	if s.federator != nil {
+		jobs = append(jobs, s.federator.Run)
+	}
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			job(stop)
+		}()
+	}
+	wg.Wait()
+}
+
+// shutdown stops accepting SBI connections and waits, up to the configured
+// shutdown timeout, for in-flight requests and pending notifications to
+// complete before tearing down the background workers and the storage
+func (s *Server) shutdown(server, adminServer *http.Server, stopCh chan struct{}) error {
+	timeout := s.config.GetShutdownTimeout()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	s.log.InitLog.Infof("draining SBI connections (timeout %v)", timeout)
+	drainErr := server.Shutdown(ctx)
+	if drainErr != nil {
+		s.log.InitLog.Errorf("SBI connections not drained: %+v", drainErr)
+	}
+	if err := s.nrfCtx.Webhooks.Flush(ctx); err != nil {
+		s.log.InitLog.Warnf("%+v", err)
+	}
+
+	s.teardown(adminServer, stopCh)
+
+	if drainErr != nil {
+		return fmt.Errorf("graceful shutdown did not complete within %v: %w", timeout, drainErr)
+	}
+	return nil
+}
+
+// teardown stops the background loops and the admin listener and closes the
+// storage and the tracer provider owned by the server
+func (s *Server) teardown(adminServer *http.Server, stopCh chan struct{}) {
+	close(stopCh)
+
+	ctx, cancel := context.WithTimeout(context.Background(), teardownTimeout)
+	defer cancel()
+	// the lease is released before the storage is disconnected
+	if s.electorDone != nil {
+		select {
+		case <-s.electorDone:
+		case <-ctx.Done():
+		}
+	}
+	if err := adminServer.Shutdown(ctx); err != nil {
+		s.log.InitLog.Warnf("failed to stop admin listener: %+v", err)
+	}
+	if s.mongoDB != nil {
+		if err := s.mongoDB.Disconnect(ctx); err != nil {
+			s.log.InitLog.Warnf("failed to disconnect from MongoDB: %+v", err)
+		}
+	}
+	if err := s.shutdownTracing(ctx); err != nil {
+		s.log.InitLog.Warnf("failed to flush the traces: %+v", err)
+	}
+	s.log.InitLog.Infoln("NRF terminated")
+}
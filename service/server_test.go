@@ -0,0 +1,340 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	nrfContext "github.com/omec-project/nrf/context"
+	"github.com/omec-project/nrf/dbadapter"
+	"github.com/omec-project/nrf/factory"
+	"github.com/omec-project/nrf/health"
+	"github.com/omec-project/nrf/producer"
+	"github.com/omec-project/nrf/service"
+	"github.com/omec-project/openapi/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.uber.org/zap"
+)
+
+type ProfilesMockMongoDBClient struct {
+	dbadapter.DBInterface
+	profiles []map[string]interface{}
+}
+
+func (db *ProfilesMockMongoDBClient) RestfulAPIGetMany(ctx context.Context, collName string, filter bson.M) ([]map[string]interface{}, error) {
+	return db.profiles, nil
+}
+
+func newTestServer(t *testing.T, nfInstanceId string, opts ...service.Option) *service.Server {
+	t.Helper()
+	config, err := factory.ReadConfig("../nrfTest/nrfcfg.yaml")
+	if err != nil {
+		t.Fatalf("failed to read test configuration: %v", err)
+	}
+	db := &ProfilesMockMongoDBClient{
+		profiles: []map[string]interface{}{
+			{"nfInstanceId": nfInstanceId, "nfType": "AMF", "nfStatus": "REGISTERED"},
+		},
+	}
+	server, err := service.New(config, append([]service.Option{service.WithStorage(db)}, opts...)...)
+	if err != nil {
+		t.Fatalf("failed to create NRF: %v", err)
+	}
+	return server
+}
+
+func TestNewWithoutConfiguration(t *testing.T) {
+	if _, err := service.New(&factory.Config{}); err == nil {
+		t.Error("expected an error for a configuration without Configuration section")
+	}
+}
+
+func TestServersDoNotShareState(t *testing.T) {
+	first := newTestServer(t, "first", service.WithLogger(zap.NewNop()))
+	// the same registry cannot hold the stats of two instances
+	registry := prometheus.NewRegistry()
+	second := newTestServer(t, "second", service.WithPrometheusRegistry(registry))
+	if _, err := service.New(first.Context().Config, service.WithStorage(first.Context().DB),
+		service.WithPrometheusRegistry(registry)); err == nil {
+		t.Error("expected an error registering the NRF stats twice with the same registry")
+	}
+
+	for name, tc := range map[string]struct {
+		server   *service.Server
+		expected string
+	}{
+		"first":  {server: first, expected: "first"},
+		"second": {server: second, expected: "second"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/nnrf-nfm/v1/nf-instances", nil)
+			rec := httptest.NewRecorder()
+			tc.server.Handler().ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+			}
+			var uriList nrfContext.UriList
+			if err := json.Unmarshal(rec.Body.Bytes(), &uriList); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(uriList.Link.Item) != 1 {
+				t.Fatalf("expected 1 item, got %+v", uriList.Link.Item)
+			}
+			if href := uriList.Link.Item[0].Href; !strings.HasSuffix(href, "/nnrf-nfm/v1/nf-instances/"+tc.expected) {
+				t.Errorf("expected the item of %s, got %s", tc.expected, href)
+			}
+		})
+	}
+}
+
+func TestDisabledServiceNotServed(t *testing.T) {
+	config, err := factory.ReadConfig("../nrfTest/nrfcfg.yaml")
+	if err != nil {
+		t.Fatalf("failed to read test configuration: %v", err)
+	}
+	config.Configuration.ServiceNameList = []string{string(models.ServiceName_NNRF_NFM)}
+	server, err := service.New(config, service.WithStorage(&ProfilesMockMongoDBClient{}))
+	if err != nil {
+		t.Fatalf("failed to create NRF: %v", err)
+	}
+	for path, expected := range map[string]int{
+		"/nnrf-nfm/v1/nf-instances": http.StatusOK,
+		"/nnrf-disc/v1/nf-instances?target-nf-type=AMF&requester-nf-type=SMF": http.StatusNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != expected {
+			t.Errorf("%s: expected status %d, got %d", path, expected, rec.Code)
+		}
+	}
+}
+
+func TestReadinessBeforeNrfProfilePublished(t *testing.T) {
+	server := newTestServer(t, "first")
+	server.Context().FetchPlmnConfig = func(context.Context) ([]models.PlmnId, error) {
+		return []models.PlmnId{{Mcc: "208", Mnc: "93"}}, nil
+	}
+
+	for path, expectedCode := range map[string]int{
+		"/healthz": http.StatusOK,
+		"/readyz":  http.StatusServiceUnavailable,
+	} {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != expectedCode {
+			t.Errorf("%s: expected status %d, got %d", path, expectedCode, rec.Code)
+		}
+		var report health.Report
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatalf("%s: invalid report: %v", path, err)
+		}
+		if report.Status != health.StatusDown {
+			t.Errorf("%s: expected status %s, got %s", path, health.StatusDown, report.Status)
+		}
+		for name, expected := range map[string]health.Status{
+			"storage":         health.StatusUp,
+			"nrfProfile":      health.StatusDown,
+			"webuiPlmnConfig": health.StatusUp,
+		} {
+			if got := report.Checks[name].Status; got != expected {
+				t.Errorf("%s: expected %s to be %s, got %+v", path, name, expected, report.Checks[name])
+			}
+		}
+	}
+}
+
+// StalledMockMongoDBClient never answers, as a MongoDB which is not reachable
+type StalledMockMongoDBClient struct {
+	dbadapter.DBInterface
+}
+
+func (db *StalledMockMongoDBClient) RestfulAPIGetMany(ctx context.Context, collName string, filter bson.M) ([]map[string]interface{}, error) {
+	<-ctx.Done()
+	return nil, fmt.Errorf("RestfulAPIGetMany err: %w", ctx.Err())
+}
+
+func TestStorageDeadline(t *testing.T) {
+	config, err := factory.ReadConfig("../nrfTest/nrfcfg.yaml")
+	if err != nil {
+		t.Fatalf("failed to read test configuration: %v", err)
+	}
+	config.Configuration.StorageTimeouts = &factory.StorageTimeouts{Read: 50 * time.Millisecond}
+	server, err := service.New(config, service.WithStorage(&StalledMockMongoDBClient{}))
+	if err != nil {
+		t.Fatalf("failed to create NRF: %v", err)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	testCases := []struct {
+		name           string
+		ctx            context.Context
+		expectedStatus int
+	}{
+		{
+			name:           "storage timeout",
+			ctx:            context.Background(),
+			expectedStatus: http.StatusGatewayTimeout,
+		},
+		{
+			name:           "request cancelled",
+			ctx:            cancelled,
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequestWithContext(tc.ctx, http.MethodGet, "/nnrf-nfm/v1/nf-instances", nil)
+			rec := httptest.NewRecorder()
+			start := time.Now()
+			server.Handler().ServeHTTP(rec, req)
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("expected an answer within the storage timeout, took %v", elapsed)
+			}
+			if rec.Code != tc.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.expectedStatus, rec.Code, rec.Body.String())
+			}
+			var problemDetails models.ProblemDetails
+			if err := json.Unmarshal(rec.Body.Bytes(), &problemDetails); err != nil {
+				t.Fatalf("failed to decode problem details: %v", err)
+			}
+			if int(problemDetails.Status) != tc.expectedStatus {
+				t.Errorf("expected problem details status %d, got %+v", tc.expectedStatus, problemDetails)
+			}
+		})
+	}
+}
+
+// UnreachableMockMongoDBClient fails its pings, as a MongoDB which is down
+type UnreachableMockMongoDBClient struct {
+	ProfilesMockMongoDBClient
+}
+
+func (db *UnreachableMockMongoDBClient) Ping(ctx context.Context) error {
+	return errors.New("server selection timeout")
+}
+
+func TestRejectWhileStorageDown(t *testing.T) {
+	config, err := factory.ReadConfig("../nrfTest/nrfcfg.yaml")
+	if err != nil {
+		t.Fatalf("failed to read test configuration: %v", err)
+	}
+	server, err := service.New(config, service.WithStorage(&UnreachableMockMongoDBClient{}))
+	if err != nil {
+		t.Fatalf("failed to create NRF: %v", err)
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go server.Supervisor().Run(stop)
+	deadline := time.Now().Add(5 * time.Second)
+	for open, _ := server.Supervisor().Open(); !open; open, _ = server.Supervisor().Open() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the breaker to open")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	for _, path := range []string{"/nnrf-nfm/v1/nf-instances", "/nnrf-disc/v1/nf-instances?target-nf-type=AMF&requester-nf-type=SMF"} {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Fatalf("%s: expected status %d, got %d: %s", path, http.StatusServiceUnavailable, rec.Code, rec.Body.String())
+		}
+		if retryAfter := rec.Header().Get("Retry-After"); retryAfter == "" {
+			t.Errorf("%s: expected a Retry-After header", path)
+		}
+		var problemDetails models.ProblemDetails
+		if err := json.Unmarshal(rec.Body.Bytes(), &problemDetails); err != nil {
+			t.Fatalf("%s: failed to decode problem details: %v", path, err)
+		}
+		if problemDetails.Cause != "STORAGE_UNAVAILABLE" {
+			t.Errorf("%s: expected cause STORAGE_UNAVAILABLE, got %+v", path, problemDetails)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/bootstrapping", nil))
+	var bootstrappingInfo producer.BootstrappingInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &bootstrappingInfo); err != nil {
+		t.Fatalf("invalid bootstrapping information: %v", err)
+	}
+	if bootstrappingInfo.Status != producer.BootstrappingStatus_NON_OPERATIVE {
+		t.Errorf("expected the NRF to be reported non operative, got %+v", bootstrappingInfo)
+	}
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var report health.Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("invalid report: %v", err)
+	}
+	if storage := report.Checks["storage"]; storage.Status != health.StatusDown || !strings.HasPrefix(storage.Detail, "DISCONNECTED") {
+		t.Errorf("expected the storage to be reported disconnected, got %+v", storage)
+	}
+}
+
+func TestBootstrapping(t *testing.T) {
+	testCases := []struct {
+		name            string
+		serviceNameList []string
+		expected        map[string]string
+	}{
+		{
+			name:            "All services",
+			serviceNameList: []string{"nnrf-nfm", "nnrf-disc"},
+			expected: map[string]string{
+				"nnrf-nfm":  "http://127.0.0.10:8000/nnrf-nfm/v1",
+				"nnrf-disc": "http://127.0.0.10:8000/nnrf-disc/v1",
+				"oauth2":    "http://127.0.0.10:8000/oauth2/token",
+			},
+		},
+		{
+			name:            "Discovery only",
+			serviceNameList: []string{"nnrf-disc"},
+			expected: map[string]string{
+				"nnrf-disc": "http://127.0.0.10:8000/nnrf-disc/v1",
+				"oauth2":    "http://127.0.0.10:8000/oauth2/token",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newTestServer(t, "amf")
+			server.Context().Config.Configuration.ServiceNameList = tc.serviceNameList
+
+			rec := httptest.NewRecorder()
+			server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/bootstrapping", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+			}
+			if contentType := rec.Header().Get("Content-Type"); contentType != "application/3gppHal+json" {
+				t.Errorf("expected a HAL document, got %s", contentType)
+			}
+			var bootstrappingInfo producer.BootstrappingInfo
+			if err := json.Unmarshal(rec.Body.Bytes(), &bootstrappingInfo); err != nil {
+				t.Fatalf("invalid bootstrapping information: %v", err)
+			}
+			if bootstrappingInfo.Status != producer.BootstrappingStatus_OPERATIVE {
+				t.Errorf("expected the NRF to be operative, got %s", bootstrappingInfo.Status)
+			}
+			links := map[string]string{}
+			for name, link := range bootstrappingInfo.Links {
+				links[name] = link.Href
+			}
+			if fmt.Sprint(links) != fmt.Sprint(tc.expected) {
+				t.Errorf("expected the links %v, got %v", tc.expected, links)
+			}
+		})
+	}
+}
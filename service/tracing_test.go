@@ -0,0 +1,58 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package service_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/omec-project/nrf/service"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+func TestSbiRequestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	server := newTestServer(t, "traced", service.WithLogger(zap.NewNop()), service.WithTracerProvider(provider))
+
+	const traceId = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/nnrf-nfm/v1/nf-instances", nil)
+	req.Header.Set("traceparent", "00-"+traceId+"-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	spans := exporter.GetSpans()
+	var serverSpan, storageSpan *tracetest.SpanStub
+	for i := range spans {
+		switch {
+		case spans[i].SpanKind == trace.SpanKindServer:
+			if serverSpan != nil {
+				t.Errorf("expected the health probes not to be traced, got %s", spans[i].Name)
+			}
+			serverSpan = &spans[i]
+		case spans[i].Name == "GetMany NfProfile":
+			storageSpan = &spans[i]
+		}
+	}
+	if serverSpan == nil || storageSpan == nil {
+		t.Fatalf("expected a server and a storage span, got %+v", spans)
+	}
+	if serverSpan.SpanContext.TraceID().String() != traceId {
+		t.Errorf("expected the incoming trace %s to be continued, got %s", traceId, serverSpan.SpanContext.TraceID())
+	}
+	if storageSpan.Parent.SpanID() != serverSpan.SpanContext.SpanID() {
+		t.Errorf("expected the storage span to be a child of the server span")
+	}
+}
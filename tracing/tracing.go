@@ -0,0 +1,108 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+ * Tracing package exports the OpenTelemetry spans of the NRF.
+ */
+
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/omec-project/nrf/factory"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// InstrumentationName names the tracer of the NRF spans
+const InstrumentationName = "github.com/omec-project/nrf"
+
+// ServiceName is the service name of the exported spans
+const ServiceName = "nrf"
+
+// Propagators returns the propagators of the trace context, in the W3C
+// traceparent and baggage headers
+func Propagators() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+}
+
+// NewTracerProvider returns the tracer provider exporting spans as set in
+// config, and the function flushing and stopping it. Spans are discarded when
+// tracing is not enabled.
+func NewTracerProvider(ctx context.Context, config *factory.Tracing) (trace.TracerProvider, func(context.Context) error, error) {
+	if config == nil || !config.Enabled {
+		return noop.NewTracerProvider(), func(context.Context) error { return nil }, nil
+	}
+
+	var exporter sdktrace.SpanExporter
+	var file io.Closer
+	var err error
+	switch config.Exporter {
+	case "", factory.NRF_TRACING_EXPORTER_OTLP:
+		var opts []otlptracehttp.Option
+		if config.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(config.Endpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	case factory.NRF_TRACING_EXPORTER_STDOUT:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case factory.NRF_TRACING_EXPORTER_FILE:
+		f, openErr := os.OpenFile(config.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if openErr != nil {
+			return nil, nil, fmt.Errorf("tracing file exporter failed: %w", openErr)
+		}
+		file = f
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(f))
+	default:
+		return nil, nil, fmt.Errorf("unsupported tracing exporter: %s", config.Exporter)
+	}
+	if err != nil {
+		if file != nil {
+			file.Close()
+		}
+		return nil, nil, fmt.Errorf("tracing exporter failed: %w", err)
+	}
+
+	ratio := config.SampleRatio
+	if ratio == 0 {
+		ratio = 1
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", ServiceName))),
+	)
+	shutdown := func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			err = errors.Join(err, file.Close())
+		}
+		return err
+	}
+	return provider, shutdown, nil
+}
+
+// Transport returns base recording a client span for each request and
+// propagating the trace context to the server
+func Transport(base http.RoundTripper, provider trace.TracerProvider) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return otelhttp.NewTransport(base,
+		otelhttp.WithTracerProvider(provider),
+		otelhttp.WithPropagators(Propagators()))
+}
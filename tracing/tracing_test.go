@@ -0,0 +1,55 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package tracing_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/omec-project/nrf/factory"
+	"github.com/omec-project/nrf/tracing"
+)
+
+func TestNewTracerProviderFileExporter(t *testing.T) {
+	traceFile := filepath.Join(t.TempDir(), "traces.json")
+	provider, shutdown, err := tracing.NewTracerProvider(context.Background(), &factory.Tracing{
+		Enabled:  true,
+		Exporter: factory.NRF_TRACING_EXPORTER_FILE,
+		File:     traceFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := provider.Tracer(tracing.InstrumentationName).Start(context.Background(), "NFRegister")
+	span.End()
+	if err = shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	traces, err := os.ReadFile(traceFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(traces), `"Name":"NFRegister"`) {
+		t.Errorf("expected the span in the trace file, got %s", traces)
+	}
+}
+
+func TestNewTracerProviderDisabled(t *testing.T) {
+	provider, shutdown, err := tracing.NewTracerProvider(context.Background(), &factory.Tracing{Exporter: "zipkin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := provider.Tracer(tracing.InstrumentationName).Start(context.Background(), "NFRegister")
+	if span.SpanContext().IsValid() {
+		t.Error("expected no span to be recorded when tracing is disabled")
+	}
+	if err = shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,301 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+/*
+ * NF-down webhooks
+ *
+ * Calls the configured hooks when a registered NF instance goes away, so
+ * that peer NFs or OAM tooling can react to it.
+ */
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/omec-project/nrf/factory"
+	"github.com/omec-project/nrf/logger"
+	"github.com/omec-project/nrf/tracing"
+	"github.com/omec-project/openapi/models"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Event string
+
+const (
+	// EventDeregistered is raised when an NF instance deregisters
+	EventDeregistered Event = "DEREGISTERED"
+	// EventSuspended is raised when an NF instance changes its status to SUSPENDED
+	EventSuspended Event = "SUSPENDED"
+	// EventExpired is raised when an NF instance is removed after missing its heartbeats
+	EventExpired Event = "EXPIRED"
+)
+
+const (
+	defaultTimeout       = 5 * time.Second
+	defaultRetryInterval = time.Second
+)
+
+// legacyAmfHook reproduces the AMF OAM call made by earlier NRF releases, it
+// is installed when the configuration has no nfDownHooks key
+var legacyAmfHook = factory.NfDownHook{
+	Name:    "amf-oam",
+	Url:     "http://amf:29518/namf-oam/v1/amfInstanceDown/{nfInstanceId}",
+	NfTypes: []string{string(models.NfType_AMF)},
+	Events:  []string{string(EventDeregistered)},
+}
+
+// NfDownNotification is the body posted to the hooks
+type NfDownNotification struct {
+	NfInstanceId string    `json:"nfInstanceId"`
+	NfType       string    `json:"nfType"`
+	Event        Event     `json:"event"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+type hook struct {
+	config factory.NfDownHook
+	client *http.Client
+}
+
+// Dispatcher calls the configured hooks in the background
+type Dispatcher struct {
+	hooks []hook
+	wg    sync.WaitGroup
+	log   *logger.Logger
+	// stopped is cancelled when Flush gives up, to cancel the pending calls
+	stopped context.Context
+	stop    context.CancelFunc
+}
+
+// NewDispatcher validates the hooks configuration and builds one HTTP client
+// per hook, tracing its requests with tracerProvider. A configuration without
+// the nfDownHooks key keeps the legacy AMF OAM hook.
+func NewDispatcher(hooksConfig []factory.NfDownHook, log *logger.Logger, tracerProvider trace.TracerProvider) (*Dispatcher, error) {
+	if hooksConfig == nil {
+		log.InitLog.Warnln("nfDownHooks not set in configuration file, using the legacy AMF OAM hook")
+		hooksConfig = []factory.NfDownHook{legacyAmfHook}
+	}
+	d := &Dispatcher{log: log}
+	d.stopped, d.stop = context.WithCancel(context.Background())
+	for i, hookConfig := range hooksConfig {
+		if hookConfig.Name == "" {
+			hookConfig.Name = fmt.Sprintf("hook-%d", i)
+		}
+		if err := validateHook(hookConfig); err != nil {
+			return nil, fmt.Errorf("invalid nfDownHook [%s]: %w", hookConfig.Name, err)
+		}
+		if hookConfig.Method == "" {
+			hookConfig.Method = http.MethodPost
+		}
+		if hookConfig.Timeout == 0 {
+			hookConfig.Timeout = defaultTimeout
+		}
+		if hookConfig.RetryInterval == 0 {
+			hookConfig.RetryInterval = defaultRetryInterval
+		}
+		client, err := newHookClient(hookConfig, tracerProvider)
+		if err != nil {
+			return nil, fmt.Errorf("invalid nfDownHook [%s]: %w", hookConfig.Name, err)
+		}
+		d.hooks = append(d.hooks, hook{config: hookConfig, client: client})
+		log.InitLog.Infof("nfDownHook [%s] configured: %s %s", hookConfig.Name, hookConfig.Method, hookConfig.Url)
+	}
+	return d, nil
+}
+
+func validateHook(hookConfig factory.NfDownHook) error {
+	if hookConfig.Url == "" {
+		return fmt.Errorf("url is required")
+	}
+	parsedUrl, err := url.Parse(expandUrl(hookConfig.Url, "id", "type", "event"))
+	if err != nil {
+		return err
+	}
+	if parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme: %s", parsedUrl.Scheme)
+	}
+	for _, event := range hookConfig.Events {
+		switch Event(event) {
+		case EventDeregistered, EventSuspended, EventExpired:
+		default:
+			return fmt.Errorf("unknown event: %s", event)
+		}
+	}
+	if hookConfig.Timeout < 0 || hookConfig.RetryInterval < 0 || hookConfig.Retries < 0 {
+		return fmt.Errorf("timeout, retries and retryInterval cannot be negative")
+	}
+	return nil
+}
+
+func newHookClient(hookConfig factory.NfDownHook, tracerProvider trace.TracerProvider) (*http.Client, error) {
+	client := &http.Client{
+		Timeout:   hookConfig.Timeout,
+		Transport: tracing.Transport(nil, tracerProvider),
+	}
+	if hookConfig.TLS == nil {
+		return client, nil
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: hookConfig.TLS.InsecureSkipVerify, // #nosec G402 -- explicitly requested in the configuration
+	}
+	if hookConfig.TLS.CaCert != "" {
+		caCert, err := os.ReadFile(hookConfig.TLS.CaCert)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no certificate found in %s", hookConfig.TLS.CaCert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if hookConfig.TLS.Cert != "" || hookConfig.TLS.Key != "" {
+		cert, err := tls.LoadX509KeyPair(hookConfig.TLS.Cert, hookConfig.TLS.Key)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	client.Transport = tracing.Transport(&http.Transport{TLSClientConfig: tlsConfig}, tracerProvider)
+	return client, nil
+}
+
+func expandUrl(template, nfInstanceId, nfType, event string) string {
+	return strings.NewReplacer(
+		"{nfInstanceId}", url.PathEscape(nfInstanceId),
+		"{nfType}", url.PathEscape(nfType),
+		"{event}", url.PathEscape(event),
+	).Replace(template)
+}
+
+func (h *hook) matches(event Event, nfType models.NfType) bool {
+	if len(h.config.Events) != 0 && !slices.Contains(h.config.Events, string(event)) {
+		return false
+	}
+	if len(h.config.NfTypes) != 0 && !slices.Contains(h.config.NfTypes, string(nfType)) {
+		return false
+	}
+	return true
+}
+
+// NotifyNfDown calls, in the background, every hook matching the event and NF
+// type. The calls are traced as part of the request of ctx but outlive it,
+// until Flush gives up on them.
+func (d *Dispatcher) NotifyNfDown(ctx context.Context, event Event, nfInstanceId string, nfType models.NfType) {
+	if d == nil {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	notification := NfDownNotification{
+		NfInstanceId: nfInstanceId,
+		NfType:       string(nfType),
+		Event:        event,
+		Timestamp:    time.Now().UTC(),
+	}
+	for i := range d.hooks {
+		h := &d.hooks[i]
+		if !h.matches(event, nfType) {
+			continue
+		}
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			defer context.AfterFunc(d.stopped, cancel)()
+			h.send(ctx, notification, d.log)
+		}()
+	}
+}
+
+// Wait blocks until every pending hook call has completed
+func (d *Dispatcher) Wait() {
+	if d == nil {
+		return
+	}
+	d.wg.Wait()
+}
+
+// Flush waits for the pending hook calls to complete, or for ctx to be done,
+// in which case the pending calls are cancelled
+func (d *Dispatcher) Flush(ctx context.Context) error {
+	if d == nil {
+		return nil
+	}
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		d.stop()
+		return fmt.Errorf("pending nfDownHook calls not flushed: %w", ctx.Err())
+	}
+}
+
+func (h *hook) send(ctx context.Context, notification NfDownNotification, log *logger.Logger) {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		log.ManagementLog.Errorf("nfDownHook [%s] marshal error: %v", h.config.Name, err)
+		return
+	}
+	target := expandUrl(h.config.Url, notification.NfInstanceId, notification.NfType, string(notification.Event))
+
+	for attempt := 0; attempt <= h.config.Retries; attempt++ {
+		if attempt > 0 {
+			timer := time.NewTimer(h.config.RetryInterval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				log.ManagementLog.Errorf("nfDownHook [%s] gave up notifying %s: %v", h.config.Name, target, ctx.Err())
+				return
+			case <-timer.C:
+			}
+		}
+		err = h.post(ctx, target, body)
+		if err == nil {
+			log.ManagementLog.Infof("nfDownHook [%s] notified %s of %s event for NF instance %s",
+				h.config.Name, target, notification.Event, notification.NfInstanceId)
+			return
+		}
+		log.ManagementLog.Warnf("nfDownHook [%s] attempt %d failed: %v", h.config.Name, attempt+1, err)
+	}
+	log.ManagementLog.Errorf("nfDownHook [%s] gave up notifying %s", h.config.Name, target)
+}
+
+func (h *hook) post(ctx context.Context, target string, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, h.config.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, h.config.Method, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	rsp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", rsp.StatusCode)
+	}
+	return nil
+}
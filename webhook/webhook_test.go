@@ -0,0 +1,259 @@
+// SPDX-FileCopyrightText: 2025 Canonical Ltd
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/omec-project/nrf/factory"
+	"github.com/omec-project/nrf/logger"
+	"github.com/omec-project/openapi/models"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+type hookServer struct {
+	mu            sync.Mutex
+	paths         []string
+	notifications []NfDownNotification
+	failures      int
+}
+
+func (s *hookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var notification NfDownNotification
+	if err := json.NewDecoder(r.Body).Decode(&notification); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.paths = append(s.paths, r.URL.Path)
+	s.notifications = append(s.notifications, notification)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestNotifyNfDown(t *testing.T) {
+	testCases := []struct {
+		name          string
+		hook          factory.NfDownHook
+		failures      int
+		event         Event
+		nfType        models.NfType
+		expectedPaths []string
+	}{
+		{
+			name: "matching NF type and event",
+			hook: factory.NfDownHook{
+				Url:     "/down/{nfType}/{nfInstanceId}",
+				NfTypes: []string{"AMF"},
+				Events:  []string{"DEREGISTERED"},
+			},
+			event:         EventDeregistered,
+			nfType:        models.NfType_AMF,
+			expectedPaths: []string{"/down/AMF/instance-1"},
+		},
+		{
+			name: "other NF type",
+			hook: factory.NfDownHook{
+				Url:     "/down/{nfInstanceId}",
+				NfTypes: []string{"AMF"},
+			},
+			event:  EventDeregistered,
+			nfType: models.NfType_SMF,
+		},
+		{
+			name: "other event",
+			hook: factory.NfDownHook{
+				Url:    "/down/{nfInstanceId}",
+				Events: []string{"SUSPENDED"},
+			},
+			event:  EventDeregistered,
+			nfType: models.NfType_SMF,
+		},
+		{
+			name: "retried after failures",
+			hook: factory.NfDownHook{
+				Url:           "/down/{event}",
+				Retries:       2,
+				RetryInterval: time.Millisecond,
+			},
+			failures:      2,
+			event:         EventSuspended,
+			nfType:        models.NfType_SMF,
+			expectedPaths: []string{"/down/SUSPENDED"},
+		},
+		{
+			name: "not enough retries",
+			hook: factory.NfDownHook{
+				Url:           "/down/{event}",
+				Retries:       1,
+				RetryInterval: time.Millisecond,
+			},
+			failures: 2,
+			event:    EventSuspended,
+			nfType:   models.NfType_SMF,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := &hookServer{failures: tc.failures}
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			tc.hook.Url = server.URL + tc.hook.Url
+			d, err := NewDispatcher([]factory.NfDownHook{tc.hook}, logger.Default(), noop.NewTracerProvider())
+			if err != nil {
+				t.Fatalf("NewDispatcher failed: %v", err)
+			}
+			d.NotifyNfDown(context.Background(), tc.event, "instance-1", tc.nfType)
+			d.Wait()
+
+			if len(handler.paths) != len(tc.expectedPaths) {
+				t.Fatalf("Expected calls %v, got %v", tc.expectedPaths, handler.paths)
+			}
+			for i, path := range tc.expectedPaths {
+				if handler.paths[i] != path {
+					t.Errorf("Expected path %s, got %s", path, handler.paths[i])
+				}
+				notification := handler.notifications[i]
+				if notification.NfInstanceId != "instance-1" || notification.Event != tc.event {
+					t.Errorf("Unexpected notification %+v", notification)
+				}
+			}
+		})
+	}
+}
+
+func TestNewDispatcherValidation(t *testing.T) {
+	testCases := []struct {
+		name    string
+		hook    factory.NfDownHook
+		isValid bool
+	}{
+		{
+			name:    "valid hook",
+			hook:    factory.NfDownHook{Url: "https://oam:8443/nf/{nfInstanceId}"},
+			isValid: true,
+		},
+		{
+			name: "missing url",
+			hook: factory.NfDownHook{},
+		},
+		{
+			name: "unsupported scheme",
+			hook: factory.NfDownHook{Url: "ftp://oam/{nfInstanceId}"},
+		},
+		{
+			name: "unknown event",
+			hook: factory.NfDownHook{Url: "http://oam/{nfInstanceId}", Events: []string{"REGISTERED"}},
+		},
+		{
+			name: "missing CA file",
+			hook: factory.NfDownHook{Url: "https://oam/{nfInstanceId}", TLS: &factory.NfDownHookTLS{CaCert: "/nonexistent"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewDispatcher([]factory.NfDownHook{tc.hook}, logger.Default(), noop.NewTracerProvider())
+			if err == nil && !tc.isValid {
+				t.Errorf("expected hook %+v to be invalid", tc.hook)
+			}
+			if err != nil && tc.isValid {
+				t.Errorf("expected hook %+v to be valid: %v", tc.hook, err)
+			}
+		})
+	}
+}
+
+func TestNewDispatcherLegacyHook(t *testing.T) {
+	d, err := NewDispatcher(nil, logger.Default(), noop.NewTracerProvider())
+	if err != nil {
+		t.Fatalf("NewDispatcher failed: %v", err)
+	}
+	if len(d.hooks) != 1 || d.hooks[0].config.Name != legacyAmfHook.Name {
+		t.Errorf("Expected the legacy AMF hook, got %+v", d.hooks)
+	}
+	d, err = NewDispatcher([]factory.NfDownHook{}, logger.Default(), noop.NewTracerProvider())
+	if err != nil {
+		t.Fatalf("NewDispatcher failed: %v", err)
+	}
+	if len(d.hooks) != 0 {
+		t.Errorf("Expected no hooks, got %+v", d.hooks)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	d, err := NewDispatcher([]factory.NfDownHook{{Url: server.URL + "/{nfInstanceId}"}}, logger.Default(), noop.NewTracerProvider())
+	if err != nil {
+		t.Fatalf("NewDispatcher failed: %v", err)
+	}
+	d.NotifyNfDown(context.Background(), EventDeregistered, "instance-1", models.NfType_AMF)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err = d.Flush(ctx); err == nil {
+		t.Errorf("Expected Flush to time out while a hook call is pending")
+	}
+
+	close(release)
+	if err = d.Flush(context.Background()); err != nil {
+		t.Errorf("Expected Flush to succeed, got %v", err)
+	}
+}
+
+func TestFlushCancelsRetries(t *testing.T) {
+	handler := &hookServer{failures: 1}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	d, err := NewDispatcher([]factory.NfDownHook{{
+		Url:           server.URL + "/{nfInstanceId}",
+		Retries:       1,
+		RetryInterval: time.Hour,
+	}}, logger.Default(), noop.NewTracerProvider())
+	if err != nil {
+		t.Fatalf("NewDispatcher failed: %v", err)
+	}
+	d.NotifyNfDown(context.Background(), EventDeregistered, "instance-1", models.NfType_AMF)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err = d.Flush(ctx); err == nil {
+		t.Errorf("Expected Flush to time out while a hook call is waiting to be retried")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		d.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the retry to be cancelled once Flush gave up")
+	}
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	if len(handler.paths) != 0 {
+		t.Errorf("Expected no retry, got %v", handler.paths)
+	}
+}